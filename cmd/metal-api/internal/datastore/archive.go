@@ -0,0 +1,413 @@
+package datastore
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+)
+
+// ArchiveFormatVersion is the version of the archive format written by Export. Import only accepts
+// archives of the same format version.
+const ArchiveFormatVersion = 1
+
+const (
+	archiveMetadataFile = "metadata.json"
+	archiveTableSuffix  = ".jsonl"
+	archivePoolPrefix   = "pools/"
+)
+
+// ArchiveMetadata describes the content of an archive.
+type ArchiveMetadata struct {
+	FormatVersion    int       `json:"format_version"`
+	MigrationVersion int       `json:"migration_version"`
+	Created          time.Time `json:"created"`
+	// Entities contains the amount of entities per table.
+	Entities map[string]int `json:"entities"`
+	// Pools contains the amount of acquired integers per integer pool.
+	Pools map[string]int `json:"pools"`
+}
+
+// archiveTable reads and decodes the entities of one table.
+type archiveTable struct {
+	name   string
+	list   func(ds Store) ([]metal.Entity, error)
+	decode func(raw []byte) (metal.Entity, error)
+}
+
+func newArchiveTable[E any, P interface {
+	*E
+	metal.Entity
+}](name string, list func(ds Store) ([]E, error)) archiveTable {
+	return archiveTable{
+		name: name,
+		list: func(ds Store) ([]metal.Entity, error) {
+			es, err := list(ds)
+			if err != nil {
+				return nil, err
+			}
+			result := make([]metal.Entity, 0, len(es))
+			for i := range es {
+				result = append(result, P(&es[i]))
+			}
+			return result, nil
+		},
+		decode: func(raw []byte) (metal.Entity, error) {
+			var e E
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.DisallowUnknownFields()
+			err := dec.Decode(&e)
+			if err != nil {
+				return nil, err
+			}
+			return P(&e), nil
+		},
+	}
+}
+
+// archiveTables are all tables contained in an archive, in the order they are restored.
+var archiveTables = []archiveTable{
+	newArchiveTable[metal.Partition](partitionTableName, func(ds Store) ([]metal.Partition, error) { return ds.ListPartitions() }),
+	newArchiveTable[metal.Size](sizeTableName, func(ds Store) ([]metal.Size, error) { return ds.ListSizes() }),
+	newArchiveTable[metal.Image](imageTableName, func(ds Store) ([]metal.Image, error) { return ds.ListImages() }),
+	newArchiveTable[metal.FilesystemLayout](filesystemLayoutTableName, func(ds Store) ([]metal.FilesystemLayout, error) {
+		return ds.ListFilesystemLayouts()
+	}),
+	newArchiveTable[metal.SizeImageConstraint](sizeImageConstraintTableName, func(ds Store) ([]metal.SizeImageConstraint, error) {
+		return ds.ListSizeImageConstraints()
+	}),
+	newArchiveTable[metal.Reservation](reservationTableName, func(ds Store) ([]metal.Reservation, error) { return ds.ListReservations() }),
+	newArchiveTable[metal.Network](networkTableName, func(ds Store) ([]metal.Network, error) { return ds.ListNetworks() }),
+	newArchiveTable[metal.IP](ipTableName, func(ds Store) ([]metal.IP, error) { return ds.ListIPs() }),
+	newArchiveTable[metal.Machine](machineTableName, func(ds Store) ([]metal.Machine, error) { return ds.ListMachines() }),
+	newArchiveTable[metal.PendingAllocation](pendingAllocationTableName, func(ds Store) ([]metal.PendingAllocation, error) {
+		return ds.ListPendingAllocations()
+	}),
+	newArchiveTable[metal.Rollout](rolloutTableName, func(ds Store) ([]metal.Rollout, error) { return ds.ListRollouts() }),
+	newArchiveTable[metal.PowerSample](powerSampleTableName, func(ds Store) ([]metal.PowerSample, error) { return ds.ListPowerSamples() }),
+	newArchiveTable[metal.ProvisioningEventContainer](eventTableName, func(ds Store) ([]metal.ProvisioningEventContainer, error) {
+		return ds.ListProvisioningEventContainers()
+	}),
+	newArchiveTable[metal.Switch](switchTableName, func(ds Store) ([]metal.Switch, error) { return ds.ListSwitches() }),
+	newArchiveTable[metal.SwitchStatus](switchStatusTableName, func(ds Store) ([]metal.SwitchStatus, error) {
+		return ds.ListSwitchStatuses()
+	}),
+}
+
+// archivePools returns the integer pools contained in an archive, the asn pools of the partitions are
+// sorted by their name.
+func archivePools(ds Store) []UniqueIntegerPool {
+	var partitionPools []UniqueIntegerPool
+	for _, pool := range ds.GetPartitionASNPools() {
+		partitionPools = append(partitionPools, pool)
+	}
+	sort.Slice(partitionPools, func(i, j int) bool { return partitionPools[i].String() < partitionPools[j].String() })
+
+	return append([]UniqueIntegerPool{ds.GetVRFPool(), ds.GetASNPool()}, partitionPools...)
+}
+
+// Export writes all entities, the acquired integers of the integer pools and the migration version
+// of the datastore as gzipped tar archive to the given writer. Every table is stored as file with
+// one json encoded entity per line.
+func Export(log *zap.SugaredLogger, ds Store, w io.Writer) (*ArchiveMetadata, error) {
+	version, err := ds.MigrationVersion()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migration version: %w", err)
+	}
+
+	meta := &ArchiveMetadata{
+		FormatVersion:    ArchiveFormatVersion,
+		MigrationVersion: version,
+		Created:          time.Now(),
+		Entities:         map[string]int{},
+		Pools:            map[string]int{},
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	for _, t := range archiveTables {
+		es, err := t.list(ds)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list table %s: %w", t.name, err)
+		}
+
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range es {
+			err := enc.Encode(e)
+			if err != nil {
+				return nil, fmt.Errorf("unable to encode %v %s: %w", getEntityName(e), e.GetID(), err)
+			}
+		}
+
+		err = writeArchiveFile(tw, t.name+archiveTableSuffix, buf.Bytes(), meta.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		meta.Entities[t.name] = len(es)
+		log.Infow("exported table", "table", t.name, "entities", len(es))
+	}
+
+	for _, pool := range archivePools(ds) {
+		acquired, err := pool.AcquiredIntegers()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read integer pool %s: %w", pool, err)
+		}
+
+		data, err := json.Marshal(acquired)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeArchiveFile(tw, archivePoolPrefix+pool.String()+".json", data, meta.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		meta.Pools[pool.String()] = len(acquired)
+		log.Infow("exported integer pool", "pool", pool.String(), "acquired", len(acquired))
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeArchiveFile(tw, archiveMetadataFile, data, meta.Created)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func writeArchiveFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write archive header of %s: %w", name, err)
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return fmt.Errorf("unable to write %s to archive: %w", name, err)
+	}
+
+	return nil
+}
+
+// Import restores an archive which was written by Export. The datastore must be empty and must have
+// the same migration version as the archive, for rethinkdb this means that the database has to be
+// initialized and migrated before. The entire archive is validated before anything is written.
+func Import(log *zap.SugaredLogger, ds Store, r io.Reader) (*ArchiveMetadata, error) {
+	files, err := readArchiveFiles(r)
+	if err != nil {
+		return nil, err
+	}
+
+	rawMeta, ok := files[archiveMetadataFile]
+	if !ok {
+		return nil, fmt.Errorf("archive does not contain %s", archiveMetadataFile)
+	}
+
+	var meta ArchiveMetadata
+	err = json.Unmarshal(rawMeta, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode archive metadata: %w", err)
+	}
+
+	if meta.FormatVersion != ArchiveFormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d, expected %d", meta.FormatVersion, ArchiveFormatVersion)
+	}
+
+	version, err := ds.MigrationVersion()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migration version: %w", err)
+	}
+	if meta.MigrationVersion != version {
+		return nil, fmt.Errorf("archive has migration version %d but the datastore has version %d", meta.MigrationVersion, version)
+	}
+
+	err = ensureEmpty(ds)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := map[string][]metal.Entity{}
+	for _, t := range archiveTables {
+		es, err := decodeArchiveTable(t, files[t.name+archiveTableSuffix])
+		if err != nil {
+			return nil, fmt.Errorf("invalid table %s: %w", t.name, err)
+		}
+		if len(es) != meta.Entities[t.name] {
+			return nil, fmt.Errorf("invalid table %s: contains %d entities, but the metadata states %d", t.name, len(es), meta.Entities[t.name])
+		}
+		entities[t.name] = es
+	}
+
+	pools := map[string][]uint{}
+	for _, pool := range archivePools(ds) {
+		name := archivePoolPrefix + pool.String() + ".json"
+		raw, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("archive does not contain %s", name)
+		}
+
+		var acquired []uint
+		err := json.Unmarshal(raw, &acquired)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer pool %s: %w", pool, err)
+		}
+		if len(acquired) != meta.Pools[pool.String()] {
+			return nil, fmt.Errorf("invalid integer pool %s: contains %d integers, but the metadata states %d", pool, len(acquired), meta.Pools[pool.String()])
+		}
+		pools[pool.String()] = acquired
+	}
+
+	for _, t := range archiveTables {
+		for _, e := range entities[t.name] {
+			err := ds.RestoreEntity(e)
+			if err != nil {
+				return nil, fmt.Errorf("unable to restore %v %s: %w", getEntityName(e), e.GetID(), err)
+			}
+		}
+		log.Infow("imported table", "table", t.name, "entities", len(entities[t.name]))
+	}
+
+	for _, pool := range archivePools(ds) {
+		for _, i := range pools[pool.String()] {
+			_, err := pool.AcquireUniqueInteger(i)
+			if err != nil {
+				return nil, fmt.Errorf("unable to acquire %d from integer pool %s: %w", i, pool, err)
+			}
+		}
+		log.Infow("imported integer pool", "pool", pool.String(), "acquired", len(pools[pool.String()]))
+	}
+
+	return &meta, nil
+}
+
+func readArchiveFiles(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open archive: %w", err)
+	}
+	defer gz.Close()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive: %w", err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s from archive: %w", h.Name, err)
+		}
+		files[h.Name] = data
+	}
+
+	return files, nil
+}
+
+// decodeArchiveTable decodes and validates the entities of a table.
+func decodeArchiveTable(t archiveTable, data []byte) ([]metal.Entity, error) {
+	var (
+		result  []metal.Entity
+		ids     = map[string]bool{}
+		scanner = bufio.NewScanner(bytes.NewReader(data))
+		line    = 0
+	)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	for scanner.Scan() {
+		line++
+		raw := scanner.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		e, err := t.decode(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		if e.GetID() == "" {
+			return nil, fmt.Errorf("line %d: entity has no id", line)
+		}
+		if ids[e.GetID()] {
+			return nil, fmt.Errorf("line %d: duplicate id %s", line, e.GetID())
+		}
+		ids[e.GetID()] = true
+
+		if v, ok := e.(interface{ Validate() error }); ok {
+			err := v.Validate()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+		}
+
+		result = append(result, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// ensureEmpty returns an error if the datastore contains any entity or acquired integer.
+func ensureEmpty(ds Store) error {
+	for _, t := range archiveTables {
+		es, err := t.list(ds)
+		if err != nil {
+			return fmt.Errorf("unable to list table %s: %w", t.name, err)
+		}
+		if len(es) > 0 {
+			return fmt.Errorf("datastore is not empty, table %s contains %d entities", t.name, len(es))
+		}
+	}
+
+	for _, pool := range archivePools(ds) {
+		acquired, err := pool.AcquiredIntegers()
+		if err != nil {
+			return fmt.Errorf("unable to read integer pool %s: %w", pool, err)
+		}
+		if len(acquired) > 0 {
+			return fmt.Errorf("datastore is not empty, integer pool %s has %d acquired integers", pool, len(acquired))
+		}
+	}
+
+	return nil
+}
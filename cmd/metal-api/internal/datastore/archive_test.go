@@ -0,0 +1,155 @@
+package datastore
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestExportImport(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+
+	source := NewMemory(log)
+	require.NoError(t, source.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+	require.NoError(t, source.CreateSize(&metal.Size{Base: metal.Base{ID: "s1"}}))
+	require.NoError(t, source.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1", SizeID: "s1"}))
+	require.NoError(t, source.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n1"}, Vrf: 5}))
+	require.NoError(t, source.CreateSwitch(&metal.Switch{Base: metal.Base{ID: "sw1"}, PartitionID: "p1"}))
+	require.NoError(t, source.SetSwitchStatus(&metal.SwitchStatus{Base: metal.Base{ID: "sw1"}, LastSync: &metal.SwitchSync{Duration: time.Second}}))
+	_, err := source.GetVRFPool().AcquireUniqueInteger(5)
+	require.NoError(t, err)
+	_, err = source.GetASNPool().AcquireUniqueInteger(42)
+	require.NoError(t, err)
+
+	var archive bytes.Buffer
+	meta, err := Export(log, source, &archive)
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveFormatVersion, meta.FormatVersion)
+	assert.Equal(t, 1, meta.Entities[machineTableName])
+	assert.Equal(t, 1, meta.Entities[switchStatusTableName])
+	assert.Equal(t, 1, meta.Pools[VRFIntegerPool.String()])
+
+	target := NewMemory(log)
+	_, err = Import(log, target, bytes.NewReader(archive.Bytes()))
+	require.NoError(t, err)
+
+	want, err := source.FindMachineByID("m1")
+	require.NoError(t, err)
+	got, err := target.FindMachineByID("m1")
+	require.NoError(t, err)
+	assert.Equal(t, want, got, "entities including their timestamps must be restored")
+
+	status, err := target.GetSwitchStatus("sw1")
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, status.LastSync.Duration)
+
+	vrfs, err := target.GetVRFPool().AcquiredIntegers()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{5}, vrfs)
+	asns, err := target.GetASNPool().AcquiredIntegers()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{42}, asns)
+
+	// importing into a datastore which is not empty must fail
+	_, err = Import(log, target, bytes.NewReader(archive.Bytes()))
+	assert.ErrorContains(t, err, "datastore is not empty")
+}
+
+func TestImport_Validation(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+
+	tests := []struct {
+		name    string
+		modify  func(meta *ArchiveMetadata, files map[string][]byte)
+		wantErr string
+	}{
+		{
+			name:    "format version",
+			modify:  func(meta *ArchiveMetadata, files map[string][]byte) { meta.FormatVersion = 2 },
+			wantErr: "unsupported archive format version 2",
+		},
+		{
+			name:    "migration version",
+			modify:  func(meta *ArchiveMetadata, files map[string][]byte) { meta.MigrationVersion = 1000 },
+			wantErr: "archive has migration version 1000",
+		},
+		{
+			name: "unknown field",
+			modify: func(meta *ArchiveMetadata, files map[string][]byte) {
+				files[machineTableName+archiveTableSuffix] = []byte(`{"id":"m1","unknown":true}` + "\n")
+				meta.Entities[machineTableName] = 1
+			},
+			wantErr: `unknown field "unknown"`,
+		},
+		{
+			name: "missing id",
+			modify: func(meta *ArchiveMetadata, files map[string][]byte) {
+				files[machineTableName+archiveTableSuffix] = []byte(`{"name":"m1"}` + "\n")
+				meta.Entities[machineTableName] = 1
+			},
+			wantErr: "entity has no id",
+		},
+		{
+			name: "duplicate id",
+			modify: func(meta *ArchiveMetadata, files map[string][]byte) {
+				files[machineTableName+archiveTableSuffix] = []byte(`{"id":"m1"}` + "\n" + `{"id":"m1"}` + "\n")
+				meta.Entities[machineTableName] = 2
+			},
+			wantErr: "duplicate id m1",
+		},
+		{
+			name: "truncated table",
+			modify: func(meta *ArchiveMetadata, files map[string][]byte) {
+				meta.Entities[machineTableName] = 2
+			},
+			wantErr: "contains 0 entities, but the metadata states 2",
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			var archive bytes.Buffer
+			meta, err := Export(log, NewMemory(log), &archive)
+			require.NoError(t, err)
+
+			files, err := readArchiveFiles(&archive)
+			require.NoError(t, err)
+			tt.modify(meta, files)
+
+			target := NewMemory(log)
+			_, err = Import(log, target, bytes.NewReader(writeTestArchive(t, meta, files)))
+			assert.ErrorContains(t, err, tt.wantErr)
+
+			ms, err := target.ListMachines()
+			require.NoError(t, err)
+			assert.Empty(t, ms, "nothing must be written if the archive is invalid")
+		})
+	}
+}
+
+func writeTestArchive(t *testing.T, meta *ArchiveMetadata, files map[string][]byte) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.Marshal(meta)
+	require.NoError(t, err)
+	files[archiveMetadataFile] = data
+
+	for name, data := range files {
+		require.NoError(t, writeArchiveFile(tw, name, data, meta.Created))
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	return buf.Bytes()
+}
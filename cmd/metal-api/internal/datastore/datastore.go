@@ -0,0 +1,305 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/rest"
+	"go.uber.org/zap"
+)
+
+// the names of the entity tables, which are shared across the datastore implementations.
+const (
+	machineTableName             = "machine"
+	switchTableName              = "switch"
+	switchStatusTableName        = "switchstatus"
+	networkTableName             = "network"
+	ipTableName                  = "ip"
+	imageTableName               = "image"
+	sizeTableName                = "size"
+	partitionTableName           = "partition"
+	eventTableName               = "event"
+	filesystemLayoutTableName    = "filesystemlayout"
+	sizeImageConstraintTableName = "sizeimageconstraint"
+	reservationTableName         = "reservation"
+	pendingAllocationTableName   = "pendingallocation"
+	rolloutTableName             = "rollout"
+	powerSampleTableName         = "powersample"
+)
+
+// Store is the persistence layer of the metal-api. It is composed of one interface per entity
+// such that consumers can depend on the smallest set of functionality they actually need.
+//
+// All implementations must stick to the semantics of the rethinkdb implementation:
+//   - finding an entity by id returns a metal.NotFound error if the entity does not exist
+//   - creating an entity that already exists returns a metal.Conflict error
+//   - updating an entity whose "changed" timestamp differs from the one of the given old entity
+//     returns a metal.Conflict error (optimistic locking)
+type Store interface {
+	MachineStore
+	SwitchStore
+	NetworkStore
+	IPStore
+	ImageStore
+	SizeStore
+	PartitionStore
+	ProvisioningEventStore
+	FilesystemLayoutStore
+	SizeImageConstraintStore
+	ReservationStore
+	PendingAllocationStore
+	RolloutStore
+	PowerSampleStore
+	IntegerPoolStore
+	WatchStore
+	ArchiveStore
+	RevisionStore
+
+	// ServiceName returns the name of the datastore for health checks.
+	ServiceName() string
+	// Check implements the health interface and tests if the datastore is healthy.
+	Check(ctx context.Context) (rest.HealthStatus, error)
+
+	// Connect connects to the datastore.
+	Connect() error
+	// Close closes the connection to the datastore.
+	Close() error
+	// Initialize ensures that tables, pools, permissions are properly initialized.
+	Initialize() error
+	// Demote switches to the runtime user of the datastore.
+	Demote() error
+	// Migrate runs the datastore migrations up or down to the given target version.
+	Migrate(targetVersion *int, dry bool) error
+	// MigrationVersion returns the version of the latest migration which was applied to the datastore.
+	MigrationVersion() (int, error)
+}
+
+// MachineStore persists machines.
+type MachineStore interface {
+	FindMachineByID(id string) (*metal.Machine, error)
+	FindMachine(q *MachineSearchQuery, ms *metal.Machine) error
+	SearchMachines(q *MachineSearchQuery, ms *metal.Machines) error
+	ListMachines() (metal.Machines, error)
+	CreateMachine(m *metal.Machine) error
+	DeleteMachine(m *metal.Machine) error
+	UpdateMachine(oldMachine *metal.Machine, newMachine *metal.Machine) error
+	FindWaitingMachine(projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*metal.Machine, error)
+}
+
+// SwitchStore persists switches and their status.
+type SwitchStore interface {
+	FindSwitch(id string) (*metal.Switch, error)
+	ListSwitches() (metal.Switches, error)
+	CreateSwitch(s *metal.Switch) error
+	DeleteSwitch(s *metal.Switch) error
+	UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.Switch) error
+	SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error
+	SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error)
+	SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error)
+	ConnectMachineWithSwitches(m *metal.Machine) error
+	GetSwitchStatus(id string) (*metal.SwitchStatus, error)
+	ListSwitchStatuses() ([]metal.SwitchStatus, error)
+	SetSwitchStatus(state *metal.SwitchStatus) error
+}
+
+// NetworkStore persists networks.
+type NetworkStore interface {
+	FindNetworkByID(id string) (*metal.Network, error)
+	FindNetwork(q *NetworkSearchQuery, n *metal.Network) error
+	SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error
+	ListNetworks() (metal.Networks, error)
+	CreateNetwork(nw *metal.Network) error
+	DeleteNetwork(nw *metal.Network) error
+	UpdateNetwork(oldNetwork *metal.Network, newNetwork *metal.Network) error
+}
+
+// IPStore persists ip addresses.
+type IPStore interface {
+	FindIPByID(id string) (*metal.IP, error)
+	SearchIPs(q *IPSearchQuery, ips *metal.IPs) error
+	ListIPs() (metal.IPs, error)
+	CreateIP(ip *metal.IP) error
+	DeleteIP(ip *metal.IP) error
+	UpdateIP(oldIP *metal.IP, newIP *metal.IP) error
+}
+
+// ImageStore persists images.
+type ImageStore interface {
+	GetImage(id string) (*metal.Image, error)
+	FindImages(id string) ([]metal.Image, error)
+	FindImage(id string) (*metal.Image, error)
+	ListImages() (metal.Images, error)
+	CreateImage(i *metal.Image) error
+	DeleteImage(i *metal.Image) error
+	UpdateImage(oldImage *metal.Image, newImage *metal.Image) error
+	SearchImages(q *ImageSearchQuery, images *metal.Images) error
+	DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error)
+}
+
+// SizeStore persists sizes.
+type SizeStore interface {
+	FindSize(id string) (*metal.Size, error)
+	ListSizes() (metal.Sizes, error)
+	CreateSize(size *metal.Size) error
+	DeleteSize(size *metal.Size) error
+	UpdateSize(oldSize *metal.Size, newSize *metal.Size) error
+	FromHardware(hw metal.MachineHardware) (*metal.Size, []*metal.SizeMatchingLog, error)
+}
+
+// PartitionStore persists partitions.
+type PartitionStore interface {
+	FindPartition(id string) (*metal.Partition, error)
+	ListPartitions() (metal.Partitions, error)
+	CreatePartition(p *metal.Partition) error
+	DeletePartition(p *metal.Partition) error
+	UpdatePartition(oldPartition *metal.Partition, newPartition *metal.Partition) error
+}
+
+// ProvisioningEventStore persists the provisioning event containers of machines.
+type ProvisioningEventStore interface {
+	ListProvisioningEventContainers() (metal.ProvisioningEventContainers, error)
+	FindProvisioningEventContainer(id string) (*metal.ProvisioningEventContainer, error)
+	UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error
+	CreateProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error
+	UpsertProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error
+	ProvisioningEventForMachine(log *zap.SugaredLogger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error)
+}
+
+// FilesystemLayoutStore persists filesystem layouts.
+type FilesystemLayoutStore interface {
+	FindFilesystemLayout(id string) (*metal.FilesystemLayout, error)
+	ListFilesystemLayouts() (metal.FilesystemLayouts, error)
+	CreateFilesystemLayout(fl *metal.FilesystemLayout) error
+	DeleteFilesystemLayout(fl *metal.FilesystemLayout) error
+	UpdateFilesystemLayout(oldFilesystemLayout *metal.FilesystemLayout, newFilesystemLayout *metal.FilesystemLayout) error
+}
+
+// SizeImageConstraintStore persists size image constraints.
+type SizeImageConstraintStore interface {
+	FindSizeImageConstraint(sizeID string) (*metal.SizeImageConstraint, error)
+	ListSizeImageConstraints() (metal.SizeImageConstraints, error)
+	CreateSizeImageConstraint(ic *metal.SizeImageConstraint) error
+	DeleteSizeImageConstraint(ic *metal.SizeImageConstraint) error
+	UpdateSizeImageConstraint(oldSizeImageConstraint *metal.SizeImageConstraint, newSizeImageConstraint *metal.SizeImageConstraint) error
+}
+
+// ReservationStore persists the capacity reservations of projects.
+type ReservationStore interface {
+	FindReservation(id string) (*metal.Reservation, error)
+	ListReservations() (metal.Reservations, error)
+	CreateReservation(r *metal.Reservation) error
+	DeleteReservation(r *metal.Reservation) error
+	UpdateReservation(oldReservation *metal.Reservation, newReservation *metal.Reservation) error
+}
+
+// PendingAllocationStore persists the machine allocations which wait for a machine.
+type PendingAllocationStore interface {
+	FindPendingAllocation(id string) (*metal.PendingAllocation, error)
+	ListPendingAllocations() (metal.PendingAllocations, error)
+	CreatePendingAllocation(p *metal.PendingAllocation) error
+	DeletePendingAllocation(p *metal.PendingAllocation) error
+	UpdatePendingAllocation(oldPendingAllocation *metal.PendingAllocation, newPendingAllocation *metal.PendingAllocation) error
+}
+
+// RolloutStore persists the rollouts which reinstall groups of machines.
+type RolloutStore interface {
+	FindRollout(id string) (*metal.Rollout, error)
+	ListRollouts() (metal.Rollouts, error)
+	CreateRollout(r *metal.Rollout) error
+	UpdateRollout(oldRollout *metal.Rollout, newRollout *metal.Rollout) error
+}
+
+// PowerSampleStore persists the power consumption of the machines which is recorded periodically.
+type PowerSampleStore interface {
+	ListPowerSamples() (metal.PowerSamples, error)
+	// SearchPowerSamples returns the power samples recorded within the given period, including its bounds.
+	SearchPowerSamples(from, to time.Time) (metal.PowerSamples, error)
+	CreatePowerSample(s *metal.PowerSample) error
+	DeletePowerSample(s *metal.PowerSample) error
+}
+
+// WatchStore streams the changes of entities. The returned channels are closed when the given
+// context is done or when the datastore cannot guarantee to deliver all further changes, in which
+// case consumers have to watch again.
+type WatchStore interface {
+	WatchMachines(ctx context.Context) (<-chan Change[metal.Machine], error)
+	WatchProvisioningEventContainers(ctx context.Context) (<-chan Change[metal.ProvisioningEventContainer], error)
+}
+
+// IntegerPoolStore provides access to the pools of unique integers.
+type IntegerPoolStore interface {
+	GetVRFPool() UniqueIntegerPool
+	GetASNPool() UniqueIntegerPool
+	// GetPartitionASNPool returns the asn pool of the given partition, the global asn pool is returned
+	// if the partition has no pool of its own.
+	GetPartitionASNPool(partitionID string) UniqueIntegerPool
+	// GetPartitionASNPools returns the asn pools of all partitions which have a pool of their own, by partition id.
+	GetPartitionASNPools() map[string]UniqueIntegerPool
+}
+
+// UniqueIntegerPool hands out unique integers of a given range.
+type UniqueIntegerPool interface {
+	String() string
+	// Range returns the range of integers which is managed by the pool.
+	Range() IntegerRange
+	AcquireRandomUniqueInteger() (uint, error)
+	AcquireUniqueInteger(value uint) (uint, error)
+	ReleaseUniqueInteger(id uint) error
+	// AcquiredIntegers returns all integers which are currently acquired, in ascending order.
+	AcquiredIntegers() ([]uint, error)
+}
+
+// RevisionStore provides access to the revisions which are recorded on every change of an entity.
+type RevisionStore interface {
+	// WithRevisionInfo returns a datastore which records the given information with all revisions.
+	WithRevisionInfo(info RevisionInfo) Store
+	// ListRevisions returns all revisions of an entity sorted by their timestamp.
+	ListRevisions(kind, id string) (metal.Revisions, error)
+}
+
+// ArchiveStore provides the access which is required to export and import the entire datastore.
+type ArchiveStore interface {
+	// RestoreEntity writes the entity as it is, including its id and timestamps. It returns a
+	// metal.Conflict error if the entity already exists.
+	RestoreEntity(entity metal.Entity) error
+}
+
+// tableNameOf returns the name of the table the given entity is stored in.
+func tableNameOf(entity metal.Entity) (string, error) {
+	switch entity.(type) {
+	case *metal.Machine:
+		return machineTableName, nil
+	case *metal.Switch:
+		return switchTableName, nil
+	case *metal.SwitchStatus:
+		return switchStatusTableName, nil
+	case *metal.Network:
+		return networkTableName, nil
+	case *metal.IP:
+		return ipTableName, nil
+	case *metal.Image:
+		return imageTableName, nil
+	case *metal.Size:
+		return sizeTableName, nil
+	case *metal.Partition:
+		return partitionTableName, nil
+	case *metal.ProvisioningEventContainer:
+		return eventTableName, nil
+	case *metal.FilesystemLayout:
+		return filesystemLayoutTableName, nil
+	case *metal.SizeImageConstraint:
+		return sizeImageConstraintTableName, nil
+	case *metal.Reservation:
+		return reservationTableName, nil
+	case *metal.PendingAllocation:
+		return pendingAllocationTableName, nil
+	case *metal.Rollout:
+		return rolloutTableName, nil
+	case *metal.PowerSample:
+		return powerSampleTableName, nil
+	default:
+		return "", fmt.Errorf("no table for %v", getEntityName(entity))
+	}
+}
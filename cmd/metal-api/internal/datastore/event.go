@@ -38,8 +38,13 @@ func (rs *RethinkStore) UpsertProvisioningEventContainer(ec *metal.ProvisioningE
 	return rs.upsertEntity(rs.eventTable(), ec)
 }
 
+// ProvisioningEventForMachine applies the given provisioning event to the event container of a machine.
 func (rs *RethinkStore) ProvisioningEventForMachine(log *zap.SugaredLogger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
-	ec, err := rs.FindProvisioningEventContainer(machineID)
+	return provisioningEventForMachine(log, rs, event, machineID)
+}
+
+func provisioningEventForMachine(log *zap.SugaredLogger, ds ProvisioningEventStore, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
+	ec, err := ds.FindProvisioningEventContainer(machineID)
 	if err != nil && !metal.IsNotFound(err) {
 		return nil, err
 	}
@@ -64,6 +69,6 @@ func (rs *RethinkStore) ProvisioningEventForMachine(log *zap.SugaredLogger, even
 
 	newEC.TrimEvents(100)
 
-	err = rs.UpsertProvisioningEventContainer(newEC)
+	err = ds.UpsertProvisioningEventContainer(newEC)
 	return newEC, err
 }
@@ -13,8 +13,9 @@ func (rs *RethinkStore) ServiceName() string {
 
 // Check implements the health interface and tests if the database is healthy.
 func (rs *RethinkStore) Check(ctx context.Context) (rest.HealthStatus, error) {
+	required := rs.tableNames()
 	t := r.Branch(
-		rs.db().TableList().SetIntersection(r.Expr(tables)).Count().Eq(len(tables)),
+		rs.db().TableList().SetIntersection(r.Expr(required)).Count().Eq(len(required)),
 		r.Expr(true),
 		r.Error("required tables are missing"),
 	)
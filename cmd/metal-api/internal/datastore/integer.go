@@ -3,6 +3,9 @@ package datastore
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
@@ -39,6 +42,95 @@ var (
 	DefaultASNPoolRangeMax = uint(131072)
 )
 
+// IntegerRange is a range of integers, both bounds are part of the range.
+type IntegerRange struct {
+	Min uint
+	Max uint
+}
+
+func (ir IntegerRange) String() string {
+	return fmt.Sprintf("%d-%d", ir.Min, ir.Max)
+}
+
+// Size returns the amount of integers in the range.
+func (ir IntegerRange) Size() uint {
+	return ir.Max - ir.Min + 1
+}
+
+// Validate returns an error if the range does not start at 1 or above or ends before it starts.
+func (ir IntegerRange) Validate() error {
+	if ir.Min == 0 || ir.Min > ir.Max {
+		return fmt.Errorf("range %s must start at 1 or above and must not end before it starts", ir)
+	}
+	return nil
+}
+
+// Overlaps returns true if the ranges have at least one integer in common.
+func (ir IntegerRange) Overlaps(other IntegerRange) bool {
+	return ir.Min <= other.Max && other.Min <= ir.Max
+}
+
+// ParseIntegerRange parses a range in the form min-max.
+func ParseIntegerRange(s string) (IntegerRange, error) {
+	minStr, maxStr, found := strings.Cut(s, "-")
+	if !found {
+		return IntegerRange{}, fmt.Errorf("range %q must be in the form min-max", s)
+	}
+	min, err := strconv.ParseUint(strings.TrimSpace(minStr), 10, 64)
+	if err != nil {
+		return IntegerRange{}, fmt.Errorf("invalid minimum of range %q: %w", s, err)
+	}
+	max, err := strconv.ParseUint(strings.TrimSpace(maxStr), 10, 64)
+	if err != nil {
+		return IntegerRange{}, fmt.Errorf("invalid maximum of range %q: %w", s, err)
+	}
+	result := IntegerRange{Min: uint(min), Max: uint(max)}
+	return result, result.Validate()
+}
+
+// PartitionASNIntegerPool returns the name of the asn pool of a partition, which is also the name of its tables.
+// Characters which are not allowed in table names are replaced by underscores.
+func PartitionASNIntegerPool(partitionID string) IntegerPoolType {
+	name := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, partitionID)
+	return IntegerPoolType(ASNIntegerPool.String() + "_" + name)
+}
+
+// ValidatePartitionASNPoolRanges verifies that the asn pools of the partitions do not overlap with the global asn pool.
+// The pools of different partitions may overlap as the asns only need to be unique within a partition. An asn is
+// released to the pool whose range contains it, so asns which were acquired before the partition got its own pool
+// are still given back to the global pool.
+func ValidatePartitionASNPoolRanges(global IntegerRange, ranges map[string]IntegerRange) error {
+	var partitions []string
+	for partitionID := range ranges {
+		partitions = append(partitions, partitionID)
+	}
+	sort.Strings(partitions)
+
+	names := map[IntegerPoolType]string{}
+	for _, partitionID := range partitions {
+		rng := ranges[partitionID]
+		err := rng.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid asn pool range of partition %s: %w", partitionID, err)
+		}
+		if rng.Overlaps(global) {
+			return fmt.Errorf("asn pool range %s of partition %s overlaps with the global asn pool range %s", rng, partitionID, global)
+		}
+		name := PartitionASNIntegerPool(partitionID)
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("partitions %s and %s would share the asn pool %s", other, partitionID, name)
+		}
+		names[name] = partitionID
+	}
+
+	return nil
+}
+
 // IntegerPool manages unique integers
 type IntegerPool struct {
 	poolType  IntegerPoolType
@@ -59,7 +151,35 @@ type integerinfo struct {
 	IsInitialized bool   `rethinkdb:"isInitialized" json:"isInitialized"`
 }
 
-func (rs *RethinkStore) GetVRFPool() *IntegerPool {
+// GetVRFPool returns the pool of unique integers for vrfs.
+func (rs *RethinkStore) GetVRFPool() UniqueIntegerPool {
+	return rs.vrfPool()
+}
+
+// GetASNPool returns the pool of unique integers for asns.
+func (rs *RethinkStore) GetASNPool() UniqueIntegerPool {
+	return rs.asnPool()
+}
+
+// GetPartitionASNPool returns the asn pool of the given partition, the global asn pool is returned
+// if the partition has no pool of its own.
+func (rs *RethinkStore) GetPartitionASNPool(partitionID string) UniqueIntegerPool {
+	if _, ok := rs.PartitionASNPoolRanges[partitionID]; !ok {
+		return rs.asnPool()
+	}
+	return rs.partitionASNPool(partitionID)
+}
+
+// GetPartitionASNPools returns the asn pools of all partitions which have a pool of their own.
+func (rs *RethinkStore) GetPartitionASNPools() map[string]UniqueIntegerPool {
+	result := map[string]UniqueIntegerPool{}
+	for partitionID := range rs.PartitionASNPoolRanges {
+		result[partitionID] = rs.partitionASNPool(partitionID)
+	}
+	return result
+}
+
+func (rs *RethinkStore) vrfPool() *IntegerPool {
 	return &IntegerPool{
 		poolType:  VRFIntegerPool,
 		session:   rs.session,
@@ -70,7 +190,7 @@ func (rs *RethinkStore) GetVRFPool() *IntegerPool {
 	}
 }
 
-func (rs *RethinkStore) GetASNPool() *IntegerPool {
+func (rs *RethinkStore) asnPool() *IntegerPool {
 	return &IntegerPool{
 		poolType:  ASNIntegerPool,
 		session:   rs.session,
@@ -81,10 +201,30 @@ func (rs *RethinkStore) GetASNPool() *IntegerPool {
 	}
 }
 
+func (rs *RethinkStore) partitionASNPool(partitionID string) *IntegerPool {
+	poolType := PartitionASNIntegerPool(partitionID)
+	rng := rs.PartitionASNPoolRanges[partitionID]
+	poolTable := r.DB(rs.dbname).Table(poolType.String())
+	infoTable := r.DB(rs.dbname).Table(poolType.String() + "info")
+	return &IntegerPool{
+		poolType:  poolType,
+		session:   rs.session,
+		min:       rng.Min,
+		max:       rng.Max,
+		poolTable: &poolTable,
+		infoTable: &infoTable,
+	}
+}
+
 func (ip *IntegerPool) String() string {
 	return ip.poolType.String()
 }
 
+// Range returns the range of integers which is managed by the pool.
+func (ip *IntegerPool) Range() IntegerRange {
+	return IntegerRange{Min: ip.min, Max: ip.max}
+}
+
 // initIntegerPool initializes a pool to acquire unique integers from.
 // the acquired integers are used from the network service for defining the:
 // one integer for:
@@ -194,6 +334,50 @@ func (ip *IntegerPool) ReleaseUniqueInteger(id uint) error {
 	return nil
 }
 
+// freeIntegers returns all integers which are not acquired yet.
+func (ip *IntegerPool) freeIntegers() ([]uint, error) {
+	res, err := ip.poolTable.Run(ip.session)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	var integers []integer
+	err = res.All(&integers)
+	if err != nil {
+		return nil, err
+	}
+
+	free := make([]uint, 0, len(integers))
+	for _, i := range integers {
+		free = append(free, i.ID)
+	}
+
+	return free, nil
+}
+
+// AcquiredIntegers returns all integers of the range which are not in the pool.
+func (ip *IntegerPool) AcquiredIntegers() ([]uint, error) {
+	free, err := ip.freeIntegers()
+	if err != nil {
+		return nil, err
+	}
+
+	isFree := make(map[uint]bool, len(free))
+	for _, i := range free {
+		isFree[i] = true
+	}
+
+	acquired := []uint{}
+	for i := ip.min; i <= ip.max; i++ {
+		if !isFree[i] {
+			acquired = append(acquired, i)
+		}
+	}
+
+	return acquired, nil
+}
+
 func (ip *IntegerPool) genericAcquire(term *r.Term) (uint, error) {
 	res, err := term.Delete(r.DeleteOpts{ReturnChanges: true}).RunWrite(ip.session)
 	if err != nil {
@@ -232,8 +416,12 @@ func makeRange(min, max uint) []integer {
 }
 
 func (ip *IntegerPool) verifyRange(value uint) error {
-	if value < ip.min || value > ip.max {
-		return fmt.Errorf("value '%d' is outside of the allowed range '%d - %d'", value, ip.min, ip.max)
+	return verifyRange(value, ip.min, ip.max)
+}
+
+func verifyRange(value, min, max uint) error {
+	if value < min || value > max {
+		return fmt.Errorf("value '%d' is outside of the allowed range '%d - %d'", value, min, max)
 	}
 	return nil
 }
@@ -68,6 +68,10 @@ func TestRethinkStore_AcquireUniqueIntegerTwiceIntegration(t *testing.T) {
 
 	_, err = pool.AcquireUniqueInteger(10000)
 	assert.True(t, metal.IsConflict(err))
+
+	acquired, err := pool.AcquiredIntegers()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{10000}, acquired)
 }
 
 func TestRethinkStore_AcquireUniqueIntegerPoolExhaustionIntegration(t *testing.T) {
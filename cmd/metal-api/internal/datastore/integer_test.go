@@ -216,7 +216,7 @@ func TestRethinkStore_genericAcquire(t *testing.T) {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
 			rs, mock := InitMockDB(t)
-			ip := rs.GetVRFPool()
+			ip := rs.vrfPool()
 
 			term := ip.poolTable.Get(tt.value)
 			if tt.requiresMock {
@@ -246,3 +246,51 @@ func TestRethinkStore_genericAcquire(t *testing.T) {
 		})
 	}
 }
+
+func TestValidatePartitionASNPoolRanges(t *testing.T) {
+	global := IntegerRange{Min: 1, Max: 100}
+
+	tests := []struct {
+		name    string
+		ranges  map[string]IntegerRange
+		wantErr string
+	}{
+		{
+			name:   "disjoint ranges",
+			ranges: map[string]IntegerRange{"p1": {Min: 101, Max: 200}, "p2": {Min: 101, Max: 300}},
+		},
+		{
+			name:    "overlaps with the global range",
+			ranges:  map[string]IntegerRange{"p1": {Min: 100, Max: 200}},
+			wantErr: "asn pool range 100-200 of partition p1 overlaps with the global asn pool range 1-100",
+		},
+		{
+			name:    "invalid range",
+			ranges:  map[string]IntegerRange{"p1": {Min: 300, Max: 200}},
+			wantErr: "invalid asn pool range of partition p1: range 300-200 must start at 1 or above and must not end before it starts",
+		},
+		{
+			name:    "same pool name",
+			ranges:  map[string]IntegerRange{"fra-1": {Min: 101, Max: 200}, "fra.1": {Min: 101, Max: 200}},
+			wantErr: "partitions fra-1 and fra.1 would share the asn pool asnpool_fra_1",
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePartitionASNPoolRanges(global, tt.ranges)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+
+	rng, err := ParseIntegerRange("101-200")
+	assert.NoError(t, err)
+	assert.Equal(t, IntegerRange{Min: 101, Max: 200}, rng)
+
+	_, err = ParseIntegerRange("200")
+	assert.Error(t, err)
+}
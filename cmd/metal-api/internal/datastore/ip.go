@@ -6,6 +6,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	"github.com/metal-stack/metal-lib/pkg/tag"
+	"golang.org/x/exp/slices"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
@@ -20,6 +21,25 @@ type IPSearchQuery struct {
 	ProjectID        *string  `json:"projectid" description:"the project this ip address belongs to, empty if not strong coupled" optional:"true"`
 	Type             *string  `json:"type" description:"the type of the ip address, ephemeral or static" optional:"true"`
 	MachineID        *string  `json:"machineid" description:"the machine an ip address is associated to" optional:"true"`
+
+	Paging
+}
+
+// ipSortKeys are the fields ips can be sorted by in a search.
+var ipSortKeys = sortKeys{
+	"ipaddress": idSortKey,
+	"name":      {path: []string{"name"}},
+	"projectid": {path: []string{"projectid"}},
+	"networkid": {path: []string{"networkid"}},
+	"type":      {path: []string{"type"}},
+	"created":   {path: []string{"created"}, time: true},
+	"changed":   {path: []string{"changed"}, time: true},
+}
+
+// NextCursor returns the cursor to continue the search after the given page of ips, it returns
+// nil if there are no further ips.
+func (p *IPSearchQuery) NextCursor(ips metal.IPs) (*string, error) {
+	return nextCursor(&p.Paging, ipSortKeys, ips)
 }
 
 // GenerateTerm generates the project search query term.
@@ -82,6 +102,51 @@ func (p *IPSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
 	return &q
 }
 
+// matches returns true if the given ip is matched by the query, it has
+// to be kept in line with generateTerm.
+func (p *IPSearchQuery) matches(ip *metal.IP) bool {
+	if p.IPAddress != nil && ip.IPAddress != *p.IPAddress {
+		return false
+	}
+
+	if p.AllocationUUID != nil && ip.AllocationUUID != *p.AllocationUUID {
+		return false
+	}
+
+	if p.Name != nil && ip.Name != *p.Name {
+		return false
+	}
+
+	if p.ProjectID != nil && ip.ProjectID != *p.ProjectID {
+		return false
+	}
+
+	if p.NetworkID != nil && ip.NetworkID != *p.NetworkID {
+		return false
+	}
+
+	if p.ParentPrefixCidr != nil && ip.ParentPrefixCidr != *p.ParentPrefixCidr {
+		return false
+	}
+
+	tags := p.Tags
+	if p.MachineID != nil {
+		tags = append(slices.Clone(tags), metal.IpTag(tag.MachineID, *p.MachineID))
+	}
+
+	for _, t := range tags {
+		if !slices.Contains(ip.Tags, t) {
+			return false
+		}
+	}
+
+	if p.Type != nil && string(ip.Type) != *p.Type {
+		return false
+	}
+
+	return true
+}
+
 // FindIPByID returns an ip of a given id.
 func (rs *RethinkStore) FindIPByID(id string) (*metal.IP, error) {
 	var ip metal.IP
@@ -94,7 +159,11 @@ func (rs *RethinkStore) FindIPByID(id string) (*metal.IP, error) {
 
 // SearchIPs returns the result of the ips search request query.
 func (rs *RethinkStore) SearchIPs(q *IPSearchQuery, ips *metal.IPs) error {
-	return rs.searchEntities(q.generateTerm(rs), ips)
+	term, err := q.Paging.generateTerm(ipSortKeys, q.generateTerm(rs))
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(term, ips)
 }
 
 // ListIPs returns all ips.
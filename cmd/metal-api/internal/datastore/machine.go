@@ -6,12 +6,18 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
+// ErrNoMachineAvailable is returned if no waiting machine can be allocated.
+var ErrNoMachineAvailable = errors.New("no machine available")
+
 // MachineSearchQuery can be used to search machines.
 type MachineSearchQuery struct {
 	ID          *string  `json:"id" optional:"true"`
@@ -71,6 +77,28 @@ type MachineSearchQuery struct {
 	FruProductManufacturer *string `json:"fru_product_manufacturer" optional:"true"`
 	FruProductPartNumber   *string `json:"fru_product_part_number" optional:"true"`
 	FruProductSerial       *string `json:"fru_product_serial" optional:"true"`
+
+	Paging
+}
+
+// machineSortKeys are the fields machines can be sorted by in a search.
+var machineSortKeys = sortKeys{
+	"id":                  idSortKey,
+	"name":                {path: []string{"name"}},
+	"partition_id":        {path: []string{"partitionid"}},
+	"sizeid":              {path: []string{"sizeid"}},
+	"rackid":              {path: []string{"rackid"}},
+	"allocation_name":     {path: []string{"allocation", "name"}},
+	"allocation_project":  {path: []string{"allocation", "project"}},
+	"allocation_hostname": {path: []string{"allocation", "hostname"}},
+	"created":             {path: []string{"created"}, time: true},
+	"changed":             {path: []string{"changed"}, time: true},
+}
+
+// NextCursor returns the cursor to continue the search after the given page of machines, it returns
+// nil if there are no further machines.
+func (p *MachineSearchQuery) NextCursor(ms metal.Machines) (*string, error) {
+	return nextCursor(&p.Paging, machineSortKeys, ms)
 }
 
 // GenerateTerm generates the project search query term.
@@ -375,6 +403,216 @@ func (p *MachineSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
 	return &q
 }
 
+// matches returns true if the given machine is matched by the query, it has
+// to be kept in line with generateTerm.
+func (p *MachineSearchQuery) matches(m *metal.Machine) bool {
+	if p.ID != nil && m.ID != *p.ID {
+		return false
+	}
+
+	if p.Name != nil && m.Name != *p.Name {
+		return false
+	}
+
+	if p.PartitionID != nil && m.PartitionID != *p.PartitionID {
+		return false
+	}
+
+	if p.SizeID != nil && m.SizeID != *p.SizeID {
+		return false
+	}
+
+	if p.RackID != nil && m.RackID != *p.RackID {
+		return false
+	}
+
+	for _, tag := range p.Tags {
+		if !slices.Contains(m.Tags, tag) {
+			return false
+		}
+	}
+
+	hasAllocationFilter := p.AllocationName != nil || p.AllocationProject != nil || p.AllocationImageID != nil ||
+		p.AllocationHostname != nil || p.AllocationRole != nil || p.AllocationSucceeded != nil ||
+		len(p.NetworkIDs) > 0 || len(p.NetworkPrefixes) > 0 || len(p.NetworkIPs) > 0 ||
+		len(p.NetworkDestinationPrefixes) > 0 || len(p.NetworkVrfs) > 0 || len(p.NetworkASNs) > 0
+
+	if hasAllocationFilter {
+		if m.Allocation == nil {
+			return false
+		}
+		if !p.matchesAllocation(m.Allocation) {
+			return false
+		}
+	}
+
+	if p.HardwareMemory != nil && int64(m.Hardware.Memory) != *p.HardwareMemory {
+		return false
+	}
+
+	if p.HardwareCPUCores != nil && int64(m.Hardware.CPUCores) != *p.HardwareCPUCores {
+		return false
+	}
+
+	if !containsAll(p.NicsMacAddresses, m.Hardware.Nics, func(nic metal.Nic) string { return string(nic.MacAddress) }) {
+		return false
+	}
+
+	if !containsAll(p.NicsNames, m.Hardware.Nics, func(nic metal.Nic) string { return nic.Name }) {
+		return false
+	}
+
+	if !containsAll(p.NicsVrfs, m.Hardware.Nics, func(nic metal.Nic) string { return nic.Vrf }) {
+		return false
+	}
+
+	var neighbors metal.Nics
+	for _, nic := range m.Hardware.Nics {
+		neighbors = append(neighbors, nic.Neighbors...)
+	}
+
+	if !containsAll(p.NicsNeighborMacAddresses, neighbors, func(nic metal.Nic) string { return string(nic.MacAddress) }) {
+		return false
+	}
+
+	if !containsAll(p.NicsNeighborNames, neighbors, func(nic metal.Nic) string { return nic.Name }) {
+		return false
+	}
+
+	if !containsAll(p.NicsNeighborVrfs, neighbors, func(nic metal.Nic) string { return nic.Vrf }) {
+		return false
+	}
+
+	if !containsAll(p.DiskNames, m.Hardware.Disks, func(bd metal.BlockDevice) string { return bd.Name }) {
+		return false
+	}
+
+	if !containsAll(p.DiskSizes, m.Hardware.Disks, func(bd metal.BlockDevice) int64 { return int64(bd.Size) }) {
+		return false
+	}
+
+	if p.StateValue != nil && string(m.State.Value) != *p.StateValue {
+		return false
+	}
+
+	if p.IpmiAddress != nil && m.IPMI.Address != *p.IpmiAddress {
+		return false
+	}
+
+	if p.IpmiMacAddress != nil && m.IPMI.MacAddress != *p.IpmiMacAddress {
+		return false
+	}
+
+	if p.IpmiUser != nil && m.IPMI.User != *p.IpmiUser {
+		return false
+	}
+
+	if p.IpmiInterface != nil && m.IPMI.Interface != *p.IpmiInterface {
+		return false
+	}
+
+	fru := m.IPMI.Fru
+
+	for _, f := range []struct {
+		want *string
+		got  string
+	}{
+		{want: p.FruChassisPartNumber, got: fru.ChassisPartNumber},
+		{want: p.FruChassisPartSerial, got: fru.ChassisPartSerial},
+		{want: p.FruBoardMfg, got: fru.BoardMfg},
+		{want: p.FruBoardMfgSerial, got: fru.BoardMfgSerial},
+		{want: p.FruBoardPartNumber, got: fru.BoardPartNumber},
+		{want: p.FruProductManufacturer, got: fru.ProductManufacturer},
+		{want: p.FruProductPartNumber, got: fru.ProductPartNumber},
+		{want: p.FruProductSerial, got: fru.ProductSerial},
+	} {
+		if f.want != nil && f.got != *f.want {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *MachineSearchQuery) matchesAllocation(a *metal.MachineAllocation) bool {
+	if p.AllocationName != nil && a.Name != *p.AllocationName {
+		return false
+	}
+
+	if p.AllocationProject != nil && a.Project != *p.AllocationProject {
+		return false
+	}
+
+	if p.AllocationImageID != nil && a.ImageID != *p.AllocationImageID {
+		return false
+	}
+
+	if p.AllocationHostname != nil && a.Hostname != *p.AllocationHostname {
+		return false
+	}
+
+	if p.AllocationRole != nil && a.Role != *p.AllocationRole {
+		return false
+	}
+
+	if p.AllocationSucceeded != nil && a.Succeeded != *p.AllocationSucceeded {
+		return false
+	}
+
+	anyNetwork := func(match func(nw *metal.MachineNetwork) bool) bool {
+		for _, nw := range a.MachineNetworks {
+			if nw != nil && match(nw) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, id := range p.NetworkIDs {
+		id := id
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return nw.NetworkID == id }) {
+			return false
+		}
+	}
+
+	for _, prefix := range p.NetworkPrefixes {
+		prefix := prefix
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.Prefixes, prefix) }) {
+			return false
+		}
+	}
+
+	for _, ip := range p.NetworkIPs {
+		ip := ip
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.IPs, ip) }) {
+			return false
+		}
+	}
+
+	for _, destPrefix := range p.NetworkDestinationPrefixes {
+		destPrefix := destPrefix
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return slices.Contains(nw.DestinationPrefixes, destPrefix) }) {
+			return false
+		}
+	}
+
+	for _, vrf := range p.NetworkVrfs {
+		vrf := vrf
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return int64(nw.Vrf) == vrf }) {
+			return false
+		}
+	}
+
+	for _, asn := range p.NetworkASNs {
+		asn := asn
+		if !anyNetwork(func(nw *metal.MachineNetwork) bool { return int64(nw.ASN) == asn }) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // FindMachineByID returns a machine for a given id.
 func (rs *RethinkStore) FindMachineByID(id string) (*metal.Machine, error) {
 	var m metal.Machine
@@ -392,7 +630,11 @@ func (rs *RethinkStore) FindMachine(q *MachineSearchQuery, ms *metal.Machine) er
 
 // SearchMachines returns the result of the machines search request query.
 func (rs *RethinkStore) SearchMachines(q *MachineSearchQuery, ms *metal.Machines) error {
-	return rs.searchEntities(q.generateTerm(rs), ms)
+	term, err := q.Paging.generateTerm(machineSortKeys, q.generateTerm(rs))
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(term, ms)
 }
 
 // ListMachines returns all machines.
@@ -428,7 +670,7 @@ func (rs *RethinkStore) UpdateMachine(oldMachine *metal.Machine, newMachine *met
 // FindWaitingMachine returns an available, not allocated, waiting and alive machine of given size within the given partition.
 // TODO: the algorithm can be optimized / shortened by using a rethinkdb join command and then using .Sample(1)
 // but current implementation should have a slightly better readability.
-func (rs *RethinkStore) FindWaitingMachine(projectid, partitionid, sizeid string, placementTags []string) (*metal.Machine, error) {
+func (rs *RethinkStore) FindWaitingMachine(projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*metal.Machine, error) {
 	q := *rs.machineTable()
 	q = q.Filter(map[string]interface{}{
 		"allocation":  nil,
@@ -447,7 +689,47 @@ func (rs *RethinkStore) FindWaitingMachine(projectid, partitionid, sizeid string
 		return nil, err
 	}
 
-	ecs, err := rs.ListProvisioningEventContainers()
+	oldMachine, err := electWaitingMachine(rs.log, rs, candidates, projectid, partitionid, sizeid, placementTags, affinity, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	newMachine := *oldMachine
+	newMachine.PreAllocated = true
+
+	err = rs.updateEntity(rs.machineTable(), &newMachine, oldMachine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &newMachine, nil
+}
+
+// waitingMachineStore is the part of the store the election of a waiting machine requires.
+type waitingMachineStore interface {
+	MachineStore
+	ProvisioningEventStore
+	ReservationStore
+	PartitionStore
+	SwitchStore
+}
+
+// electWaitingMachine picks one of the given waiting machines which is alive and satisfies the hardware
+// requirements and the affinity with the placement strategy of the partition. Machines which are reserved
+// for other projects are not handed out.
+func electWaitingMachine(log *zap.SugaredLogger, ds waitingMachineStore, candidates metal.Machines, projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*metal.Machine, error) {
+	finalists, err := electionFinalists(log, ds, candidates, projectid, partitionid, sizeid, placementTags, affinity, requirements, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &finalists[randomIndex(len(finalists))], nil
+}
+
+// electionFinalists narrows the given waiting machines down to the machines which are suited best for the allocation.
+// The reasons why candidates were excluded are recorded if excluded is not nil.
+func electionFinalists(log *zap.SugaredLogger, ds waitingMachineStore, candidates metal.Machines, projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint, excluded exclusions) (metal.Machines, error) {
+	ecs, err := ds.ListProvisioningEventContainers()
 	if err != nil {
 		return nil, err
 	}
@@ -455,20 +737,58 @@ func (rs *RethinkStore) FindWaitingMachine(projectid, partitionid, sizeid string
 
 	var available metal.Machines
 	for _, m := range candidates {
+		if mismatches, ok := metal.MatchesConstraints(requirements, m.Hardware); !ok {
+			var logs []string
+			for _, ml := range mismatches {
+				logs = append(logs, fmt.Sprintf("%s: %s", ml.Constraint.Type, ml.Log))
+			}
+			excluded.add(fmt.Sprintf("hardware does not satisfy the requirements (%s)", strings.Join(logs, ", ")), m)
+			continue
+		}
 		ec, ok := ecMap[m.ID]
 		if !ok {
-			rs.log.Errorw("cannot find machine provisioning event container", "machine", m, "error", err)
+			log.Errorw("cannot find machine provisioning event container", "machine", m, "error", err)
+			excluded.add("machine has no provisioning event container", m)
 			// fall through, so the rest of the machines is getting evaluated
 			continue
 		}
 		if ec.Liveliness != metal.MachineLivelinessAlive {
+			excluded.add(fmt.Sprintf("machine is not alive, liveliness is %s", ec.Liveliness), m)
 			continue
 		}
 		available = append(available, m)
 	}
 
 	if available == nil || len(available) < 1 {
-		return nil, errors.New("no machine available")
+		return nil, ErrNoMachineAvailable
+	}
+
+	reservations, err := ds.ListReservations()
+	if err != nil {
+		return nil, err
+	}
+	if len(reservations) > 0 {
+		var allocated metal.Machines
+		err = ds.SearchMachines(&MachineSearchQuery{PartitionID: &partitionid, SizeID: &sizeid}, &allocated)
+		if err != nil {
+			return nil, err
+		}
+
+		reserved := reservations.ReservedForOthers(projectid, partitionid, sizeid, allocated, time.Now())
+		if len(available) <= reserved {
+			excluded.add("machine is reserved for other projects", available...)
+			return nil, fmt.Errorf("%w, %d waiting machines are reserved for other projects", ErrNoMachineAvailable, len(available))
+		}
+	}
+
+	partition, err := ds.FindPartition(partitionid)
+	if err != nil {
+		return nil, err
+	}
+
+	strategy, err := NewPlacementStrategy(partition.PlacementStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid placement strategy of partition %s: %w", partitionid, err)
 	}
 
 	query := MachineSearchQuery{
@@ -477,26 +797,125 @@ func (rs *RethinkStore) FindWaitingMachine(projectid, partitionid, sizeid string
 	}
 
 	var projectMachines metal.Machines
-	err = rs.SearchMachines(&query, &projectMachines)
+	err = ds.SearchMachines(&query, &projectMachines)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err = applyAffinity(available, projectMachines, affinity, excluded)
 	if err != nil {
 		return nil, err
 	}
 
-	spreadCandidates := spreadAcrossRacks(available, projectMachines, placementTags)
-	if len(spreadCandidates) == 0 {
-		return nil, errors.New("no machine available")
+	pc := &PlacementContext{
+		Candidates:      available,
+		ProjectMachines: projectMachines,
+		PlacementTags:   placementTags,
+		Events:          ecMap,
 	}
 
-	oldMachine := spreadCandidates[randomIndex(len(spreadCandidates))]
-	newMachine := oldMachine
-	newMachine.PreAllocated = true
+	for _, sc := range partition.PlacementStrategy.Scorers {
+		if sc.Name != metal.PlacementScorerSwitchCapacity {
+			continue
+		}
+		err = ds.SearchSwitches(&SwitchSearchQuery{PartitionID: &partitionid}, &pc.Switches)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	finalists, reasons := strategy.Finalists(pc)
+	for _, m := range available {
+		if reason, ok := reasons[m.ID]; ok {
+			excluded.add(reason, m)
+		}
+	}
+	if len(finalists) == 0 {
+		return nil, ErrNoMachineAvailable
+	}
+
+	return finalists, nil
+}
+
+// WaitingMachineExplanation explains the election of a waiting machine for an allocation.
+type WaitingMachineExplanation struct {
+	// Machine is the machine which would have been elected, nil if no machine is available.
+	Machine *metal.Machine
+	// Finalists are the machines which are suited equally well, one of them is elected randomly.
+	Finalists metal.Machines
+	// Machines are all machines of the partition.
+	Machines metal.Machines
+	// Exclusions contains the reasons why machines of the partition were not finalists by machine id.
+	Exclusions map[string]string
+	// Message explains why no machine is available.
+	Message string
+}
 
-	err = rs.updateEntity(rs.machineTable(), &newMachine, &oldMachine)
+// ExplainWaitingMachine runs the election of FindWaitingMachine on all machines of the partition and explains
+// why machines were excluded. In contrast to FindWaitingMachine the elected machine is not preallocated.
+func ExplainWaitingMachine(log *zap.SugaredLogger, ds Store, projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*WaitingMachineExplanation, error) {
+	result := &WaitingMachineExplanation{
+		Exclusions: map[string]string{},
+	}
+
+	err := ds.SearchMachines(&MachineSearchQuery{PartitionID: &partitionid}, &result.Machines)
 	if err != nil {
 		return nil, err
 	}
 
-	return &newMachine, nil
+	var candidates metal.Machines
+	for _, m := range result.Machines {
+		reason := waitingMachineExclusion(&m, sizeid)
+		if reason != "" {
+			result.Exclusions[m.ID] = reason
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+
+	result.Finalists, err = electionFinalists(log, ds, candidates, projectid, partitionid, sizeid, placementTags, affinity, requirements, result.Exclusions)
+	if err != nil {
+		if errors.Is(err, ErrNoMachineAvailable) {
+			result.Message = err.Error()
+			return result, nil
+		}
+		return nil, err
+	}
+
+	result.Machine = &result.Finalists[randomIndex(len(result.Finalists))]
+
+	return result, nil
+}
+
+// waitingMachineExclusion returns why the given machine is not a candidate of FindWaitingMachine, an empty
+// string if it is a candidate.
+func waitingMachineExclusion(m *metal.Machine, sizeid string) string {
+	switch {
+	case m.SizeID != sizeid:
+		return fmt.Sprintf("machine has size %q", m.SizeID)
+	case m.Allocation != nil:
+		return "machine is allocated"
+	case m.State.Value != metal.AvailableState:
+		return fmt.Sprintf("machine is in state %s", m.State.Value)
+	case !m.Waiting:
+		return "machine is not waiting for an allocation"
+	case m.PreAllocated:
+		return "machine is preallocated for another allocation"
+	}
+	return ""
+}
+
+// exclusions records the reasons why machines were excluded from an election by machine id, a nil map
+// records nothing.
+type exclusions map[string]string
+
+func (e exclusions) add(reason string, ms ...metal.Machine) {
+	if e == nil {
+		return
+	}
+	for _, m := range ms {
+		e[m.ID] = reason
+	}
 }
 
 func spreadAcrossRacks(allMachines, projectMachines metal.Machines, tags []string) metal.Machines {
@@ -619,3 +1038,20 @@ func intersect[T comparable](a, b []T) []T {
 
 	return c
 }
+
+// containsAll returns true if for every wanted value there is an item with this value as key.
+func containsAll[E any, V comparable](want []V, items []E, key func(E) V) bool {
+	for _, w := range want {
+		found := false
+		for _, item := range items {
+			if key(item) == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
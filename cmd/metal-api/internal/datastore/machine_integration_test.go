@@ -817,6 +817,40 @@ func TestRethinkStore_SearchMachines(t *testing.T) {
 			},
 			wantErr: nil,
 		},
+		{
+			name: "search with limit sorted by name",
+			q: &MachineSearchQuery{
+				Paging: Paging{Limit: pointer.Pointer(uint64(2)), SortBy: pointer.Pointer("-name")},
+			},
+			mock: []*metal.Machine{
+				{Base: metal.Base{ID: "1", Name: "c"}},
+				{Base: metal.Base{ID: "2", Name: "a"}},
+				{Base: metal.Base{ID: "3", Name: "b"}},
+			},
+			want: []*metal.Machine{
+				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1", Name: "c"}}),
+				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3", Name: "b"}}),
+			},
+			wantErr: nil,
+		},
+		{
+			name: "search after cursor",
+			q: &MachineSearchQuery{
+				Paging: Paging{SortBy: pointer.Pointer("name"), Next: pointer.Pointer("eyJ2IjoiYSIsImlkIjoiMiJ9")},
+			},
+			mock: []*metal.Machine{
+				{Base: metal.Base{ID: "1", Name: "c"}},
+				{Base: metal.Base{ID: "2", Name: "a"}},
+				{Base: metal.Base{ID: "3", Name: "b"}},
+				{Base: metal.Base{ID: "4", Name: "a"}},
+			},
+			want: []*metal.Machine{
+				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "1", Name: "c"}}),
+				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "3", Name: "b"}}),
+				tt.defaultBody(&metal.Machine{Base: metal.Base{ID: "4", Name: "a"}}),
+			},
+			wantErr: nil,
+		},
 	}
 
 	for i := range tests {
@@ -0,0 +1,559 @@
+package datastore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/rest"
+	"go.uber.org/zap"
+)
+
+// A MemoryStore is a datastore which holds all entities in memory. It is intended
+// for tests and local development and does not persist anything.
+type MemoryStore struct {
+	*memoryState
+
+	revisionInfo RevisionInfo
+}
+
+// memoryState is the state of a memory store, which is shared with the copies created by WithRevisionInfo.
+type memoryState struct {
+	log *zap.SugaredLogger
+
+	mu        sync.RWMutex
+	tables    map[string]map[string][]byte
+	watchers  map[string]map[*memoryWatcher]bool
+	revisions metal.Revisions
+
+	vrfPool           *memoryIntegerPool
+	asnPool           *memoryIntegerPool
+	partitionASNPools map[string]*memoryIntegerPool
+}
+
+var _ Store = &MemoryStore{}
+
+// NewMemory creates a new in-memory store.
+func NewMemory(log *zap.SugaredLogger) *MemoryStore {
+	ms := &MemoryStore{memoryState: &memoryState{
+		log:      log,
+		tables:   map[string]map[string][]byte{},
+		watchers: map[string]map[*memoryWatcher]bool{},
+
+		partitionASNPools: map[string]*memoryIntegerPool{},
+	}}
+	ms.vrfPool = newMemoryIntegerPool(VRFIntegerPool, DefaultVRFPoolRangeMin, DefaultVRFPoolRangeMax)
+	ms.asnPool = newMemoryIntegerPool(ASNIntegerPool, DefaultASNPoolRangeMin, DefaultASNPoolRangeMax)
+	return ms
+}
+
+// ServiceName returns the name of the datastore.
+func (ms *MemoryStore) ServiceName() string {
+	return "memory"
+}
+
+// Check implements the health interface, the memory store is always healthy.
+func (ms *MemoryStore) Check(ctx context.Context) (rest.HealthStatus, error) {
+	return rest.HealthStatusHealthy, nil
+}
+
+// Connect is a noop for the memory store.
+func (ms *MemoryStore) Connect() error {
+	ms.log.Info("memory store connected")
+	return nil
+}
+
+// Close is a noop for the memory store.
+func (ms *MemoryStore) Close() error {
+	ms.log.Info("memory store disconnected")
+	return nil
+}
+
+// Initialize is a noop for the memory store, tables are created on first write.
+func (ms *MemoryStore) Initialize() error {
+	return nil
+}
+
+// Demote is a noop for the memory store.
+func (ms *MemoryStore) Demote() error {
+	return nil
+}
+
+// Migrate is a noop for the memory store as it always starts with the latest schema.
+func (ms *MemoryStore) Migrate(targetVersion *int, dry bool) error {
+	ms.log.Infow("no database migration required for memory store")
+	return nil
+}
+
+// MigrationVersion returns the latest registered migration version as the memory store always starts with the latest schema.
+func (ms *MemoryStore) MigrationVersion() (int, error) {
+	return LatestMigrationVersion(), nil
+}
+
+// GetVRFPool returns the pool of unique integers for vrfs.
+func (ms *MemoryStore) GetVRFPool() UniqueIntegerPool {
+	return ms.vrfPool
+}
+
+// GetASNPool returns the pool of unique integers for asns.
+func (ms *MemoryStore) GetASNPool() UniqueIntegerPool {
+	return ms.asnPool
+}
+
+// GetPartitionASNPool returns the asn pool of the given partition, the global asn pool is returned
+// if the partition has no pool of its own.
+func (ms *MemoryStore) GetPartitionASNPool(partitionID string) UniqueIntegerPool {
+	pool, ok := ms.partitionASNPools[partitionID]
+	if !ok {
+		return ms.asnPool
+	}
+	return pool
+}
+
+// GetPartitionASNPools returns the asn pools of all partitions which have a pool of their own.
+func (ms *MemoryStore) GetPartitionASNPools() map[string]UniqueIntegerPool {
+	result := map[string]UniqueIntegerPool{}
+	for partitionID, pool := range ms.partitionASNPools {
+		result[partitionID] = pool
+	}
+	return result
+}
+
+// SetVRFPoolRange sets the range of the vrf pool, already acquired integers are forgotten.
+func (ms *MemoryStore) SetVRFPoolRange(min, max uint) {
+	ms.vrfPool = newMemoryIntegerPool(VRFIntegerPool, min, max)
+}
+
+// SetASNPoolRange sets the range of the asn pool, already acquired integers are forgotten.
+func (ms *MemoryStore) SetASNPoolRange(min, max uint) {
+	ms.asnPool = newMemoryIntegerPool(ASNIntegerPool, min, max)
+}
+
+// SetPartitionASNPoolRange gives the partition an asn pool of its own, already acquired integers are forgotten.
+func (ms *MemoryStore) SetPartitionASNPoolRange(partitionID string, min, max uint) {
+	ms.partitionASNPools[partitionID] = newMemoryIntegerPool(PartitionASNIntegerPool(partitionID), min, max)
+}
+
+// entities are stored serialized, such that callers can never modify the stored state
+// through pointers they hold.
+
+func (ms *MemoryStore) findEntityByID(table string, entity any, id string) error {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	raw, ok := ms.tables[table][id]
+	if !ok {
+		return metal.NotFound("no %v with id %q found", getEntityName(entity), id)
+	}
+
+	err := json.Unmarshal(raw, entity)
+	if err != nil {
+		return fmt.Errorf("cannot find %v with id %q in database: %w", getEntityName(entity), id, err)
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) createEntity(table string, entity metal.Entity) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	entity.SetCreated(now)
+	entity.SetChanged(now)
+
+	if entity.GetID() == "" {
+		entity.SetID(uuid.NewString())
+	}
+
+	if _, ok := ms.tables[table][entity.GetID()]; ok {
+		return metal.Conflict("cannot create %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+	}
+
+	err := ms.put(table, entity)
+	if err != nil {
+		return err
+	}
+
+	return ms.recordRevision(metal.RevisionOperationCreate, entity)
+}
+
+func (ms *MemoryStore) upsertEntity(table string, entity metal.Entity) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	if entity.GetCreated().IsZero() {
+		entity.SetCreated(now)
+	}
+	entity.SetChanged(now)
+
+	if entity.GetID() == "" {
+		entity.SetID(uuid.NewString())
+	}
+
+	err := ms.put(table, entity)
+	if err != nil {
+		return err
+	}
+
+	return ms.recordRevision(upsertOperation(entity), entity)
+}
+
+// RestoreEntity writes the entity as it is, keeping its id and timestamps.
+func (ms *MemoryStore) RestoreEntity(entity metal.Entity) error {
+	table, err := tableNameOf(entity)
+	if err != nil {
+		return err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, ok := ms.tables[table][entity.GetID()]; ok {
+		return metal.Conflict("cannot restore %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+	}
+
+	return ms.put(table, entity)
+}
+
+func (ms *MemoryStore) deleteEntity(table string, entity metal.Entity) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	raw, ok := ms.tables[table][entity.GetID()]
+	if !ok {
+		return nil
+	}
+
+	delete(ms.tables[table], entity.GetID())
+	ms.notify(table, ChangeTypeDelete, raw)
+
+	return ms.recordRevision(metal.RevisionOperationDelete, entity)
+}
+
+func (ms *MemoryStore) updateEntity(table string, newEntity metal.Entity, oldEntity metal.Entity) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	raw, ok := ms.tables[table][oldEntity.GetID()]
+	if !ok {
+		return metal.NotFound("cannot update %v (%s): entity does not exist", getEntityName(newEntity), oldEntity.GetID())
+	}
+
+	var current struct {
+		Changed time.Time `json:"changed"`
+	}
+	err := json.Unmarshal(raw, &current)
+	if err != nil {
+		return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
+	}
+
+	if !current.Changed.Equal(oldEntity.GetChanged()) {
+		return metal.Conflict("cannot update %v (%s): %s", getEntityName(newEntity), oldEntity.GetID(), entityAlreadyModifiedErrorMessage)
+	}
+
+	newEntity.SetChanged(time.Now())
+
+	err = ms.put(table, newEntity)
+	if err != nil {
+		return err
+	}
+
+	return ms.recordRevision(metal.RevisionOperationUpdate, newEntity)
+}
+
+// WithRevisionInfo returns a copy of the store which records the given information with all revisions.
+func (ms *MemoryStore) WithRevisionInfo(info RevisionInfo) Store {
+	return &MemoryStore{memoryState: ms.memoryState, revisionInfo: info}
+}
+
+// recordRevision must be called with the write lock held.
+func (ms *MemoryStore) recordRevision(op metal.RevisionOperation, entity metal.Entity) error {
+	revision, err := newRevision(ms.revisionInfo, op, entity)
+	if err != nil || revision == nil {
+		return err
+	}
+
+	revision.ID = uuid.NewString()
+	ms.revisions = append(ms.revisions, *revision)
+
+	return nil
+}
+
+// ListRevisions returns all revisions of an entity sorted by their timestamp.
+func (ms *MemoryStore) ListRevisions(kind, id string) (metal.Revisions, error) {
+	if !isRevisionKind(kind) {
+		return nil, fmt.Errorf("revisions of %s are not recorded", kind)
+	}
+
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	result := metal.Revisions{}
+	for _, revision := range ms.revisions {
+		if revision.EntityKind == kind && revision.EntityID == id {
+			result = append(result, revision)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+
+	return result, nil
+}
+
+// put must be called with the write lock held.
+func (ms *MemoryStore) put(table string, entity metal.Entity) error {
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("cannot store %v (%s): %w", getEntityName(entity), entity.GetID(), err)
+	}
+
+	if _, ok := ms.tables[table]; !ok {
+		ms.tables[table] = map[string][]byte{}
+	}
+
+	changeType := ChangeTypeCreate
+	if _, ok := ms.tables[table][entity.GetID()]; ok {
+		changeType = ChangeTypeUpdate
+	}
+
+	ms.tables[table][entity.GetID()] = raw
+	ms.notify(table, changeType, raw)
+
+	return nil
+}
+
+// memoryWatcher receives the changes of a table. Watchers which do not keep up with the changes
+// are dropped, which closes their channel.
+type memoryWatcher struct {
+	changes chan memoryChange
+}
+
+type memoryChange struct {
+	changeType ChangeType
+	raw        []byte
+}
+
+// notify must be called with the write lock held.
+func (ms *MemoryStore) notify(table string, changeType ChangeType, raw []byte) {
+	for w := range ms.watchers[table] {
+		select {
+		case w.changes <- memoryChange{changeType: changeType, raw: raw}:
+		default:
+			ms.log.Warnw("dropping slow watcher", "table", table)
+			delete(ms.watchers[table], w)
+			close(w.changes)
+		}
+	}
+}
+
+// WatchMachines streams the changes of the machine table.
+func (ms *MemoryStore) WatchMachines(ctx context.Context) (<-chan Change[metal.Machine], error) {
+	return watchMemoryEntities[metal.Machine](ctx, ms, machineTableName)
+}
+
+// WatchProvisioningEventContainers streams the changes of the event table.
+func (ms *MemoryStore) WatchProvisioningEventContainers(ctx context.Context) (<-chan Change[metal.ProvisioningEventContainer], error) {
+	return watchMemoryEntities[metal.ProvisioningEventContainer](ctx, ms, eventTableName)
+}
+
+func watchMemoryEntities[E any](ctx context.Context, ms *MemoryStore, table string) (<-chan Change[E], error) {
+	w := &memoryWatcher{changes: make(chan memoryChange, 1000)}
+
+	ms.mu.Lock()
+	if _, ok := ms.watchers[table]; !ok {
+		ms.watchers[table] = map[*memoryWatcher]bool{}
+	}
+	ms.watchers[table][w] = true
+	ms.mu.Unlock()
+
+	changes := make(chan Change[E])
+	go func() {
+		defer close(changes)
+		defer func() {
+			ms.mu.Lock()
+			defer ms.mu.Unlock()
+			if ms.watchers[table][w] {
+				delete(ms.watchers[table], w)
+				close(w.changes)
+			}
+		}()
+
+		for {
+			select {
+			case c, ok := <-w.changes:
+				if !ok {
+					return
+				}
+				var e E
+				err := json.Unmarshal(c.raw, &e)
+				if err != nil {
+					ms.log.Errorw("cannot decode change", "table", table, "error", err)
+					return
+				}
+				select {
+				case changes <- Change[E]{Type: c.changeType, Entity: &e}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// listMemoryEntities returns all entities of a table which are accepted by the given filter, sorted by id.
+func listMemoryEntities[E any](ms *MemoryStore, table string, filter func(e *E) bool) ([]E, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	ids := make([]string, 0, len(ms.tables[table]))
+	for id := range ms.tables[table] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make([]E, 0, len(ids))
+	for _, id := range ids {
+		var e E
+		err := json.Unmarshal(ms.tables[table][id], &e)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch all entities: %w", err)
+		}
+		if filter != nil && !filter(&e) {
+			continue
+		}
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// findMemoryEntity returns exactly one entity of a table which is accepted by the given filter.
+func findMemoryEntity[E any](ms *MemoryStore, table string, filter func(e *E) bool, entity *E) error {
+	es, err := listMemoryEntities(ms, table, filter)
+	if err != nil {
+		return err
+	}
+
+	switch len(es) {
+	case 0:
+		return metal.NotFound("no %v found", getEntityName(entity))
+	case 1:
+		*entity = es[0]
+		return nil
+	default:
+		return fmt.Errorf("more than one %v exists", getEntityName(entity))
+	}
+}
+
+// memoryIntegerPool is the in-memory counterpart of the IntegerPool.
+type memoryIntegerPool struct {
+	poolType IntegerPoolType
+	min      uint
+	max      uint
+
+	mu       sync.Mutex
+	acquired map[uint]bool
+}
+
+func newMemoryIntegerPool(poolType IntegerPoolType, min, max uint) *memoryIntegerPool {
+	return &memoryIntegerPool{
+		poolType: poolType,
+		min:      min,
+		max:      max,
+		acquired: map[uint]bool{},
+	}
+}
+
+func (ip *memoryIntegerPool) String() string {
+	return ip.poolType.String()
+}
+
+// Range returns the range of integers which is managed by the pool.
+func (ip *memoryIntegerPool) Range() IntegerRange {
+	return IntegerRange{Min: ip.min, Max: ip.max}
+}
+
+// AcquireRandomUniqueInteger returns a random unique integer from the pool.
+func (ip *memoryIntegerPool) AcquireRandomUniqueInteger() (uint, error) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	size := int(ip.max - ip.min + 1)
+	if len(ip.acquired) >= size {
+		return 0, metal.Internal("acquisition of a value failed for exhausted pool")
+	}
+
+	offset := randomIndex(size)
+	for i := 0; i < size; i++ {
+		value := ip.min + uint((offset+i)%size)
+		if !ip.acquired[value] {
+			ip.acquired[value] = true
+			return value, nil
+		}
+	}
+
+	return 0, metal.Internal("acquisition of a value failed for exhausted pool")
+}
+
+// AcquireUniqueInteger returns a unique integer from the pool.
+func (ip *memoryIntegerPool) AcquireUniqueInteger(value uint) (uint, error) {
+	err := verifyRange(value, ip.min, ip.max)
+	if err != nil {
+		return 0, err
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	if ip.acquired[value] {
+		if len(ip.acquired) >= int(ip.max-ip.min+1) {
+			return 0, metal.Internal("acquisition of a value failed for exhausted pool")
+		}
+		return 0, metal.Conflict("integer is already acquired by another")
+	}
+
+	ip.acquired[value] = true
+
+	return value, nil
+}
+
+// ReleaseUniqueInteger returns a unique integer to the pool.
+func (ip *memoryIntegerPool) ReleaseUniqueInteger(id uint) error {
+	err := verifyRange(id, ip.min, ip.max)
+	if err != nil {
+		return err
+	}
+
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	delete(ip.acquired, id)
+
+	return nil
+}
+
+// AcquiredIntegers returns all integers which are currently acquired.
+func (ip *memoryIntegerPool) AcquiredIntegers() ([]uint, error) {
+	ip.mu.Lock()
+	defer ip.mu.Unlock()
+
+	acquired := make([]uint, 0, len(ip.acquired))
+	for value := range ip.acquired {
+		acquired = append(acquired, value)
+	}
+	sort.Slice(acquired, func(i, j int) bool { return acquired[i] < acquired[j] })
+
+	return acquired, nil
+}
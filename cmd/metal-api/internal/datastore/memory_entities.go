@@ -0,0 +1,593 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+)
+
+// FindMachineByID returns a machine for a given id.
+func (ms *MemoryStore) FindMachineByID(id string) (*metal.Machine, error) {
+	var m metal.Machine
+	err := ms.findEntityByID(machineTableName, &m, id)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindMachine returns a machine by the given query, fails if there is no record or multiple records found.
+func (ms *MemoryStore) FindMachine(q *MachineSearchQuery, m *metal.Machine) error {
+	return findMemoryEntity(ms, machineTableName, q.matches, m)
+}
+
+// SearchMachines returns the result of the machines search request query.
+func (ms *MemoryStore) SearchMachines(q *MachineSearchQuery, machines *metal.Machines) error {
+	res, err := listMemoryEntities(ms, machineTableName, q.matches)
+	if err != nil {
+		return err
+	}
+	res, err = pageEntities(&q.Paging, machineSortKeys, res)
+	if err != nil {
+		return err
+	}
+	*machines = res
+	return nil
+}
+
+// ListMachines returns all machines.
+func (ms *MemoryStore) ListMachines() (metal.Machines, error) {
+	return listMemoryEntities[metal.Machine](ms, machineTableName, nil)
+}
+
+// CreateMachine creates a new machine, allocated machines cannot be created.
+func (ms *MemoryStore) CreateMachine(m *metal.Machine) error {
+	if m.Allocation != nil {
+		return fmt.Errorf("a machine cannot be created when it is allocated: %q: %+v", m.ID, *m.Allocation)
+	}
+	return ms.createEntity(machineTableName, m)
+}
+
+// DeleteMachine removes a machine.
+func (ms *MemoryStore) DeleteMachine(m *metal.Machine) error {
+	return ms.deleteEntity(machineTableName, m)
+}
+
+// UpdateMachine replaces a machine if the 'changed' field of the old value equals the stored one.
+func (ms *MemoryStore) UpdateMachine(oldMachine *metal.Machine, newMachine *metal.Machine) error {
+	return ms.updateEntity(machineTableName, newMachine, oldMachine)
+}
+
+// FindWaitingMachine returns an available, not allocated, waiting and alive machine of given size within the given partition.
+func (ms *MemoryStore) FindWaitingMachine(projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*metal.Machine, error) {
+	candidates, err := listMemoryEntities(ms, machineTableName, func(m *metal.Machine) bool {
+		return m.Allocation == nil &&
+			m.PartitionID == partitionid &&
+			m.SizeID == sizeid &&
+			m.State.Value == metal.AvailableState &&
+			m.Waiting &&
+			!m.PreAllocated
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	oldMachine, err := electWaitingMachine(ms.log, ms, candidates, projectid, partitionid, sizeid, placementTags, affinity, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	newMachine := *oldMachine
+	newMachine.PreAllocated = true
+
+	err = ms.updateEntity(machineTableName, &newMachine, oldMachine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &newMachine, nil
+}
+
+// FindSwitch returns a switch for a given id.
+func (ms *MemoryStore) FindSwitch(id string) (*metal.Switch, error) {
+	var s metal.Switch
+	err := ms.findEntityByID(switchTableName, &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSwitches returns all known switches.
+func (ms *MemoryStore) ListSwitches() (metal.Switches, error) {
+	return listMemoryEntities[metal.Switch](ms, switchTableName, nil)
+}
+
+// CreateSwitch creates a new switch.
+func (ms *MemoryStore) CreateSwitch(s *metal.Switch) error {
+	return ms.createEntity(switchTableName, s)
+}
+
+// DeleteSwitch deletes a switch.
+func (ms *MemoryStore) DeleteSwitch(s *metal.Switch) error {
+	return ms.deleteEntity(switchTableName, s)
+}
+
+// UpdateSwitch updates a switch.
+func (ms *MemoryStore) UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.Switch) error {
+	return ms.updateEntity(switchTableName, newSwitch, oldSwitch)
+}
+
+// SearchSwitches searches for switches by the given parameters.
+func (ms *MemoryStore) SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error {
+	res, err := listMemoryEntities(ms, switchTableName, q.matches)
+	if err != nil {
+		return err
+	}
+	res, err = pageEntities(&q.Paging, switchSortKeys, res)
+	if err != nil {
+		return err
+	}
+	*ss = res
+	return nil
+}
+
+// SearchSwitchesConnectedToMachine searches switches that are connected to the given machine.
+func (ms *MemoryStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error) {
+	return searchSwitchesConnectedToMachine(ms, m)
+}
+
+// SetVrfAtSwitches finds the switches connected to the given machine and puts the switch ports into the given vrf.
+func (ms *MemoryStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error) {
+	return setVrfAtSwitches(ms, m, vrf)
+}
+
+// ConnectMachineWithSwitches connects the given machine to the switches it is wired to.
+func (ms *MemoryStore) ConnectMachineWithSwitches(m *metal.Machine) error {
+	return connectMachineWithSwitches(ms, m)
+}
+
+// GetSwitchStatus get SwitchStatus for a given switch id
+func (ms *MemoryStore) GetSwitchStatus(id string) (*metal.SwitchStatus, error) {
+	var ss metal.SwitchStatus
+	err := ms.findEntityByID(switchStatusTableName, &ss, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// ListSwitchStatuses returns the status of all switches.
+func (ms *MemoryStore) ListSwitchStatuses() ([]metal.SwitchStatus, error) {
+	return listMemoryEntities[metal.SwitchStatus](ms, switchStatusTableName, nil)
+}
+
+// SetSwitchStatus create or update the switch status.
+func (ms *MemoryStore) SetSwitchStatus(state *metal.SwitchStatus) error {
+	return ms.upsertEntity(switchStatusTableName, state)
+}
+
+// FindNetworkByID returns an network of a given id.
+func (ms *MemoryStore) FindNetworkByID(id string) (*metal.Network, error) {
+	var nw metal.Network
+	err := ms.findEntityByID(networkTableName, &nw, id)
+	if err != nil {
+		return nil, err
+	}
+	return &nw, nil
+}
+
+// FindNetwork returns a network by the given query, fails if there is no record or multiple records found.
+func (ms *MemoryStore) FindNetwork(q *NetworkSearchQuery, n *metal.Network) error {
+	return findMemoryEntity(ms, networkTableName, q.matches, n)
+}
+
+// SearchNetworks returns the networks that match the given properties
+func (ms *MemoryStore) SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error {
+	res, err := listMemoryEntities(ms, networkTableName, q.matches)
+	if err != nil {
+		return err
+	}
+	res, err = pageEntities(&q.Paging, networkSortKeys, res)
+	if err != nil {
+		return err
+	}
+	*ns = res
+	return nil
+}
+
+// ListNetworks returns all networks.
+func (ms *MemoryStore) ListNetworks() (metal.Networks, error) {
+	return listMemoryEntities[metal.Network](ms, networkTableName, nil)
+}
+
+// CreateNetwork creates a new network.
+func (ms *MemoryStore) CreateNetwork(nw *metal.Network) error {
+	return ms.createEntity(networkTableName, nw)
+}
+
+// DeleteNetwork deletes an network.
+func (ms *MemoryStore) DeleteNetwork(nw *metal.Network) error {
+	return ms.deleteEntity(networkTableName, nw)
+}
+
+// UpdateNetwork updates an network.
+func (ms *MemoryStore) UpdateNetwork(oldNetwork *metal.Network, newNetwork *metal.Network) error {
+	return ms.updateEntity(networkTableName, newNetwork, oldNetwork)
+}
+
+// FindIPByID returns an ip of a given id.
+func (ms *MemoryStore) FindIPByID(id string) (*metal.IP, error) {
+	var ip metal.IP
+	err := ms.findEntityByID(ipTableName, &ip, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ip, nil
+}
+
+// SearchIPs returns the result of the ips search request query.
+func (ms *MemoryStore) SearchIPs(q *IPSearchQuery, ips *metal.IPs) error {
+	res, err := listMemoryEntities(ms, ipTableName, q.matches)
+	if err != nil {
+		return err
+	}
+	res, err = pageEntities(&q.Paging, ipSortKeys, res)
+	if err != nil {
+		return err
+	}
+	*ips = res
+	return nil
+}
+
+// ListIPs returns all ips.
+func (ms *MemoryStore) ListIPs() (metal.IPs, error) {
+	return listMemoryEntities[metal.IP](ms, ipTableName, nil)
+}
+
+// CreateIP creates a new ip.
+func (ms *MemoryStore) CreateIP(ip *metal.IP) error {
+	if ip.AllocationUUID == "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			return fmt.Errorf("unable to create uuid for IP allocation: %w", err)
+		}
+		ip.AllocationUUID = u.String()
+	}
+	return ms.createEntity(ipTableName, ip)
+}
+
+// DeleteIP deletes an ip.
+func (ms *MemoryStore) DeleteIP(ip *metal.IP) error {
+	return ms.deleteEntity(ipTableName, ip)
+}
+
+// UpdateIP updates an ip.
+func (ms *MemoryStore) UpdateIP(oldIP *metal.IP, newIP *metal.IP) error {
+	return ms.updateEntity(ipTableName, newIP, oldIP)
+}
+
+// GetImage return a image for a given id without semver matching.
+func (ms *MemoryStore) GetImage(id string) (*metal.Image, error) {
+	var i metal.Image
+	err := ms.findEntityByID(imageTableName, &i, id)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// FindImages returns all images for the given image id.
+func (ms *MemoryStore) FindImages(id string) ([]metal.Image, error) {
+	return findImages(ms, id)
+}
+
+// FindImage returns an image for the given image id.
+func (ms *MemoryStore) FindImage(id string) (*metal.Image, error) {
+	return findImage(ms, id)
+}
+
+// ListImages returns all images.
+func (ms *MemoryStore) ListImages() (metal.Images, error) {
+	return listMemoryEntities[metal.Image](ms, imageTableName, nil)
+}
+
+// CreateImage creates a new image.
+func (ms *MemoryStore) CreateImage(i *metal.Image) error {
+	return ms.createEntity(imageTableName, i)
+}
+
+// DeleteImage deletes an image.
+func (ms *MemoryStore) DeleteImage(i *metal.Image) error {
+	return ms.deleteEntity(imageTableName, i)
+}
+
+// UpdateImage updates an image.
+func (ms *MemoryStore) UpdateImage(oldImage *metal.Image, newImage *metal.Image) error {
+	return ms.updateEntity(imageTableName, newImage, oldImage)
+}
+
+// SearchImages searches for images by the given parameters.
+func (ms *MemoryStore) SearchImages(q *ImageSearchQuery, images *metal.Images) error {
+	res, err := listMemoryEntities(ms, imageTableName, q.matches)
+	if err != nil {
+		return err
+	}
+	*images = res
+	return nil
+}
+
+// DeleteOrphanImages deletes Images which are no longer allocated by a machine and older than allowed.
+func (ms *MemoryStore) DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error) {
+	return deleteOrphanImages(ms, images, machines)
+}
+
+// FindSize return a size for a given id.
+func (ms *MemoryStore) FindSize(id string) (*metal.Size, error) {
+	var s metal.Size
+	err := ms.findEntityByID(sizeTableName, &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSizes returns all sizes.
+func (ms *MemoryStore) ListSizes() (metal.Sizes, error) {
+	return listMemoryEntities[metal.Size](ms, sizeTableName, nil)
+}
+
+// CreateSize creates a new size.
+func (ms *MemoryStore) CreateSize(size *metal.Size) error {
+	return ms.createEntity(sizeTableName, size)
+}
+
+// DeleteSize deletes a size.
+func (ms *MemoryStore) DeleteSize(size *metal.Size) error {
+	return ms.deleteEntity(sizeTableName, size)
+}
+
+// UpdateSize updates a size.
+func (ms *MemoryStore) UpdateSize(oldSize *metal.Size, newSize *metal.Size) error {
+	return ms.updateEntity(sizeTableName, newSize, oldSize)
+}
+
+// FromHardware tries to find a size which matches the given hardware specs.
+func (ms *MemoryStore) FromHardware(hw metal.MachineHardware) (*metal.Size, []*metal.SizeMatchingLog, error) {
+	return fromHardware(ms.log, ms, hw)
+}
+
+// FindPartition return a partition for the given id.
+func (ms *MemoryStore) FindPartition(id string) (*metal.Partition, error) {
+	var p metal.Partition
+	err := ms.findEntityByID(partitionTableName, &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPartitions returns all partition.
+func (ms *MemoryStore) ListPartitions() (metal.Partitions, error) {
+	return listMemoryEntities[metal.Partition](ms, partitionTableName, nil)
+}
+
+// CreatePartition creates a new partition.
+func (ms *MemoryStore) CreatePartition(p *metal.Partition) error {
+	return ms.createEntity(partitionTableName, p)
+}
+
+// DeletePartition delets a partition.
+func (ms *MemoryStore) DeletePartition(p *metal.Partition) error {
+	return ms.deleteEntity(partitionTableName, p)
+}
+
+// UpdatePartition updates a partition.
+func (ms *MemoryStore) UpdatePartition(oldPartition *metal.Partition, newPartition *metal.Partition) error {
+	return ms.updateEntity(partitionTableName, newPartition, oldPartition)
+}
+
+// ListProvisioningEventContainers returns all machine provisioning event containers.
+func (ms *MemoryStore) ListProvisioningEventContainers() (metal.ProvisioningEventContainers, error) {
+	return listMemoryEntities[metal.ProvisioningEventContainer](ms, eventTableName, nil)
+}
+
+// FindProvisioningEventContainer finds a provisioning event container to a given machine id.
+func (ms *MemoryStore) FindProvisioningEventContainer(id string) (*metal.ProvisioningEventContainer, error) {
+	var e metal.ProvisioningEventContainer
+	err := ms.findEntityByID(eventTableName, &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// UpdateProvisioningEventContainer updates a provisioning event container.
+func (ms *MemoryStore) UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error {
+	return ms.updateEntity(eventTableName, new, old)
+}
+
+// CreateProvisioningEventContainer creates a new provisioning event container.
+func (ms *MemoryStore) CreateProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ms.createEntity(eventTableName, ec)
+}
+
+// UpsertProvisioningEventContainer inserts a machine's event container.
+func (ms *MemoryStore) UpsertProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ms.upsertEntity(eventTableName, ec)
+}
+
+// ProvisioningEventForMachine applies the given provisioning event to the event container of a machine.
+func (ms *MemoryStore) ProvisioningEventForMachine(log *zap.SugaredLogger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
+	return provisioningEventForMachine(log, ms, event, machineID)
+}
+
+// FindFilesystemLayout return a filesystemlayout for a given id.
+func (ms *MemoryStore) FindFilesystemLayout(id string) (*metal.FilesystemLayout, error) {
+	var fl metal.FilesystemLayout
+	err := ms.findEntityByID(filesystemLayoutTableName, &fl, id)
+	if err != nil {
+		return nil, err
+	}
+	return &fl, nil
+}
+
+// ListFilesystemLayouts returns all filesystemlayouts.
+func (ms *MemoryStore) ListFilesystemLayouts() (metal.FilesystemLayouts, error) {
+	return listMemoryEntities[metal.FilesystemLayout](ms, filesystemLayoutTableName, nil)
+}
+
+// CreateFilesystemLayout creates a new filesystemlayout.
+func (ms *MemoryStore) CreateFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ms.createEntity(filesystemLayoutTableName, fl)
+}
+
+// DeleteFilesystemLayout deletes a filesystemlayout.
+func (ms *MemoryStore) DeleteFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ms.deleteEntity(filesystemLayoutTableName, fl)
+}
+
+// UpdateFilesystemLayout updates a filesystemlayout.
+func (ms *MemoryStore) UpdateFilesystemLayout(oldFilesystemLayout *metal.FilesystemLayout, newFilesystemLayout *metal.FilesystemLayout) error {
+	return ms.updateEntity(filesystemLayoutTableName, newFilesystemLayout, oldFilesystemLayout)
+}
+
+// FindSizeImageConstraint return a size image constraint for a given size id.
+func (ms *MemoryStore) FindSizeImageConstraint(sizeID string) (*metal.SizeImageConstraint, error) {
+	var ic metal.SizeImageConstraint
+	err := ms.findEntityByID(sizeImageConstraintTableName, &ic, sizeID)
+	if err != nil {
+		return nil, err
+	}
+	return &ic, nil
+}
+
+// ListSizeImageConstraints returns all size image constraints.
+func (ms *MemoryStore) ListSizeImageConstraints() (metal.SizeImageConstraints, error) {
+	return listMemoryEntities[metal.SizeImageConstraint](ms, sizeImageConstraintTableName, nil)
+}
+
+// CreateSizeImageConstraint creates a new size image constraint.
+func (ms *MemoryStore) CreateSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ms.createEntity(sizeImageConstraintTableName, ic)
+}
+
+// DeleteSizeImageConstraint deletes a size image constraint.
+func (ms *MemoryStore) DeleteSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ms.deleteEntity(sizeImageConstraintTableName, ic)
+}
+
+// UpdateSizeImageConstraint updates a size image constraint.
+func (ms *MemoryStore) UpdateSizeImageConstraint(oldSizeImageConstraint *metal.SizeImageConstraint, newSizeImageConstraint *metal.SizeImageConstraint) error {
+	return ms.updateEntity(sizeImageConstraintTableName, newSizeImageConstraint, oldSizeImageConstraint)
+}
+
+// FindReservation returns a reservation for a given id.
+func (ms *MemoryStore) FindReservation(id string) (*metal.Reservation, error) {
+	var r metal.Reservation
+	err := ms.findEntityByID(reservationTableName, &r, id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListReservations returns all reservations.
+func (ms *MemoryStore) ListReservations() (metal.Reservations, error) {
+	return listMemoryEntities[metal.Reservation](ms, reservationTableName, nil)
+}
+
+// CreateReservation creates a new reservation.
+func (ms *MemoryStore) CreateReservation(r *metal.Reservation) error {
+	return ms.createEntity(reservationTableName, r)
+}
+
+// DeleteReservation deletes a reservation.
+func (ms *MemoryStore) DeleteReservation(r *metal.Reservation) error {
+	return ms.deleteEntity(reservationTableName, r)
+}
+
+// UpdateReservation updates a reservation.
+func (ms *MemoryStore) UpdateReservation(oldReservation *metal.Reservation, newReservation *metal.Reservation) error {
+	return ms.updateEntity(reservationTableName, newReservation, oldReservation)
+}
+
+// FindPendingAllocation returns a pending allocation for a given id.
+func (ms *MemoryStore) FindPendingAllocation(id string) (*metal.PendingAllocation, error) {
+	var p metal.PendingAllocation
+	err := ms.findEntityByID(pendingAllocationTableName, &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingAllocations returns all pending allocations.
+func (ms *MemoryStore) ListPendingAllocations() (metal.PendingAllocations, error) {
+	return listMemoryEntities[metal.PendingAllocation](ms, pendingAllocationTableName, nil)
+}
+
+// CreatePendingAllocation creates a new pending allocation.
+func (ms *MemoryStore) CreatePendingAllocation(p *metal.PendingAllocation) error {
+	return ms.createEntity(pendingAllocationTableName, p)
+}
+
+// DeletePendingAllocation deletes a pending allocation.
+func (ms *MemoryStore) DeletePendingAllocation(p *metal.PendingAllocation) error {
+	return ms.deleteEntity(pendingAllocationTableName, p)
+}
+
+// UpdatePendingAllocation updates a pending allocation.
+func (ms *MemoryStore) UpdatePendingAllocation(oldPendingAllocation *metal.PendingAllocation, newPendingAllocation *metal.PendingAllocation) error {
+	return ms.updateEntity(pendingAllocationTableName, newPendingAllocation, oldPendingAllocation)
+}
+
+// FindRollout returns a rollout for a given id.
+func (ms *MemoryStore) FindRollout(id string) (*metal.Rollout, error) {
+	var r metal.Rollout
+	err := ms.findEntityByID(rolloutTableName, &r, id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRollouts returns all rollouts.
+func (ms *MemoryStore) ListRollouts() (metal.Rollouts, error) {
+	return listMemoryEntities[metal.Rollout](ms, rolloutTableName, nil)
+}
+
+// CreateRollout creates a new rollout.
+func (ms *MemoryStore) CreateRollout(r *metal.Rollout) error {
+	return ms.createEntity(rolloutTableName, r)
+}
+
+// UpdateRollout updates a rollout.
+func (ms *MemoryStore) UpdateRollout(oldRollout *metal.Rollout, newRollout *metal.Rollout) error {
+	return ms.updateEntity(rolloutTableName, newRollout, oldRollout)
+}
+
+// ListPowerSamples returns all power samples.
+func (ms *MemoryStore) ListPowerSamples() (metal.PowerSamples, error) {
+	return listMemoryEntities[metal.PowerSample](ms, powerSampleTableName, nil)
+}
+
+// SearchPowerSamples returns the power samples recorded within the given period.
+func (ms *MemoryStore) SearchPowerSamples(from, to time.Time) (metal.PowerSamples, error) {
+	return listMemoryEntities(ms, powerSampleTableName, func(s *metal.PowerSample) bool {
+		return !s.Timestamp.Before(from) && !s.Timestamp.After(to)
+	})
+}
+
+// CreatePowerSample creates a new power sample.
+func (ms *MemoryStore) CreatePowerSample(s *metal.PowerSample) error {
+	return ms.createEntity(powerSampleTableName, s)
+}
+
+// DeletePowerSample deletes a power sample.
+func (ms *MemoryStore) DeletePowerSample(s *metal.PowerSample) error {
+	return ms.deleteEntity(powerSampleTableName, s)
+}
@@ -0,0 +1,167 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestMemoryStore_CRUD(t *testing.T) {
+	ms := NewMemory(zaptest.NewLogger(t).Sugar())
+
+	_, err := ms.FindMachineByID("1")
+	assert.True(t, metal.IsNotFound(err), "expected not found, got %v", err)
+
+	m := &metal.Machine{Base: metal.Base{ID: "1"}, PartitionID: "p1", SizeID: "s1", Tags: []string{"a"}}
+	require.NoError(t, ms.CreateMachine(m))
+	assert.False(t, m.Created.IsZero())
+
+	err = ms.CreateMachine(m)
+	assert.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	err = ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "2"}, Allocation: &metal.MachineAllocation{}})
+	assert.Error(t, err)
+
+	old, err := ms.FindMachineByID("1")
+	require.NoError(t, err)
+
+	// mutating the returned entity must not mutate the stored state
+	old.Tags[0] = "b"
+	stored, err := ms.FindMachineByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a"}, stored.Tags)
+
+	newMachine := *stored
+	newMachine.RackID = "r1"
+	require.NoError(t, ms.UpdateMachine(stored, &newMachine))
+
+	// updating with the stale entity must fail
+	staleMachine := *stored
+	staleMachine.RackID = "r2"
+	err = ms.UpdateMachine(stored, &staleMachine)
+	assert.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	updated, err := ms.FindMachineByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, "r1", updated.RackID)
+
+	require.NoError(t, ms.DeleteMachine(updated))
+	_, err = ms.FindMachineByID("1")
+	assert.True(t, metal.IsNotFound(err), "expected not found, got %v", err)
+}
+
+func TestMemoryStore_Search(t *testing.T) {
+	ms := NewMemory(zaptest.NewLogger(t).Sugar())
+
+	for _, m := range []metal.Machine{
+		{Base: metal.Base{ID: "1"}, PartitionID: "p1", Tags: []string{"a", "b"}},
+		{Base: metal.Base{ID: "2"}, PartitionID: "p1", Tags: []string{"a"}},
+		{Base: metal.Base{ID: "3"}, PartitionID: "p2"},
+	} {
+		m := m
+		require.NoError(t, ms.CreateMachine(&m))
+	}
+
+	partition := "p1"
+	var machines metal.Machines
+	require.NoError(t, ms.SearchMachines(&MachineSearchQuery{PartitionID: &partition, Tags: []string{"b"}}, &machines))
+	require.Len(t, machines, 1)
+	assert.Equal(t, "1", machines[0].ID)
+
+	project := "p"
+	require.NoError(t, ms.SearchMachines(&MachineSearchQuery{AllocationProject: &project}, &machines))
+	assert.Empty(t, machines)
+
+	var m metal.Machine
+	err := ms.FindMachine(&MachineSearchQuery{PartitionID: &partition}, &m)
+	assert.Error(t, err)
+
+	id := "3"
+	require.NoError(t, ms.FindMachine(&MachineSearchQuery{ID: &id}, &m))
+	assert.Equal(t, "p2", m.PartitionID)
+
+	require.NoError(t, ms.CreateNetwork(&metal.Network{
+		Base:     metal.Base{ID: "n1"},
+		Prefixes: metal.Prefixes{{IP: "10.0.0.0", Length: "8"}},
+		Labels:   map[string]string{"a": "b"},
+	}))
+
+	var nws metal.Networks
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{Prefixes: []string{"10.0.0.0/8"}, Labels: map[string]string{"a": "b"}}, &nws))
+	assert.Len(t, nws, 1)
+	require.NoError(t, ms.SearchNetworks(&NetworkSearchQuery{Prefixes: []string{"10.0.0.0/16"}}, &nws))
+	assert.Empty(t, nws)
+}
+
+func TestMemoryStore_IntegerPool(t *testing.T) {
+	ms := NewMemory(zaptest.NewLogger(t).Sugar())
+	ms.SetVRFPoolRange(10, 11)
+	pool := ms.GetVRFPool()
+
+	_, err := pool.AcquireUniqueInteger(12)
+	assert.Error(t, err)
+
+	got, err := pool.AcquireUniqueInteger(10)
+	require.NoError(t, err)
+	assert.Equal(t, uint(10), got)
+
+	got, err = pool.AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+	assert.Equal(t, uint(11), got)
+
+	_, err = pool.AcquireRandomUniqueInteger()
+	assert.True(t, metal.IsInternal(err), "expected exhausted pool, got %v", err)
+
+	require.NoError(t, pool.ReleaseUniqueInteger(10))
+
+	got, err = pool.AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+	assert.Equal(t, uint(10), got)
+}
+
+func TestMemoryStore_WatchMachineEvents(t *testing.T) {
+	ms := NewMemory(zaptest.NewLogger(t).Sugar())
+
+	require.NoError(t, ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "0"}, PartitionID: "p1"}))
+	old, err := ms.FindMachineByID("0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	partition := "p1"
+	events, err := WatchMachineEvents(ctx, ms.log, ms, &MachineSearchQuery{PartitionID: &partition}, old.Changed.UnixNano()-1)
+	require.NoError(t, err)
+
+	// the machine which changed after the given resource version is sent first
+	e := <-events
+	assert.Equal(t, ChangeTypeCreate, e.Type)
+	assert.Equal(t, "0", e.Machine.ID)
+
+	require.NoError(t, ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "1"}, PartitionID: "p2"}))
+	require.NoError(t, ms.CreateMachine(&metal.Machine{Base: metal.Base{ID: "2"}, PartitionID: "p1"}))
+
+	e = <-events
+	assert.Equal(t, ChangeTypeCreate, e.Type)
+	assert.Equal(t, "2", e.Machine.ID)
+
+	require.NoError(t, ms.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: "2"}}))
+
+	e = <-events
+	assert.Equal(t, ChangeTypeUpdate, e.Type)
+	assert.Equal(t, "2", e.Machine.ID)
+
+	require.NoError(t, ms.DeleteMachine(e.Machine))
+
+	e = <-events
+	assert.Equal(t, ChangeTypeDelete, e.Type)
+	assert.Equal(t, "2", e.Machine.ID)
+
+	cancel()
+	for range events {
+	}
+}
@@ -21,6 +21,13 @@ type Migration struct {
 	Name    string
 	Version int
 	Up      MigrateFunc
+	// Down reverts the changes of Up, it is optional. Migrations without a down step cannot be reverted.
+	Down MigrateFunc
+}
+
+// Reversible returns true if the migration can be reverted.
+func (m Migration) Reversible() bool {
+	return m.Down != nil
 }
 
 // MigrationVersionEntry is a version entry in the migration database
@@ -83,7 +90,46 @@ func (ms Migrations) Between(current int, target *int) (Migrations, error) {
 	return result, nil
 }
 
+// Reverting returns the migrations that have to be reverted to get from the given current version
+// down to the target version (target version not contained), sorted from the newest to the oldest
+// migration. It returns an error if one of these migrations cannot be reverted.
+func (ms Migrations) Reverting(current int, target int) (Migrations, error) {
+	if target < 0 {
+		return nil, fmt.Errorf("target version (=%d) must not be negative", target)
+	}
+
+	targetFound := target == 0
+	var result Migrations
+	for _, m := range ms {
+		if m.Version == target {
+			targetFound = true
+		}
+
+		if m.Version <= target || m.Version > current {
+			continue
+		}
+
+		if !m.Reversible() {
+			return nil, fmt.Errorf("migration %d (%s) cannot be reverted", m.Version, m.Name)
+		}
+
+		result = append(result, m)
+	}
+
+	if !targetFound {
+		return nil, errors.New("target version not found")
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version > result[j].Version
+	})
+
+	return result, nil
+}
+
 // Migrate runs database migrations and puts the database into read only mode for demoted runtime users.
+// If the target version is smaller than the current version, the down steps of the applied migrations
+// are run in reverse order until the target version is reached.
 func (rs *RethinkStore) Migrate(targetVersion *int, dry bool) error {
 	_, err := rs.migrationTable().Insert(MigrationVersionEntry{Version: 0}, r.InsertOpts{
 		Conflict: "replace",
@@ -92,36 +138,37 @@ func (rs *RethinkStore) Migrate(targetVersion *int, dry bool) error {
 		return err
 	}
 
-	results, err := rs.migrationTable().Max().Run(rs.session)
+	current, err := rs.MigrationVersion()
 	if err != nil {
 		return err
 	}
-	defer results.Close()
 
-	var current MigrationVersionEntry
-	err = results.One(&current)
-	if err != nil {
-		return err
-	}
+	down := targetVersion != nil && *targetVersion < current
 
-	if targetVersion != nil && *targetVersion < current.Version {
-		return fmt.Errorf("target version (=%d) smaller than current version (=%d) and down migrations not supported", *targetVersion, current.Version)
+	var ms Migrations
+	if down {
+		ms, err = migrations.Reverting(current, *targetVersion)
+	} else {
+		ms, err = migrations.Between(current, targetVersion)
 	}
-	ms, err := migrations.Between(current.Version, targetVersion)
 	if err != nil {
 		return err
 	}
 
 	if len(ms) == 0 {
-		rs.log.Infow("no database migration required", "current-version", current.Version)
+		rs.log.Infow("no database migration required", "current-version", current)
 		return nil
 	}
 
-	rs.log.Infow("database migration required", "current-version", current.Version, "newer-versions", len(ms), "target-version", ms[len(ms)-1].Version)
+	if down {
+		rs.log.Infow("database down migration required", "current-version", current, "reverted-versions", len(ms), "target-version", *targetVersion)
+	} else {
+		rs.log.Infow("database migration required", "current-version", current, "newer-versions", len(ms), "target-version", ms[len(ms)-1].Version)
+	}
 
 	if dry {
 		for _, m := range ms {
-			rs.log.Infow("database migration dry run", "version", m.Version, "name", m.Name)
+			rs.log.Infow("database migration dry run", "version", m.Version, "name", m.Name, "down", down)
 		}
 		return nil
 	}
@@ -140,6 +187,20 @@ func (rs *RethinkStore) Migrate(targetVersion *int, dry bool) error {
 	}()
 
 	for _, m := range ms {
+		if down {
+			rs.log.Infow("reverting database migration", "version", m.Version, "name", m.Name)
+			err = m.Down(rs.db(), rs.session, rs)
+			if err != nil {
+				return fmt.Errorf("error reverting database migration: %w", err)
+			}
+
+			_, err := rs.migrationTable().Get(m.Version).Delete().RunWrite(rs.session)
+			if err != nil {
+				return fmt.Errorf("error updating database migration version: %w", err)
+			}
+			continue
+		}
+
 		rs.log.Infow("running database migration", "version", m.Version, "name", m.Name)
 		err = m.Up(rs.db(), rs.session, rs)
 		if err != nil {
@@ -158,3 +219,49 @@ func (rs *RethinkStore) Migrate(targetVersion *int, dry bool) error {
 
 	return nil
 }
+
+// RegisteredMigrations returns all registered migrations sorted by their version.
+func RegisteredMigrations() Migrations {
+	migrationRegisterLock.Lock()
+	defer migrationRegisterLock.Unlock()
+
+	result := make(Migrations, len(migrations))
+	copy(result, migrations)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+
+	return result
+}
+
+// LatestMigrationVersion returns the version of the newest registered migration, it is 0 if no migrations are registered.
+func LatestMigrationVersion() int {
+	migrationRegisterLock.Lock()
+	defer migrationRegisterLock.Unlock()
+
+	latest := 0
+	for _, m := range migrations {
+		if m.Version > latest {
+			latest = m.Version
+		}
+	}
+	return latest
+}
+
+// MigrationVersion returns the version of the latest migration which was applied to the database,
+// it is 0 if the database was never migrated.
+func (rs *RethinkStore) MigrationVersion() (int, error) {
+	var entries []MigrationVersionEntry
+	err := rs.listEntities(rs.migrationTable(), &entries)
+	if err != nil {
+		return 0, err
+	}
+
+	version := 0
+	for _, e := range entries {
+		if e.Version > version {
+			version = e.Version
+		}
+	}
+	return version, nil
+}
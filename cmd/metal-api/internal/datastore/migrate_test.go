@@ -3,6 +3,8 @@ package datastore
 import (
 	"reflect"
 	"testing"
+
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 )
 
 func TestMigrations_Between(t *testing.T) {
@@ -163,6 +165,67 @@ func TestMigrations_Between(t *testing.T) {
 	}
 }
 
+func TestMigrations_Reverting(t *testing.T) {
+	noop := func(db *r.Term, session r.QueryExecutor, rs *RethinkStore) error { return nil }
+
+	ms := Migrations{
+		{Name: "migration 2", Version: 2, Down: noop},
+		{Name: "migration 4", Version: 4, Down: noop},
+		{Name: "migration 1", Version: 1},
+	}
+
+	tests := []struct {
+		name    string
+		current int
+		target  int
+		want    []int
+		wantErr bool
+	}{
+		{
+			name:    "revert down to target version, newest first",
+			current: 4,
+			target:  1,
+			want:    []int{4, 2},
+		},
+		{
+			name:    "migrations above the current version are not reverted",
+			current: 2,
+			target:  1,
+			want:    []int{2},
+		},
+		{
+			name:    "error on irreversible migration",
+			current: 4,
+			target:  0,
+			wantErr: true,
+		},
+		{
+			name:    "error on unknown target version",
+			current: 4,
+			target:  3,
+			wantErr: true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ms.Reverting(tt.current, tt.target)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Migrations.Reverting() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			var versions []int
+			for _, m := range got {
+				versions = append(versions, m.Version)
+			}
+			if !reflect.DeepEqual(versions, tt.want) {
+				t.Errorf("Migrations.Reverting() = %v, want %v", versions, tt.want)
+			}
+		})
+	}
+}
+
 func intPtr(i int) *int {
 	return &i
 }
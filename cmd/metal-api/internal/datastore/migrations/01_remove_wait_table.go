@@ -28,5 +28,23 @@ func init() {
 			}
 			return err
 		},
+		Down: func(db *r.Term, session r.QueryExecutor, rs *datastore.RethinkStore) error {
+			res, err := db.TableList().Contains("wait").Run(session)
+			if err != nil {
+				return err
+			}
+			defer res.Close()
+
+			var exists bool
+			err = res.One(&exists)
+			if err != nil {
+				return err
+			}
+
+			if !exists {
+				_, err = db.TableCreate("wait").RunWrite(session)
+			}
+			return err
+		},
 	})
 }
@@ -37,5 +37,9 @@ func init() {
 			}
 			return nil
 		},
+		Down: func(db *r.Term, session r.QueryExecutor, rs *datastore.RethinkStore) error {
+			// older versions ignore the allocation uuid, so it can be kept
+			return nil
+		},
 	})
 }
@@ -38,6 +38,10 @@ func init() {
 			}
 			return nil
 		},
+		Down: func(db *r.Term, session r.QueryExecutor, rs *datastore.RethinkStore) error {
+			// older versions ignore the allocation role, so it can be kept
+			return nil
+		},
 	})
 }
 
@@ -43,5 +43,9 @@ func init() {
 			}
 			return nil
 		},
+		Down: func(db *r.Term, session r.QueryExecutor, rs *datastore.RethinkStore) error {
+			// sorted events are valid for older versions as well
+			return nil
+		},
 	})
 }
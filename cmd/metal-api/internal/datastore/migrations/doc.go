@@ -13,8 +13,10 @@
 // this use-case has not been implemented and it possibly requires more difficult
 // deployment orchestration to apply a migration.
 //
-// We also do not support down migrations for the time being because it also makes
-// things more complicated than they need to be.
+// Migrations can optionally define a down step which reverts the changes of the up step,
+// e.g. to roll back a failed rollout. A database can only be migrated down to a version
+// if all migrations above this version have a down step. Down migrations must be run
+// *before* the rollback of the clients, such that the old clients find their schema.
 //
 // Please ensure that your migrations are idempotent (they need to work for existing and
 // for fresh deployments). Check the state before modifying it.
@@ -99,4 +99,19 @@ func Test_Migration(t *testing.T) {
 	assert.Equal(t, ec.LastEventTime.Unix(), lastEventTime.Unix())
 	assert.Equal(t, ec.Events[0].Time.Unix(), lastEventTime.Unix())
 	assert.Equal(t, ec.Events[1].Time.Unix(), now.Unix())
+
+	target := 0
+	err = rs.Migrate(&target, false)
+	require.NoError(t, err)
+
+	version, err := rs.MigrationVersion()
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+
+	err = rs.Migrate(nil, false)
+	require.NoError(t, err)
+
+	version, err = rs.MigrationVersion()
+	require.NoError(t, err)
+	assert.Equal(t, datastore.LatestMigrationVersion(), version)
 }
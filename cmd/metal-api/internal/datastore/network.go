@@ -22,6 +22,24 @@ type NetworkSearchQuery struct {
 	Vrf                 *int64            `json:"vrf" optional:"true"`
 	ParentNetworkID     *string           `json:"parentnetworkid" optional:"true"`
 	Labels              map[string]string `json:"labels" optional:"true"`
+
+	Paging
+}
+
+// networkSortKeys are the fields networks can be sorted by in a search.
+var networkSortKeys = sortKeys{
+	"id":          idSortKey,
+	"name":        {path: []string{"name"}},
+	"partitionid": {path: []string{"partitionid"}},
+	"projectid":   {path: []string{"projectid"}},
+	"created":     {path: []string{"created"}, time: true},
+	"changed":     {path: []string{"changed"}, time: true},
+}
+
+// NextCursor returns the cursor to continue the search after the given page of networks, it returns
+// nil if there are no further networks.
+func (p *NetworkSearchQuery) NextCursor(ns metal.Networks) (*string, error) {
+	return nextCursor(&p.Paging, networkSortKeys, ns)
 }
 
 // GenerateTerm generates the project search query term.
@@ -129,6 +147,75 @@ func (p *NetworkSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
 	return &q
 }
 
+// matches returns true if the given network is matched by the query, it has
+// to be kept in line with generateTerm.
+func (p *NetworkSearchQuery) matches(nw *metal.Network) bool {
+	if p.ID != nil && nw.ID != *p.ID {
+		return false
+	}
+
+	if p.ProjectID != nil && nw.ProjectID != *p.ProjectID {
+		return false
+	}
+
+	if p.PartitionID != nil && nw.PartitionID != *p.PartitionID {
+		return false
+	}
+
+	if p.ParentNetworkID != nil && nw.ParentNetworkID != *p.ParentNetworkID {
+		return false
+	}
+
+	if p.Name != nil && nw.Name != *p.Name {
+		return false
+	}
+
+	if p.Vrf != nil && int64(nw.Vrf) != *p.Vrf {
+		return false
+	}
+
+	if p.Nat != nil && nw.Nat != *p.Nat {
+		return false
+	}
+
+	if p.PrivateSuper != nil && nw.PrivateSuper != *p.PrivateSuper {
+		return false
+	}
+
+	if p.Underlay != nil && nw.Underlay != *p.Underlay {
+		return false
+	}
+
+	for k, v := range p.Labels {
+		if got, ok := nw.Labels[k]; !ok || got != v {
+			return false
+		}
+	}
+
+	if !prefixesMatch(p.Prefixes, nw.Prefixes) {
+		return false
+	}
+
+	if !prefixesMatch(p.DestinationPrefixes, nw.DestinationPrefixes) {
+		return false
+	}
+
+	return true
+}
+
+func prefixesMatch(cidrs []string, prefixes metal.Prefixes) bool {
+	for _, cidr := range cidrs {
+		ip, length := utils.SplitCIDR(cidr)
+		if !containsAll([]string{ip}, prefixes, func(p metal.Prefix) string { return p.IP }) {
+			return false
+		}
+		if length != nil && !containsAll([]string{strconv.Itoa(*length)}, prefixes, func(p metal.Prefix) string { return p.Length }) {
+			return false
+		}
+	}
+	return true
+}
+
 // FindNetworkByID returns an network of a given id.
 func (rs *RethinkStore) FindNetworkByID(id string) (*metal.Network, error) {
 	var nw metal.Network
@@ -146,7 +233,11 @@ func (rs *RethinkStore) FindNetwork(q *NetworkSearchQuery, n *metal.Network) err
 
 // SearchNetworks returns the networks that match the given properties
 func (rs *RethinkStore) SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error {
-	return rs.searchEntities(q.generateTerm(rs), ns)
+	term, err := q.Paging.generateTerm(networkSortKeys, q.generateTerm(rs))
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(term, ns)
 }
 
 // ListNetworks returns all networks.
@@ -0,0 +1,299 @@
+package datastore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// Paging restricts the result of a search to a page of entities. If none of the fields is set,
+// a search returns all matching entities at once.
+type Paging struct {
+	Limit  *uint64 `json:"limit" description:"the maximum number of entities to return, the cursor for the next page is returned in the X-Next-Cursor header" optional:"true"`
+	Next   *string `json:"next" description:"the cursor returned by the previous search, the search continues after the last entity of the previous page" optional:"true"`
+	SortBy *string `json:"sort_by" description:"the field to sort the entities by, prefix it with - for descending order. entities are sorted by id if no field is given" optional:"true"`
+}
+
+// sortKey is a field which entities can be sorted by, the path points to the field in the stored document.
+type sortKey struct {
+	path []string
+	time bool
+}
+
+type sortKeys map[string]sortKey
+
+var idSortKey = sortKey{path: []string{"id"}}
+
+// cursor is the position of the last entity of a page. Entities are always sorted by their id
+// in addition to the sort key, so that every entity has a unique position.
+type cursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// page is the paging of a search after it was validated against the sort keys of the search.
+type page struct {
+	key        sortKey
+	descending bool
+	limit      *uint64
+	after      *cursor
+}
+
+// resolve validates the paging, it returns nil if no paging was requested.
+func (p *Paging) resolve(keys sortKeys) (*page, error) {
+	if p.Limit == nil && p.Next == nil && p.SortBy == nil {
+		return nil, nil
+	}
+
+	result := &page{
+		key:   idSortKey,
+		limit: p.Limit,
+	}
+
+	if p.SortBy != nil && *p.SortBy != "" {
+		name := *p.SortBy
+		if strings.HasPrefix(name, "-") {
+			result.descending = true
+			name = strings.TrimPrefix(name, "-")
+		}
+
+		key, ok := keys[name]
+		if !ok {
+			var names []string
+			for n := range keys {
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("entities cannot be sorted by %q, supported fields are: %s", name, strings.Join(names, ", "))
+		}
+		result.key = key
+	}
+
+	if p.Next != nil && *p.Next != "" {
+		raw, err := base64.RawURLEncoding.DecodeString(*p.Next)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		c := &cursor{}
+		err = json.Unmarshal(raw, c)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor: %w", err)
+		}
+		if result.key.time {
+			_, err = time.Parse(time.RFC3339Nano, c.Value)
+			if err != nil {
+				return nil, fmt.Errorf("cursor does not belong to a search sorted by time: %w", err)
+			}
+		}
+		result.after = c
+	}
+
+	return result, nil
+}
+
+// value returns the value of the sort key of the given entity and its id.
+func (k sortKey) value(entity any) (string, string, error) {
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	var doc map[string]any
+	err = json.Unmarshal(raw, &doc)
+	if err != nil {
+		return "", "", fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	id, _ := doc["id"].(string)
+
+	var current any = doc
+	for _, field := range k.path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return "", id, nil
+		}
+		current = m[field]
+	}
+
+	value, _ := current.(string)
+	return value, id, nil
+}
+
+// compare compares two values of the sort key.
+func (k sortKey) compare(a, b string) int {
+	if k.time {
+		// values were validated before or come from encoded timestamps
+		ta, _ := time.Parse(time.RFC3339Nano, a)
+		tb, _ := time.Parse(time.RFC3339Nano, b)
+		switch {
+		case ta.Before(tb):
+			return -1
+		case ta.After(tb):
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// nextCursor returns the cursor which continues the search after the given page of entities. It returns
+// nil if the search was not limited or if there cannot be further entities.
+func nextCursor[E any](p *Paging, keys sortKeys, entities []E) (*string, error) {
+	pg, err := p.resolve(keys)
+	if err != nil {
+		return nil, err
+	}
+	if pg == nil || pg.limit == nil || uint64(len(entities)) < *pg.limit || len(entities) == 0 {
+		return nil, nil
+	}
+
+	value, id, err := pg.key.value(&entities[len(entities)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	// encoding a struct of strings cannot fail
+	raw, _ := json.Marshal(cursor{Value: value, ID: id})
+	next := base64.RawURLEncoding.EncodeToString(raw)
+
+	return &next, nil
+}
+
+// generateTerm sorts and limits the given query term of a search.
+func (p *Paging) generateTerm(keys sortKeys, q *r.Term) (*r.Term, error) {
+	pg, err := p.resolve(keys)
+	if err != nil || pg == nil {
+		return q, err
+	}
+	term := pg.generateTerm(*q)
+	return &term, nil
+}
+
+func (pg *page) generateTerm(q r.Term) r.Term {
+	field := func(row r.Term) r.Term {
+		for _, f := range pg.key.path {
+			row = row.Field(f)
+		}
+		if pg.key.time {
+			return row
+		}
+		return row.Default("")
+	}
+
+	if pg.after != nil {
+		var value any = pg.after.Value
+		if pg.key.time {
+			value, _ = time.Parse(time.RFC3339Nano, pg.after.Value)
+		}
+		q = q.Filter(func(row r.Term) r.Term {
+			if pg.descending {
+				return field(row).Lt(value).Or(field(row).Eq(value).And(row.Field("id").Lt(pg.after.ID)))
+			}
+			return field(row).Gt(value).Or(field(row).Eq(value).And(row.Field("id").Gt(pg.after.ID)))
+		})
+	}
+
+	if pg.descending {
+		q = q.OrderBy(r.Desc(field), r.Desc("id"))
+	} else {
+		q = q.OrderBy(r.Asc(field), r.Asc("id"))
+	}
+
+	if pg.limit != nil {
+		q = q.Limit(*pg.limit)
+	}
+
+	return q
+}
+
+// generateSQL sorts and limits the given query of a search.
+func (p *Paging) generateSQL(keys sortKeys, q *postgresQuery) error {
+	pg, err := p.resolve(keys)
+	if err != nil || pg == nil {
+		return err
+	}
+	pg.generateSQL(q)
+	return nil
+}
+
+func (pg *page) generateSQL(q *postgresQuery) {
+	expr := `id COLLATE "C"`
+	if pg.key.time {
+		// created and changed are stored in columns of their own
+		expr = pg.key.path[0]
+	} else if len(pg.key.path) != 1 || pg.key.path[0] != "id" {
+		expr = fmt.Sprintf(`COALESCE(data #>> '{%s}', '') COLLATE "C"`, strings.Join(pg.key.path, ","))
+	}
+
+	direction, op := "ASC", ">"
+	if pg.descending {
+		direction, op = "DESC", "<"
+	}
+
+	if pg.after != nil {
+		var value any = pg.after.Value
+		if pg.key.time {
+			value, _ = time.Parse(time.RFC3339Nano, pg.after.Value)
+		}
+		q.args = append(q.args, value, pg.after.ID)
+		q.conditions = append(q.conditions, fmt.Sprintf(`(%[1]s %[2]s $%[3]d OR (%[1]s = $%[3]d AND id COLLATE "C" %[2]s $%[4]d))`, expr, op, len(q.args)-1, len(q.args)))
+	}
+
+	q.orderBy = fmt.Sprintf(`%s %s, id COLLATE "C" %s`, expr, direction, direction)
+	q.limit = pg.limit
+}
+
+// pageEntities sorts and limits the entities found by a search.
+func pageEntities[E any](p *Paging, keys sortKeys, entities []E) ([]E, error) {
+	pg, err := p.resolve(keys)
+	if err != nil || pg == nil {
+		return entities, err
+	}
+
+	type entry struct {
+		value string
+		id    string
+		e     E
+	}
+
+	entries := make([]entry, 0, len(entities))
+	for i := range entities {
+		value, id, err := pg.key.value(&entities[i])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{value: value, id: id, e: entities[i]})
+	}
+
+	less := func(a, b entry) bool {
+		c := pg.key.compare(a.value, b.value)
+		if c == 0 {
+			c = strings.Compare(a.id, b.id)
+		}
+		if pg.descending {
+			return c > 0
+		}
+		return c < 0
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+
+	result := make([]E, 0, len(entries))
+	for _, e := range entries {
+		if pg.after != nil && !less(entry{value: pg.after.Value, id: pg.after.ID}, e) {
+			continue
+		}
+		if pg.limit != nil && uint64(len(result)) >= *pg.limit {
+			break
+		}
+		result = append(result, e.e)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,106 @@
+package datastore
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestSearchMachines_Paging(t *testing.T) {
+	ds := NewMemory(zaptest.NewLogger(t).Sugar())
+
+	for _, m := range []metal.Machine{
+		{Base: metal.Base{ID: "1", Name: "c"}, PartitionID: "p1"},
+		{Base: metal.Base{ID: "2", Name: "a"}, PartitionID: "p1"},
+		{Base: metal.Base{ID: "3", Name: "b"}, PartitionID: "p1"},
+		{Base: metal.Base{ID: "4", Name: "a"}, PartitionID: "p1"},
+		{Base: metal.Base{ID: "5", Name: "a"}, PartitionID: "p2"},
+	} {
+		m := m
+		require.NoError(t, ds.CreateMachine(&m))
+	}
+
+	search := func(q *MachineSearchQuery) ([]string, *string) {
+		var ms metal.Machines
+		require.NoError(t, ds.SearchMachines(q, &ms))
+		next, err := q.NextCursor(ms)
+		require.NoError(t, err)
+
+		var ids []string
+		for _, m := range ms {
+			ids = append(ids, m.ID)
+		}
+		return ids, next
+	}
+
+	partition := "p1"
+	limit := uint64(2)
+	sortBy := "-name"
+
+	var got []string
+	q := &MachineSearchQuery{PartitionID: &partition, Paging: Paging{Limit: &limit, SortBy: &sortBy}}
+	for {
+		ids, next := search(q)
+		assert.LessOrEqual(t, len(ids), 2)
+		got = append(got, ids...)
+		if next == nil {
+			break
+		}
+		q.Next = next
+	}
+	assert.Equal(t, []string{"1", "3", "4", "2"}, got, "machines must be sorted descending by name and id")
+
+	ids, next := search(&MachineSearchQuery{})
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, ids, "searches without paging return all machines")
+	assert.Nil(t, next)
+
+	invalid := "rack"
+	var ms metal.Machines
+	err := ds.SearchMachines(&MachineSearchQuery{Paging: Paging{SortBy: &invalid}}, &ms)
+	assert.ErrorContains(t, err, `entities cannot be sorted by "rack"`)
+
+	cursor := "!"
+	err = ds.SearchMachines(&MachineSearchQuery{Paging: Paging{Next: &cursor}}, &ms)
+	assert.ErrorContains(t, err, "invalid cursor")
+}
+
+func TestPaging_generateSQL(t *testing.T) {
+	limit := uint64(10)
+	sortBy := "-allocation_project"
+
+	q := &MachineSearchQuery{Paging: Paging{Limit: &limit, SortBy: &sortBy}}
+	sq := q.generateSQL()
+	require.NoError(t, q.Paging.generateSQL(machineSortKeys, sq))
+
+	gotSQL, _ := sq.sql(machineTableName)
+	want := `SELECT data FROM "machine" ORDER BY COALESCE(data #>> '{allocation,project}', '') COLLATE "C" DESC, id COLLATE "C" DESC LIMIT 10`
+	if diff := cmp.Diff(want, gotSQL); diff != "" {
+		t.Errorf("generateSQL() diff = %s", diff)
+	}
+
+	next, err := q.NextCursor(metal.Machines{{Base: metal.Base{ID: "m1"}, Allocation: &metal.MachineAllocation{Project: "p"}}})
+	require.NoError(t, err)
+	assert.Nil(t, next, "a page smaller than the limit is the last one")
+
+	limit = 1
+	next, err = q.NextCursor(metal.Machines{{Base: metal.Base{ID: "m1"}, Allocation: &metal.MachineAllocation{Project: "p"}}})
+	require.NoError(t, err)
+	require.NotNil(t, next)
+
+	q.Next = next
+	sq = q.generateSQL()
+	require.NoError(t, q.Paging.generateSQL(machineSortKeys, sq))
+
+	gotSQL, gotArgs := sq.sql(machineTableName)
+	want = `SELECT data FROM "machine" WHERE (COALESCE(data #>> '{allocation,project}', '') COLLATE "C" < $1 OR (COALESCE(data #>> '{allocation,project}', '') COLLATE "C" = $1 AND id COLLATE "C" < $2)) ORDER BY COALESCE(data #>> '{allocation,project}', '') COLLATE "C" DESC, id COLLATE "C" DESC LIMIT 1`
+	if diff := cmp.Diff(want, gotSQL); diff != "" {
+		t.Errorf("generateSQL() diff = %s", diff)
+	}
+	if diff := cmp.Diff([]any{"p", "m1"}, gotArgs); diff != "" {
+		t.Errorf("generateSQL() args diff = %s", diff)
+	}
+}
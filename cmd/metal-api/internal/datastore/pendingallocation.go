@@ -0,0 +1,35 @@
+package datastore
+
+import "github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+
+// FindPendingAllocation returns a pending allocation for a given id.
+func (rs *RethinkStore) FindPendingAllocation(id string) (*metal.PendingAllocation, error) {
+	var p metal.PendingAllocation
+	err := rs.findEntityByID(rs.pendingAllocationTable(), &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingAllocations returns all pending allocations.
+func (rs *RethinkStore) ListPendingAllocations() (metal.PendingAllocations, error) {
+	ps := make(metal.PendingAllocations, 0)
+	err := rs.listEntities(rs.pendingAllocationTable(), &ps)
+	return ps, err
+}
+
+// CreatePendingAllocation creates a new pending allocation.
+func (rs *RethinkStore) CreatePendingAllocation(p *metal.PendingAllocation) error {
+	return rs.createEntity(rs.pendingAllocationTable(), p)
+}
+
+// DeletePendingAllocation deletes a pending allocation.
+func (rs *RethinkStore) DeletePendingAllocation(p *metal.PendingAllocation) error {
+	return rs.deleteEntity(rs.pendingAllocationTable(), p)
+}
+
+// UpdatePendingAllocation updates a pending allocation.
+func (rs *RethinkStore) UpdatePendingAllocation(oldPendingAllocation *metal.PendingAllocation, newPendingAllocation *metal.PendingAllocation) error {
+	return rs.updateEntity(rs.pendingAllocationTable(), newPendingAllocation, oldPendingAllocation)
+}
@@ -0,0 +1,370 @@
+package datastore
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"unicode"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"golang.org/x/exp/slices"
+)
+
+// PlacementContext contains the information a placement strategy decides on.
+type PlacementContext struct {
+	// Candidates are the waiting and alive machines the allocation can be placed on.
+	Candidates metal.Machines
+	// ProjectMachines are the machines of the allocating project in the partition.
+	ProjectMachines metal.Machines
+	PlacementTags   []string
+	Events          metal.ProvisioningEventContainerMap
+	// Switches of the partition, only present if a scorer of the strategy requires them.
+	Switches metal.Switches
+}
+
+// PlacementStrategy narrows the candidates down to the finalists which are suited best for an allocation, one
+// of the finalists is elected randomly. The reasons why the other candidates were not preferred are returned
+// by machine id.
+type PlacementStrategy interface {
+	Finalists(pc *PlacementContext) (metal.Machines, map[string]string)
+}
+
+// PlacementScorer rates the candidates of a placement context with a score between 0 and 1 by machine id,
+// candidates with a higher score are preferred. Candidates without a score are rated with 0.
+type PlacementScorer interface {
+	Score(pc *PlacementContext) map[string]float64
+}
+
+var placementScorers = map[metal.PlacementScorerName]PlacementScorer{
+	metal.PlacementScorerRackSpread:       rackSpreadScorer{},
+	metal.PlacementScorerBIOSVersion:      versionScorer{version: func(m *metal.Machine) string { return m.BIOS.Version }},
+	metal.PlacementScorerBMCVersion:       versionScorer{version: func(m *metal.Machine) string { return m.IPMI.BMCVersion }},
+	metal.PlacementScorerPowerConsumption: powerConsumptionScorer{},
+	metal.PlacementScorerCrashLoops:       crashLoopScorer{},
+	metal.PlacementScorerSwitchCapacity:   switchCapacityScorer{},
+}
+
+// NewPlacementStrategy returns the strategy for the given partition configuration. Without scorers the
+// machines are spread across the racks, otherwise the candidates with the highest sum of weighted scores
+// are the finalists.
+func NewPlacementStrategy(s metal.PlacementStrategy) (PlacementStrategy, error) {
+	if s.IsDefault() {
+		return rackSpreadPlacement{}, nil
+	}
+
+	err := s.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var w weightedPlacement
+	for _, sc := range s.Scorers {
+		scorer, ok := placementScorers[sc.Name]
+		if !ok {
+			return nil, fmt.Errorf("placement scorer %q is not implemented", sc.Name)
+		}
+		w.scorers = append(w.scorers, weightedScorer{scorer: scorer, weight: sc.Weight})
+	}
+
+	return w, nil
+}
+
+// rackSpreadPlacement spreads the machines of a project across the racks.
+type rackSpreadPlacement struct{}
+
+func (rackSpreadPlacement) Finalists(pc *PlacementContext) (metal.Machines, map[string]string) {
+	finalists := spreadAcrossRacks(pc.Candidates, pc.ProjectMachines, pc.PlacementTags)
+
+	reasons := map[string]string{}
+	for _, m := range pc.Candidates {
+		if !containsMachine(finalists, m.ID) {
+			reasons[m.ID] = "other racks are less occupied by the project or by machines with the placement tags"
+		}
+	}
+
+	return finalists, reasons
+}
+
+type weightedScorer struct {
+	scorer PlacementScorer
+	weight float64
+}
+
+// weightedPlacement prefers the candidates with the highest sum of weighted scores.
+type weightedPlacement struct {
+	scorers []weightedScorer
+}
+
+func (w weightedPlacement) Finalists(pc *PlacementContext) (metal.Machines, map[string]string) {
+	totals := make([]float64, len(pc.Candidates))
+	for _, ws := range w.scorers {
+		scores := ws.scorer.Score(pc)
+		for i := range pc.Candidates {
+			totals[i] += ws.weight * scores[pc.Candidates[i].ID]
+		}
+	}
+
+	// tolerates rounding errors of the summed up scores
+	const epsilon = 1e-9
+
+	max := math.Inf(-1)
+	for _, total := range totals {
+		max = math.Max(max, total)
+	}
+
+	var finalists metal.Machines
+	reasons := map[string]string{}
+	for i, total := range totals {
+		if total >= max-epsilon {
+			finalists = append(finalists, pc.Candidates[i])
+			continue
+		}
+		reasons[pc.Candidates[i].ID] = fmt.Sprintf("placement score %.2f is lower than the best score %.2f", total, max)
+	}
+
+	return finalists, reasons
+}
+
+func containsMachine(ms metal.Machines, id string) bool {
+	for _, m := range ms {
+		if m.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// rackSpreadScorer rates the candidates in the racks which are least occupied by the project and the placement tags with 1.
+type rackSpreadScorer struct{}
+
+func (rackSpreadScorer) Score(pc *PlacementContext) map[string]float64 {
+	scores := map[string]float64{}
+	for _, m := range spreadAcrossRacks(pc.Candidates, pc.ProjectMachines, pc.PlacementTags) {
+		scores[m.ID] = 1
+	}
+	return scores
+}
+
+// versionScorer rates the candidates by the rank of their version, machines without a version are rated with 0.
+type versionScorer struct {
+	version func(m *metal.Machine) string
+}
+
+func (s versionScorer) Score(pc *PlacementContext) map[string]float64 {
+	var versions []string
+	for i := range pc.Candidates {
+		v := s.version(&pc.Candidates[i])
+		if v == "" || slices.Contains(versions, v) {
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return compareVersions(versions[i], versions[j]) < 0
+	})
+
+	ranks := map[string]float64{}
+	for i, v := range versions {
+		ranks[v] = float64(i + 1)
+	}
+
+	values := map[string]float64{}
+	for i := range pc.Candidates {
+		if rank, ok := ranks[s.version(&pc.Candidates[i])]; ok {
+			values[pc.Candidates[i].ID] = rank
+		}
+	}
+	return normalizeScores(values)
+}
+
+// powerConsumptionScorer rates the candidates with the lowest recent average power consumption the highest,
+// machines without a power metric are rated with 0.
+type powerConsumptionScorer struct{}
+
+func (powerConsumptionScorer) Score(pc *PlacementContext) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range pc.Candidates {
+		if m.IPMI.PowerMetric == nil {
+			continue
+		}
+		values[m.ID] = -float64(m.IPMI.PowerMetric.AverageConsumedWatts)
+	}
+	return normalizeScores(values)
+}
+
+// crashLoopScorer rates the candidates with the fewest crash events in their provisioning history the highest.
+type crashLoopScorer struct{}
+
+func (crashLoopScorer) Score(pc *PlacementContext) map[string]float64 {
+	values := map[string]float64{}
+	for _, m := range pc.Candidates {
+		crashes := 0
+		for _, e := range pc.Events[m.ID].Events {
+			if e.Event == metal.ProvisioningEventCrashed {
+				crashes++
+			}
+		}
+		values[m.ID] = -float64(crashes)
+	}
+	return normalizeScores(values)
+}
+
+// switchCapacityScorer rates the candidates in the racks whose switches have the most unconnected ports the highest.
+type switchCapacityScorer struct{}
+
+func (switchCapacityScorer) Score(pc *PlacementContext) map[string]float64 {
+	free := map[string]int{}
+	for _, sw := range pc.Switches {
+		connected := 0
+		for _, cons := range sw.MachineConnections {
+			connected += len(cons)
+		}
+		free[sw.RackID] += len(sw.Nics) - connected
+	}
+
+	values := map[string]float64{}
+	for _, m := range pc.Candidates {
+		if ports, ok := free[m.RackID]; ok {
+			values[m.ID] = float64(ports)
+		}
+	}
+	return normalizeScores(values)
+}
+
+// normalizeScores scales the given values linearly between 0 and 1, if all values are equal they are rated with 1.
+func normalizeScores(values map[string]float64) map[string]float64 {
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+
+	scores := map[string]float64{}
+	for id, v := range values {
+		if max == min {
+			scores[id] = 1
+			continue
+		}
+		scores[id] = (v - min) / (max - min)
+	}
+	return scores
+}
+
+// compareVersions compares firmware versions, numeric parts are compared by their value and all other parts lexically.
+func compareVersions(a, b string) int {
+	as, bs := splitVersion(a), splitVersion(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil && an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		case (aErr != nil || bErr != nil) && as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// splitVersion splits a version into its numeric and non-numeric parts, separators are dropped.
+func splitVersion(v string) []string {
+	var (
+		parts   []string
+		current []rune
+		digits  bool
+	)
+	flush := func() {
+		if len(current) > 0 {
+			parts = append(parts, string(current))
+			current = nil
+		}
+	}
+	for _, r := range v {
+		switch {
+		case r == '.' || r == '-' || r == '_' || unicode.IsSpace(r):
+			flush()
+			continue
+		case unicode.IsDigit(r) != digits:
+			flush()
+			digits = unicode.IsDigit(r)
+		}
+		current = append(current, r)
+	}
+	flush()
+	return parts
+}
+
+// applyAffinity narrows the candidates to the racks which satisfy the affinity to the machines of the project.
+// Required terms must be satisfied, preferred terms are applied in their order as long as candidates remain.
+func applyAffinity(candidates, projectMachines metal.Machines, affinity metal.MachineAffinity, excluded exclusions) (metal.Machines, error) {
+	type term struct {
+		metal.AffinityTerm
+		anti bool
+	}
+
+	var terms []term
+	for _, t := range affinity.RackAffinity {
+		terms = append(terms, term{AffinityTerm: t})
+	}
+	for _, t := range affinity.RackAntiAffinity {
+		terms = append(terms, term{AffinityTerm: t, anti: true})
+	}
+
+	// required terms are applied first, such that preferred terms cannot prevent them from being satisfied
+	sort.SliceStable(terms, func(i, j int) bool {
+		return terms[i].Required && !terms[j].Required
+	})
+
+	for _, t := range terms {
+		kind := "rack affinity"
+		if t.anti {
+			kind = "rack anti-affinity"
+		}
+
+		racks := map[string]bool{}
+		found := false
+		for i := range projectMachines {
+			if t.Matches(&projectMachines[i]) {
+				racks[projectMachines[i].RackID] = true
+				found = true
+			}
+		}
+		if !found && t.MachineID != "" {
+			return nil, fmt.Errorf("machine %s of the %s is not allocated to the project in this partition", t.MachineID, kind)
+		}
+
+		var filtered, rest metal.Machines
+		for _, m := range candidates {
+			if racks[m.RackID] != t.anti {
+				filtered = append(filtered, m)
+			} else {
+				rest = append(rest, m)
+			}
+		}
+
+		if len(filtered) == 0 {
+			if t.Required {
+				excluded.add(fmt.Sprintf("rack does not satisfy the required %s to %s", kind, t), rest...)
+				return nil, fmt.Errorf("%w which satisfies the required %s to %s", ErrNoMachineAvailable, kind, t)
+			}
+			continue
+		}
+		excluded.add(fmt.Sprintf("rack does not satisfy the %s to %s", kind, t), rest...)
+		candidates = filtered
+	}
+
+	return candidates, nil
+}
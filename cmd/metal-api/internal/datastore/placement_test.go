@@ -0,0 +1,235 @@
+package datastore
+
+import (
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"golang.org/x/exp/slices"
+)
+
+func Test_compareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.2.3", b: "1.2.3", want: 0},
+		{a: "1.2.3", b: "1.10.0", want: -1},
+		{a: "2.0", b: "1.99.99", want: 1},
+		{a: "3.3a", b: "3.3b", want: -1},
+		{a: "1.2", b: "1.2.1", want: -1},
+		{a: "v1.0.0-rc1", b: "v1.0.0-rc2", want: -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewPlacementStrategy(t *testing.T) {
+	newMachine := func(id, rack, bios string, watts float32) metal.Machine {
+		return metal.Machine{
+			Base:   metal.Base{ID: id},
+			RackID: rack,
+			BIOS:   metal.BIOS{Version: bios},
+			IPMI:   metal.IPMI{PowerMetric: &metal.PowerMetric{AverageConsumedWatts: watts}},
+		}
+	}
+
+	pc := &PlacementContext{
+		Candidates: metal.Machines{
+			newMachine("old-bios", "r1", "1.9", 100),
+			newMachine("hungry", "r1", "1.10", 300),
+			newMachine("crashing", "r2", "1.10", 100),
+			newMachine("best", "r2", "1.10", 100),
+		},
+		ProjectMachines: metal.Machines{newMachine("allocated", "r1", "1.10", 100)},
+		Events: metal.ProvisioningEventContainerMap{
+			"crashing": {Events: metal.ProvisioningEvents{{Event: metal.ProvisioningEventCrashed}, {Event: metal.ProvisioningEventCrashed}}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		strategy metal.PlacementStrategy
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name: "default spreads across racks",
+			want: []string{"crashing", "best"},
+		},
+		{
+			name: "newest bios",
+			strategy: metal.PlacementStrategy{Scorers: []metal.PlacementScorer{
+				{Name: metal.PlacementScorerBIOSVersion, Weight: 1},
+			}},
+			want: []string{"hungry", "crashing", "best"},
+		},
+		{
+			name: "weighted scorers",
+			strategy: metal.PlacementStrategy{Scorers: []metal.PlacementScorer{
+				{Name: metal.PlacementScorerBIOSVersion, Weight: 1},
+				{Name: metal.PlacementScorerPowerConsumption, Weight: 1},
+				{Name: metal.PlacementScorerCrashLoops, Weight: 1},
+			}},
+			want: []string{"best"},
+		},
+		{
+			name: "rack spread outweighs power consumption",
+			strategy: metal.PlacementStrategy{Scorers: []metal.PlacementScorer{
+				{Name: metal.PlacementScorerRackSpread, Weight: 10},
+				{Name: metal.PlacementScorerPowerConsumption, Weight: 1},
+			}},
+			want: []string{"crashing", "best"},
+		},
+		{
+			name: "unknown scorer",
+			strategy: metal.PlacementStrategy{Scorers: []metal.PlacementScorer{
+				{Name: "foo", Weight: 1},
+			}},
+			wantErr: true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewPlacementStrategy(tt.strategy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewPlacementStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			finalists, reasons := strategy.Finalists(pc)
+
+			var ids []string
+			for _, m := range finalists {
+				ids = append(ids, m.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Finalists() = %v, want %v", ids, tt.want)
+			}
+			if len(reasons)+len(finalists) != len(pc.Candidates) {
+				t.Errorf("Finalists() reasons = %v, want a reason for every other candidate", reasons)
+			}
+		})
+	}
+}
+
+func Test_switchCapacityScorer(t *testing.T) {
+	pc := &PlacementContext{
+		Candidates: metal.Machines{
+			{Base: metal.Base{ID: "m1"}, RackID: "r1"},
+			{Base: metal.Base{ID: "m2"}, RackID: "r2"},
+			{Base: metal.Base{ID: "m3"}, RackID: "r3"},
+		},
+		Switches: metal.Switches{
+			{RackID: "r1", Nics: metal.Nics{{Name: "swp1"}, {Name: "swp2"}}, MachineConnections: metal.ConnectionMap{"x": {{MachineID: "x"}, {MachineID: "x"}}}},
+			{RackID: "r2", Nics: metal.Nics{{Name: "swp1"}, {Name: "swp2"}}, MachineConnections: metal.ConnectionMap{"y": {{MachineID: "y"}}}},
+			{RackID: "r2", Nics: metal.Nics{{Name: "swp1"}, {Name: "swp2"}}},
+		},
+	}
+
+	scores := switchCapacityScorer{}.Score(pc)
+	if scores["m1"] != 0 || scores["m2"] != 1 {
+		t.Errorf("switchCapacityScorer.Score() = %v, want m1 = 0 and m2 = 1", scores)
+	}
+	if _, ok := scores["m3"]; ok {
+		t.Errorf("switchCapacityScorer.Score() = %v, racks without switches must not be rated", scores)
+	}
+}
+
+func Test_applyAffinity(t *testing.T) {
+	candidates := metal.Machines{
+		{Base: metal.Base{ID: "c1"}, RackID: "r1"},
+		{Base: metal.Base{ID: "c2"}, RackID: "r2"},
+		{Base: metal.Base{ID: "c3"}, RackID: "r3"},
+	}
+	projectMachines := metal.Machines{
+		{Base: metal.Base{ID: "db-1"}, RackID: "r1", Tags: []string{"storage", "replica"}},
+		{Base: metal.Base{ID: "web-1"}, RackID: "r2", Tags: []string{"web"}},
+	}
+
+	tests := []struct {
+		name     string
+		affinity metal.MachineAffinity
+		want     []string
+		wantErr  string
+	}{
+		{
+			name: "no affinity",
+			want: []string{"c1", "c2", "c3"},
+		},
+		{
+			name:     "required affinity to tags",
+			affinity: metal.MachineAffinity{RackAffinity: []metal.AffinityTerm{{Tags: []string{"storage", "replica"}, Required: true}}},
+			want:     []string{"c1"},
+		},
+		{
+			name:     "preferred affinity to machine",
+			affinity: metal.MachineAffinity{RackAffinity: []metal.AffinityTerm{{MachineID: "web-1"}}},
+			want:     []string{"c2"},
+		},
+		{
+			name:     "required anti-affinity",
+			affinity: metal.MachineAffinity{RackAntiAffinity: []metal.AffinityTerm{{Tags: []string{"web"}, Required: true}}},
+			want:     []string{"c1", "c3"},
+		},
+		{
+			name:     "unsatisfiable preferred affinity is ignored",
+			affinity: metal.MachineAffinity{RackAffinity: []metal.AffinityTerm{{Tags: []string{"unknown"}}}},
+			want:     []string{"c1", "c2", "c3"},
+		},
+		{
+			name:     "unsatisfiable required affinity",
+			affinity: metal.MachineAffinity{RackAffinity: []metal.AffinityTerm{{Tags: []string{"unknown"}, Required: true}}},
+			wantErr:  "no machine available which satisfies the required rack affinity to machines tagged unknown",
+		},
+		{
+			name: "conflicting required terms",
+			affinity: metal.MachineAffinity{
+				RackAffinity:     []metal.AffinityTerm{{MachineID: "db-1", Required: true}},
+				RackAntiAffinity: []metal.AffinityTerm{{Tags: []string{"storage"}, Required: true}},
+			},
+			wantErr: "no machine available which satisfies the required rack anti-affinity to machines tagged storage",
+		},
+		{
+			name: "preferred terms do not override required terms",
+			affinity: metal.MachineAffinity{
+				RackAffinity:     []metal.AffinityTerm{{MachineID: "web-1"}},
+				RackAntiAffinity: []metal.AffinityTerm{{MachineID: "web-1", Required: true}},
+			},
+			want: []string{"c1", "c3"},
+		},
+		{
+			name:     "machine of another project",
+			affinity: metal.MachineAffinity{RackAffinity: []metal.AffinityTerm{{MachineID: "foreign"}}},
+			wantErr:  "machine foreign of the rack affinity is not allocated to the project in this partition",
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := applyAffinity(candidates, projectMachines, tt.affinity, nil)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("applyAffinity() error = %v, wantErr %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyAffinity() error = %v", err)
+			}
+
+			var ids []string
+			for _, m := range got {
+				ids = append(ids, m.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("applyAffinity() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
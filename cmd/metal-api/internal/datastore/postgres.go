@@ -0,0 +1,438 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/rest"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultPostgresPort is used if the database address does not contain a port
+	DefaultPostgresPort = "5432"
+
+	postgresUniqueViolation = "23505"
+)
+
+// postgresEntityTables are the tables which hold metal entities, every entity is stored as jsonb document.
+var postgresEntityTables = []string{
+	imageTableName,
+	sizeTableName,
+	partitionTableName,
+	machineTableName,
+	switchTableName,
+	switchStatusTableName,
+	eventTableName,
+	networkTableName,
+	ipTableName,
+	filesystemLayoutTableName,
+	sizeImageConstraintTableName,
+	reservationTableName,
+	pendingAllocationTableName,
+	rolloutTableName,
+	powerSampleTableName,
+}
+
+// postgresSearchableTables get an additional index on the document because they are searched by document fields.
+var postgresSearchableTables = []string{machineTableName, networkTableName, ipTableName, switchTableName, imageTableName}
+
+// A PostgresStore is the database access layer for postgres.
+type PostgresStore struct {
+	log *zap.SugaredLogger
+
+	db *sqlx.DB
+
+	dbhost    string
+	dbport    string
+	dbname    string
+	dbuser    string
+	dbpass    string
+	dbsslmode string
+
+	VRFPoolRangeMin uint
+	VRFPoolRangeMax uint
+	ASNPoolRangeMin uint
+	ASNPoolRangeMax uint
+	// PartitionASNPoolRanges contains the ranges of the partitions which have an asn pool of their own
+	PartitionASNPoolRanges map[string]IntegerRange
+
+	revisionInfo RevisionInfo
+}
+
+var _ Store = &PostgresStore{}
+
+// NewPostgres creates a new postgres store. The address may contain a port, otherwise the
+// default postgres port is used.
+func NewPostgres(log *zap.SugaredLogger, dbaddr string, dbname string, dbuser string, dbpass string, sslmode string) *PostgresStore {
+	host, port, err := net.SplitHostPort(dbaddr)
+	if err != nil {
+		host = dbaddr
+		port = DefaultPostgresPort
+	}
+
+	return &PostgresStore{
+		log:       log,
+		dbhost:    host,
+		dbport:    port,
+		dbname:    dbname,
+		dbuser:    dbuser,
+		dbpass:    dbpass,
+		dbsslmode: sslmode,
+
+		VRFPoolRangeMin: DefaultVRFPoolRangeMin,
+		VRFPoolRangeMax: DefaultVRFPoolRangeMax,
+		ASNPoolRangeMin: DefaultASNPoolRangeMin,
+		ASNPoolRangeMax: DefaultASNPoolRangeMax,
+	}
+}
+
+// ServiceName returns the name of the datastore for health checks.
+func (ps *PostgresStore) ServiceName() string {
+	return "postgres"
+}
+
+// Check implements the health interface and tests if the database is healthy.
+func (ps *PostgresStore) Check(ctx context.Context) (rest.HealthStatus, error) {
+	required := append(append([]string{}, postgresEntityTables...), ps.vrfPool().tables()...)
+	required = append(required, ps.asnPool().tables()...)
+	for partitionID := range ps.PartitionASNPoolRanges {
+		required = append(required, ps.partitionASNPool(partitionID).tables()...)
+	}
+	required = append(required, postgresChangelogTable, revisionTableName)
+
+	var count int
+	err := ps.db.GetContext(ctx, &count, `SELECT count(*) FROM information_schema.tables WHERE table_schema = current_schema() AND table_name = ANY($1)`, pq.Array(required))
+	if err != nil {
+		return rest.HealthStatusUnhealthy, err
+	}
+
+	if count != len(required) {
+		return rest.HealthStatusUnhealthy, errors.New("required tables are missing")
+	}
+
+	return rest.HealthStatusHealthy, nil
+}
+
+// Connect connects to the database. If there is an error, it will run until there is
+// a connection.
+func (ps *PostgresStore) Connect() error {
+	for {
+		db, err := sqlx.Connect("postgres", ps.dsn())
+		if err == nil {
+			ps.db = db
+			break
+		}
+		ps.log.Errorw("db connection error", "db", ps.dbname, "host", ps.dbhost, "error", err)
+		time.Sleep(3 * time.Second)
+	}
+	ps.log.Info("Postgresstore connected")
+	return nil
+}
+
+func (ps *PostgresStore) dsn() string {
+	return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=%s", ps.dbuser, ps.dbpass, net.JoinHostPort(ps.dbhost, ps.dbport), ps.dbname, ps.dbsslmode)
+}
+
+// Close closes the database connection.
+func (ps *PostgresStore) Close() error {
+	if ps.db != nil {
+		err := ps.db.Close()
+		if err != nil {
+			return err
+		}
+	}
+	ps.log.Info("Postgresstore disconnected")
+	return nil
+}
+
+// Demote is a noop for postgres, there is no dedicated runtime user.
+func (ps *PostgresStore) Demote() error {
+	return nil
+}
+
+// Migrate is a noop for postgres, the schema is maintained by Initialize. Data from rethinkdb
+// can be copied over with CopyRethinkToPostgres.
+func (ps *PostgresStore) Migrate(targetVersion *int, dry bool) error {
+	ps.log.Infow("no database migration required for postgres")
+	return nil
+}
+
+// MigrationVersion returns the latest registered migration version as postgres always starts with the latest schema.
+func (ps *PostgresStore) MigrationVersion() (int, error) {
+	return LatestMigrationVersion(), nil
+}
+
+// Initialize creates the tables and integer pools, it should be called before serving the metal-api.
+func (ps *PostgresStore) Initialize() error {
+	ps.log.Info("starting database init")
+
+	for _, table := range postgresEntityTables {
+		_, err := ps.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+	id      TEXT PRIMARY KEY,
+	created TIMESTAMPTZ NOT NULL,
+	changed TIMESTAMPTZ NOT NULL,
+	data    JSONB NOT NULL
+)`, table))
+		if err != nil {
+			return fmt.Errorf("cannot create table %s: %w", table, err)
+		}
+	}
+
+	for _, table := range postgresSearchableTables {
+		_, err := ps.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q USING GIN (data jsonb_path_ops)`, table+"_data_idx", table))
+		if err != nil {
+			return fmt.Errorf("cannot create index for table %s: %w", table, err)
+		}
+	}
+
+	_, err := ps.db.Exec(`CREATE INDEX IF NOT EXISTS machine_project_idx ON machine ((data->'allocation'->>'project'))`)
+	if err != nil {
+		return fmt.Errorf("cannot create project index for machines: %w", err)
+	}
+
+	err = ps.initChangelog()
+	if err != nil {
+		return err
+	}
+
+	err = ps.initRevisions()
+	if err != nil {
+		return err
+	}
+
+	err = ps.vrfPool().initIntegerPool(ps.log)
+	if err != nil {
+		return err
+	}
+
+	err = ps.asnPool().initIntegerPool(ps.log)
+	if err != nil {
+		return err
+	}
+
+	for partitionID := range ps.PartitionASNPoolRanges {
+		err = ps.partitionASNPool(partitionID).initIntegerPool(ps.log)
+		if err != nil {
+			return err
+		}
+	}
+
+	ps.log.Info("database init complete")
+
+	return nil
+}
+
+// postgres stores timestamps with microsecond precision, so all timestamps are truncated before
+// writing in order to make the optimistic locking with the changed field work.
+func postgresNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (ps *PostgresStore) findEntityByID(table string, entity any, id string) error {
+	var data []byte
+	err := ps.db.Get(&data, fmt.Sprintf(`SELECT data FROM %q WHERE id = $1`, table), id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return metal.NotFound("no %v with id %q found", getEntityName(entity), id)
+		}
+		return fmt.Errorf("cannot find %v with id %q in database: %w", getEntityName(entity), id, err)
+	}
+
+	err = json.Unmarshal(data, entity)
+	if err != nil {
+		return fmt.Errorf("cannot decode %v with id %q: %w", getEntityName(entity), id, err)
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) createEntity(table string, entity metal.Entity) error {
+	now := postgresNow()
+	entity.SetCreated(now)
+	entity.SetChanged(now)
+
+	if entity.GetID() == "" {
+		entity.SetID(uuid.NewString())
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	_, err = ps.db.Exec(fmt.Sprintf(`INSERT INTO %q (id, created, changed, data) VALUES ($1, $2, $3, $4)`, table), entity.GetID(), now, now, data)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == postgresUniqueViolation {
+			return metal.Conflict("cannot create %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+		}
+		return fmt.Errorf("cannot create %v in database: %w", getEntityName(entity), err)
+	}
+
+	ps.recordRevision(metal.RevisionOperationCreate, entity)
+
+	return nil
+}
+
+func (ps *PostgresStore) upsertEntity(table string, entity metal.Entity) error {
+	now := postgresNow()
+	if entity.GetCreated().IsZero() {
+		entity.SetCreated(now)
+	}
+	entity.SetChanged(now)
+
+	err := ps.importEntity(table, entity)
+	if err != nil {
+		return err
+	}
+
+	ps.recordRevision(upsertOperation(entity), entity)
+
+	return nil
+}
+
+// importEntity writes the entity as it is without touching the timestamps.
+func (ps *PostgresStore) importEntity(table string, entity metal.Entity) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	_, err = ps.db.Exec(fmt.Sprintf(`INSERT INTO %q (id, created, changed, data) VALUES ($1, $2, $3, $4)
+ON CONFLICT (id) DO UPDATE SET created = EXCLUDED.created, changed = EXCLUDED.changed, data = EXCLUDED.data`, table),
+		entity.GetID(), entity.GetCreated(), entity.GetChanged(), data)
+	if err != nil {
+		return fmt.Errorf("cannot upsert %v (%s) in database: %w", getEntityName(entity), entity.GetID(), err)
+	}
+
+	return nil
+}
+
+// RestoreEntity writes the entity as it is, keeping its id and timestamps.
+func (ps *PostgresStore) RestoreEntity(entity metal.Entity) error {
+	table, err := tableNameOf(entity)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	_, err = ps.db.Exec(fmt.Sprintf(`INSERT INTO %q (id, created, changed, data) VALUES ($1, $2, $3, $4)`, table),
+		entity.GetID(), entity.GetCreated(), entity.GetChanged(), data)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == postgresUniqueViolation {
+			return metal.Conflict("cannot restore %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+		}
+		return fmt.Errorf("cannot restore %v (%s) in database: %w", getEntityName(entity), entity.GetID(), err)
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) deleteEntity(table string, entity metal.Entity) error {
+	_, err := ps.db.Exec(fmt.Sprintf(`DELETE FROM %q WHERE id = $1`, table), entity.GetID())
+	if err != nil {
+		return fmt.Errorf("cannot delete %v with id %q from database: %w", getEntityName(entity), entity.GetID(), err)
+	}
+
+	ps.recordRevision(metal.RevisionOperationDelete, entity)
+
+	return nil
+}
+
+func (ps *PostgresStore) updateEntity(table string, newEntity metal.Entity, oldEntity metal.Entity) error {
+	now := postgresNow()
+	newEntity.SetChanged(now)
+
+	data, err := json.Marshal(newEntity)
+	if err != nil {
+		return fmt.Errorf("cannot encode %v: %w", getEntityName(newEntity), err)
+	}
+
+	res, err := ps.db.Exec(fmt.Sprintf(`UPDATE %q SET changed = $1, data = $2 WHERE id = $3 AND changed = $4`, table),
+		now, data, oldEntity.GetID(), oldEntity.GetChanged())
+	if err != nil {
+		return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
+	}
+
+	if affected == 0 {
+		var exists bool
+		err = ps.db.Get(&exists, fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %q WHERE id = $1)`, table), oldEntity.GetID())
+		if err != nil {
+			return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
+		}
+		if !exists {
+			return metal.NotFound("cannot update %v (%s): entity does not exist", getEntityName(newEntity), oldEntity.GetID())
+		}
+		return metal.Conflict("cannot update %v (%s): %s", getEntityName(newEntity), oldEntity.GetID(), entityAlreadyModifiedErrorMessage)
+	}
+
+	ps.recordRevision(metal.RevisionOperationUpdate, newEntity)
+
+	return nil
+}
+
+// searchPostgresEntities returns all entities of a table which match the given query, sorted by id.
+func searchPostgresEntities[E any](ps *PostgresStore, table string, q *postgresQuery) ([]E, error) {
+	if q == nil {
+		q = &postgresQuery{}
+	}
+
+	query, args := q.sql(table)
+
+	var rows [][]byte
+	err := ps.db.Select(&rows, query, args...)
+	if err != nil {
+		var e E
+		return nil, fmt.Errorf("cannot search %v in database: %w", getEntityName(e), err)
+	}
+
+	result := make([]E, 0, len(rows))
+	for _, data := range rows {
+		var e E
+		err := json.Unmarshal(data, &e)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch all entities: %w", err)
+		}
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// findPostgresEntity returns exactly one entity of a table which matches the given query.
+func findPostgresEntity[E any](ps *PostgresStore, table string, q *postgresQuery, entity *E) error {
+	es, err := searchPostgresEntities[E](ps, table, q)
+	if err != nil {
+		return err
+	}
+
+	switch len(es) {
+	case 0:
+		return metal.NotFound("no %v found", getEntityName(entity))
+	case 1:
+		*entity = es[0]
+		return nil
+	default:
+		return fmt.Errorf("more than one %v exists", getEntityName(entity))
+	}
+}
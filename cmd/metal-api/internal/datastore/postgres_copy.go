@@ -0,0 +1,109 @@
+package datastore
+
+import (
+	"fmt"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// CopyRethinkToPostgres copies all entities and the state of the integer pools from rethinkdb to postgres.
+// The postgres tables must already be initialized. Entities which already exist in postgres are overwritten,
+// such that the copy can be repeated until the metal-api is switched over to postgres.
+func CopyRethinkToPostgres(log *zap.SugaredLogger, rs *RethinkStore, ps *PostgresStore) error {
+	copies := []struct {
+		name string
+		copy func() (int, error)
+	}{
+		{name: imageTableName, copy: func() (int, error) { return copyEntities[metal.Image](rs, rs.imageTable(), ps, imageTableName) }},
+		{name: sizeTableName, copy: func() (int, error) { return copyEntities[metal.Size](rs, rs.sizeTable(), ps, sizeTableName) }},
+		{name: partitionTableName, copy: func() (int, error) {
+			return copyEntities[metal.Partition](rs, rs.partitionTable(), ps, partitionTableName)
+		}},
+		{name: machineTableName, copy: func() (int, error) {
+			return copyEntities[metal.Machine](rs, rs.machineTable(), ps, machineTableName)
+		}},
+		{name: switchTableName, copy: func() (int, error) {
+			return copyEntities[metal.Switch](rs, rs.switchTable(), ps, switchTableName)
+		}},
+		{name: switchStatusTableName, copy: func() (int, error) {
+			return copyEntities[metal.SwitchStatus](rs, rs.switchStatusTable(), ps, switchStatusTableName)
+		}},
+		{name: eventTableName, copy: func() (int, error) {
+			return copyEntities[metal.ProvisioningEventContainer](rs, rs.eventTable(), ps, eventTableName)
+		}},
+		{name: networkTableName, copy: func() (int, error) {
+			return copyEntities[metal.Network](rs, rs.networkTable(), ps, networkTableName)
+		}},
+		{name: ipTableName, copy: func() (int, error) { return copyEntities[metal.IP](rs, rs.ipTable(), ps, ipTableName) }},
+		{name: filesystemLayoutTableName, copy: func() (int, error) {
+			return copyEntities[metal.FilesystemLayout](rs, rs.filesystemLayoutTable(), ps, filesystemLayoutTableName)
+		}},
+		{name: sizeImageConstraintTableName, copy: func() (int, error) {
+			return copyEntities[metal.SizeImageConstraint](rs, rs.sizeImageConstraintTable(), ps, sizeImageConstraintTableName)
+		}},
+		{name: reservationTableName, copy: func() (int, error) {
+			return copyEntities[metal.Reservation](rs, rs.reservationTable(), ps, reservationTableName)
+		}},
+		{name: pendingAllocationTableName, copy: func() (int, error) {
+			return copyEntities[metal.PendingAllocation](rs, rs.pendingAllocationTable(), ps, pendingAllocationTableName)
+		}},
+		{name: rolloutTableName, copy: func() (int, error) {
+			return copyEntities[metal.Rollout](rs, rs.rolloutTable(), ps, rolloutTableName)
+		}},
+		{name: powerSampleTableName, copy: func() (int, error) {
+			return copyEntities[metal.PowerSample](rs, rs.powerSampleTable(), ps, powerSampleTableName)
+		}},
+	}
+
+	for _, c := range copies {
+		count, err := c.copy()
+		if err != nil {
+			return fmt.Errorf("unable to copy table %s: %w", c.name, err)
+		}
+		log.Infow("copied table", "table", c.name, "entities", count)
+	}
+
+	for _, pools := range []struct {
+		from *IntegerPool
+		to   *postgresIntegerPool
+	}{
+		{from: rs.vrfPool(), to: ps.vrfPool()},
+		{from: rs.asnPool(), to: ps.asnPool()},
+	} {
+		free, err := pools.from.freeIntegers()
+		if err != nil {
+			return fmt.Errorf("unable to read integer pool %s: %w", pools.from, err)
+		}
+
+		err = pools.to.importIntegers(free)
+		if err != nil {
+			return fmt.Errorf("unable to copy integer pool %s: %w", pools.from, err)
+		}
+		log.Infow("copied integer pool", "pool", pools.from.String(), "free", len(free))
+	}
+
+	return nil
+}
+
+// copyEntities copies all entities of a rethinkdb table into the postgres table, keeping their timestamps.
+func copyEntities[E any, P interface {
+	*E
+	metal.Entity
+}](rs *RethinkStore, from *r.Term, ps *PostgresStore, to string) (int, error) {
+	var es []E
+	err := rs.listEntities(from, &es)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range es {
+		err := ps.importEntity(to, P(&es[i]))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(es), nil
+}
@@ -0,0 +1,598 @@
+package datastore
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+)
+
+// FindMachineByID returns a machine for a given id.
+func (ps *PostgresStore) FindMachineByID(id string) (*metal.Machine, error) {
+	var m metal.Machine
+	err := ps.findEntityByID(machineTableName, &m, id)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindMachine returns a machine by the given query, fails if there is no record or multiple records found.
+func (ps *PostgresStore) FindMachine(q *MachineSearchQuery, m *metal.Machine) error {
+	return findPostgresEntity(ps, machineTableName, q.generateSQL(), m)
+}
+
+// SearchMachines returns the result of the machines search request query.
+func (ps *PostgresStore) SearchMachines(q *MachineSearchQuery, machines *metal.Machines) error {
+	sq := q.generateSQL()
+	err := q.Paging.generateSQL(machineSortKeys, sq)
+	if err != nil {
+		return err
+	}
+	res, err := searchPostgresEntities[metal.Machine](ps, machineTableName, sq)
+	if err != nil {
+		return err
+	}
+	*machines = res
+	return nil
+}
+
+// ListMachines returns all machines.
+func (ps *PostgresStore) ListMachines() (metal.Machines, error) {
+	return searchPostgresEntities[metal.Machine](ps, machineTableName, nil)
+}
+
+// CreateMachine creates a new machine, allocated machines cannot be created.
+func (ps *PostgresStore) CreateMachine(m *metal.Machine) error {
+	if m.Allocation != nil {
+		return fmt.Errorf("a machine cannot be created when it is allocated: %q: %+v", m.ID, *m.Allocation)
+	}
+	return ps.createEntity(machineTableName, m)
+}
+
+// DeleteMachine removes a machine.
+func (ps *PostgresStore) DeleteMachine(m *metal.Machine) error {
+	return ps.deleteEntity(machineTableName, m)
+}
+
+// UpdateMachine replaces a machine if the 'changed' field of the old value equals the stored one.
+func (ps *PostgresStore) UpdateMachine(oldMachine *metal.Machine, newMachine *metal.Machine) error {
+	return ps.updateEntity(machineTableName, newMachine, oldMachine)
+}
+
+// FindWaitingMachine returns an available, not allocated, waiting and alive machine of given size within the given partition.
+func (ps *PostgresStore) FindWaitingMachine(projectid, partitionid, sizeid string, placementTags []string, affinity metal.MachineAffinity, requirements []metal.Constraint) (*metal.Machine, error) {
+	q := &postgresQuery{}
+	q.contains(nil, "allocation")
+	q.contains(partitionid, "partitionid")
+	q.contains(sizeid, "sizeid")
+	q.contains(metal.AvailableState, "state", "value")
+	q.contains(true, "waiting")
+	q.contains(false, "preallocated")
+
+	candidates, err := searchPostgresEntities[metal.Machine](ps, machineTableName, q)
+	if err != nil {
+		return nil, err
+	}
+
+	oldMachine, err := electWaitingMachine(ps.log, ps, candidates, projectid, partitionid, sizeid, placementTags, affinity, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	newMachine := *oldMachine
+	newMachine.PreAllocated = true
+
+	err = ps.updateEntity(machineTableName, &newMachine, oldMachine)
+	if err != nil {
+		return nil, err
+	}
+
+	return &newMachine, nil
+}
+
+// FindSwitch returns a switch for a given id.
+func (ps *PostgresStore) FindSwitch(id string) (*metal.Switch, error) {
+	var s metal.Switch
+	err := ps.findEntityByID(switchTableName, &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSwitches returns all known switches.
+func (ps *PostgresStore) ListSwitches() (metal.Switches, error) {
+	return searchPostgresEntities[metal.Switch](ps, switchTableName, nil)
+}
+
+// CreateSwitch creates a new switch.
+func (ps *PostgresStore) CreateSwitch(s *metal.Switch) error {
+	return ps.createEntity(switchTableName, s)
+}
+
+// DeleteSwitch deletes a switch.
+func (ps *PostgresStore) DeleteSwitch(s *metal.Switch) error {
+	return ps.deleteEntity(switchTableName, s)
+}
+
+// UpdateSwitch updates a switch.
+func (ps *PostgresStore) UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.Switch) error {
+	return ps.updateEntity(switchTableName, newSwitch, oldSwitch)
+}
+
+// SearchSwitches searches for switches by the given parameters.
+func (ps *PostgresStore) SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error {
+	sq := q.generateSQL()
+	err := q.Paging.generateSQL(switchSortKeys, sq)
+	if err != nil {
+		return err
+	}
+	res, err := searchPostgresEntities[metal.Switch](ps, switchTableName, sq)
+	if err != nil {
+		return err
+	}
+	*ss = res
+	return nil
+}
+
+// SearchSwitchesConnectedToMachine searches switches that are connected to the given machine.
+func (ps *PostgresStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error) {
+	return searchSwitchesConnectedToMachine(ps, m)
+}
+
+// SetVrfAtSwitches finds the switches connected to the given machine and puts the switch ports into the given vrf.
+func (ps *PostgresStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error) {
+	return setVrfAtSwitches(ps, m, vrf)
+}
+
+// ConnectMachineWithSwitches connects the given machine to the switches it is wired to.
+func (ps *PostgresStore) ConnectMachineWithSwitches(m *metal.Machine) error {
+	return connectMachineWithSwitches(ps, m)
+}
+
+// GetSwitchStatus get SwitchStatus for a given switch id
+func (ps *PostgresStore) GetSwitchStatus(id string) (*metal.SwitchStatus, error) {
+	var ss metal.SwitchStatus
+	err := ps.findEntityByID(switchStatusTableName, &ss, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// ListSwitchStatuses returns the status of all switches.
+func (ps *PostgresStore) ListSwitchStatuses() ([]metal.SwitchStatus, error) {
+	return searchPostgresEntities[metal.SwitchStatus](ps, switchStatusTableName, nil)
+}
+
+// SetSwitchStatus create or update the switch status.
+func (ps *PostgresStore) SetSwitchStatus(state *metal.SwitchStatus) error {
+	return ps.upsertEntity(switchStatusTableName, state)
+}
+
+// FindNetworkByID returns an network of a given id.
+func (ps *PostgresStore) FindNetworkByID(id string) (*metal.Network, error) {
+	var nw metal.Network
+	err := ps.findEntityByID(networkTableName, &nw, id)
+	if err != nil {
+		return nil, err
+	}
+	return &nw, nil
+}
+
+// FindNetwork returns a network by the given query, fails if there is no record or multiple records found.
+func (ps *PostgresStore) FindNetwork(q *NetworkSearchQuery, n *metal.Network) error {
+	return findPostgresEntity(ps, networkTableName, q.generateSQL(), n)
+}
+
+// SearchNetworks returns the networks that match the given properties
+func (ps *PostgresStore) SearchNetworks(q *NetworkSearchQuery, ns *metal.Networks) error {
+	sq := q.generateSQL()
+	err := q.Paging.generateSQL(networkSortKeys, sq)
+	if err != nil {
+		return err
+	}
+	res, err := searchPostgresEntities[metal.Network](ps, networkTableName, sq)
+	if err != nil {
+		return err
+	}
+	*ns = res
+	return nil
+}
+
+// ListNetworks returns all networks.
+func (ps *PostgresStore) ListNetworks() (metal.Networks, error) {
+	return searchPostgresEntities[metal.Network](ps, networkTableName, nil)
+}
+
+// CreateNetwork creates a new network.
+func (ps *PostgresStore) CreateNetwork(nw *metal.Network) error {
+	return ps.createEntity(networkTableName, nw)
+}
+
+// DeleteNetwork deletes an network.
+func (ps *PostgresStore) DeleteNetwork(nw *metal.Network) error {
+	return ps.deleteEntity(networkTableName, nw)
+}
+
+// UpdateNetwork updates an network.
+func (ps *PostgresStore) UpdateNetwork(oldNetwork *metal.Network, newNetwork *metal.Network) error {
+	return ps.updateEntity(networkTableName, newNetwork, oldNetwork)
+}
+
+// FindIPByID returns an ip of a given id.
+func (ps *PostgresStore) FindIPByID(id string) (*metal.IP, error) {
+	var ip metal.IP
+	err := ps.findEntityByID(ipTableName, &ip, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ip, nil
+}
+
+// SearchIPs returns the result of the ips search request query.
+func (ps *PostgresStore) SearchIPs(q *IPSearchQuery, ips *metal.IPs) error {
+	sq := q.generateSQL()
+	err := q.Paging.generateSQL(ipSortKeys, sq)
+	if err != nil {
+		return err
+	}
+	res, err := searchPostgresEntities[metal.IP](ps, ipTableName, sq)
+	if err != nil {
+		return err
+	}
+	*ips = res
+	return nil
+}
+
+// ListIPs returns all ips.
+func (ps *PostgresStore) ListIPs() (metal.IPs, error) {
+	return searchPostgresEntities[metal.IP](ps, ipTableName, nil)
+}
+
+// CreateIP creates a new ip.
+func (ps *PostgresStore) CreateIP(ip *metal.IP) error {
+	if ip.AllocationUUID == "" {
+		u, err := uuid.NewRandom()
+		if err != nil {
+			return fmt.Errorf("unable to create uuid for IP allocation: %w", err)
+		}
+		ip.AllocationUUID = u.String()
+	}
+	return ps.createEntity(ipTableName, ip)
+}
+
+// DeleteIP deletes an ip.
+func (ps *PostgresStore) DeleteIP(ip *metal.IP) error {
+	return ps.deleteEntity(ipTableName, ip)
+}
+
+// UpdateIP updates an ip.
+func (ps *PostgresStore) UpdateIP(oldIP *metal.IP, newIP *metal.IP) error {
+	return ps.updateEntity(ipTableName, newIP, oldIP)
+}
+
+// GetImage return a image for a given id without semver matching.
+func (ps *PostgresStore) GetImage(id string) (*metal.Image, error) {
+	var i metal.Image
+	err := ps.findEntityByID(imageTableName, &i, id)
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// FindImages returns all images for the given image id.
+func (ps *PostgresStore) FindImages(id string) ([]metal.Image, error) {
+	return findImages(ps, id)
+}
+
+// FindImage returns an image for the given image id.
+func (ps *PostgresStore) FindImage(id string) (*metal.Image, error) {
+	return findImage(ps, id)
+}
+
+// ListImages returns all images.
+func (ps *PostgresStore) ListImages() (metal.Images, error) {
+	return searchPostgresEntities[metal.Image](ps, imageTableName, nil)
+}
+
+// CreateImage creates a new image.
+func (ps *PostgresStore) CreateImage(i *metal.Image) error {
+	return ps.createEntity(imageTableName, i)
+}
+
+// DeleteImage deletes an image.
+func (ps *PostgresStore) DeleteImage(i *metal.Image) error {
+	return ps.deleteEntity(imageTableName, i)
+}
+
+// UpdateImage updates an image.
+func (ps *PostgresStore) UpdateImage(oldImage *metal.Image, newImage *metal.Image) error {
+	return ps.updateEntity(imageTableName, newImage, oldImage)
+}
+
+// SearchImages searches for images by the given parameters.
+func (ps *PostgresStore) SearchImages(q *ImageSearchQuery, images *metal.Images) error {
+	res, err := searchPostgresEntities[metal.Image](ps, imageTableName, q.generateSQL())
+	if err != nil {
+		return err
+	}
+	*images = res
+	return nil
+}
+
+// DeleteOrphanImages deletes Images which are no longer allocated by a machine and older than allowed.
+func (ps *PostgresStore) DeleteOrphanImages(images metal.Images, machines metal.Machines) (metal.Images, error) {
+	return deleteOrphanImages(ps, images, machines)
+}
+
+// FindSize return a size for a given id.
+func (ps *PostgresStore) FindSize(id string) (*metal.Size, error) {
+	var s metal.Size
+	err := ps.findEntityByID(sizeTableName, &s, id)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListSizes returns all sizes.
+func (ps *PostgresStore) ListSizes() (metal.Sizes, error) {
+	return searchPostgresEntities[metal.Size](ps, sizeTableName, nil)
+}
+
+// CreateSize creates a new size.
+func (ps *PostgresStore) CreateSize(size *metal.Size) error {
+	return ps.createEntity(sizeTableName, size)
+}
+
+// DeleteSize deletes a size.
+func (ps *PostgresStore) DeleteSize(size *metal.Size) error {
+	return ps.deleteEntity(sizeTableName, size)
+}
+
+// UpdateSize updates a size.
+func (ps *PostgresStore) UpdateSize(oldSize *metal.Size, newSize *metal.Size) error {
+	return ps.updateEntity(sizeTableName, newSize, oldSize)
+}
+
+// FromHardware tries to find a size which matches the given hardware specs.
+func (ps *PostgresStore) FromHardware(hw metal.MachineHardware) (*metal.Size, []*metal.SizeMatchingLog, error) {
+	return fromHardware(ps.log, ps, hw)
+}
+
+// FindPartition return a partition for the given id.
+func (ps *PostgresStore) FindPartition(id string) (*metal.Partition, error) {
+	var p metal.Partition
+	err := ps.findEntityByID(partitionTableName, &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPartitions returns all partition.
+func (ps *PostgresStore) ListPartitions() (metal.Partitions, error) {
+	return searchPostgresEntities[metal.Partition](ps, partitionTableName, nil)
+}
+
+// CreatePartition creates a new partition.
+func (ps *PostgresStore) CreatePartition(p *metal.Partition) error {
+	return ps.createEntity(partitionTableName, p)
+}
+
+// DeletePartition delets a partition.
+func (ps *PostgresStore) DeletePartition(p *metal.Partition) error {
+	return ps.deleteEntity(partitionTableName, p)
+}
+
+// UpdatePartition updates a partition.
+func (ps *PostgresStore) UpdatePartition(oldPartition *metal.Partition, newPartition *metal.Partition) error {
+	return ps.updateEntity(partitionTableName, newPartition, oldPartition)
+}
+
+// ListProvisioningEventContainers returns all machine provisioning event containers.
+func (ps *PostgresStore) ListProvisioningEventContainers() (metal.ProvisioningEventContainers, error) {
+	return searchPostgresEntities[metal.ProvisioningEventContainer](ps, eventTableName, nil)
+}
+
+// FindProvisioningEventContainer finds a provisioning event container to a given machine id.
+func (ps *PostgresStore) FindProvisioningEventContainer(id string) (*metal.ProvisioningEventContainer, error) {
+	var e metal.ProvisioningEventContainer
+	err := ps.findEntityByID(eventTableName, &e, id)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// UpdateProvisioningEventContainer updates a provisioning event container.
+func (ps *PostgresStore) UpdateProvisioningEventContainer(old *metal.ProvisioningEventContainer, new *metal.ProvisioningEventContainer) error {
+	return ps.updateEntity(eventTableName, new, old)
+}
+
+// CreateProvisioningEventContainer creates a new provisioning event container.
+func (ps *PostgresStore) CreateProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ps.createEntity(eventTableName, ec)
+}
+
+// UpsertProvisioningEventContainer inserts a machine's event container.
+func (ps *PostgresStore) UpsertProvisioningEventContainer(ec *metal.ProvisioningEventContainer) error {
+	return ps.upsertEntity(eventTableName, ec)
+}
+
+// ProvisioningEventForMachine applies the given provisioning event to the event container of a machine.
+func (ps *PostgresStore) ProvisioningEventForMachine(log *zap.SugaredLogger, event *metal.ProvisioningEvent, machineID string) (*metal.ProvisioningEventContainer, error) {
+	return provisioningEventForMachine(log, ps, event, machineID)
+}
+
+// FindFilesystemLayout return a filesystemlayout for a given id.
+func (ps *PostgresStore) FindFilesystemLayout(id string) (*metal.FilesystemLayout, error) {
+	var fl metal.FilesystemLayout
+	err := ps.findEntityByID(filesystemLayoutTableName, &fl, id)
+	if err != nil {
+		return nil, err
+	}
+	return &fl, nil
+}
+
+// ListFilesystemLayouts returns all filesystemlayouts.
+func (ps *PostgresStore) ListFilesystemLayouts() (metal.FilesystemLayouts, error) {
+	return searchPostgresEntities[metal.FilesystemLayout](ps, filesystemLayoutTableName, nil)
+}
+
+// CreateFilesystemLayout creates a new filesystemlayout.
+func (ps *PostgresStore) CreateFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ps.createEntity(filesystemLayoutTableName, fl)
+}
+
+// DeleteFilesystemLayout deletes a filesystemlayout.
+func (ps *PostgresStore) DeleteFilesystemLayout(fl *metal.FilesystemLayout) error {
+	return ps.deleteEntity(filesystemLayoutTableName, fl)
+}
+
+// UpdateFilesystemLayout updates a filesystemlayout.
+func (ps *PostgresStore) UpdateFilesystemLayout(oldFilesystemLayout *metal.FilesystemLayout, newFilesystemLayout *metal.FilesystemLayout) error {
+	return ps.updateEntity(filesystemLayoutTableName, newFilesystemLayout, oldFilesystemLayout)
+}
+
+// FindSizeImageConstraint return a size image constraint for a given size id.
+func (ps *PostgresStore) FindSizeImageConstraint(sizeID string) (*metal.SizeImageConstraint, error) {
+	var ic metal.SizeImageConstraint
+	err := ps.findEntityByID(sizeImageConstraintTableName, &ic, sizeID)
+	if err != nil {
+		return nil, err
+	}
+	return &ic, nil
+}
+
+// ListSizeImageConstraints returns all size image constraints.
+func (ps *PostgresStore) ListSizeImageConstraints() (metal.SizeImageConstraints, error) {
+	return searchPostgresEntities[metal.SizeImageConstraint](ps, sizeImageConstraintTableName, nil)
+}
+
+// CreateSizeImageConstraint creates a new size image constraint.
+func (ps *PostgresStore) CreateSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ps.createEntity(sizeImageConstraintTableName, ic)
+}
+
+// DeleteSizeImageConstraint deletes a size image constraint.
+func (ps *PostgresStore) DeleteSizeImageConstraint(ic *metal.SizeImageConstraint) error {
+	return ps.deleteEntity(sizeImageConstraintTableName, ic)
+}
+
+// UpdateSizeImageConstraint updates a size image constraint.
+func (ps *PostgresStore) UpdateSizeImageConstraint(oldSizeImageConstraint *metal.SizeImageConstraint, newSizeImageConstraint *metal.SizeImageConstraint) error {
+	return ps.updateEntity(sizeImageConstraintTableName, newSizeImageConstraint, oldSizeImageConstraint)
+}
+
+// FindReservation returns a reservation for a given id.
+func (ps *PostgresStore) FindReservation(id string) (*metal.Reservation, error) {
+	var r metal.Reservation
+	err := ps.findEntityByID(reservationTableName, &r, id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListReservations returns all reservations.
+func (ps *PostgresStore) ListReservations() (metal.Reservations, error) {
+	return searchPostgresEntities[metal.Reservation](ps, reservationTableName, nil)
+}
+
+// CreateReservation creates a new reservation.
+func (ps *PostgresStore) CreateReservation(r *metal.Reservation) error {
+	return ps.createEntity(reservationTableName, r)
+}
+
+// DeleteReservation deletes a reservation.
+func (ps *PostgresStore) DeleteReservation(r *metal.Reservation) error {
+	return ps.deleteEntity(reservationTableName, r)
+}
+
+// UpdateReservation updates a reservation.
+func (ps *PostgresStore) UpdateReservation(oldReservation *metal.Reservation, newReservation *metal.Reservation) error {
+	return ps.updateEntity(reservationTableName, newReservation, oldReservation)
+}
+
+// FindPendingAllocation returns a pending allocation for a given id.
+func (ps *PostgresStore) FindPendingAllocation(id string) (*metal.PendingAllocation, error) {
+	var p metal.PendingAllocation
+	err := ps.findEntityByID(pendingAllocationTableName, &p, id)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListPendingAllocations returns all pending allocations.
+func (ps *PostgresStore) ListPendingAllocations() (metal.PendingAllocations, error) {
+	return searchPostgresEntities[metal.PendingAllocation](ps, pendingAllocationTableName, nil)
+}
+
+// CreatePendingAllocation creates a new pending allocation.
+func (ps *PostgresStore) CreatePendingAllocation(p *metal.PendingAllocation) error {
+	return ps.createEntity(pendingAllocationTableName, p)
+}
+
+// DeletePendingAllocation deletes a pending allocation.
+func (ps *PostgresStore) DeletePendingAllocation(p *metal.PendingAllocation) error {
+	return ps.deleteEntity(pendingAllocationTableName, p)
+}
+
+// UpdatePendingAllocation updates a pending allocation.
+func (ps *PostgresStore) UpdatePendingAllocation(oldPendingAllocation *metal.PendingAllocation, newPendingAllocation *metal.PendingAllocation) error {
+	return ps.updateEntity(pendingAllocationTableName, newPendingAllocation, oldPendingAllocation)
+}
+
+// FindRollout returns a rollout for a given id.
+func (ps *PostgresStore) FindRollout(id string) (*metal.Rollout, error) {
+	var r metal.Rollout
+	err := ps.findEntityByID(rolloutTableName, &r, id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRollouts returns all rollouts.
+func (ps *PostgresStore) ListRollouts() (metal.Rollouts, error) {
+	return searchPostgresEntities[metal.Rollout](ps, rolloutTableName, nil)
+}
+
+// CreateRollout creates a new rollout.
+func (ps *PostgresStore) CreateRollout(r *metal.Rollout) error {
+	return ps.createEntity(rolloutTableName, r)
+}
+
+// UpdateRollout updates a rollout.
+func (ps *PostgresStore) UpdateRollout(oldRollout *metal.Rollout, newRollout *metal.Rollout) error {
+	return ps.updateEntity(rolloutTableName, newRollout, oldRollout)
+}
+
+// ListPowerSamples returns all power samples.
+func (ps *PostgresStore) ListPowerSamples() (metal.PowerSamples, error) {
+	return searchPostgresEntities[metal.PowerSample](ps, powerSampleTableName, nil)
+}
+
+// SearchPowerSamples returns the power samples recorded within the given period.
+func (ps *PostgresStore) SearchPowerSamples(from, to time.Time) (metal.PowerSamples, error) {
+	q := &postgresQuery{}
+	q.between("timestamp", from, to)
+	return searchPostgresEntities[metal.PowerSample](ps, powerSampleTableName, q)
+}
+
+// CreatePowerSample creates a new power sample.
+func (ps *PostgresStore) CreatePowerSample(s *metal.PowerSample) error {
+	return ps.createEntity(powerSampleTableName, s)
+}
+
+// DeletePowerSample deletes a power sample.
+func (ps *PostgresStore) DeletePowerSample(s *metal.PowerSample) error {
+	return ps.deleteEntity(powerSampleTableName, s)
+}
@@ -0,0 +1,257 @@
+package datastore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+)
+
+// postgresIntegerPool is the postgres counterpart of the IntegerPool, it works the same way:
+// every free integer is a row in the pool table, acquiring an integer deletes its row and
+// releasing inserts it again.
+type postgresIntegerPool struct {
+	poolType IntegerPoolType
+	min      uint
+	max      uint
+	db       *sqlx.DB
+}
+
+// GetVRFPool returns the pool of unique integers for vrfs.
+func (ps *PostgresStore) GetVRFPool() UniqueIntegerPool {
+	return ps.vrfPool()
+}
+
+// GetASNPool returns the pool of unique integers for asns.
+func (ps *PostgresStore) GetASNPool() UniqueIntegerPool {
+	return ps.asnPool()
+}
+
+// GetPartitionASNPool returns the asn pool of the given partition, the global asn pool is returned
+// if the partition has no pool of its own.
+func (ps *PostgresStore) GetPartitionASNPool(partitionID string) UniqueIntegerPool {
+	if _, ok := ps.PartitionASNPoolRanges[partitionID]; !ok {
+		return ps.asnPool()
+	}
+	return ps.partitionASNPool(partitionID)
+}
+
+// GetPartitionASNPools returns the asn pools of all partitions which have a pool of their own.
+func (ps *PostgresStore) GetPartitionASNPools() map[string]UniqueIntegerPool {
+	result := map[string]UniqueIntegerPool{}
+	for partitionID := range ps.PartitionASNPoolRanges {
+		result[partitionID] = ps.partitionASNPool(partitionID)
+	}
+	return result
+}
+
+func (ps *PostgresStore) vrfPool() *postgresIntegerPool {
+	return &postgresIntegerPool{
+		poolType: VRFIntegerPool,
+		min:      ps.VRFPoolRangeMin,
+		max:      ps.VRFPoolRangeMax,
+		db:       ps.db,
+	}
+}
+
+func (ps *PostgresStore) asnPool() *postgresIntegerPool {
+	return &postgresIntegerPool{
+		poolType: ASNIntegerPool,
+		min:      ps.ASNPoolRangeMin,
+		max:      ps.ASNPoolRangeMax,
+		db:       ps.db,
+	}
+}
+
+func (ps *PostgresStore) partitionASNPool(partitionID string) *postgresIntegerPool {
+	rng := ps.PartitionASNPoolRanges[partitionID]
+	return &postgresIntegerPool{
+		poolType: PartitionASNIntegerPool(partitionID),
+		min:      rng.Min,
+		max:      rng.Max,
+		db:       ps.db,
+	}
+}
+
+func (ip *postgresIntegerPool) String() string {
+	return ip.poolType.String()
+}
+
+// Range returns the range of integers which is managed by the pool.
+func (ip *postgresIntegerPool) Range() IntegerRange {
+	return IntegerRange{Min: ip.min, Max: ip.max}
+}
+
+func (ip *postgresIntegerPool) poolTable() string {
+	return ip.poolType.String()
+}
+
+func (ip *postgresIntegerPool) infoTable() string {
+	return ip.poolType.String() + "info"
+}
+
+func (ip *postgresIntegerPool) tables() []string {
+	return []string{ip.poolTable(), ip.infoTable()}
+}
+
+// initIntegerPool creates the pool tables and fills the pool with the configured range on first start,
+// see IntegerPool.initIntegerPool for details.
+func (ip *postgresIntegerPool) initIntegerPool(log *zap.SugaredLogger) error {
+	_, err := ip.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (id BIGINT PRIMARY KEY)`, ip.poolTable()))
+	if err != nil {
+		return fmt.Errorf("cannot create table %s: %w", ip.poolTable(), err)
+	}
+
+	_, err = ip.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (id TEXT PRIMARY KEY, isinitialized BOOLEAN NOT NULL)`, ip.infoTable()))
+	if err != nil {
+		return fmt.Errorf("cannot create table %s: %w", ip.infoTable(), err)
+	}
+
+	var initialized bool
+	err = ip.db.Get(&initialized, fmt.Sprintf(`SELECT isinitialized FROM %q WHERE id = $1`, ip.infoTable()), ip.String())
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	log.Infow("pool info", "id", ip.String(), "initialized", initialized)
+	if initialized {
+		return nil
+	}
+
+	log.Infow("initializing integer pool", "for", ip.String(), "RangeMin", ip.min, "RangeMax", ip.max)
+
+	tx, err := ip.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %q (id) SELECT generate_series($1::BIGINT, $2::BIGINT) ON CONFLICT DO NOTHING`, ip.poolTable()), ip.min, ip.max)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %q (id, isinitialized) VALUES ($1, TRUE) ON CONFLICT (id) DO UPDATE SET isinitialized = TRUE`, ip.infoTable()), ip.String())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AcquireRandomUniqueInteger returns a random unique integer from the pool.
+func (ip *postgresIntegerPool) AcquireRandomUniqueInteger() (uint, error) {
+	query := fmt.Sprintf(`DELETE FROM %[1]q WHERE id = (SELECT id FROM %[1]q LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING id`, ip.poolTable())
+
+	var integer uint
+	err := retry.Do(
+		func() error {
+			var err2 error
+			integer, err2 = ip.genericAcquire(query)
+			return err2
+		},
+		retry.Attempts(10),
+		retry.MaxDelay(100*time.Millisecond),
+		retry.LastErrorOnly(true),
+	)
+
+	return integer, err
+}
+
+// AcquireUniqueInteger returns a unique integer from the pool.
+func (ip *postgresIntegerPool) AcquireUniqueInteger(value uint) (uint, error) {
+	err := verifyRange(value, ip.min, ip.max)
+	if err != nil {
+		return 0, err
+	}
+	return ip.genericAcquire(fmt.Sprintf(`DELETE FROM %q WHERE id = $1 RETURNING id`, ip.poolTable()), value)
+}
+
+// ReleaseUniqueInteger returns a unique integer to the pool.
+func (ip *postgresIntegerPool) ReleaseUniqueInteger(id uint) error {
+	err := verifyRange(id, ip.min, ip.max)
+	if err != nil {
+		return err
+	}
+
+	_, err = ip.db.Exec(fmt.Sprintf(`INSERT INTO %q (id) VALUES ($1) ON CONFLICT DO NOTHING`, ip.poolTable()), id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AcquiredIntegers returns all integers of the range which are not in the pool.
+func (ip *postgresIntegerPool) AcquiredIntegers() ([]uint, error) {
+	acquired := []uint{}
+	err := ip.db.Select(&acquired, fmt.Sprintf(`SELECT s.id FROM generate_series($1::BIGINT, $2::BIGINT) AS s(id)
+WHERE NOT EXISTS (SELECT 1 FROM %q p WHERE p.id = s.id) ORDER BY s.id`, ip.poolTable()), ip.min, ip.max)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list acquired integers of pool %s: %w", ip, err)
+	}
+	return acquired, nil
+}
+
+func (ip *postgresIntegerPool) genericAcquire(query string, args ...any) (uint, error) {
+	var integer uint
+	err := ip.db.Get(&integer, query, args...)
+	if err == nil {
+		return integer, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	var count int64
+	err = ip.db.Get(&count, fmt.Sprintf(`SELECT count(*) FROM %q`, ip.poolTable()))
+	if err != nil {
+		return 0, err
+	}
+
+	if count <= 0 {
+		return 0, metal.Internal("acquisition of a value failed for exhausted pool")
+	}
+	return 0, metal.Conflict("integer is already acquired by another")
+}
+
+// importIntegers replaces the content of the pool with the given free integers and marks the pool as initialized.
+func (ip *postgresIntegerPool) importIntegers(free []uint) error {
+	tx, err := ip.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(fmt.Sprintf(`DELETE FROM %q`, ip.poolTable()))
+	if err != nil {
+		return err
+	}
+
+	ids := make([]int64, 0, len(free))
+	for _, i := range free {
+		ids = append(ids, int64(i))
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %q (id) SELECT unnest($1::BIGINT[])`, ip.poolTable()), pq.Array(ids))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`INSERT INTO %q (id, isinitialized) VALUES ($1, TRUE) ON CONFLICT (id) DO UPDATE SET isinitialized = TRUE`, ip.infoTable()), ip.String())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,141 @@
+//go:build integration
+// +build integration
+
+package postgres_integration
+
+import (
+	"context"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-api/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func startPostgresStore(t *testing.T) *datastore.PostgresStore {
+	container, c, err := test.StartPostgres()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = container.Terminate(context.Background())
+	})
+
+	ps := datastore.NewPostgres(zaptest.NewLogger(t).Sugar(), c.IP+":"+c.Port, c.DB, c.User, c.Password, "disable")
+	ps.VRFPoolRangeMin = 10000
+	ps.VRFPoolRangeMax = 10001
+	ps.ASNPoolRangeMin = 10000
+	ps.ASNPoolRangeMax = 10001
+
+	require.NoError(t, ps.Connect())
+	t.Cleanup(func() {
+		_ = ps.Close()
+	})
+	require.NoError(t, ps.Initialize())
+
+	return ps
+}
+
+func TestPostgresStore(t *testing.T) {
+	ps := startPostgresStore(t)
+
+	status, err := ps.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", string(status))
+
+	// initializing twice must not fail
+	require.NoError(t, ps.Initialize())
+
+	m := &metal.Machine{Base: metal.Base{ID: "1"}, PartitionID: "p1", SizeID: "s1", Tags: []string{"a"}}
+	require.NoError(t, ps.CreateMachine(m))
+	require.NoError(t, ps.CreateMachine(&metal.Machine{Base: metal.Base{ID: "2"}, PartitionID: "p2"}))
+
+	err = ps.CreateMachine(m)
+	assert.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	stored, err := ps.FindMachineByID("1")
+	require.NoError(t, err)
+	assert.Equal(t, m.Changed, stored.Changed)
+
+	newMachine := *stored
+	newMachine.RackID = "r1"
+	require.NoError(t, ps.UpdateMachine(stored, &newMachine))
+
+	staleMachine := *stored
+	staleMachine.RackID = "r2"
+	err = ps.UpdateMachine(stored, &staleMachine)
+	assert.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	partition := "p1"
+	var machines metal.Machines
+	require.NoError(t, ps.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &partition, Tags: []string{"a"}}, &machines))
+	require.Len(t, machines, 1)
+	assert.Equal(t, "r1", machines[0].RackID)
+
+	require.NoError(t, ps.DeleteMachine(&newMachine))
+	_, err = ps.FindMachineByID("1")
+	assert.True(t, metal.IsNotFound(err), "expected not found, got %v", err)
+
+	require.NoError(t, ps.CreateNetwork(&metal.Network{
+		Base:     metal.Base{ID: "n1"},
+		Prefixes: metal.Prefixes{{IP: "10.0.0.0", Length: "8"}},
+	}))
+
+	var nws metal.Networks
+	require.NoError(t, ps.SearchNetworks(&datastore.NetworkSearchQuery{Prefixes: []string{"10.0.0.0/8"}}, &nws))
+	assert.Len(t, nws, 1)
+	require.NoError(t, ps.SearchNetworks(&datastore.NetworkSearchQuery{Prefixes: []string{"10.0.0.0/16"}}, &nws))
+	assert.Empty(t, nws)
+}
+
+func TestPostgresStore_IntegerPool(t *testing.T) {
+	ps := startPostgresStore(t)
+	pool := ps.GetVRFPool()
+
+	got, err := pool.AcquireUniqueInteger(10000)
+	require.NoError(t, err)
+	assert.Equal(t, uint(10000), got)
+
+	_, err = pool.AcquireUniqueInteger(10000)
+	assert.True(t, metal.IsConflict(err), "expected conflict, got %v", err)
+
+	got, err = pool.AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+	assert.Equal(t, uint(10001), got)
+
+	_, err = pool.AcquireRandomUniqueInteger()
+	assert.True(t, metal.IsInternal(err), "expected exhausted pool, got %v", err)
+
+	require.NoError(t, pool.ReleaseUniqueInteger(10000))
+
+	got, err = pool.AcquireRandomUniqueInteger()
+	require.NoError(t, err)
+	assert.Equal(t, uint(10000), got)
+
+	acquired, err := pool.AcquiredIntegers()
+	require.NoError(t, err)
+	assert.Equal(t, []uint{10000, 10001}, acquired)
+}
+
+func TestPostgresStore_WatchMachines(t *testing.T) {
+	ps := startPostgresStore(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes, err := ps.WatchMachines(ctx)
+	require.NoError(t, err)
+
+	m := &metal.Machine{Base: metal.Base{ID: "1"}}
+	require.NoError(t, ps.CreateMachine(m))
+	require.NoError(t, ps.DeleteMachine(m))
+
+	c := <-changes
+	assert.Equal(t, datastore.ChangeTypeCreate, c.Type)
+	assert.Equal(t, "1", c.Entity.ID)
+
+	c = <-changes
+	assert.Equal(t, datastore.ChangeTypeDelete, c.Type)
+	assert.Equal(t, "1", c.Entity.ID)
+}
@@ -0,0 +1,404 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/utils"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+)
+
+// postgresQuery collects the conditions of a search query. Entities are stored as jsonb
+// documents, so most conditions are expressed as jsonb containment (@>), which is
+// supported by the gin index on the data column.
+type postgresQuery struct {
+	conditions []string
+	args       []any
+	orderBy    string
+	limit      *uint64
+}
+
+// eq adds a condition which compares a column with the given value.
+func (q *postgresQuery) eq(column string, value any) {
+	q.args = append(q.args, value)
+	q.conditions = append(q.conditions, fmt.Sprintf("%s = $%d", column, len(q.args)))
+}
+
+// contains adds a condition which requires the document to contain the given value
+// under the given path.
+func (q *postgresQuery) contains(value any, path ...string) {
+	var doc any = value
+	for i := len(path) - 1; i >= 0; i-- {
+		doc = map[string]any{path[i]: doc}
+	}
+
+	// marshalling maps, slices and primitives cannot fail
+	raw, _ := json.Marshal(doc)
+
+	q.args = append(q.args, string(raw))
+	q.conditions = append(q.conditions, fmt.Sprintf("data @> $%d::jsonb", len(q.args)))
+}
+
+// hasKey adds a condition which requires the object under the given path to have the given key.
+func (q *postgresQuery) hasKey(key string, path ...string) {
+	selector := "data"
+	for _, p := range path {
+		selector += fmt.Sprintf("->'%s'", strings.ReplaceAll(p, "'", "''"))
+	}
+
+	q.args = append(q.args, key)
+	q.conditions = append(q.conditions, fmt.Sprintf("jsonb_exists(%s, $%d)", selector, len(q.args)))
+}
+
+// between adds a condition which requires the timestamp of the document under the given key to lie within
+// the given period, including its bounds.
+func (q *postgresQuery) between(key string, from, to time.Time) {
+	q.args = append(q.args, from, to)
+	q.conditions = append(q.conditions, fmt.Sprintf("(data->>'%s')::timestamptz BETWEEN $%d AND $%d", strings.ReplaceAll(key, "'", "''"), len(q.args)-1, len(q.args)))
+}
+
+func (q *postgresQuery) sql(table string) (string, []any) {
+	query := fmt.Sprintf("SELECT data FROM %q", table)
+	if len(q.conditions) > 0 {
+		query += " WHERE " + strings.Join(q.conditions, " AND ")
+	}
+	if q.orderBy != "" {
+		query += " ORDER BY " + q.orderBy
+	} else {
+		query += " ORDER BY id"
+	}
+	if q.limit != nil {
+		query += fmt.Sprintf(" LIMIT %d", *q.limit)
+	}
+	return query, q.args
+}
+
+// generateSQL generates the machine search query, it has to be kept in line with generateTerm.
+func (p *MachineSearchQuery) generateSQL() *postgresQuery {
+	q := &postgresQuery{}
+
+	if p.ID != nil {
+		q.eq("id", *p.ID)
+	}
+
+	if p.Name != nil {
+		q.contains(*p.Name, "name")
+	}
+
+	if p.PartitionID != nil {
+		q.contains(*p.PartitionID, "partitionid")
+	}
+
+	if p.SizeID != nil {
+		q.contains(*p.SizeID, "sizeid")
+	}
+
+	if p.RackID != nil {
+		q.contains(*p.RackID, "rackid")
+	}
+
+	for _, t := range p.Tags {
+		q.contains([]string{t}, "tags")
+	}
+
+	if p.AllocationName != nil {
+		q.contains(*p.AllocationName, "allocation", "name")
+	}
+
+	if p.AllocationProject != nil {
+		q.contains(*p.AllocationProject, "allocation", "project")
+	}
+
+	if p.AllocationImageID != nil {
+		q.contains(*p.AllocationImageID, "allocation", "imageid")
+	}
+
+	if p.AllocationHostname != nil {
+		q.contains(*p.AllocationHostname, "allocation", "hostname")
+	}
+
+	if p.AllocationRole != nil {
+		q.contains(*p.AllocationRole, "allocation", "role")
+	}
+
+	if p.AllocationSucceeded != nil {
+		q.contains(*p.AllocationSucceeded, "allocation", "succeeded")
+	}
+
+	for _, id := range p.NetworkIDs {
+		q.contains([]map[string]any{{"networkid": id}}, "allocation", "networks")
+	}
+
+	for _, prefix := range p.NetworkPrefixes {
+		q.contains([]map[string]any{{"prefixes": []string{prefix}}}, "allocation", "networks")
+	}
+
+	for _, ip := range p.NetworkIPs {
+		q.contains([]map[string]any{{"ips": []string{ip}}}, "allocation", "networks")
+	}
+
+	for _, destPrefix := range p.NetworkDestinationPrefixes {
+		q.contains([]map[string]any{{"destinationprefixes": []string{destPrefix}}}, "allocation", "networks")
+	}
+
+	for _, vrf := range p.NetworkVrfs {
+		q.contains([]map[string]any{{"vrf": vrf}}, "allocation", "networks")
+	}
+
+	for _, asn := range p.NetworkASNs {
+		q.contains([]map[string]any{{"asn": asn}}, "allocation", "networks")
+	}
+
+	if p.HardwareMemory != nil {
+		q.contains(*p.HardwareMemory, "hardware", "memory")
+	}
+
+	if p.HardwareCPUCores != nil {
+		q.contains(*p.HardwareCPUCores, "hardware", "cpu_cores")
+	}
+
+	for _, mac := range p.NicsMacAddresses {
+		q.contains([]map[string]any{{"macAddress": mac}}, "hardware", "network_interfaces")
+	}
+
+	for _, name := range p.NicsNames {
+		q.contains([]map[string]any{{"name": name}}, "hardware", "network_interfaces")
+	}
+
+	for _, vrf := range p.NicsVrfs {
+		q.contains([]map[string]any{{"vrf": vrf}}, "hardware", "network_interfaces")
+	}
+
+	for _, mac := range p.NicsNeighborMacAddresses {
+		q.contains([]map[string]any{{"neighbors": []map[string]any{{"macAddress": mac}}}}, "hardware", "network_interfaces")
+	}
+
+	for _, name := range p.NicsNeighborNames {
+		q.contains([]map[string]any{{"neighbors": []map[string]any{{"name": name}}}}, "hardware", "network_interfaces")
+	}
+
+	for _, vrf := range p.NicsNeighborVrfs {
+		q.contains([]map[string]any{{"neighbors": []map[string]any{{"vrf": vrf}}}}, "hardware", "network_interfaces")
+	}
+
+	for _, name := range p.DiskNames {
+		q.contains([]map[string]any{{"name": name}}, "hardware", "block_devices")
+	}
+
+	for _, size := range p.DiskSizes {
+		q.contains([]map[string]any{{"size": size}}, "hardware", "block_devices")
+	}
+
+	if p.StateValue != nil {
+		q.contains(*p.StateValue, "state", "value")
+	}
+
+	if p.IpmiAddress != nil {
+		q.contains(*p.IpmiAddress, "ipmi", "address")
+	}
+
+	if p.IpmiMacAddress != nil {
+		q.contains(*p.IpmiMacAddress, "ipmi", "mac")
+	}
+
+	if p.IpmiUser != nil {
+		q.contains(*p.IpmiUser, "ipmi", "user")
+	}
+
+	if p.IpmiInterface != nil {
+		q.contains(*p.IpmiInterface, "ipmi", "interface")
+	}
+
+	for _, f := range []struct {
+		field string
+		value *string
+	}{
+		{field: "chassis_part_number", value: p.FruChassisPartNumber},
+		{field: "chassis_part_serial", value: p.FruChassisPartSerial},
+		{field: "board_mfg", value: p.FruBoardMfg},
+		{field: "board_mfg_serial", value: p.FruBoardMfgSerial},
+		{field: "board_part_number", value: p.FruBoardPartNumber},
+		{field: "product_manufacturer", value: p.FruProductManufacturer},
+		{field: "product_part_number", value: p.FruProductPartNumber},
+		{field: "product_serial", value: p.FruProductSerial},
+	} {
+		if f.value != nil {
+			q.contains(*f.value, "ipmi", "fru", f.field)
+		}
+	}
+
+	return q
+}
+
+// generateSQL generates the ip search query, it has to be kept in line with generateTerm.
+func (p *IPSearchQuery) generateSQL() *postgresQuery {
+	q := &postgresQuery{}
+
+	if p.IPAddress != nil {
+		q.eq("id", *p.IPAddress)
+	}
+
+	if p.AllocationUUID != nil {
+		q.contains(*p.AllocationUUID, "allocationuuid")
+	}
+
+	if p.Name != nil {
+		q.contains(*p.Name, "name")
+	}
+
+	if p.ProjectID != nil {
+		q.contains(*p.ProjectID, "projectid")
+	}
+
+	if p.NetworkID != nil {
+		q.contains(*p.NetworkID, "networkid")
+	}
+
+	if p.ParentPrefixCidr != nil {
+		q.contains(*p.ParentPrefixCidr, "prefix")
+	}
+
+	tags := append([]string{}, p.Tags...)
+	if p.MachineID != nil {
+		tags = append(tags, metal.IpTag(tag.MachineID, *p.MachineID))
+	}
+
+	for _, t := range tags {
+		q.contains([]string{t}, "tags")
+	}
+
+	if p.Type != nil {
+		q.contains(*p.Type, "type")
+	}
+
+	return q
+}
+
+// generateSQL generates the network search query, it has to be kept in line with generateTerm.
+func (p *NetworkSearchQuery) generateSQL() *postgresQuery {
+	q := &postgresQuery{}
+
+	if p.ID != nil {
+		q.eq("id", *p.ID)
+	}
+
+	if p.ProjectID != nil {
+		q.contains(*p.ProjectID, "projectid")
+	}
+
+	if p.PartitionID != nil {
+		q.contains(*p.PartitionID, "partitionid")
+	}
+
+	if p.ParentNetworkID != nil {
+		q.contains(*p.ParentNetworkID, "parentnetworkid")
+	}
+
+	if p.Name != nil {
+		q.contains(*p.Name, "name")
+	}
+
+	if p.Vrf != nil {
+		q.contains(*p.Vrf, "vrf")
+	}
+
+	if p.Nat != nil {
+		q.contains(*p.Nat, "nat")
+	}
+
+	if p.PrivateSuper != nil {
+		q.contains(*p.PrivateSuper, "privatesuper")
+	}
+
+	if p.Underlay != nil {
+		q.contains(*p.Underlay, "underlay")
+	}
+
+	for k, v := range p.Labels {
+		q.contains(map[string]string{k: v}, "labels")
+	}
+
+	for _, prefix := range p.Prefixes {
+		ip, length := utils.SplitCIDR(prefix)
+		q.contains([]map[string]any{{"ip": ip}}, "prefixes")
+		if length != nil {
+			q.contains([]map[string]any{{"length": strconv.Itoa(*length)}}, "prefixes")
+		}
+	}
+
+	for _, destPrefix := range p.DestinationPrefixes {
+		ip, length := utils.SplitCIDR(destPrefix)
+		q.contains([]map[string]any{{"ip": ip}}, "destinationprefixes")
+		if length != nil {
+			q.contains([]map[string]any{{"length": strconv.Itoa(*length)}}, "destinationprefixes")
+		}
+	}
+
+	return q
+}
+
+// generateSQL generates the switch search query, it has to be kept in line with generateTerm.
+func (p *SwitchSearchQuery) generateSQL() *postgresQuery {
+	q := &postgresQuery{}
+
+	if p.ID != nil {
+		q.eq("id", *p.ID)
+	}
+
+	if p.Name != nil {
+		q.contains(*p.Name, "name")
+	}
+
+	if p.PartitionID != nil {
+		q.contains(*p.PartitionID, "partitionid")
+	}
+
+	if p.RackID != nil {
+		q.contains(*p.RackID, "rackid")
+	}
+
+	if p.OSVendor != nil {
+		q.contains(*p.OSVendor, "os", "vendor")
+	}
+
+	if p.OSVersion != nil {
+		q.contains(*p.OSVersion, "os", "version")
+	}
+
+	return q
+}
+
+// generateSQL generates the image search query, it has to be kept in line with generateTerm.
+func (p *ImageSearchQuery) generateSQL() *postgresQuery {
+	q := &postgresQuery{}
+
+	if p.ID != nil {
+		q.eq("id", *p.ID)
+	}
+
+	if p.Name != nil {
+		q.contains(*p.Name, "name")
+	}
+
+	if p.OS != nil {
+		q.contains(*p.OS, "os")
+	}
+
+	if p.Version != nil {
+		q.contains(*p.Version, "version")
+	}
+
+	if p.Classification != nil {
+		q.contains(*p.Classification, "classification")
+	}
+
+	for _, f := range p.Features {
+		q.hasKey(f, "features")
+	}
+
+	return q
+}
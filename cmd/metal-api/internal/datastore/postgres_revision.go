@@ -0,0 +1,86 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+// initRevisions creates the table which holds the revisions of the entities.
+func (ps *PostgresStore) initRevisions() error {
+	_, err := ps.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+	id         TEXT PRIMARY KEY,
+	entitykind TEXT NOT NULL,
+	entityid   TEXT NOT NULL,
+	timestamp  TIMESTAMPTZ NOT NULL,
+	data       JSONB NOT NULL
+)`, revisionTableName))
+	if err != nil {
+		return fmt.Errorf("cannot create table %s: %w", revisionTableName, err)
+	}
+
+	_, err = ps.db.Exec(fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %q ON %q (entitykind, entityid, timestamp)`, revisionTableName+"_entity_idx", revisionTableName))
+	if err != nil {
+		return fmt.Errorf("cannot create index for table %s: %w", revisionTableName, err)
+	}
+
+	return nil
+}
+
+// WithRevisionInfo returns a copy of the store which records the given information with all revisions.
+func (ps *PostgresStore) WithRevisionInfo(info RevisionInfo) Store {
+	c := *ps
+	c.revisionInfo = info
+	return &c
+}
+
+// recordRevision writes a revision of the entity. The change of the entity already happened, so
+// failures are only logged.
+func (ps *PostgresStore) recordRevision(op metal.RevisionOperation, entity metal.Entity) {
+	err := func() error {
+		revision, err := newRevision(ps.revisionInfo, op, entity)
+		if err != nil || revision == nil {
+			return err
+		}
+
+		revision.ID = uuid.NewString()
+		data, err := json.Marshal(revision)
+		if err != nil {
+			return err
+		}
+
+		_, err = ps.db.Exec(fmt.Sprintf(`INSERT INTO %q (id, entitykind, entityid, timestamp, data) VALUES ($1, $2, $3, $4, $5)`, revisionTableName),
+			revision.ID, revision.EntityKind, revision.EntityID, revision.Timestamp, data)
+		return err
+	}()
+	if err != nil {
+		ps.log.Errorw("unable to record revision", "entity", getEntityName(entity), "id", entity.GetID(), "error", err)
+	}
+}
+
+// ListRevisions returns all revisions of an entity sorted by their timestamp.
+func (ps *PostgresStore) ListRevisions(kind, id string) (metal.Revisions, error) {
+	if !isRevisionKind(kind) {
+		return nil, fmt.Errorf("revisions of %s are not recorded", kind)
+	}
+
+	var rows [][]byte
+	err := ps.db.Select(&rows, fmt.Sprintf(`SELECT data FROM %q WHERE entitykind = $1 AND entityid = $2 ORDER BY timestamp`, revisionTableName), kind, id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list revisions from database: %w", err)
+	}
+
+	revisions := make(metal.Revisions, 0, len(rows))
+	for _, row := range rows {
+		var revision metal.Revision
+		err := json.Unmarshal(row, &revision)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode revision: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,87 @@
+package datastore
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+func TestMachineSearchQuery_generateSQL(t *testing.T) {
+	id := "1"
+	partition := "p1"
+	project := "project"
+
+	tests := []struct {
+		name     string
+		q        *MachineSearchQuery
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "empty query",
+			q:       &MachineSearchQuery{},
+			wantSQL: `SELECT data FROM "machine" ORDER BY id`,
+		},
+		{
+			name:     "search by id",
+			q:        &MachineSearchQuery{ID: &id},
+			wantSQL:  `SELECT data FROM "machine" WHERE id = $1 ORDER BY id`,
+			wantArgs: []any{"1"},
+		},
+		{
+			name: "search by partition, allocation and networks",
+			q: &MachineSearchQuery{
+				PartitionID:       &partition,
+				AllocationProject: &project,
+				Tags:              []string{"a"},
+				NetworkIDs:        []string{"internet"},
+			},
+			wantSQL: `SELECT data FROM "machine" WHERE data @> $1::jsonb AND data @> $2::jsonb AND data @> $3::jsonb AND data @> $4::jsonb ORDER BY id`,
+			wantArgs: []any{
+				`{"partitionid":"p1"}`,
+				`{"tags":["a"]}`,
+				`{"allocation":{"project":"project"}}`,
+				`{"allocation":{"networks":[{"networkid":"internet"}]}}`,
+			},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			gotSQL, gotArgs := tt.q.generateSQL().sql(machineTableName)
+			if diff := cmp.Diff(tt.wantSQL, gotSQL); diff != "" {
+				t.Errorf("generateSQL() diff = %s", diff)
+			}
+			if diff := cmp.Diff(tt.wantArgs, gotArgs); diff != "" {
+				t.Errorf("generateSQL() args diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestIPSearchQuery_generateSQL(t *testing.T) {
+	ip := "10.0.0.1"
+	machineID := "m1"
+	ipType := string(metal.Ephemeral)
+
+	gotSQL, gotArgs := (&IPSearchQuery{IPAddress: &ip, MachineID: &machineID, Type: &ipType}).generateSQL().sql(ipTableName)
+
+	if diff := cmp.Diff(`SELECT data FROM "ip" WHERE id = $1 AND data @> $2::jsonb AND data @> $3::jsonb ORDER BY id`, gotSQL); diff != "" {
+		t.Errorf("generateSQL() diff = %s", diff)
+	}
+	if diff := cmp.Diff([]any{"10.0.0.1", `{"tags":["machine.metal-stack.io/id=m1"]}`, `{"type":"ephemeral"}`}, gotArgs); diff != "" {
+		t.Errorf("generateSQL() args diff = %s", diff)
+	}
+}
+
+func TestNetworkSearchQuery_generateSQL(t *testing.T) {
+	gotSQL, gotArgs := (&NetworkSearchQuery{Prefixes: []string{"10.0.0.0/8"}}).generateSQL().sql(networkTableName)
+
+	if diff := cmp.Diff(`SELECT data FROM "network" WHERE data @> $1::jsonb AND data @> $2::jsonb ORDER BY id`, gotSQL); diff != "" {
+		t.Errorf("generateSQL() diff = %s", diff)
+	}
+	if diff := cmp.Diff([]any{`{"prefixes":[{"ip":"10.0.0.0"}]}`, `{"prefixes":[{"length":"8"}]}`}, gotArgs); diff != "" {
+		t.Errorf("generateSQL() args diff = %s", diff)
+	}
+}
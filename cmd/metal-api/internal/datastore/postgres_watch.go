@@ -0,0 +1,165 @@
+package datastore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+const (
+	// postgresChangelogTable holds the recent changes of the watched tables, the sequence number
+	// of a change is published on the notification channel of the same name.
+	postgresChangelogTable = "changelog"
+	// postgresChangelogSize is the amount of changes kept in the changelog.
+	postgresChangelogSize = 10000
+)
+
+// postgresWatchedTables are the tables whose changes are written to the changelog.
+var postgresWatchedTables = []string{machineTableName, eventTableName}
+
+// initChangelog creates the changelog and the triggers which fill it. The changelog is needed because
+// notification payloads are limited to 8000 bytes, which is not enough for a machine.
+func (ps *PostgresStore) initChangelog() error {
+	_, err := ps.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %q (
+	seq       BIGSERIAL PRIMARY KEY,
+	tablename TEXT NOT NULL,
+	op        TEXT NOT NULL,
+	data      JSONB NOT NULL
+)`, postgresChangelogTable))
+	if err != nil {
+		return fmt.Errorf("cannot create table %s: %w", postgresChangelogTable, err)
+	}
+
+	_, err = ps.db.Exec(fmt.Sprintf(`CREATE OR REPLACE FUNCTION record_change() RETURNS trigger AS $$
+DECLARE
+	s BIGINT;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		INSERT INTO %[1]q (tablename, op, data) VALUES (TG_TABLE_NAME, TG_OP, OLD.data) RETURNING seq INTO s;
+	ELSE
+		INSERT INTO %[1]q (tablename, op, data) VALUES (TG_TABLE_NAME, TG_OP, NEW.data) RETURNING seq INTO s;
+	END IF;
+	DELETE FROM %[1]q WHERE seq <= s - %[2]d;
+	PERFORM pg_notify('%[1]s', s::text);
+	RETURN NULL;
+END;
+$$ LANGUAGE plpgsql`, postgresChangelogTable, postgresChangelogSize))
+	if err != nil {
+		return fmt.Errorf("cannot create changelog function: %w", err)
+	}
+
+	for _, table := range postgresWatchedTables {
+		trigger := table + "_changes"
+		_, err = ps.db.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS %q ON %q`, trigger, table))
+		if err != nil {
+			return fmt.Errorf("cannot drop changelog trigger of table %s: %w", table, err)
+		}
+		_, err = ps.db.Exec(fmt.Sprintf(`CREATE TRIGGER %q AFTER INSERT OR UPDATE OR DELETE ON %q FOR EACH ROW EXECUTE FUNCTION record_change()`, trigger, table))
+		if err != nil {
+			return fmt.Errorf("cannot create changelog trigger of table %s: %w", table, err)
+		}
+	}
+
+	return nil
+}
+
+// WatchMachines streams the changes of the machine table.
+func (ps *PostgresStore) WatchMachines(ctx context.Context) (<-chan Change[metal.Machine], error) {
+	return watchPostgresEntities[metal.Machine](ctx, ps, machineTableName)
+}
+
+// WatchProvisioningEventContainers streams the changes of the event table.
+func (ps *PostgresStore) WatchProvisioningEventContainers(ctx context.Context) (<-chan Change[metal.ProvisioningEventContainer], error) {
+	return watchPostgresEntities[metal.ProvisioningEventContainer](ctx, ps, eventTableName)
+}
+
+// watchPostgresEntities listens for notifications of the changelog. If the connection of the listener
+// is lost, notifications may have been missed, so the returned channel is closed.
+func watchPostgresEntities[E any](ctx context.Context, ps *PostgresStore, table string) (<-chan Change[E], error) {
+	listener := pq.NewListener(ps.dsn(), time.Second, time.Minute, nil)
+	err := listener.Listen(postgresChangelogTable)
+	if err != nil {
+		_ = listener.Close()
+		var e E
+		return nil, fmt.Errorf("cannot watch %v: %w", getEntityName(&e), err)
+	}
+
+	changes := make(chan Change[E])
+	go func() {
+		defer close(changes)
+		defer func() {
+			_ = listener.Close()
+		}()
+
+		for {
+			select {
+			case n := <-listener.Notify:
+				if n == nil {
+					ps.log.Warnw("connection of the changelog listener was reestablished, closing watch", "table", table)
+					return
+				}
+
+				c, ok, err := readPostgresChange[E](ctx, ps, table, n.Extra)
+				if err != nil {
+					ps.log.Errorw("cannot read change", "table", table, "seq", n.Extra, "error", err)
+					return
+				}
+				if !ok {
+					continue
+				}
+
+				select {
+				case changes <- *c:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return changes, nil
+}
+
+// readPostgresChange reads a change from the changelog, it returns false if the change belongs to another table.
+func readPostgresChange[E any](ctx context.Context, ps *PostgresStore, table string, seq string) (*Change[E], bool, error) {
+	var row struct {
+		Tablename string `db:"tablename"`
+		Op        string `db:"op"`
+		Data      []byte `db:"data"`
+	}
+	err := ps.db.GetContext(ctx, &row, fmt.Sprintf(`SELECT tablename, op, data FROM %q WHERE seq = $1`, postgresChangelogTable), seq)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, errors.New("change was already removed from the changelog")
+		}
+		return nil, false, err
+	}
+
+	if row.Tablename != table {
+		return nil, false, nil
+	}
+
+	var e E
+	err = json.Unmarshal(row.Data, &e)
+	if err != nil {
+		return nil, false, err
+	}
+
+	c := &Change[E]{Type: ChangeTypeUpdate, Entity: &e}
+	switch row.Op {
+	case "INSERT":
+		c.Type = ChangeTypeCreate
+	case "DELETE":
+		c.Type = ChangeTypeDelete
+	}
+
+	return c, true, nil
+}
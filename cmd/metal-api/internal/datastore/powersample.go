@@ -0,0 +1,36 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// ListPowerSamples returns all power samples.
+func (rs *RethinkStore) ListPowerSamples() (metal.PowerSamples, error) {
+	samples := make(metal.PowerSamples, 0)
+	err := rs.listEntities(rs.powerSampleTable(), &samples)
+	return samples, err
+}
+
+// SearchPowerSamples returns the power samples recorded within the given period.
+func (rs *RethinkStore) SearchPowerSamples(from, to time.Time) (metal.PowerSamples, error) {
+	q := rs.powerSampleTable().Filter(func(row r.Term) r.Term {
+		return row.Field("timestamp").During(from, to, r.DuringOpts{RightBound: "closed"})
+	})
+
+	samples := make(metal.PowerSamples, 0)
+	err := rs.searchEntities(&q, &samples)
+	return samples, err
+}
+
+// CreatePowerSample creates a new power sample.
+func (rs *RethinkStore) CreatePowerSample(s *metal.PowerSample) error {
+	return rs.createEntity(rs.powerSampleTable(), s)
+}
+
+// DeletePowerSample deletes a power sample.
+func (rs *RethinkStore) DeletePowerSample(s *metal.PowerSample) error {
+	return rs.deleteEntity(rs.powerSampleTable(), s)
+}
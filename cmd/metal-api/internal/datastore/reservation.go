@@ -0,0 +1,35 @@
+package datastore
+
+import "github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+
+// FindReservation returns a reservation for a given id.
+func (rs *RethinkStore) FindReservation(id string) (*metal.Reservation, error) {
+	var res metal.Reservation
+	err := rs.findEntityByID(rs.reservationTable(), &res, id)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// ListReservations returns all reservations.
+func (rs *RethinkStore) ListReservations() (metal.Reservations, error) {
+	res := make(metal.Reservations, 0)
+	err := rs.listEntities(rs.reservationTable(), &res)
+	return res, err
+}
+
+// CreateReservation creates a new reservation.
+func (rs *RethinkStore) CreateReservation(res *metal.Reservation) error {
+	return rs.createEntity(rs.reservationTable(), res)
+}
+
+// DeleteReservation deletes a reservation.
+func (rs *RethinkStore) DeleteReservation(res *metal.Reservation) error {
+	return rs.deleteEntity(rs.reservationTable(), res)
+}
+
+// UpdateReservation updates a reservation.
+func (rs *RethinkStore) UpdateReservation(oldReservation *metal.Reservation, newReservation *metal.Reservation) error {
+	return rs.updateEntity(rs.reservationTable(), newReservation, oldReservation)
+}
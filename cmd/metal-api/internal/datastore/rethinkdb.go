@@ -18,7 +18,7 @@ const (
 )
 
 var tables = []string{
-	"image", "size", "partition", "machine", "switch", "switchstatus", "event", "network", "ip", "migration", "filesystemlayout", "sizeimageconstraint",
+	"image", "size", "partition", "machine", "switch", "switchstatus", "event", "network", "ip", "migration", "filesystemlayout", "sizeimageconstraint", "reservation", "pendingallocation", "rollout", "powersample", "revision",
 	VRFIntegerPool.String(), VRFIntegerPool.String() + "info",
 	ASNIntegerPool.String(), ASNIntegerPool.String() + "info",
 }
@@ -40,8 +40,14 @@ type RethinkStore struct {
 	VRFPoolRangeMax uint
 	ASNPoolRangeMin uint
 	ASNPoolRangeMax uint
+	// PartitionASNPoolRanges contains the ranges of the partitions which have an asn pool of their own
+	PartitionASNPoolRanges map[string]IntegerRange
+
+	revisionInfo RevisionInfo
 }
 
+var _ Store = &RethinkStore{}
+
 // New creates a new rethink store.
 func New(log *zap.SugaredLogger, dbhost string, dbname string, dbuser string, dbpass string) *RethinkStore {
 	return &RethinkStore{
@@ -58,6 +64,16 @@ func New(log *zap.SugaredLogger, dbhost string, dbname string, dbuser string, db
 	}
 }
 
+// tableNames returns the names of all tables including the tables of the partition asn pools.
+func (rs *RethinkStore) tableNames() []string {
+	result := append([]string{}, tables...)
+	for partitionID := range rs.PartitionASNPoolRanges {
+		name := PartitionASNIntegerPool(partitionID).String()
+		result = append(result, name, name+"info")
+	}
+	return result
+}
+
 func multi(session r.QueryExecutor, tt ...r.Term) error {
 	for _, t := range tt {
 		if err := t.Exec(session); err != nil {
@@ -88,13 +104,18 @@ func (rs *RethinkStore) initializeTables(opts r.TableCreateOpts) error {
 		// 	return db.Table("integerpoolinfo").Config().Update(map[string]interface{}{"name": VRFIntegerPoolName + "info"})
 		// }),
 		// create our tables
-		r.Expr(tables).Difference(db.TableList()).ForEach(func(r r.Term) r.Term {
+		r.Expr(rs.tableNames()).Difference(db.TableList()).ForEach(func(r r.Term) r.Term {
 			return db.TableCreate(r, opts)
 		}),
 		// create indices
 		db.Table("machine").IndexList().Contains("project").Do(func(i r.Term) r.Term {
 			return r.Branch(i, nil, db.Table("machine").IndexCreate("project"))
 		}),
+		db.Table(revisionTableName).IndexList().Contains("entity").Do(func(i r.Term) r.Term {
+			return r.Branch(i, nil, db.Table(revisionTableName).IndexCreateFunc("entity", func(row r.Term) interface{} {
+				return []interface{}{row.Field("entitykind"), row.Field("entityid")}
+			}))
+		}),
 	)
 	if err != nil {
 		return err
@@ -122,16 +143,23 @@ func (rs *RethinkStore) initializeTables(opts r.TableCreateOpts) error {
 	}
 
 	// integer pools
-	err = rs.GetVRFPool().initIntegerPool(rs.log)
+	err = rs.vrfPool().initIntegerPool(rs.log)
 	if err != nil {
 		return err
 	}
 
-	err = rs.GetASNPool().initIntegerPool(rs.log)
+	err = rs.asnPool().initIntegerPool(rs.log)
 	if err != nil {
 		return err
 	}
 
+	for partitionID := range rs.PartitionASNPoolRanges {
+		err = rs.partitionASNPool(partitionID).initIntegerPool(rs.log)
+		if err != nil {
+			return err
+		}
+	}
+
 	rs.log.Info("database init complete")
 
 	return nil
@@ -190,6 +218,26 @@ func (rs *RethinkStore) sizeImageConstraintTable() *r.Term {
 	return &res
 }
 
+func (rs *RethinkStore) reservationTable() *r.Term {
+	res := r.DB(rs.dbname).Table("reservation")
+	return &res
+}
+
+func (rs *RethinkStore) pendingAllocationTable() *r.Term {
+	res := r.DB(rs.dbname).Table("pendingallocation")
+	return &res
+}
+
+func (rs *RethinkStore) rolloutTable() *r.Term {
+	res := r.DB(rs.dbname).Table("rollout")
+	return &res
+}
+
+func (rs *RethinkStore) powerSampleTable() *r.Term {
+	res := r.DB(rs.dbname).Table("powersample")
+	return &res
+}
+
 func (rs *RethinkStore) asnTable() *r.Term {
 	res := r.DB(rs.dbname).Table(ASNIntegerPool.String())
 	return &res
@@ -392,6 +440,8 @@ func (rs *RethinkStore) createEntity(table *r.Term, entity metal.Entity) error {
 		entity.SetID(res.GeneratedKeys[0])
 	}
 
+	rs.recordRevision(metal.RevisionOperationCreate, entity)
+
 	return nil
 }
 
@@ -412,6 +462,27 @@ func (rs *RethinkStore) upsertEntity(table *r.Term, entity metal.Entity) error {
 	if entity.GetID() == "" && len(res.GeneratedKeys) > 0 {
 		entity.SetID(res.GeneratedKeys[0])
 	}
+
+	rs.recordRevision(upsertOperation(entity), entity)
+
+	return nil
+}
+
+// RestoreEntity writes the entity as it is, keeping its id and timestamps.
+func (rs *RethinkStore) RestoreEntity(entity metal.Entity) error {
+	table, err := tableNameOf(entity)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.DB(rs.dbname).Table(table).Insert(entity).RunWrite(rs.session)
+	if err != nil {
+		if r.IsConflictErr(err) {
+			return metal.Conflict("cannot restore %v in database, entity already exists: %s", getEntityName(entity), entity.GetID())
+		}
+		return fmt.Errorf("cannot restore %v (%s) in database: %w", getEntityName(entity), entity.GetID(), err)
+	}
+
 	return nil
 }
 
@@ -420,6 +491,9 @@ func (rs *RethinkStore) deleteEntity(table *r.Term, entity metal.Entity) error {
 	if err != nil {
 		return fmt.Errorf("cannot delete %v with id %q from database: %w", getEntityName(entity), entity.GetID(), err)
 	}
+
+	rs.recordRevision(metal.RevisionOperationDelete, entity)
+
 	return nil
 }
 
@@ -436,6 +510,8 @@ func (rs *RethinkStore) updateEntity(table *r.Term, newEntity metal.Entity, oldE
 		return fmt.Errorf("cannot update %v (%s): %w", getEntityName(newEntity), oldEntity.GetID(), err)
 	}
 
+	rs.recordRevision(metal.RevisionOperationUpdate, newEntity)
+
 	return nil
 }
 
@@ -0,0 +1,149 @@
+package datastore
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+const revisionTableName = "revision"
+
+// RevisionInfo describes who caused the changes which are recorded as revisions.
+type RevisionInfo struct {
+	User      string
+	RequestID string
+}
+
+// RevisionKinds are the kinds of entities whose changes are recorded as revisions. Provisioning events
+// and switch states are left out because they change with a high frequency and are not configuration.
+var RevisionKinds = []string{
+	machineTableName,
+	switchTableName,
+	networkTableName,
+	ipTableName,
+	imageTableName,
+	sizeTableName,
+	partitionTableName,
+	filesystemLayoutTableName,
+	sizeImageConstraintTableName,
+	reservationTableName,
+}
+
+func isRevisionKind(kind string) bool {
+	for _, k := range RevisionKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// newRevision creates the revision for a change of the given entity, it returns nil if changes of
+// the entity are not recorded.
+func newRevision(info RevisionInfo, op metal.RevisionOperation, entity metal.Entity) (*metal.Revision, error) {
+	kind, err := tableNameOf(entity)
+	if err != nil {
+		return nil, err
+	}
+	if !isRevisionKind(kind) {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	var snapshot map[string]any
+	err = json.Unmarshal(raw, &snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode %v: %w", getEntityName(entity), err)
+	}
+
+	timestamp := entity.GetChanged()
+	if op == metal.RevisionOperationDelete || timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	return &metal.Revision{
+		EntityKind: kind,
+		EntityID:   entity.GetID(),
+		Operation:  op,
+		Timestamp:  timestamp,
+		User:       info.User,
+		RequestID:  info.RequestID,
+		Snapshot:   snapshot,
+	}, nil
+}
+
+// upsertOperation returns the operation of an upsert, entities whose creation and change timestamp
+// are equal were just created.
+func upsertOperation(entity metal.Entity) metal.RevisionOperation {
+	if entity.GetCreated().Equal(entity.GetChanged()) {
+		return metal.RevisionOperationCreate
+	}
+	return metal.RevisionOperationUpdate
+}
+
+// FindRevisionAt returns the state of the entity at the given point in time.
+func FindRevisionAt(ds RevisionStore, kind, id string, at time.Time) (*metal.Revision, error) {
+	revisions, err := ds.ListRevisions(kind, id)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := metal.Revisions(revisions).At(at)
+	if revision == nil {
+		return nil, metal.NotFound("%s with id %q did not exist at %s", kind, id, at.Format(time.RFC3339))
+	}
+
+	return revision, nil
+}
+
+func (rs *RethinkStore) revisionTable() *r.Term {
+	res := r.DB(rs.dbname).Table(revisionTableName)
+	return &res
+}
+
+// WithRevisionInfo returns a copy of the store which records the given information with all revisions.
+func (rs *RethinkStore) WithRevisionInfo(info RevisionInfo) Store {
+	c := *rs
+	c.revisionInfo = info
+	return &c
+}
+
+// recordRevision writes a revision of the entity. The change of the entity already happened, so
+// failures are only logged.
+func (rs *RethinkStore) recordRevision(op metal.RevisionOperation, entity metal.Entity) {
+	revision, err := newRevision(rs.revisionInfo, op, entity)
+	if err == nil && revision != nil {
+		_, err = rs.revisionTable().Insert(revision).RunWrite(rs.session)
+	}
+	if err != nil {
+		rs.log.Errorw("unable to record revision", "entity", getEntityName(entity), "id", entity.GetID(), "error", err)
+	}
+}
+
+// ListRevisions returns all revisions of an entity sorted by their timestamp.
+func (rs *RethinkStore) ListRevisions(kind, id string) (metal.Revisions, error) {
+	if !isRevisionKind(kind) {
+		return nil, fmt.Errorf("revisions of %s are not recorded", kind)
+	}
+
+	res, err := rs.revisionTable().GetAllByIndex("entity", []any{kind, id}).OrderBy("timestamp").Run(rs.session)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list revisions from database: %w", err)
+	}
+	defer res.Close()
+
+	revisions := metal.Revisions{}
+	err = res.All(&revisions)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch all revisions: %w", err)
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,66 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRevisions(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := NewMemory(log)
+
+	creator := ds.WithRevisionInfo(RevisionInfo{User: "alice", RequestID: "rq-1"})
+	m := &metal.Machine{Base: metal.Base{ID: "m1", Name: "first"}}
+	require.NoError(t, creator.CreateMachine(m))
+	created := m.Changed
+
+	time.Sleep(time.Millisecond)
+
+	updater := ds.WithRevisionInfo(RevisionInfo{User: "bob", RequestID: "rq-2"})
+	newMachine := *m
+	newMachine.Name = "second"
+	require.NoError(t, updater.UpdateMachine(m, &newMachine))
+	updated := newMachine.Changed
+
+	time.Sleep(time.Millisecond)
+
+	require.NoError(t, updater.DeleteMachine(&newMachine))
+
+	// switch states are not recorded
+	require.NoError(t, ds.SetSwitchStatus(&metal.SwitchStatus{Base: metal.Base{ID: "sw1"}}))
+
+	revisions, err := ds.ListRevisions(machineTableName, "m1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+
+	assert.Equal(t, metal.RevisionOperationCreate, revisions[0].Operation)
+	assert.Equal(t, "alice", revisions[0].User)
+	assert.Equal(t, "rq-1", revisions[0].RequestID)
+	assert.Equal(t, "first", revisions[0].Snapshot["name"])
+	assert.Equal(t, metal.RevisionOperationUpdate, revisions[1].Operation)
+	assert.Equal(t, "bob", revisions[1].User)
+	assert.Equal(t, "second", revisions[1].Snapshot["name"])
+	assert.Equal(t, metal.RevisionOperationDelete, revisions[2].Operation)
+
+	_, err = FindRevisionAt(ds, machineTableName, "m1", created.Add(-time.Second))
+	assert.True(t, metal.IsNotFound(err), "expected not found before creation, got %v", err)
+
+	revision, err := FindRevisionAt(ds, machineTableName, "m1", created)
+	require.NoError(t, err)
+	assert.Equal(t, "first", revision.Snapshot["name"])
+
+	revision, err = FindRevisionAt(ds, machineTableName, "m1", updated)
+	require.NoError(t, err)
+	assert.Equal(t, "second", revision.Snapshot["name"])
+
+	_, err = FindRevisionAt(ds, machineTableName, "m1", time.Now())
+	assert.True(t, metal.IsNotFound(err), "expected not found after deletion, got %v", err)
+
+	_, err = ds.ListRevisions(switchStatusTableName, "sw1")
+	assert.ErrorContains(t, err, "revisions of switchstatus are not recorded")
+}
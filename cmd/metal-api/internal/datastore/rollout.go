@@ -0,0 +1,30 @@
+package datastore
+
+import "github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+
+// FindRollout returns a rollout for a given id.
+func (rs *RethinkStore) FindRollout(id string) (*metal.Rollout, error) {
+	var r metal.Rollout
+	err := rs.findEntityByID(rs.rolloutTable(), &r, id)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRollouts returns all rollouts.
+func (rs *RethinkStore) ListRollouts() (metal.Rollouts, error) {
+	rollouts := make(metal.Rollouts, 0)
+	err := rs.listEntities(rs.rolloutTable(), &rollouts)
+	return rollouts, err
+}
+
+// CreateRollout creates a new rollout.
+func (rs *RethinkStore) CreateRollout(r *metal.Rollout) error {
+	return rs.createEntity(rs.rolloutTable(), r)
+}
+
+// UpdateRollout updates a rollout.
+func (rs *RethinkStore) UpdateRollout(oldRollout *metal.Rollout, newRollout *metal.Rollout) error {
+	return rs.updateEntity(rs.rolloutTable(), newRollout, oldRollout)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
 )
 
 // FindSize return a size for a given id.
@@ -40,7 +41,11 @@ func (rs *RethinkStore) UpdateSize(oldSize *metal.Size, newSize *metal.Size) err
 
 // FromHardware tries to find a size which matches the given hardware specs.
 func (rs *RethinkStore) FromHardware(hw metal.MachineHardware) (*metal.Size, []*metal.SizeMatchingLog, error) {
-	sz, err := rs.ListSizes()
+	return fromHardware(rs.log, rs, hw)
+}
+
+func fromHardware(log *zap.SugaredLogger, ds SizeStore, hw metal.MachineHardware) (*metal.Size, []*metal.SizeMatchingLog, error) {
+	sz, err := ds.ListSizes()
 	if err != nil {
 		return nil, nil, err
 	}
@@ -51,7 +56,7 @@ func (rs *RethinkStore) FromHardware(hw metal.MachineHardware) (*metal.Size, []*
 	var sizes metal.Sizes
 	for _, s := range sz {
 		if len(s.Constraints) < 1 {
-			rs.log.Error("missing constraints", "size", s)
+			log.Error("missing constraints", "size", s)
 			continue
 		}
 		sizes = append(sizes, s)
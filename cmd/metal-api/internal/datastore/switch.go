@@ -15,6 +15,24 @@ type SwitchSearchQuery struct {
 	RackID      *string `json:"rackid" optional:"true"`
 	OSVendor    *string `json:"osvendor" optional:"true"`
 	OSVersion   *string `json:"osversion" optional:"true"`
+
+	Paging
+}
+
+// switchSortKeys are the fields switches can be sorted by in a search.
+var switchSortKeys = sortKeys{
+	"id":          idSortKey,
+	"name":        {path: []string{"name"}},
+	"partitionid": {path: []string{"partitionid"}},
+	"rackid":      {path: []string{"rackid"}},
+	"created":     {path: []string{"created"}, time: true},
+	"changed":     {path: []string{"changed"}, time: true},
+}
+
+// NextCursor returns the cursor to continue the search after the given page of switches, it returns
+// nil if there are no further switches.
+func (p *SwitchSearchQuery) NextCursor(ss metal.Switches) (*string, error) {
+	return nextCursor(&p.Paging, switchSortKeys, ss)
 }
 
 // GenerateTerm generates the switch search query term.
@@ -60,6 +78,36 @@ func (p *SwitchSearchQuery) generateTerm(rs *RethinkStore) *r.Term {
 	return &q
 }
 
+// matches returns true if the given switch is matched by the query, it has
+// to be kept in line with generateTerm.
+func (p *SwitchSearchQuery) matches(s *metal.Switch) bool {
+	if p.ID != nil && s.ID != *p.ID {
+		return false
+	}
+
+	if p.Name != nil && s.Name != *p.Name {
+		return false
+	}
+
+	if p.PartitionID != nil && s.PartitionID != *p.PartitionID {
+		return false
+	}
+
+	if p.RackID != nil && s.RackID != *p.RackID {
+		return false
+	}
+
+	if p.OSVendor != nil && (s.OS == nil || s.OS.Vendor != *p.OSVendor) {
+		return false
+	}
+
+	if p.OSVersion != nil && (s.OS == nil || s.OS.Version != *p.OSVersion) {
+		return false
+	}
+
+	return true
+}
+
 // FindSwitch returns a switch for a given id.
 func (rs *RethinkStore) FindSwitch(id string) (*metal.Switch, error) {
 	var s metal.Switch
@@ -94,14 +142,22 @@ func (rs *RethinkStore) UpdateSwitch(oldSwitch *metal.Switch, newSwitch *metal.S
 
 // SearchSwitches searches for switches by the given parameters.
 func (rs *RethinkStore) SearchSwitches(q *SwitchSearchQuery, ss *metal.Switches) error {
-	return rs.searchEntities(q.generateTerm(rs), ss)
+	term, err := q.Paging.generateTerm(switchSortKeys, q.generateTerm(rs))
+	if err != nil {
+		return err
+	}
+	return rs.searchEntities(term, ss)
 }
 
 // SearchSwitchesConnectedToMachine searches switches that are connected to the given machine.
 func (rs *RethinkStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (metal.Switches, error) {
+	return searchSwitchesConnectedToMachine(rs, m)
+}
+
+func searchSwitchesConnectedToMachine(ds SwitchStore, m *metal.Machine) (metal.Switches, error) {
 	switches := metal.Switches{}
 
-	err := rs.SearchSwitches(&SwitchSearchQuery{RackID: &m.RackID}, &switches)
+	err := ds.SearchSwitches(&SwitchSearchQuery{RackID: &m.RackID}, &switches)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +175,11 @@ func (rs *RethinkStore) SearchSwitchesConnectedToMachine(m *metal.Machine) (meta
 // SetVrfAtSwitches finds the switches connected to the given machine and puts the switch ports into the given vrf.
 // Returns the updated switches.
 func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Switches, error) {
-	switches, err := rs.SearchSwitchesConnectedToMachine(m)
+	return setVrfAtSwitches(rs, m, vrf)
+}
+
+func setVrfAtSwitches(ds SwitchStore, m *metal.Machine, vrf string) (metal.Switches, error) {
+	switches, err := ds.SearchSwitchesConnectedToMachine(m)
 	if err != nil {
 		return nil, err
 	}
@@ -128,7 +188,7 @@ func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Sw
 		sw := switches[i]
 		oldSwitch := sw
 		sw.SetVrfOfMachine(m, vrf)
-		err := rs.UpdateSwitch(&oldSwitch, &sw)
+		err := ds.UpdateSwitch(&oldSwitch, &sw)
 		if err != nil {
 			return nil, err
 		}
@@ -137,8 +197,14 @@ func (rs *RethinkStore) SetVrfAtSwitches(m *metal.Machine, vrf string) (metal.Sw
 	return newSwitches, nil
 }
 
+// ConnectMachineWithSwitches connects the given machine to the switches it is wired to and
+// detects rack and partition of the machine from these connections.
 func (rs *RethinkStore) ConnectMachineWithSwitches(m *metal.Machine) error {
-	switches, err := rs.ListSwitches()
+	return connectMachineWithSwitches(rs, m)
+}
+
+func connectMachineWithSwitches(ds SwitchStore, m *metal.Machine) error {
+	switches, err := ds.ListSwitches()
 	if err != nil {
 		return err
 	}
@@ -188,7 +254,7 @@ func (rs *RethinkStore) ConnectMachineWithSwitches(m *metal.Machine) error {
 	}
 
 	for i := range oldSwitches {
-		err = rs.UpdateSwitch(&oldSwitches[i], &newSwitches[i])
+		err = ds.UpdateSwitch(&oldSwitches[i], &newSwitches[i])
 		if err != nil {
 			return err
 		}
@@ -207,6 +273,13 @@ func (rs *RethinkStore) GetSwitchStatus(id string) (*metal.SwitchStatus, error)
 	return &ss, nil
 }
 
+// ListSwitchStatuses returns the status of all switches.
+func (rs *RethinkStore) ListSwitchStatuses() ([]metal.SwitchStatus, error) {
+	ss := make([]metal.SwitchStatus, 0)
+	err := rs.listEntities(rs.switchStatusTable(), &ss)
+	return ss, err
+}
+
 // SetSwitchStatus create or update the switch status.
 func (rs *RethinkStore) SetSwitchStatus(state *metal.SwitchStatus) error {
 	return rs.upsertEntity(rs.switchStatusTable(), state)
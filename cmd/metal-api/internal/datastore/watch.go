@@ -0,0 +1,208 @@
+package datastore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"go.uber.org/zap"
+	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
+)
+
+// ChangeType describes what happened to an entity.
+type ChangeType string
+
+const (
+	// ChangeTypeCreate is emitted when an entity was created.
+	ChangeTypeCreate ChangeType = "create"
+	// ChangeTypeUpdate is emitted when an entity was updated.
+	ChangeTypeUpdate ChangeType = "update"
+	// ChangeTypeDelete is emitted when an entity was deleted.
+	ChangeTypeDelete ChangeType = "delete"
+)
+
+// Change is a change of an entity. For deletions the entity contains the last known state.
+type Change[E any] struct {
+	Type   ChangeType
+	Entity *E
+}
+
+// WatchMachines streams the changes of the machine table.
+func (rs *RethinkStore) WatchMachines(ctx context.Context) (<-chan Change[metal.Machine], error) {
+	return watchRethinkEntities[metal.Machine](ctx, rs, rs.machineTable())
+}
+
+// WatchProvisioningEventContainers streams the changes of the event table.
+func (rs *RethinkStore) WatchProvisioningEventContainers(ctx context.Context) (<-chan Change[metal.ProvisioningEventContainer], error) {
+	return watchRethinkEntities[metal.ProvisioningEventContainer](ctx, rs, rs.eventTable())
+}
+
+// watchRethinkEntities opens a changefeed on the given table, the changefeed is closed when the context is done.
+func watchRethinkEntities[E any](ctx context.Context, rs *RethinkStore, table *r.Term) (<-chan Change[E], error) {
+	res, err := table.Changes().Run(rs.session)
+	if err != nil {
+		var e E
+		return nil, fmt.Errorf("cannot watch %v: %w", getEntityName(&e), err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = res.Close()
+	}()
+
+	changes := make(chan Change[E])
+	go func() {
+		defer close(changes)
+
+		var change struct {
+			NewVal *E `rethinkdb:"new_val"`
+			OldVal *E `rethinkdb:"old_val"`
+		}
+		for res.Next(&change) {
+			c := Change[E]{Type: ChangeTypeUpdate, Entity: change.NewVal}
+			switch {
+			case change.OldVal == nil:
+				c.Type = ChangeTypeCreate
+			case change.NewVal == nil:
+				c.Type = ChangeTypeDelete
+				c.Entity = change.OldVal
+			}
+
+			select {
+			case changes <- c:
+			case <-ctx.Done():
+				return
+			}
+
+			change.NewVal = nil
+			change.OldVal = nil
+		}
+
+		if err := res.Err(); err != nil && ctx.Err() == nil {
+			rs.log.Errorw("changefeed was closed", "error", err)
+		}
+	}()
+
+	return changes, nil
+}
+
+// MachineWatchEvent is a change of a machine which is sent to watching clients.
+type MachineWatchEvent struct {
+	Type    ChangeType
+	Machine *metal.Machine
+	// ResourceVersion can be used to resume watching after this event.
+	ResourceVersion int64
+}
+
+// WatchMachineEvents streams the changes of all machines which match the given query. Changes of
+// the provisioning events of a machine are emitted as update of the machine, because they change
+// the liveliness and the last event of the machine.
+//
+// If a resource version is given, all machines which were created or updated after this version are
+// sent first, such that clients can resume a watch without listing all machines again. Deletions
+// cannot be replayed, clients which were disconnected for a longer time should list the machines.
+// Events are delivered at least once.
+func WatchMachineEvents(ctx context.Context, log *zap.SugaredLogger, ds interface {
+	MachineStore
+	WatchStore
+}, q *MachineSearchQuery, resourceVersion int64) (<-chan MachineWatchEvent, error) {
+	if q == nil {
+		q = &MachineSearchQuery{}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	machineChanges, err := ds.WatchMachines(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	eventChanges, err := ds.WatchProvisioningEventContainers(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	var initial metal.Machines
+	if resourceVersion > 0 {
+		err = ds.SearchMachines(q, &initial)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	events := make(chan MachineWatchEvent)
+	go func() {
+		defer cancel()
+		defer close(events)
+
+		send := func(e MachineWatchEvent) bool {
+			select {
+			case events <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		for i := range initial {
+			m := initial[i]
+			if m.Changed.UnixNano() <= resourceVersion {
+				continue
+			}
+			t := ChangeTypeUpdate
+			if m.Created.UnixNano() > resourceVersion {
+				t = ChangeTypeCreate
+			}
+			if !send(MachineWatchEvent{Type: t, Machine: &m, ResourceVersion: m.Changed.UnixNano()}) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case c, ok := <-machineChanges:
+				if !ok {
+					return
+				}
+				if !q.matches(c.Entity) {
+					continue
+				}
+				version := c.Entity.Changed.UnixNano()
+				if c.Type == ChangeTypeDelete {
+					version = time.Now().UnixNano()
+				}
+				if !send(MachineWatchEvent{Type: c.Type, Machine: c.Entity, ResourceVersion: version}) {
+					return
+				}
+			case c, ok := <-eventChanges:
+				if !ok {
+					return
+				}
+				if c.Type == ChangeTypeDelete {
+					continue
+				}
+				m, err := ds.FindMachineByID(c.Entity.ID)
+				if err != nil {
+					if !metal.IsNotFound(err) {
+						log.Errorw("unable to find machine of provisioning event", "id", c.Entity.ID, "error", err)
+					}
+					continue
+				}
+				if !q.matches(m) {
+					continue
+				}
+				if !send(MachineWatchEvent{Type: ChangeTypeUpdate, Machine: m, ResourceVersion: c.Entity.Changed.UnixNano()}) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, nil
+}
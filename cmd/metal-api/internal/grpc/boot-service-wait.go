@@ -149,5 +149,9 @@ func (b *BootService) updateWaitingFlag(machineID string, flag bool) error {
 	}
 	old := *m
 	m.Waiting = flag
+	if flag {
+		// a waiting machine is powered on, regardless of whether it was powered off by a power policy before
+		m.PowerSaving = false
+	}
 	return b.ds.UpdateMachine(&old, m)
 }
@@ -18,7 +18,7 @@ import (
 
 type BootService struct {
 	log              *zap.SugaredLogger
-	ds               *datastore.RethinkStore
+	ds               datastore.Store
 	ipmiSuperUser    metal.MachineIPMISuperUser
 	publisher        bus.Publisher
 	consumer         *bus.Consumer
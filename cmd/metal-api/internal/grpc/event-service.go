@@ -14,7 +14,7 @@ import (
 
 type EventService struct {
 	log *zap.SugaredLogger
-	ds  *datastore.RethinkStore
+	ds  datastore.Store
 }
 
 func NewEventService(cfg *ServerConfig) *EventService {
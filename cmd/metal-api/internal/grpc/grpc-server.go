@@ -39,7 +39,7 @@ type ServerConfig struct {
 	Context                  context.Context
 	Publisher                bus.Publisher
 	Consumer                 *bus.Consumer
-	Store                    *datastore.RethinkStore
+	Store                    datastore.Store
 	Logger                   *zap.SugaredLogger
 	GrpcPort                 int
 	TlsEnabled               bool
@@ -125,6 +125,7 @@ func Run(cfg *ServerConfig) error {
 
 	eventService := NewEventService(cfg)
 	bootService := NewBootService(cfg, eventService)
+	machineService := NewMachineService(cfg)
 
 	err := bootService.initWaitEndpoint()
 	if err != nil {
@@ -133,6 +134,7 @@ func Run(cfg *ServerConfig) error {
 
 	v1.RegisterEventServiceServer(server, eventService)
 	v1.RegisterBootServiceServer(server, bootService)
+	v1.RegisterMachineServiceServer(server, machineService)
 
 	// this is only for the integration test of this package
 	if cfg.integrationTestAllocator != nil {
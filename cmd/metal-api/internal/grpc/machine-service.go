@@ -0,0 +1,196 @@
+package grpc
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/pkg/api/v1"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type MachineService struct {
+	log *zap.SugaredLogger
+	ds  datastore.Store
+}
+
+func NewMachineService(cfg *ServerConfig) *MachineService {
+	return &MachineService{
+		ds:  cfg.Store,
+		log: cfg.Logger.Named("machine-service"),
+	}
+}
+
+// Watch streams the changes of all machines matching the query until the client cancels the call.
+func (m *MachineService) Watch(req *v1.MachineServiceWatchRequest, srv v1.MachineService_WatchServer) error {
+	m.log.Infow("watch machines called", "query", req.Query, "resource version", req.ResourceVersion)
+
+	events, err := datastore.WatchMachineEvents(srv.Context(), m.log, m.ds, toMachineSearchQuery(req.Query), req.ResourceVersion)
+	if err != nil {
+		return err
+	}
+
+	for e := range events {
+		liveliness := ""
+		ec, err := m.ds.FindProvisioningEventContainer(e.Machine.ID)
+		if err != nil && !metal.IsNotFound(err) {
+			return err
+		}
+		if ec != nil {
+			liveliness = string(ec.Liveliness)
+		}
+
+		err = srv.Send(&v1.MachineServiceWatchResponse{
+			Type:            toMachineEventType(e.Type),
+			ResourceVersion: e.ResourceVersion,
+			Machine:         toMachine(e.Machine, liveliness),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return srv.Context().Err()
+}
+
+func toMachineEventType(t datastore.ChangeType) v1.MachineEventType {
+	switch t {
+	case datastore.ChangeTypeCreate:
+		return v1.MachineEventType_MACHINE_EVENT_TYPE_CREATE
+	case datastore.ChangeTypeUpdate:
+		return v1.MachineEventType_MACHINE_EVENT_TYPE_UPDATE
+	case datastore.ChangeTypeDelete:
+		return v1.MachineEventType_MACHINE_EVENT_TYPE_DELETE
+	default:
+		return v1.MachineEventType_MACHINE_EVENT_TYPE_UNSPECIFIED
+	}
+}
+
+func toMachine(m *metal.Machine, liveliness string) *v1.Machine {
+	var nics []*v1.MachineNic
+	for _, nic := range m.Hardware.Nics {
+		nics = append(nics, toMachineNic(nic))
+	}
+
+	var disks []*v1.MachineBlockDevice
+	for _, disk := range m.Hardware.Disks {
+		disks = append(disks, &v1.MachineBlockDevice{
+			Name: disk.Name,
+			Size: disk.Size,
+		})
+	}
+
+	var allocation *v1.MachineAllocation
+	if m.Allocation != nil {
+		allocation = &v1.MachineAllocation{
+			Name:        m.Allocation.Name,
+			Description: m.Allocation.Description,
+			Project:     m.Allocation.Project,
+			Hostname:    m.Allocation.Hostname,
+			ImageId:     m.Allocation.ImageID,
+			Role:        string(m.Allocation.Role),
+			Succeeded:   m.Allocation.Succeeded,
+			Created:     timestamppb.New(m.Allocation.Created),
+		}
+	}
+
+	return &v1.Machine{
+		Id:          m.ID,
+		Name:        m.Name,
+		Description: m.Description,
+		PartitionId: m.PartitionID,
+		SizeId:      m.SizeID,
+		RackId:      m.RackID,
+		Tags:        m.Tags,
+		State: &v1.MachineState{
+			Value:       string(m.State.Value),
+			Description: m.State.Description,
+		},
+		Liveliness:   liveliness,
+		Waiting:      m.Waiting,
+		PreAllocated: m.PreAllocated,
+		Allocation:   allocation,
+		Hardware: &v1.MachineHardware{
+			Memory:   m.Hardware.Memory,
+			CpuCores: uint32(m.Hardware.CPUCores),
+			Disks:    disks,
+			Nics:     nics,
+		},
+		Bios: &v1.MachineBIOS{
+			Version: m.BIOS.Version,
+			Vendor:  m.BIOS.Vendor,
+			Date:    m.BIOS.Date,
+		},
+		Created: timestamppb.New(m.Created),
+		Changed: timestamppb.New(m.Changed),
+	}
+}
+
+func toMachineNic(nic metal.Nic) *v1.MachineNic {
+	var neighbors []*v1.MachineNic
+	for _, n := range nic.Neighbors {
+		neighbors = append(neighbors, toMachineNic(n))
+	}
+	return &v1.MachineNic{
+		Mac:        string(nic.MacAddress),
+		Name:       nic.Name,
+		Hostname:   nic.Hostname,
+		Identifier: nic.Identifier,
+		Neighbors:  neighbors,
+	}
+}
+
+func toMachineSearchQuery(q *v1.MachineQuery) *datastore.MachineSearchQuery {
+	if q == nil {
+		return &datastore.MachineSearchQuery{}
+	}
+
+	var role *metal.Role
+	if q.AllocationRole != nil {
+		r := metal.Role(*q.AllocationRole)
+		role = &r
+	}
+
+	return &datastore.MachineSearchQuery{
+		ID:                         q.Id,
+		Name:                       q.Name,
+		PartitionID:                q.PartitionId,
+		SizeID:                     q.SizeId,
+		RackID:                     q.RackId,
+		Tags:                       q.Tags,
+		AllocationName:             q.AllocationName,
+		AllocationProject:          q.AllocationProject,
+		AllocationImageID:          q.AllocationImageId,
+		AllocationHostname:         q.AllocationHostname,
+		AllocationRole:             role,
+		AllocationSucceeded:        q.AllocationSucceeded,
+		NetworkIDs:                 q.NetworkIds,
+		NetworkPrefixes:            q.NetworkPrefixes,
+		NetworkIPs:                 q.NetworkIps,
+		NetworkDestinationPrefixes: q.NetworkDestinationPrefixes,
+		NetworkVrfs:                q.NetworkVrfs,
+		NetworkASNs:                q.NetworkAsns,
+		HardwareMemory:             q.HardwareMemory,
+		HardwareCPUCores:           q.HardwareCpuCores,
+		NicsMacAddresses:           q.NicsMacAddresses,
+		NicsNames:                  q.NicsNames,
+		NicsVrfs:                   q.NicsVrfs,
+		NicsNeighborMacAddresses:   q.NicsNeighborMacAddresses,
+		NicsNeighborNames:          q.NicsNeighborNames,
+		NicsNeighborVrfs:           q.NicsNeighborVrfs,
+		DiskNames:                  q.DiskNames,
+		DiskSizes:                  q.DiskSizes,
+		StateValue:                 q.StateValue,
+		IpmiAddress:                q.IpmiAddress,
+		IpmiMacAddress:             q.IpmiMacAddress,
+		IpmiUser:                   q.IpmiUser,
+		IpmiInterface:              q.IpmiInterface,
+		FruChassisPartNumber:       q.FruChassisPartNumber,
+		FruChassisPartSerial:       q.FruChassisPartSerial,
+		FruBoardMfg:                q.FruBoardMfg,
+		FruBoardMfgSerial:          q.FruBoardMfgSerial,
+		FruBoardPartNumber:         q.FruBoardPartNumber,
+		FruProductManufacturer:     q.FruProductManufacturer,
+		FruProductPartNumber:       q.FruProductPartNumber,
+		FruProductSerial:           q.FruProductSerial,
+	}
+}
@@ -29,7 +29,7 @@ func (i *issueBMCInfoOutdated) Evaluate(m metal.Machine, ec metal.ProvisioningEv
 
 	lastUpdated := time.Since(m.IPMI.LastUpdated)
 
-	if lastUpdated > 20*time.Minute {
+	if lastUpdated > metal.IPMIOutdatedAfter {
 		i.details = fmt.Sprintf("last updated %s ago", lastUpdated.String())
 		return true
 	}
@@ -89,18 +89,25 @@ func TestFindIssues(t *testing.T) {
 			name: "liveliness dead",
 			only: []Type{TypeLivelinessDead},
 			machines: func() metal.Machines {
+				poweredOff := machineTemplate("powered-off")
+				poweredOff.PowerSaving = true
+
 				return metal.Machines{
 					machineTemplate("dead"),
 					machineTemplate("good"),
+					poweredOff,
 				}
 			},
 			eventContainers: func() metal.ProvisioningEventContainers {
 				dead := eventContainerTemplate("dead")
 				dead.Liveliness = metal.MachineLivelinessDead
+				poweredOff := eventContainerTemplate("powered-off")
+				poweredOff.Liveliness = metal.MachineLivelinessDead
 
 				return metal.ProvisioningEventContainers{
 					dead,
 					eventContainerTemplate("good"),
+					poweredOff,
 				}
 			},
 			want: func(machines metal.Machines) MachineIssues {
@@ -20,7 +20,8 @@ func (i *issueLivelinessDead) Spec() *spec {
 }
 
 func (i *issueLivelinessDead) Evaluate(m metal.Machine, ec metal.ProvisioningEventContainer, c *Config) bool {
-	return ec.Liveliness == metal.MachineLivelinessDead
+	// machines which were powered off by the power policy of their partition are not expected to send events
+	return ec.Liveliness == metal.MachineLivelinessDead && !m.PowerSaving
 }
 
 func (i *issueLivelinessDead) Details() string {
@@ -20,7 +20,8 @@ func (i *issueLivelinessUnknown) Spec() *spec {
 }
 
 func (i *issueLivelinessUnknown) Evaluate(m metal.Machine, ec metal.ProvisioningEventContainer, c *Config) bool {
-	return ec.Liveliness == metal.MachineLivelinessUnknown
+	// machines which were powered off by the power policy of their partition are not expected to send events
+	return ec.Liveliness == metal.MachineLivelinessUnknown && !m.PowerSaving
 }
 
 func (i *issueLivelinessUnknown) Details() string {
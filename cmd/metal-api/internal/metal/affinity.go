@@ -0,0 +1,63 @@
+package metal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// MachineAffinity places an allocated machine relative to the other machines of the project in the partition.
+type MachineAffinity struct {
+	// RackAffinity places the machine in a rack with machines matching the terms.
+	RackAffinity []AffinityTerm `rethinkdb:"rack_affinity" json:"rack_affinity"`
+	// RackAntiAffinity places the machine in a rack without machines matching the terms.
+	RackAntiAffinity []AffinityTerm `rethinkdb:"rack_anti_affinity" json:"rack_anti_affinity"`
+}
+
+// AffinityTerm matches either the machines carrying all of the tags or the machine with the given id.
+// Required terms must be satisfied by the allocation, other terms are only preferred.
+type AffinityTerm struct {
+	Tags      []string `rethinkdb:"tags" json:"tags"`
+	MachineID string   `rethinkdb:"machineid" json:"machineid"`
+	Required  bool     `rethinkdb:"required" json:"required"`
+}
+
+// IsEmpty returns true if no affinity terms are given.
+func (a MachineAffinity) IsEmpty() bool {
+	return len(a.RackAffinity) == 0 && len(a.RackAntiAffinity) == 0
+}
+
+// Validate returns an error if a term does not reference either tags or a machine.
+func (a MachineAffinity) Validate() error {
+	for _, t := range append(append([]AffinityTerm{}, a.RackAffinity...), a.RackAntiAffinity...) {
+		if len(t.Tags) == 0 && t.MachineID == "" {
+			return errors.New("affinity term must either contain tags or a machine id")
+		}
+		if len(t.Tags) > 0 && t.MachineID != "" {
+			return errors.New("affinity term cannot contain tags and a machine id at the same time")
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the given machine matches the term.
+func (t AffinityTerm) Matches(m *Machine) bool {
+	if t.MachineID != "" {
+		return m.ID == t.MachineID
+	}
+	for _, tag := range t.Tags {
+		if !slices.Contains(m.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t AffinityTerm) String() string {
+	if t.MachineID != "" {
+		return fmt.Sprintf("machine %s", t.MachineID)
+	}
+	return fmt.Sprintf("machines tagged %s", strings.Join(t.Tags, ","))
+}
@@ -0,0 +1,40 @@
+package metal
+
+import "testing"
+
+func TestMachineAffinity_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		affinity MachineAffinity
+		wantErr  bool
+	}{
+		{
+			name: "empty affinity",
+		},
+		{
+			name: "tags and machine in separate terms",
+			affinity: MachineAffinity{
+				RackAffinity:     []AffinityTerm{{Tags: []string{"storage"}, Required: true}},
+				RackAntiAffinity: []AffinityTerm{{MachineID: "m1"}},
+			},
+		},
+		{
+			name:     "empty term",
+			affinity: MachineAffinity{RackAntiAffinity: []AffinityTerm{{Required: true}}},
+			wantErr:  true,
+		},
+		{
+			name:     "tags and machine in one term",
+			affinity: MachineAffinity{RackAffinity: []AffinityTerm{{Tags: []string{"storage"}, MachineID: "m1"}}},
+			wantErr:  true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.affinity.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("MachineAffinity.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
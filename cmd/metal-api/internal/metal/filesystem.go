@@ -481,6 +481,11 @@ func (fls FilesystemLayouts) From(size, image string) (*FilesystemLayout, error)
 	return nil, fmt.Errorf("could not find a matching filesystemLayout for size:%s and image:%s", size, image)
 }
 
+// Supports returns true if the layout may be used for the given size and image.
+func (fl *FilesystemLayout) Supports(sizeID, imageID string) bool {
+	return fl.Constraints.matches(sizeID, imageID)
+}
+
 // IsReinstallable returns true if at least one disk configures has WipeOnReInstall set, otherwise false
 func (fl *FilesystemLayout) IsReinstallable() bool {
 	for _, d := range fl.Disks {
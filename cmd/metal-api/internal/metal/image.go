@@ -2,8 +2,11 @@ package metal
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/Masterminds/semver/v3"
 )
 
 // An Image describes an image which could be used for provisioning.
@@ -92,6 +95,42 @@ func (ii Images) ByID() ImageMap {
 	return res
 }
 
+// PatchUpgradesFor returns the supported images with the same operating system, major and minor version as
+// the given image, the newest version comes first.
+func (ii Images) PatchUpgradesFor(image Image) Images {
+	v, err := semver.NewVersion(image.Version)
+	if err != nil {
+		return nil
+	}
+
+	type candidate struct {
+		image   Image
+		version *semver.Version
+	}
+
+	var candidates []candidate
+	for _, i := range ii {
+		if i.OS != image.OS || i.Classification != ClassificationSupported {
+			continue
+		}
+		cv, err := semver.NewVersion(i.Version)
+		if err != nil || cv.Major() != v.Major() || cv.Minor() != v.Minor() {
+			continue
+		}
+		candidates = append(candidates, candidate{image: i, version: cv})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].version.GreaterThan(candidates[j].version)
+	})
+
+	var result Images
+	for _, c := range candidates {
+		result = append(result, c.image)
+	}
+	return result
+}
+
 // HasFeature returns true if this image has given feature enabled, otherwise false.
 func (i *Image) HasFeature(feature ImageFeatureType) bool {
 	return i.Features[feature]
@@ -56,3 +56,23 @@ func TestImages_ByID(t *testing.T) {
 		})
 	}
 }
+
+func TestImages_PatchUpgradesFor(t *testing.T) {
+	images := Images{
+		{Base: Base{ID: "ubuntu-20.04.20230101"}, OS: "ubuntu", Version: "20.04.20230101", Classification: ClassificationSupported},
+		{Base: Base{ID: "ubuntu-20.04.20230301"}, OS: "ubuntu", Version: "20.04.20230301", Classification: ClassificationSupported},
+		{Base: Base{ID: "ubuntu-20.04.20230401"}, OS: "ubuntu", Version: "20.04.20230401", Classification: ClassificationPreview},
+		{Base: Base{ID: "ubuntu-20.10.20230501"}, OS: "ubuntu", Version: "20.10.20230501", Classification: ClassificationSupported},
+		{Base: Base{ID: "debian-20.04.20230601"}, OS: "debian", Version: "20.04.20230601", Classification: ClassificationSupported},
+	}
+
+	var got []string
+	for _, i := range images.PatchUpgradesFor(Image{OS: "ubuntu", Version: "20.04.20220101"}) {
+		got = append(got, i.ID)
+	}
+
+	want := []string{"ubuntu-20.04.20230301", "ubuntu-20.04.20230101"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Images.PatchUpgradesFor() = %v, want %v", got, want)
+	}
+}
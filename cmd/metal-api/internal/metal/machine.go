@@ -1,6 +1,7 @@
 package metal
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -24,6 +25,8 @@ const (
 	ReservedState MState = "RESERVED"
 	// LockedState describes a machine state where a machine cannot be deleted or allocated anymore
 	LockedState MState = "LOCKED"
+	// MaintenanceState describes a machine state where a machine is drained and not allocated for a planned maintenance
+	MaintenanceState MState = "MAINTENANCE"
 )
 
 var (
@@ -35,7 +38,7 @@ var (
 
 var (
 	// AllStates contains all possible values of a machine state
-	AllStates = []MState{AvailableState, ReservedState, LockedState}
+	AllStates = []MState{AvailableState, ReservedState, LockedState, MaintenanceState}
 	// AllRoles contains all possible values of a role
 	AllRoles = map[Role]bool{
 		RoleMachine:  true,
@@ -51,6 +54,49 @@ type MachineState struct {
 	Description        string `rethinkdb:"description" json:"description"`
 	Issuer             string `rethinkdb:"issuer" json:"issuer,omitempty"`
 	MetalHammerVersion string `rethinkdb:"metal_hammer_version" json:"metal_hammer_version"`
+	// MaintenanceWindow is only set for the maintenance state.
+	MaintenanceWindow *MaintenanceWindow `rethinkdb:"maintenance_window" json:"maintenance_window,omitempty"`
+	// ScheduledMaintenance is set when a maintenance window starts in the future, the machine keeps its state
+	// until the window starts.
+	ScheduledMaintenance *ScheduledMaintenance `rethinkdb:"scheduled_maintenance" json:"scheduled_maintenance,omitempty"`
+}
+
+// A ScheduledMaintenance is a maintenance state which is applied when its window starts.
+type ScheduledMaintenance struct {
+	Description string            `rethinkdb:"description" json:"description"`
+	Issuer      string            `rethinkdb:"issuer" json:"issuer,omitempty"`
+	Window      MaintenanceWindow `rethinkdb:"window" json:"window"`
+}
+
+// MaintenanceWindow is the period of a planned maintenance. Without a start the maintenance starts immediately
+// and without an end it lasts until the state of the machine is changed again.
+type MaintenanceWindow struct {
+	Start *time.Time `rethinkdb:"start" json:"start"`
+	End   *time.Time `rethinkdb:"end" json:"end"`
+}
+
+// Validate returns an error if the window ends before it starts or has already ended.
+func (w *MaintenanceWindow) Validate(now time.Time) error {
+	if w.End == nil {
+		return nil
+	}
+	if w.Start != nil && !w.End.After(*w.Start) {
+		return errors.New("maintenance window must end after it starts")
+	}
+	if !w.End.After(now) {
+		return errors.New("maintenance window must end in the future")
+	}
+	return nil
+}
+
+// HasStarted returns true if the window has started at the given point in time.
+func (w *MaintenanceWindow) HasStarted(now time.Time) bool {
+	return w.Start == nil || !now.Before(*w.Start)
+}
+
+// IsOver returns true if the window has ended at the given point in time.
+func (w *MaintenanceWindow) IsOver(now time.Time) bool {
+	return w != nil && w.End != nil && !now.Before(*w.End)
 }
 
 // MachineStateFrom converts a machineState string to the type
@@ -62,6 +108,8 @@ func MachineStateFrom(name string) (MState, error) {
 		return ReservedState, nil
 	case string(LockedState):
 		return LockedState, nil
+	case string(MaintenanceState):
+		return MaintenanceState, nil
 	default:
 		return "", fmt.Errorf("unknown MachineState:%s", name)
 	}
@@ -112,6 +160,8 @@ type Machine struct {
 	Tags         []string                `rethinkdb:"tags" json:"tags"`
 	IPMI         IPMI                    `rethinkdb:"ipmi" json:"ipmi"`
 	BIOS         BIOS                    `rethinkdb:"bios" json:"bios"`
+	// PowerSaving is true if the machine was powered off by the power policy of its partition while it was waiting.
+	PowerSaving bool `rethinkdb:"powersaving" json:"powersaving"`
 }
 
 // Machines is a slice of Machine
@@ -147,6 +197,26 @@ type MachineAllocation struct {
 	MachineSetup     *MachineSetup     `rethinkdb:"setup" json:"setup"`
 	Role             Role              `rethinkdb:"role" json:"role"`
 	VPN              *MachineVPN       `rethinkdb:"vpn" json:"vpn"`
+	Lease            *MachineLease     `rethinkdb:"lease" json:"lease"`
+	// Preemptible allocations yield their machine to the allocations of projects with the same or a higher Priority.
+	Preemptible bool `rethinkdb:"preemptible" json:"preemptible"`
+	Priority    int  `rethinkdb:"priority" json:"priority"`
+	// Preempted is set when the machine was claimed by another allocation, the machine is freed when the
+	// PreemptionDeadline passed.
+	Preempted          bool      `rethinkdb:"preempted" json:"preempted"`
+	PreemptionDeadline time.Time `rethinkdb:"preemption_deadline" json:"preemption_deadline"`
+}
+
+// A MachineLease limits the time a machine stays allocated, the machine is freed when the lease expires.
+type MachineLease struct {
+	Expires time.Time `rethinkdb:"expires" json:"expires"`
+	// WarningSent is set when the warning about the upcoming expiry was published.
+	WarningSent bool `rethinkdb:"warning_sent" json:"warning_sent"`
+}
+
+// IsExpired returns true if the lease expired at the given point in time.
+func (l *MachineLease) IsExpired(now time.Time) bool {
+	return !now.Before(l.Expires)
 }
 
 // A MachineSetup stores the data used for machine reinstallations.
@@ -431,6 +501,22 @@ type AllocationEvent struct {
 	MachineID string `json:"old,omitempty"`
 }
 
+// MachineDrainEvent is propagated to the owning project when an allocated machine is put into maintenance,
+// the project is asked to move its workload off the machine before the maintenance starts.
+type MachineDrainEvent struct {
+	MachineID string             `json:"machineid"`
+	ProjectID string             `json:"projectid"`
+	Reason    string             `json:"reason"`
+	Window    *MaintenanceWindow `json:"window,omitempty"`
+}
+
+// LeaseExpiryEvent is propagated before the lease of a machine expires and the machine is freed.
+type LeaseExpiryEvent struct {
+	MachineID string    `json:"machineid"`
+	ProjectID string    `json:"projectid"`
+	Expires   time.Time `json:"expires"`
+}
+
 type FirmwareUpdate struct {
 	Kind FirmwareKind `json:"kind"`
 	URL  string       `json:"url"`
@@ -2,6 +2,7 @@ package metal
 
 import (
 	"testing"
+	"time"
 )
 
 func TestMachine_HasMAC(t *testing.T) {
@@ -195,3 +196,38 @@ func TestMachineNetwork_NetworkType(t *testing.T) {
 }
 
 // TODO: Write tests for machine allocation
+
+func TestMaintenanceWindow(t *testing.T) {
+	now := time.Now()
+	before := now.Add(-time.Hour)
+	after := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		window  MaintenanceWindow
+		wantErr bool
+		started bool
+		over    bool
+	}{
+		{name: "open end", window: MaintenanceWindow{Start: &before}, started: true},
+		{name: "ends in the future", window: MaintenanceWindow{Start: &before, End: &after}, started: true},
+		{name: "starts immediately", window: MaintenanceWindow{End: &after}, started: true},
+		{name: "scheduled", window: MaintenanceWindow{Start: &after}},
+		{name: "ended", window: MaintenanceWindow{End: &before}, wantErr: true, started: true, over: true},
+		{name: "ends before it starts", window: MaintenanceWindow{Start: &after, End: &after}, wantErr: true},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.window.Validate(now); (err != nil) != tt.wantErr {
+				t.Errorf("MaintenanceWindow.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.window.HasStarted(now); got != tt.started {
+				t.Errorf("MaintenanceWindow.HasStarted() = %v, want %v", got, tt.started)
+			}
+			if got := tt.window.IsOver(now); got != tt.over {
+				t.Errorf("MaintenanceWindow.IsOver() = %v, want %v", got, tt.over)
+			}
+		})
+	}
+}
@@ -52,11 +52,14 @@ const (
 	UPDATE  EventType = "update"
 	DELETE  EventType = "delete"
 	COMMAND EventType = "command"
+	PREEMPT EventType = "preempt"
 )
 
 var (
 	TopicMachine    = NSQTopic{Name: "machine", PartitionAgnostic: true}
 	TopicAllocation = NSQTopic{Name: "allocation", PartitionAgnostic: false}
+	TopicLease      = NSQTopic{Name: "lease", PartitionAgnostic: false}
+	TopicDrain      = NSQTopic{Name: "drain", PartitionAgnostic: false}
 )
 
 // Topics is a list of topics of which the metal-api is a producer.
@@ -64,6 +67,8 @@ var (
 var Topics = []NSQTopic{
 	TopicMachine,
 	TopicAllocation,
+	TopicLease,
+	TopicDrain,
 }
 
 // GetFQN gets the fully qualified name of a NSQTopic
@@ -1,11 +1,19 @@
 package metal
 
+import (
+	"fmt"
+
+	"golang.org/x/exp/slices"
+)
+
 // A Partition represents a location.
 type Partition struct {
 	Base
 	BootConfiguration          BootConfiguration `rethinkdb:"bootconfig" json:"bootconfig"`
 	MgmtServiceAddress         string            `rethinkdb:"mgmtserviceaddr" json:"mgmtserviceaddr"`
 	PrivateNetworkPrefixLength uint8             `rethinkdb:"privatenetworkprefixlength" json:"privatenetworkprefixlength"`
+	PlacementStrategy          PlacementStrategy `rethinkdb:"placementstrategy" json:"placementstrategy"`
+	PowerPolicies              PowerPolicies     `rethinkdb:"powerpolicies" json:"powerpolicies"`
 }
 
 // BootConfiguration defines the metal-hammer initrd, kernel and commandline
@@ -15,6 +23,113 @@ type BootConfiguration struct {
 	CommandLine string `rethinkdb:"commandline" json:"commandline"`
 }
 
+// PlacementStrategy configures how a waiting machine is elected for an allocation within a partition.
+// Without scorers machines are spread across the racks and picked randomly among the remaining candidates.
+type PlacementStrategy struct {
+	Scorers []PlacementScorer `rethinkdb:"scorers" json:"scorers"`
+}
+
+// PlacementScorer rates the candidates of an allocation, the weight defines how much the score counts
+// compared to the other scorers of a strategy.
+type PlacementScorer struct {
+	Name   PlacementScorerName `rethinkdb:"name" json:"name"`
+	Weight float64             `rethinkdb:"weight" json:"weight"`
+}
+
+// PlacementScorerName is the name of a placement scorer.
+type PlacementScorerName string
+
+// The names of the available placement scorers.
+const (
+	// PlacementScorerRackSpread prefers racks which are least occupied by the project and the placement tags.
+	PlacementScorerRackSpread PlacementScorerName = "rack-spread"
+	// PlacementScorerBIOSVersion prefers machines with the newest bios version.
+	PlacementScorerBIOSVersion PlacementScorerName = "bios-version"
+	// PlacementScorerBMCVersion prefers machines with the newest bmc firmware.
+	PlacementScorerBMCVersion PlacementScorerName = "bmc-version"
+	// PlacementScorerPowerConsumption prefers machines with the lowest recent average power consumption.
+	PlacementScorerPowerConsumption PlacementScorerName = "power-consumption"
+	// PlacementScorerCrashLoops prefers machines with the fewest crashes in their provisioning history.
+	PlacementScorerCrashLoops PlacementScorerName = "crash-loops"
+	// PlacementScorerSwitchCapacity prefers racks whose switches have the most unconnected ports.
+	PlacementScorerSwitchCapacity PlacementScorerName = "switch-capacity"
+)
+
+// PlacementScorerNames contains all available placement scorers.
+var PlacementScorerNames = []PlacementScorerName{
+	PlacementScorerRackSpread,
+	PlacementScorerBIOSVersion,
+	PlacementScorerBMCVersion,
+	PlacementScorerPowerConsumption,
+	PlacementScorerCrashLoops,
+	PlacementScorerSwitchCapacity,
+}
+
+// IsDefault returns true if the strategy has no scorers configured.
+func (s PlacementStrategy) IsDefault() bool {
+	return len(s.Scorers) == 0
+}
+
+// Validate returns an error if the placement strategy contains unknown scorers or invalid weights.
+func (s PlacementStrategy) Validate() error {
+	seen := map[PlacementScorerName]bool{}
+	for _, sc := range s.Scorers {
+		if !slices.Contains(PlacementScorerNames, sc.Name) {
+			return fmt.Errorf("unknown placement scorer %q, must be one of %v", sc.Name, PlacementScorerNames)
+		}
+		if seen[sc.Name] {
+			return fmt.Errorf("placement scorer %q is configured more than once", sc.Name)
+		}
+		seen[sc.Name] = true
+		if sc.Weight <= 0 {
+			return fmt.Errorf("weight of placement scorer %q must be positive", sc.Name)
+		}
+	}
+	return nil
+}
+
+// PowerPolicy keeps the given number of waiting machines of a size powered on, the other waiting machines of the
+// size are powered off until they are needed. A policy without a size applies to all sizes without a policy of their own.
+type PowerPolicy struct {
+	SizeID    string `rethinkdb:"sizeid" json:"sizeid"`
+	HotSpares int    `rethinkdb:"hotspares" json:"hotspares"`
+}
+
+// PowerPolicies are the power policies of a partition.
+type PowerPolicies []PowerPolicy
+
+// Validate returns an error if a size has more than one policy or if a policy keeps a negative number of machines powered on.
+func (ps PowerPolicies) Validate() error {
+	seen := map[string]bool{}
+	for _, p := range ps {
+		if seen[p.SizeID] {
+			if p.SizeID == "" {
+				return fmt.Errorf("power policy for all sizes is configured more than once")
+			}
+			return fmt.Errorf("power policy for size %q is configured more than once", p.SizeID)
+		}
+		seen[p.SizeID] = true
+		if p.HotSpares < 0 {
+			return fmt.Errorf("hot spares of power policy for size %q must not be negative", p.SizeID)
+		}
+	}
+	return nil
+}
+
+// For returns the power policy which applies to the given size, nil if the machines of the size are not powered off.
+func (ps PowerPolicies) For(sizeID string) *PowerPolicy {
+	var fallback *PowerPolicy
+	for i := range ps {
+		switch ps[i].SizeID {
+		case sizeID:
+			return &ps[i]
+		case "":
+			fallback = &ps[i]
+		}
+	}
+	return fallback
+}
+
 // Partitions is a list of partitions.
 type Partitions []Partition
 
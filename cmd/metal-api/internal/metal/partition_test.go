@@ -50,3 +50,103 @@ func TestPartitions_ByID(t *testing.T) {
 		})
 	}
 }
+
+func TestPlacementStrategy_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy PlacementStrategy
+		wantErr  bool
+	}{
+		{
+			name: "default strategy",
+		},
+		{
+			name: "weighted scorers",
+			strategy: PlacementStrategy{Scorers: []PlacementScorer{
+				{Name: PlacementScorerRackSpread, Weight: 2},
+				{Name: PlacementScorerPowerConsumption, Weight: 0.5},
+			}},
+		},
+		{
+			name:     "unknown scorer",
+			strategy: PlacementStrategy{Scorers: []PlacementScorer{{Name: "foo", Weight: 1}}},
+			wantErr:  true,
+		},
+		{
+			name:     "weight not positive",
+			strategy: PlacementStrategy{Scorers: []PlacementScorer{{Name: PlacementScorerCrashLoops}}},
+			wantErr:  true,
+		},
+		{
+			name: "duplicate scorer",
+			strategy: PlacementStrategy{Scorers: []PlacementScorer{
+				{Name: PlacementScorerCrashLoops, Weight: 1},
+				{Name: PlacementScorerCrashLoops, Weight: 2},
+			}},
+			wantErr: true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.strategy.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("PlacementStrategy.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPowerPolicies_For(t *testing.T) {
+	ps := PowerPolicies{
+		{SizeID: "s1", HotSpares: 2},
+		{HotSpares: 1},
+	}
+	if got := ps.For("s1"); got == nil || got.HotSpares != 2 {
+		t.Errorf("PowerPolicies.For() = %v, want policy of size s1", got)
+	}
+	if got := ps.For("s2"); got == nil || got.HotSpares != 1 {
+		t.Errorf("PowerPolicies.For() = %v, want default policy", got)
+	}
+	if got := ps[:1].For("s2"); got != nil {
+		t.Errorf("PowerPolicies.For() = %v, want nil", got)
+	}
+}
+
+func TestPowerPolicies_Validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies PowerPolicies
+		wantErr  bool
+	}{
+		{
+			name: "no policies",
+		},
+		{
+			name:     "size and default policy",
+			policies: PowerPolicies{{SizeID: "s1", HotSpares: 2}, {HotSpares: 0}},
+		},
+		{
+			name:     "negative hot spares",
+			policies: PowerPolicies{{SizeID: "s1", HotSpares: -1}},
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate size",
+			policies: PowerPolicies{{SizeID: "s1", HotSpares: 1}, {SizeID: "s1", HotSpares: 2}},
+			wantErr:  true,
+		},
+		{
+			name:     "duplicate default",
+			policies: PowerPolicies{{HotSpares: 1}, {HotSpares: 2}},
+			wantErr:  true,
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.policies.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("PowerPolicies.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
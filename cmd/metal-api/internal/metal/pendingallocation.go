@@ -0,0 +1,90 @@
+package metal
+
+import (
+	"sort"
+	"time"
+)
+
+// PendingAllocationState is the state of a pending allocation.
+type PendingAllocationState string
+
+const (
+	// PendingAllocationStatePending is the state of an allocation which waits for a machine.
+	PendingAllocationStatePending PendingAllocationState = "pending"
+	// PendingAllocationStateAllocating is the state of an allocation which is currently assigned to a machine.
+	PendingAllocationStateAllocating PendingAllocationState = "allocating"
+	// PendingAllocationStateFulfilled is the state of an allocation for which a machine was allocated.
+	PendingAllocationStateFulfilled PendingAllocationState = "fulfilled"
+	// PendingAllocationStateFailed is the state of an allocation which cannot be fulfilled.
+	PendingAllocationStateFailed PendingAllocationState = "failed"
+	// PendingAllocationStateExpired is the state of an allocation which was not fulfilled before its deadline.
+	PendingAllocationStateExpired PendingAllocationState = "expired"
+)
+
+// PendingAllocationClaimTimeout is the time after which an allocation which is still being assigned to a machine is
+// considered as interrupted, for example because the api instance which claimed it was stopped.
+const PendingAllocationClaimTimeout = 10 * time.Minute
+
+// PendingAllocation is a machine allocation which is queued until a machine of the requested size or with the
+// requested hardware becomes available in the partition. The name and the description of the entity are the ones of
+// the allocated machine.
+type PendingAllocation struct {
+	Base
+	Creator            string                     `rethinkdb:"creator" json:"creator"`
+	Hostname           string                     `rethinkdb:"hostname" json:"hostname"`
+	ProjectID          string                     `rethinkdb:"projectid" json:"projectid"`
+	PartitionID        string                     `rethinkdb:"partitionid" json:"partitionid"`
+	SizeID             string                     `rethinkdb:"sizeid" json:"sizeid"`
+	Requirements       []Constraint               `rethinkdb:"requirements" json:"requirements"`
+	ImageID            string                     `rethinkdb:"imageid" json:"imageid"`
+	FilesystemLayoutID *string                    `rethinkdb:"filesystemlayoutid" json:"filesystemlayoutid"`
+	SSHPubKeys         []string                   `rethinkdb:"sshPubKeys" json:"sshPubKeys"`
+	UserData           *string                    `rethinkdb:"userdata" json:"userdata"`
+	Tags               []string                   `rethinkdb:"tags" json:"tags"`
+	Networks           []PendingAllocationNetwork `rethinkdb:"networks" json:"networks"`
+	IPs                []string                   `rethinkdb:"ips" json:"ips"`
+	PlacementTags      []string                   `rethinkdb:"placement_tags" json:"placement_tags"`
+	Affinity           MachineAffinity            `rethinkdb:"affinity" json:"affinity"`
+	LeaseDuration      time.Duration              `rethinkdb:"lease_duration" json:"lease_duration"`
+	Preemptible        bool                       `rethinkdb:"preemptible" json:"preemptible"`
+	Priority           int                        `rethinkdb:"priority" json:"priority"`
+	Deadline           time.Time                  `rethinkdb:"deadline" json:"deadline"`
+	State              PendingAllocationState     `rethinkdb:"state" json:"state"`
+	Claimed            time.Time                  `rethinkdb:"claimed" json:"claimed"`
+	MachineID          string                     `rethinkdb:"machineid" json:"machineid"`
+	Message            string                     `rethinkdb:"message" json:"message"`
+	PreemptedMachineID string                     `rethinkdb:"preempted_machineid" json:"preempted_machineid"`
+}
+
+// PendingAllocationNetwork is a network a queued machine allocation is placed in.
+type PendingAllocationNetwork struct {
+	NetworkID     string `rethinkdb:"networkid" json:"networkid"`
+	AutoAcquireIP *bool  `rethinkdb:"autoacquire" json:"autoacquire"`
+}
+
+// PendingAllocations is a slice of PendingAllocation
+type PendingAllocations []PendingAllocation
+
+// IsDone returns true if the allocation does not wait for a machine anymore.
+func (p *PendingAllocation) IsDone() bool {
+	return p.State != PendingAllocationStatePending && p.State != PendingAllocationStateAllocating
+}
+
+// IsStale returns true if the allocation was claimed for too long without being assigned to a machine.
+func (p *PendingAllocation) IsStale(now time.Time) bool {
+	return p.State == PendingAllocationStateAllocating && now.Sub(p.Claimed) > PendingAllocationClaimTimeout
+}
+
+// Sort sorts the allocations in the order they are fulfilled, allocations with a higher priority come first
+// and allocations with the same priority are fulfilled in the order they were queued.
+func (ps PendingAllocations) Sort() {
+	sort.SliceStable(ps, func(i, j int) bool {
+		if ps[i].Priority != ps[j].Priority {
+			return ps[i].Priority > ps[j].Priority
+		}
+		if !ps[i].Created.Equal(ps[j].Created) {
+			return ps[i].Created.Before(ps[j].Created)
+		}
+		return ps[i].ID < ps[j].ID
+	})
+}
@@ -0,0 +1,30 @@
+package metal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingAllocations_Sort(t *testing.T) {
+	now := time.Now()
+
+	ps := PendingAllocations{
+		{Base: Base{ID: "late", Created: now.Add(time.Minute)}},
+		{Base: Base{ID: "b", Created: now}},
+		{Base: Base{ID: "urgent", Created: now.Add(time.Hour)}, Priority: 10},
+		{Base: Base{ID: "a", Created: now}},
+	}
+	ps.Sort()
+
+	var got []string
+	for _, p := range ps {
+		got = append(got, p.ID)
+	}
+
+	want := []string{"urgent", "a", "b", "late"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Sort() = %v, want %v", got, want)
+		}
+	}
+}
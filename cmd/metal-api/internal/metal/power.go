@@ -0,0 +1,120 @@
+package metal
+
+import (
+	"sort"
+	"time"
+)
+
+// IPMIOutdatedAfter is the duration after which the ipmi data of a machine, including its power metric, is outdated
+// because neither the metal-hammer nor the metal-bmc reported it anymore.
+const IPMIOutdatedAfter = 20 * time.Minute
+
+// PowerSampleMaxInterval is the longest interval in which the power consumption of the machines can be recorded.
+const PowerSampleMaxInterval = 24 * time.Hour
+
+// PowerConsumption sums up the power metrics of a group of machines. Machines whose power metric is outdated are
+// not counted but listed as stale.
+type PowerConsumption struct {
+	Machines             int
+	ReportingMachines    int
+	StaleMachines        []string
+	AverageConsumedWatts float64
+	MinConsumedWatts     float64
+	MaxConsumedWatts     float64
+}
+
+// Add counts the power metric of the given machine.
+func (pc *PowerConsumption) Add(m *Machine) {
+	pc.Machines++
+	if m.IPMI.PowerMetric == nil {
+		return
+	}
+	if time.Since(m.IPMI.LastUpdated) > IPMIOutdatedAfter {
+		pc.StaleMachines = append(pc.StaleMachines, m.ID)
+		return
+	}
+
+	pc.ReportingMachines++
+	pc.AverageConsumedWatts += float64(m.IPMI.PowerMetric.AverageConsumedWatts)
+	pc.MinConsumedWatts += float64(m.IPMI.PowerMetric.MinConsumedWatts)
+	pc.MaxConsumedWatts += float64(m.IPMI.PowerMetric.MaxConsumedWatts)
+}
+
+// PowerSample is the power consumption of the machines of a size in a partition which are allocated by a project,
+// recorded at a point in time. It accounts for the energy used by the machines in the interval before the sample
+// was recorded. Machines which are not allocated are recorded without project.
+type PowerSample struct {
+	Base
+	Timestamp            time.Time     `rethinkdb:"timestamp" json:"timestamp"`
+	Interval             time.Duration `rethinkdb:"interval" json:"interval"`
+	PartitionID          string        `rethinkdb:"partitionid" json:"partitionid"`
+	SizeID               string        `rethinkdb:"sizeid" json:"sizeid"`
+	ProjectID            string        `rethinkdb:"projectid" json:"projectid"`
+	Machines             int           `rethinkdb:"machines" json:"machines"`
+	StaleMachines        int           `rethinkdb:"stalemachines" json:"stalemachines"`
+	AverageConsumedWatts float64       `rethinkdb:"averageconsumedwatts" json:"averageconsumedwatts"`
+}
+
+// PowerSamples is a list of power samples.
+type PowerSamples []PowerSample
+
+// NewPowerSamples returns the power samples of the given machines, grouped by partition, size and project.
+func NewPowerSamples(machines Machines, timestamp time.Time, interval time.Duration) PowerSamples {
+	type key struct{ partition, size, project string }
+	consumptions := map[key]*PowerConsumption{}
+	for i := range machines {
+		m := &machines[i]
+		k := key{partition: m.PartitionID, size: m.SizeID}
+		if m.Allocation != nil {
+			k.project = m.Allocation.Project
+		}
+		pc, ok := consumptions[k]
+		if !ok {
+			pc = &PowerConsumption{}
+			consumptions[k] = pc
+		}
+		pc.Add(m)
+	}
+
+	var result PowerSamples
+	for k, pc := range consumptions {
+		result = append(result, PowerSample{
+			Timestamp:            timestamp,
+			Interval:             interval,
+			PartitionID:          k.partition,
+			SizeID:               k.size,
+			ProjectID:            k.project,
+			Machines:             pc.Machines,
+			StaleMachines:        len(pc.StaleMachines),
+			AverageConsumedWatts: pc.AverageConsumedWatts,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.PartitionID != b.PartitionID {
+			return a.PartitionID < b.PartitionID
+		}
+		if a.SizeID != b.SizeID {
+			return a.SizeID < b.SizeID
+		}
+		return a.ProjectID < b.ProjectID
+	})
+
+	return result
+}
+
+// WattHours returns the energy in watt hours which was used by the machines of the sample within the given period.
+func (s *PowerSample) WattHours(from, to time.Time) float64 {
+	start := s.Timestamp.Add(-s.Interval)
+	if start.Before(from) {
+		start = from
+	}
+	end := s.Timestamp
+	if end.After(to) {
+		end = to
+	}
+	if !end.After(start) {
+		return 0
+	}
+	return s.AverageConsumedWatts * end.Sub(start).Hours()
+}
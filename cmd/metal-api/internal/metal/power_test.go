@@ -0,0 +1,78 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewPowerSamples(t *testing.T) {
+	now := time.Now()
+	machines := Machines{
+		{Base: Base{ID: "m1"}, PartitionID: "p1", SizeID: "s1", Allocation: &MachineAllocation{Project: "pr1"}, IPMI: IPMI{LastUpdated: now, PowerMetric: &PowerMetric{AverageConsumedWatts: 100, MinConsumedWatts: 80, MaxConsumedWatts: 120}}},
+		{Base: Base{ID: "m2"}, PartitionID: "p1", SizeID: "s1", Allocation: &MachineAllocation{Project: "pr1"}, IPMI: IPMI{LastUpdated: now, PowerMetric: &PowerMetric{AverageConsumedWatts: 200, MinConsumedWatts: 150, MaxConsumedWatts: 250}}},
+		{Base: Base{ID: "m3"}, PartitionID: "p1", SizeID: "s1", Allocation: &MachineAllocation{Project: "pr1"}, IPMI: IPMI{LastUpdated: now.Add(-time.Hour), PowerMetric: &PowerMetric{AverageConsumedWatts: 300}}},
+		{Base: Base{ID: "m4"}, PartitionID: "p1", SizeID: "s1", IPMI: IPMI{LastUpdated: now, PowerMetric: &PowerMetric{AverageConsumedWatts: 50}}},
+		{Base: Base{ID: "m5"}, PartitionID: "p1", SizeID: "s1"},
+	}
+
+	want := PowerSamples{
+		{Timestamp: now, Interval: time.Minute, PartitionID: "p1", SizeID: "s1", Machines: 2, AverageConsumedWatts: 50},
+		{Timestamp: now, Interval: time.Minute, PartitionID: "p1", SizeID: "s1", ProjectID: "pr1", Machines: 3, StaleMachines: 1, AverageConsumedWatts: 300},
+	}
+	if diff := cmp.Diff(want, NewPowerSamples(machines, now, time.Minute)); diff != "" {
+		t.Errorf("NewPowerSamples() diff = %s", diff)
+	}
+
+	var pc PowerConsumption
+	for i := range machines[:3] {
+		pc.Add(&machines[i])
+	}
+	if diff := cmp.Diff(PowerConsumption{Machines: 3, ReportingMachines: 2, StaleMachines: []string{"m3"}, AverageConsumedWatts: 300, MinConsumedWatts: 230, MaxConsumedWatts: 370}, pc); diff != "" {
+		t.Errorf("PowerConsumption.Add() diff = %s", diff)
+	}
+}
+
+func TestPowerSample_WattHours(t *testing.T) {
+	now := time.Now()
+	s := PowerSample{Timestamp: now, Interval: time.Hour, AverageConsumedWatts: 100}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     float64
+	}{
+		{
+			name: "sample within period",
+			from: now.Add(-2 * time.Hour),
+			to:   now,
+			want: 100,
+		},
+		{
+			name: "sample overlaps start of period",
+			from: now.Add(-30 * time.Minute),
+			to:   now.Add(time.Hour),
+			want: 50,
+		},
+		{
+			name: "sample overlaps end of period",
+			from: now.Add(-2 * time.Hour),
+			to:   now.Add(-45 * time.Minute),
+			want: 25,
+		},
+		{
+			name: "sample after period",
+			from: now.Add(-3 * time.Hour),
+			to:   now.Add(-2 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.WattHours(tt.from, tt.to); got != tt.want {
+				t.Errorf("WattHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package metal
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Reservation reserves an amount of machines of a size in a partition for a project until it expires.
+// Machines of other projects can only be allocated as long as enough waiting machines remain for the
+// reservations which are not used up by the allocations of their projects.
+type Reservation struct {
+	Base
+	ProjectID   string    `rethinkdb:"projectid" json:"projectid"`
+	PartitionID string    `rethinkdb:"partitionid" json:"partitionid"`
+	SizeID      string    `rethinkdb:"sizeid" json:"sizeid"`
+	Amount      int       `rethinkdb:"amount" json:"amount"`
+	Expires     time.Time `rethinkdb:"expires" json:"expires"`
+}
+
+// Reservations is a slice of Reservation
+type Reservations []Reservation
+
+// Validate checks whether the reservation is complete.
+func (r *Reservation) Validate() error {
+	if r.ProjectID == "" {
+		return errors.New("project of the reservation must be given")
+	}
+	if r.PartitionID == "" {
+		return errors.New("partition of the reservation must be given")
+	}
+	if r.SizeID == "" {
+		return errors.New("size of the reservation must be given")
+	}
+	if r.Amount <= 0 {
+		return errors.New("amount of the reservation must be greater than zero")
+	}
+	if r.Expires.IsZero() {
+		return errors.New("expiration of the reservation must be given")
+	}
+	return nil
+}
+
+// IsExpired returns true if the reservation does not hold any capacity at the given time anymore.
+func (r *Reservation) IsExpired(now time.Time) bool {
+	return !now.Before(r.Expires)
+}
+
+// RemainingByID returns the amount of machines which every reservation still holds back. The machines which
+// a project has already allocated in the partition and size of its reservations use up the reservations which
+// expire first, expired reservations do not hold back any machines.
+func (rs Reservations) RemainingByID(machines Machines, now time.Time) map[string]int {
+	type key struct{ project, partition, size string }
+
+	allocated := map[key]int{}
+	for _, m := range machines {
+		if m.Allocation == nil {
+			continue
+		}
+		allocated[key{project: m.Allocation.Project, partition: m.PartitionID, size: m.SizeID}]++
+	}
+
+	active := Reservations{}
+	for _, r := range rs {
+		if !r.IsExpired(now) {
+			active = append(active, r)
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool { return active[i].Expires.Before(active[j].Expires) })
+
+	result := map[string]int{}
+	for _, r := range rs {
+		result[r.ID] = 0
+	}
+	for _, r := range active {
+		k := key{project: r.ProjectID, partition: r.PartitionID, size: r.SizeID}
+		used := allocated[k]
+		if used > r.Amount {
+			used = r.Amount
+		}
+		allocated[k] -= used
+		result[r.ID] = r.Amount - used
+	}
+
+	return result
+}
+
+// ReservedForOthers returns the amount of machines which are still held back by the reservations of the
+// given partition and size for other projects than the given one.
+func (rs Reservations) ReservedForOthers(projectID, partitionID, sizeID string, machines Machines, now time.Time) int {
+	remaining := rs.RemainingByID(machines, now)
+
+	result := 0
+	for _, r := range rs {
+		if r.ProjectID == projectID || r.PartitionID != partitionID || r.SizeID != sizeID {
+			continue
+		}
+		result += remaining[r.ID]
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package metal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReservations_RemainingByID(t *testing.T) {
+	now := time.Now()
+
+	allocated := func(id, project, partition, size string) Machine {
+		return Machine{
+			Base:        Base{ID: id},
+			PartitionID: partition,
+			SizeID:      size,
+			Allocation:  &MachineAllocation{Project: project},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		reservations Reservations
+		machines     Machines
+		want         map[string]int
+	}{
+		{
+			name: "reservations without allocations hold back their amount",
+			reservations: Reservations{
+				{Base: Base{ID: "r1"}, ProjectID: "p1", PartitionID: "a", SizeID: "s", Amount: 2, Expires: now.Add(time.Hour)},
+				{Base: Base{ID: "r2"}, ProjectID: "p2", PartitionID: "a", SizeID: "s", Amount: 1, Expires: now.Add(time.Hour)},
+			},
+			want: map[string]int{"r1": 2, "r2": 1},
+		},
+		{
+			name: "expired reservations do not hold back machines",
+			reservations: Reservations{
+				{Base: Base{ID: "r1"}, ProjectID: "p1", PartitionID: "a", SizeID: "s", Amount: 2, Expires: now},
+			},
+			want: map[string]int{"r1": 0},
+		},
+		{
+			name: "allocations use up the reservations of their project, partition and size which expire first",
+			reservations: Reservations{
+				{Base: Base{ID: "r1"}, ProjectID: "p1", PartitionID: "a", SizeID: "s", Amount: 2, Expires: now.Add(2 * time.Hour)},
+				{Base: Base{ID: "r2"}, ProjectID: "p1", PartitionID: "a", SizeID: "s", Amount: 2, Expires: now.Add(time.Hour)},
+				{Base: Base{ID: "r3"}, ProjectID: "p2", PartitionID: "a", SizeID: "s", Amount: 1, Expires: now.Add(time.Hour)},
+			},
+			machines: Machines{
+				allocated("m1", "p1", "a", "s"),
+				allocated("m2", "p1", "a", "s"),
+				allocated("m3", "p1", "a", "s"),
+				allocated("m4", "p1", "b", "s"),
+				allocated("m5", "p1", "a", "t"),
+				allocated("m6", "p3", "a", "s"),
+				{Base: Base{ID: "m7"}, PartitionID: "a", SizeID: "s"},
+			},
+			want: map[string]int{"r1": 1, "r2": 0, "r3": 1},
+		},
+	}
+	for i := range tests {
+		tt := tests[i]
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.reservations.RemainingByID(tt.machines, now)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("RemainingByID() diff = %s", diff)
+			}
+		})
+	}
+}
+
+func TestReservations_ReservedForOthers(t *testing.T) {
+	now := time.Now()
+
+	rs := Reservations{
+		{Base: Base{ID: "r1"}, ProjectID: "p1", PartitionID: "a", SizeID: "s", Amount: 2, Expires: now.Add(time.Hour)},
+		{Base: Base{ID: "r2"}, ProjectID: "p2", PartitionID: "a", SizeID: "s", Amount: 3, Expires: now.Add(time.Hour)},
+		{Base: Base{ID: "r3"}, ProjectID: "p2", PartitionID: "b", SizeID: "s", Amount: 3, Expires: now.Add(time.Hour)},
+	}
+
+	if got := rs.ReservedForOthers("p1", "a", "s", nil, now); got != 3 {
+		t.Errorf("ReservedForOthers() = %d, want 3", got)
+	}
+	if got := rs.ReservedForOthers("p3", "a", "s", nil, now); got != 5 {
+		t.Errorf("ReservedForOthers() = %d, want 5", got)
+	}
+}
@@ -0,0 +1,48 @@
+package metal
+
+import "time"
+
+// RevisionOperation is the kind of change which led to a revision.
+type RevisionOperation string
+
+// The operations which are recorded as revisions.
+const (
+	RevisionOperationCreate RevisionOperation = "create"
+	RevisionOperationUpdate RevisionOperation = "update"
+	RevisionOperationDelete RevisionOperation = "delete"
+)
+
+// Revision is a snapshot of an entity, it is recorded on every change of the entity.
+type Revision struct {
+	ID string `rethinkdb:"id,omitempty" json:"id"`
+	// EntityKind is the name of the table the entity is stored in, e.g. machine or switch.
+	EntityKind string            `rethinkdb:"entitykind" json:"entitykind"`
+	EntityID   string            `rethinkdb:"entityid" json:"entityid"`
+	Operation  RevisionOperation `rethinkdb:"operation" json:"operation"`
+	Timestamp  time.Time         `rethinkdb:"timestamp" json:"timestamp"`
+	User       string            `rethinkdb:"user" json:"user"`
+	RequestID  string            `rethinkdb:"requestid" json:"requestid"`
+	// Snapshot is the entity after the change, for deletions it is the last state of the entity.
+	Snapshot map[string]any `rethinkdb:"snapshot" json:"snapshot"`
+}
+
+// Revisions is a list of revisions.
+type Revisions []Revision
+
+// At returns the revision which was valid at the given point in time, revisions have to be sorted by
+// their timestamp. It returns nil if the entity did not exist at this point in time.
+func (rs Revisions) At(t time.Time) *Revision {
+	var result *Revision
+	for i := range rs {
+		if rs[i].Timestamp.After(t) {
+			break
+		}
+		result = &rs[i]
+	}
+
+	if result == nil || result.Operation == RevisionOperationDelete {
+		return nil
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+package metal
+
+import (
+	"time"
+)
+
+// RolloutState is the state of a rollout.
+type RolloutState string
+
+const (
+	// RolloutStateRunning is the state of a rollout which reinstalls its machines.
+	RolloutStateRunning RolloutState = "running"
+	// RolloutStatePaused is the state of a rollout which does not start further reinstallations, either because it
+	// was paused by a user or because a reinstallation failed.
+	RolloutStatePaused RolloutState = "paused"
+	// RolloutStateAborted is the state of a rollout which was aborted by a user.
+	RolloutStateAborted RolloutState = "aborted"
+	// RolloutStateFinished is the state of a rollout whose machines were all reinstalled successfully.
+	RolloutStateFinished RolloutState = "finished"
+)
+
+// RolloutMachineState is the state of the reinstallation of a machine of a rollout.
+type RolloutMachineState string
+
+const (
+	// RolloutMachineStatePending is the state of a machine which was not reinstalled yet.
+	RolloutMachineStatePending RolloutMachineState = "pending"
+	// RolloutMachineStateReinstalling is the state of a machine which is reinstalled currently.
+	RolloutMachineStateReinstalling RolloutMachineState = "reinstalling"
+	// RolloutMachineStateSucceeded is the state of a machine which phoned home after its reinstallation.
+	RolloutMachineStateSucceeded RolloutMachineState = "succeeded"
+	// RolloutMachineStateFailed is the state of a machine whose reinstallation failed or timed out.
+	RolloutMachineStateFailed RolloutMachineState = "failed"
+)
+
+// Rollout reinstalls a group of allocated machines with a new image in waves, at most MaxUnavailable machines
+// are reinstalled at the same time. A machine is reinstalled successfully as soon as it phoned home.
+type Rollout struct {
+	Base
+	Creator        string           `rethinkdb:"creator" json:"creator"`
+	ImageID        string           `rethinkdb:"imageid" json:"imageid"`
+	MaxUnavailable int              `rethinkdb:"max_unavailable" json:"max_unavailable"`
+	Timeout        time.Duration    `rethinkdb:"timeout" json:"timeout"`
+	State          RolloutState     `rethinkdb:"state" json:"state"`
+	Message        string           `rethinkdb:"message" json:"message"`
+	Machines       []RolloutMachine `rethinkdb:"machines" json:"machines"`
+}
+
+// RolloutMachine is the progress of the reinstallation of a machine of a rollout.
+type RolloutMachine struct {
+	MachineID string              `rethinkdb:"machineid" json:"machineid"`
+	State     RolloutMachineState `rethinkdb:"state" json:"state"`
+	Started   time.Time           `rethinkdb:"started" json:"started"`
+	Finished  time.Time           `rethinkdb:"finished" json:"finished"`
+	Message   string              `rethinkdb:"message" json:"message"`
+}
+
+// Rollouts is a slice of Rollout
+type Rollouts []Rollout
+
+// IsDone returns true if the rollout does not reinstall machines anymore and cannot be resumed.
+func (r *Rollout) IsDone() bool {
+	return r.State == RolloutStateAborted || r.State == RolloutStateFinished
+}
+
+// Count returns the number of machines of the rollout in the given state.
+func (r *Rollout) Count(state RolloutMachineState) int {
+	count := 0
+	for _, m := range r.Machines {
+		if m.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// NextWave returns the indices of the pending machines which are reinstalled next. A wave is only started when
+// the previous wave is completed, such that at most MaxUnavailable machines are unavailable at the same time.
+func (r *Rollout) NextWave() []int {
+	if r.Count(RolloutMachineStateReinstalling) > 0 {
+		return nil
+	}
+
+	var wave []int
+	for i, m := range r.Machines {
+		if len(wave) >= r.MaxUnavailable {
+			break
+		}
+		if m.State == RolloutMachineStatePending {
+			wave = append(wave, i)
+		}
+	}
+	return wave
+}
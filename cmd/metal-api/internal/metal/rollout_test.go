@@ -0,0 +1,50 @@
+package metal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRollout_NextWave(t *testing.T) {
+	tests := []struct {
+		name     string
+		machines []RolloutMachineState
+		max      int
+		want     []int
+	}{
+		{
+			name:     "first wave",
+			machines: []RolloutMachineState{RolloutMachineStatePending, RolloutMachineStatePending, RolloutMachineStatePending},
+			max:      2,
+			want:     []int{0, 1},
+		},
+		{
+			name:     "previous wave is still reinstalling",
+			machines: []RolloutMachineState{RolloutMachineStateSucceeded, RolloutMachineStateReinstalling, RolloutMachineStatePending},
+			max:      2,
+		},
+		{
+			name:     "skips machines which are done",
+			machines: []RolloutMachineState{RolloutMachineStateSucceeded, RolloutMachineStateFailed, RolloutMachineStatePending},
+			max:      2,
+			want:     []int{2},
+		},
+		{
+			name:     "nothing left",
+			machines: []RolloutMachineState{RolloutMachineStateSucceeded},
+			max:      1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rollout{MaxUnavailable: tt.max}
+			for _, s := range tt.machines {
+				r.Machines = append(r.Machines, RolloutMachine{State: s})
+			}
+			if got := r.NextWave(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NextWave() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -72,6 +72,19 @@ func (c *Constraint) Matches(hw MachineHardware) (ConstraintMatchingLog, bool) {
 	return cml, res
 }
 
+// MatchesConstraints returns true if the given machine hardware matches all of the constraints. The matching
+// logs of the constraints which do not match are returned.
+func MatchesConstraints(constraints []Constraint, hw MachineHardware) ([]ConstraintMatchingLog, bool) {
+	var mismatches []ConstraintMatchingLog
+	for i := range constraints {
+		lg, match := constraints[i].Matches(hw)
+		if !match {
+			mismatches = append(mismatches, lg)
+		}
+	}
+	return mismatches, len(mismatches) == 0
+}
+
 // FromHardware searches a Size for given hardware specs. It will search
 // for a size where the constraints matches the given hardware.
 func (sz Sizes) FromHardware(hardware MachineHardware) (*Size, []*SizeMatchingLog, error) {
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+var powerConsumptionLabels = []string{"partition", "rack", "size", "project"}
+
+// PowerConsumptionCollector exports the summed up power consumption of the machines by partition, rack, size and
+// allocation project. Machines whose power metric is outdated are not counted but exported as stale.
+type PowerConsumptionCollector struct {
+	log *zap.SugaredLogger
+	ms  datastore.MachineStore
+
+	average *prometheus.Desc
+	min     *prometheus.Desc
+	max     *prometheus.Desc
+	stale   *prometheus.Desc
+}
+
+// NewPowerConsumptionCollector returns a collector which reads the power metrics of the machines on every scrape.
+func NewPowerConsumptionCollector(log *zap.SugaredLogger, ms datastore.MachineStore) *PowerConsumptionCollector {
+	return &PowerConsumptionCollector{
+		log: log,
+		ms:  ms,
+		average: prometheus.NewDesc(
+			prometheus.BuildFQName("metal", "machine", "power_average_consumed_watts"),
+			"The summed up average power consumption of the machines in watts.",
+			powerConsumptionLabels, nil,
+		),
+		min: prometheus.NewDesc(
+			prometheus.BuildFQName("metal", "machine", "power_min_consumed_watts"),
+			"The summed up minimum power consumption of the machines in watts.",
+			powerConsumptionLabels, nil,
+		),
+		max: prometheus.NewDesc(
+			prometheus.BuildFQName("metal", "machine", "power_max_consumed_watts"),
+			"The summed up maximum power consumption of the machines in watts.",
+			powerConsumptionLabels, nil,
+		),
+		stale: prometheus.NewDesc(
+			prometheus.BuildFQName("metal", "machine", "power_stale_machines"),
+			"The number of machines whose power metric is outdated and therefore not counted.",
+			powerConsumptionLabels, nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *PowerConsumptionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.average
+	ch <- c.min
+	ch <- c.max
+	ch <- c.stale
+}
+
+// Collect implements prometheus.Collector.
+func (c *PowerConsumptionCollector) Collect(ch chan<- prometheus.Metric) {
+	machines, err := c.ms.ListMachines()
+	if err != nil {
+		c.log.Errorw("unable to list machines for power consumption metrics", "error", err)
+		return
+	}
+
+	type key struct{ partition, rack, size, project string }
+	consumptions := map[key]*metal.PowerConsumption{}
+	for i := range machines {
+		m := &machines[i]
+		k := key{partition: m.PartitionID, rack: m.RackID, size: m.SizeID}
+		if m.Allocation != nil {
+			k.project = m.Allocation.Project
+		}
+		pc, ok := consumptions[k]
+		if !ok {
+			pc = &metal.PowerConsumption{}
+			consumptions[k] = pc
+		}
+		pc.Add(m)
+	}
+
+	for k, pc := range consumptions {
+		labels := []string{k.partition, k.rack, k.size, k.project}
+		ch <- prometheus.MustNewConstMetric(c.average, prometheus.GaugeValue, pc.AverageConsumedWatts, labels...)
+		ch <- prometheus.MustNewConstMetric(c.min, prometheus.GaugeValue, pc.MinConsumedWatts, labels...)
+		ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, pc.MaxConsumedWatts, labels...)
+		ch <- prometheus.MustNewConstMetric(c.stale, prometheus.GaugeValue, float64(len(pc.StaleMachines)), labels...)
+	}
+}
@@ -19,9 +19,9 @@ const (
 	ASNMax = uint32(4294967294)
 )
 
-// acquireASN fetches a unique integer by using the existing integer pool and adding to ASNBase
-func acquireASN(ds *datastore.RethinkStore) (*uint32, error) {
-	i, err := ds.GetASNPool().AcquireRandomUniqueInteger()
+// acquireASN fetches a unique integer from the asn pool of the partition and adds it to ASNBase
+func acquireASN(ds datastore.Store, partitionID string) (*uint32, error) {
+	i, err := ds.GetPartitionASNPool(partitionID).AcquireRandomUniqueInteger()
 	if err != nil {
 		return nil, err
 	}
@@ -32,12 +32,24 @@ func acquireASN(ds *datastore.RethinkStore) (*uint32, error) {
 	return &asn, nil
 }
 
-// releaseASN will release the asn from the integerpool
-func releaseASN(ds *datastore.RethinkStore, asn uint32) error {
+// releaseASN will release the asn to the integer pool it was acquired from
+func releaseASN(ds datastore.Store, partitionID string, asn uint32) error {
 	if asn < ASNBase || asn > ASNMax {
 		return fmt.Errorf("asn %d might not be smaller than:%d or larger than %d", asn, ASNBase, ASNMax)
 	}
 	i := uint(asn - ASNBase)
 
-	return ds.GetASNPool().ReleaseUniqueInteger(i)
+	return asnPool(ds, partitionID, i).ReleaseUniqueInteger(i)
+}
+
+// asnPool returns the pool which holds the given integer of an asn in the partition. The asn pool of a
+// partition does not overlap with the global asn pool, asns acquired before the partition got a pool
+// of its own belong to the global pool.
+func asnPool(ds datastore.Store, partitionID string, i uint) datastore.UniqueIntegerPool {
+	pool := ds.GetPartitionASNPool(partitionID)
+	rng := pool.Range()
+	if i < rng.Min || i > rng.Max {
+		return ds.GetASNPool()
+	}
+	return pool
 }
@@ -20,16 +20,16 @@ import (
 type asyncActor struct {
 	log *zap.SugaredLogger
 	ipam.IPAMer
-	*datastore.RethinkStore
+	datastore.Store
 	machineNetworkReleaser bus.Func
 	ipReleaser             bus.Func
 }
 
-func newAsyncActor(l *zap.SugaredLogger, ep *bus.Endpoints, ds *datastore.RethinkStore, ip ipam.IPAMer) (*asyncActor, error) {
+func newAsyncActor(l *zap.SugaredLogger, ep *bus.Endpoints, ds datastore.Store, ip ipam.IPAMer) (*asyncActor, error) {
 	actor := &asyncActor{
-		log:          l,
-		IPAMer:       ip,
-		RethinkStore: ds,
+		log:    l,
+		IPAMer: ip,
+		Store:  ds,
 	}
 	var err error
 	_, actor.machineNetworkReleaser, err = ep.Function("releaseMachineNetworks", actor.releaseMachineNetworks)
@@ -55,7 +55,7 @@ func (a *asyncActor) freeMachine(ctx context.Context, pub bus.Publisher, m *meta
 		}
 	}
 
-	err := deleteVRFSwitches(a.RethinkStore, m, a.log.Desugar())
+	err := deleteVRFSwitches(a.Store, m, a.log.Desugar())
 	if err != nil {
 		return err
 	}
@@ -122,7 +122,7 @@ func (a *asyncActor) releaseMachineNetworks(machine *metal.Machine) error {
 		}
 	}
 	if asn >= ASNBase {
-		err := releaseASN(a.RethinkStore, asn)
+		err := releaseASN(a.Store, machine.PartitionID, asn)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+const (
+	eTagHeader    = "ETag"
+	ifMatchHeader = "If-Match"
+)
+
+// entityTag returns the entity tag of an entity, it is derived from the time of its last change.
+// The datastore does not keep more than millisecond precision for timestamps.
+func entityTag(entity metal.Entity) string {
+	return strconv.Quote(strconv.FormatInt(entity.GetChanged().UnixMilli(), 10))
+}
+
+// setEntityTag adds the entity tag of the entity to the response. Clients can pass it in the If-Match
+// header of subsequent updates and deletions of the entity.
+func setEntityTag(response *restful.Response, entity metal.Entity) {
+	response.AddHeader(eTagHeader, entityTag(entity))
+}
+
+// checkIfMatch returns an error with status 412 if the request contains an If-Match header which does
+// not match the entity tag of the given entity. Requests without If-Match header are always accepted.
+func checkIfMatch(request *restful.Request, entity metal.Entity) *httperrors.HTTPErrorResponse {
+	ifMatch := request.HeaderParameter(ifMatchHeader)
+	if ifMatch == "" {
+		return nil
+	}
+
+	tag := entityTag(entity)
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == tag {
+			return nil
+		}
+	}
+
+	return httperrors.NewHTTPError(http.StatusPreconditionFailed, fmt.Errorf("entity %s has changed since it was read, current entity tag is %s", entity.GetID(), tag))
+}
+
+// ifMatchParam documents the If-Match header of update and delete routes.
+func ifMatchParam(ws *restful.WebService) *restful.Parameter {
+	return ws.HeaderParameter(ifMatchHeader, "only apply the change if the entity tag of the entity matches, the entity tag is returned in the ETag header when reading the entity").DataType("string")
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestIfMatch(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+	require.NoError(t, ds.CreateSize(&metal.Size{Base: metal.Base{ID: "s1", Name: "a"}}))
+
+	container := restful.NewContainer().Add(NewSize(log, ds))
+
+	serve := func(method, path string, body any, ifMatch string) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			require.NoError(t, json.NewEncoder(&buf).Encode(body))
+		}
+		req := httptest.NewRequest(method, path, &buf)
+		req.Header.Add("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Add("If-Match", ifMatch)
+		}
+		container = injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("GET", "/v1/size/s1", nil, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	tag := w.Header().Get("ETag")
+	require.NotEmpty(t, tag)
+
+	name := "b"
+	update := v1.SizeUpdateRequest{Common: v1.Common{Identifiable: v1.Identifiable{ID: "s1"}, Describable: v1.Describable{Name: &name}}}
+
+	w = serve("POST", "/v1/size", update, `"1"`)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+
+	// entity tags have millisecond precision
+	time.Sleep(time.Millisecond)
+
+	w = serve("POST", "/v1/size", update, tag)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	newTag := w.Header().Get("ETag")
+	require.NotEmpty(t, newTag)
+
+	// the tag of the first read is outdated now
+	w = serve("DELETE", "/v1/size/s1", nil, tag)
+	require.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+
+	w = serve("GET", "/v1/size/s1", nil, "")
+	require.Equal(t, newTag, w.Header().Get("ETag"))
+
+	w = serve("DELETE", "/v1/size/s1", nil, newTag)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
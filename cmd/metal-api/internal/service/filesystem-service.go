@@ -20,7 +20,7 @@ type filesystemResource struct {
 }
 
 // NewFilesystemLayout returns a webservice for filesystem specific endpoints.
-func NewFilesystemLayout(log *zap.SugaredLogger, ds *datastore.RethinkStore) *restful.WebService {
+func NewFilesystemLayout(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
 	r := filesystemResource{
 		webResource: webResource{
 			log: log,
@@ -63,9 +63,11 @@ func (r *filesystemResource) webService() *restful.WebService {
 		Operation("deleteFilesystemLayout").
 		Doc("deletes an filesystemlayout and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the filesystemlayout").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.FilesystemLayoutResponse{}).
 		Returns(http.StatusOK, "OK", v1.FilesystemLayoutResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -82,10 +84,12 @@ func (r *filesystemResource) webService() *restful.WebService {
 		To(admin(r.updateFilesystemLayout)).
 		Operation("updateFilesystemLayout").
 		Doc("updates a filesystemlayout. if the filesystemlayout was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.FilesystemLayoutUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.FilesystemLayoutResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/try").
@@ -114,17 +118,18 @@ func (r *filesystemResource) webService() *restful.WebService {
 func (r *filesystemResource) findFilesystemLayout(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindFilesystemLayout(id)
+	s, err := r.store(request).FindFilesystemLayout(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, s)
 	r.send(request, response, http.StatusOK, v1.NewFilesystemLayoutResponse(s))
 }
 
 func (r *filesystemResource) listFilesystemLayouts(request *restful.Request, response *restful.Response) {
-	ss, err := r.ds.ListFilesystemLayouts()
+	ss, err := r.store(request).ListFilesystemLayouts()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -150,7 +155,7 @@ func (r *filesystemResource) createFilesystemLayout(request *restful.Request, re
 		r.sendError(request, response, httperrors.BadRequest(errors.New("id should not be empty")))
 		return
 	}
-	existing, _ := r.ds.FindFilesystemLayout(requestPayload.ID)
+	existing, _ := r.store(request).FindFilesystemLayout(requestPayload.ID)
 	if existing != nil {
 		r.sendError(request, response, httperrors.Conflict(fmt.Errorf("filesystemlayout:%s already exists", existing.ID)))
 		return
@@ -168,7 +173,7 @@ func (r *filesystemResource) createFilesystemLayout(request *restful.Request, re
 		return
 	}
 
-	fsls, err := r.ds.ListFilesystemLayouts()
+	fsls, err := r.store(request).ListFilesystemLayouts()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -181,7 +186,7 @@ func (r *filesystemResource) createFilesystemLayout(request *restful.Request, re
 		return
 	}
 
-	err = r.ds.CreateFilesystemLayout(fsl)
+	err = r.store(request).CreateFilesystemLayout(fsl)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -193,13 +198,18 @@ func (r *filesystemResource) createFilesystemLayout(request *restful.Request, re
 func (r *filesystemResource) deleteFilesystemLayout(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindFilesystemLayout(id)
+	s, err := r.store(request).FindFilesystemLayout(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = r.ds.DeleteFilesystemLayout(s)
+	if httperr := checkIfMatch(request, s); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeleteFilesystemLayout(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -216,12 +226,17 @@ func (r *filesystemResource) updateFilesystemLayout(request *restful.Request, re
 		return
 	}
 
-	oldFilesystemLayout, err := r.ds.FindFilesystemLayout(requestPayload.ID)
+	oldFilesystemLayout, err := r.store(request).FindFilesystemLayout(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldFilesystemLayout); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newFilesystemLayout, err := v1.NewFilesystemLayout(v1.FilesystemLayoutCreateRequest(requestPayload))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -234,7 +249,7 @@ func (r *filesystemResource) updateFilesystemLayout(request *restful.Request, re
 		return
 	}
 
-	fsls, err := r.ds.ListFilesystemLayouts()
+	fsls, err := r.store(request).ListFilesystemLayouts()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -247,12 +262,13 @@ func (r *filesystemResource) updateFilesystemLayout(request *restful.Request, re
 		return
 	}
 
-	err = r.ds.UpdateFilesystemLayout(oldFilesystemLayout, newFilesystemLayout)
+	err = r.store(request).UpdateFilesystemLayout(oldFilesystemLayout, newFilesystemLayout)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, newFilesystemLayout)
 	r.send(request, response, http.StatusOK, v1.NewFilesystemLayoutResponse(newFilesystemLayout))
 }
 
@@ -264,7 +280,7 @@ func (r *filesystemResource) tryFilesystemLayout(request *restful.Request, respo
 		return
 	}
 
-	ss, err := r.ds.ListFilesystemLayouts()
+	ss, err := r.store(request).ListFilesystemLayouts()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -287,13 +303,13 @@ func (r *filesystemResource) matchFilesystemLayout(request *restful.Request, res
 		return
 	}
 
-	fsl, err := r.ds.FindFilesystemLayout(match.FilesystemLayout)
+	fsl, err := r.store(request).FindFilesystemLayout(match.FilesystemLayout)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	machine, err := r.ds.FindMachineByID(match.Machine)
+	machine, err := r.store(request).FindMachineByID(match.Machine)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -43,7 +43,7 @@ type firewallResource struct {
 // NewFirewall returns a webservice for firewall specific endpoints.
 func NewFirewall(
 	log *zap.SugaredLogger,
-	ds *datastore.RethinkStore,
+	ds datastore.Store,
 	pub bus.Publisher,
 	ipamer ipam.IPAMer,
 	ep *bus.Endpoints,
@@ -100,7 +100,7 @@ func (r *firewallResource) webService() *restful.WebService {
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Metadata(auditing.Exclude, true).
 		Writes([]v1.FirewallResponse{}).
-		Returns(http.StatusOK, "OK", []v1.FirewallResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.FirewallResponse{}, nextCursorHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.GET("/").
@@ -127,7 +127,7 @@ func (r *firewallResource) webService() *restful.WebService {
 func (r *firewallResource) findFirewall(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	fw, err := r.ds.FindMachineByID(id)
+	fw, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -138,12 +138,13 @@ func (r *firewallResource) findFirewall(request *restful.Request, response *rest
 		return
 	}
 
-	resp, err := makeFirewallResponse(fw, r.ds)
+	resp, err := makeFirewallResponse(fw, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, fw)
 	r.send(request, response, http.StatusOK, resp)
 }
 
@@ -158,13 +159,20 @@ func (r *firewallResource) findFirewalls(request *restful.Request, response *res
 	requestPayload.AllocationRole = &metal.RoleFirewall
 
 	var fws metal.Machines
-	err = r.ds.SearchMachines(&requestPayload, &fws)
+	err = r.store(request).SearchMachines(&requestPayload, &fws)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	next, err := requestPayload.NextCursor(fws)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
+	setNextCursor(response, next)
 
-	resp, err := makeFirewallResponseList(fws, r.ds)
+	resp, err := makeFirewallResponseList(fws, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -175,7 +183,7 @@ func (r *firewallResource) findFirewalls(request *restful.Request, response *res
 
 func (r *firewallResource) listFirewalls(request *restful.Request, response *restful.Response) {
 	var fws metal.Machines
-	err := r.ds.SearchMachines(&datastore.MachineSearchQuery{
+	err := r.store(request).SearchMachines(&datastore.MachineSearchQuery{
 		AllocationRole: &metal.RoleFirewall,
 	}, &fws)
 	if err != nil {
@@ -183,7 +191,7 @@ func (r *firewallResource) listFirewalls(request *restful.Request, response *res
 		return
 	}
 
-	resp, err := makeFirewallResponseList(fws, r.ds)
+	resp, err := makeFirewallResponseList(fws, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -206,24 +214,24 @@ func (r *firewallResource) allocateFirewall(request *restful.Request, response *
 		return
 	}
 
-	spec, err := createMachineAllocationSpec(r.ds, requestPayload.MachineAllocateRequest, metal.RoleFirewall, user)
+	spec, err := createMachineAllocationSpec(r.store(request), requestPayload.MachineAllocateRequest, metal.RoleFirewall, user)
 	if err != nil {
 		r.sendError(request, response, httperrors.BadRequest(err))
 		return
 	}
 
-	if err := r.setVPNConfigInSpec(request.Request.Context(), spec); err != nil {
+	if err := setVPNConfigInSpec(request.Request.Context(), r.headscaleClient, spec); err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	m, err := allocateMachine(r.logger(request), r.ds, r.ipamer, spec, r.mdc, r.actor, r.Publisher)
+	m, err := allocateMachine(r.logger(request), r.store(request), r.ipamer, spec, r.mdc, r.actor, r.Publisher)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	resp, err := makeMachineResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -232,32 +240,32 @@ func (r *firewallResource) allocateFirewall(request *restful.Request, response *
 	r.send(request, response, http.StatusOK, resp)
 }
 
-func (r firewallResource) setVPNConfigInSpec(ctx context.Context, allocationSpec *machineAllocationSpec) error {
-	if r.headscaleClient == nil {
+func setVPNConfigInSpec(ctx context.Context, headscaleClient *headscale.HeadscaleClient, allocationSpec *machineAllocationSpec) error {
+	if headscaleClient == nil {
 		return nil
 	}
 
 	// Try to create user in Headscale DB
 	projectID := allocationSpec.ProjectID
-	if err := r.headscaleClient.CreateUser(ctx, projectID); err != nil {
+	if err := headscaleClient.CreateUser(ctx, projectID); err != nil {
 		return fmt.Errorf("failed to create new VPN user for the project: %w", err)
 	}
 
 	expiration := time.Now().Add(2 * time.Hour)
-	key, err := r.headscaleClient.CreatePreAuthKey(ctx, projectID, expiration, false)
+	key, err := headscaleClient.CreatePreAuthKey(ctx, projectID, expiration, false)
 	if err != nil {
 		return fmt.Errorf("failed to create new auth key for the firewall: %w", err)
 	}
 
 	allocationSpec.VPN = &metal.MachineVPN{
-		ControlPlaneAddress: r.headscaleClient.GetControlPlaneAddress(),
+		ControlPlaneAddress: headscaleClient.GetControlPlaneAddress(),
 		AuthKey:             key,
 	}
 
 	return nil
 }
 
-func makeFirewallResponse(fw *metal.Machine, ds *datastore.RethinkStore) (*v1.FirewallResponse, error) {
+func makeFirewallResponse(fw *metal.Machine, ds datastore.Store) (*v1.FirewallResponse, error) {
 	ms, err := makeMachineResponse(fw, ds)
 	if err != nil {
 		return nil, err
@@ -265,7 +273,7 @@ func makeFirewallResponse(fw *metal.Machine, ds *datastore.RethinkStore) (*v1.Fi
 	return &v1.FirewallResponse{MachineResponse: *ms}, nil
 }
 
-func makeFirewallResponseList(fws metal.Machines, ds *datastore.RethinkStore) ([]*v1.FirewallResponse, error) {
+func makeFirewallResponseList(fws metal.Machines, ds datastore.Store) ([]*v1.FirewallResponse, error) {
 	machineResponseList, err := makeMachineResponseList(fws, ds)
 	if err != nil {
 		return nil, err
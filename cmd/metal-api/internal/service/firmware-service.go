@@ -29,7 +29,7 @@ type firmwareResource struct {
 }
 
 // NewFirmware returns a webservice for firmware specific endpoints.
-func NewFirmware(log *zap.SugaredLogger, ds *datastore.RethinkStore, s3Client *s3server.Client) (*restful.WebService, error) {
+func NewFirmware(log *zap.SugaredLogger, ds datastore.Store, s3Client *s3server.Client) (*restful.WebService, error) {
 	r := firmwareResource{
 		webResource: webResource{
 			log: log,
@@ -111,7 +111,7 @@ func (r *firmwareResource) uploadFirmware(request *restful.Request, response *re
 
 	// check that at least one machine matches kind, vendor and board
 	validReq := false
-	mm, err := r.ds.ListMachines()
+	mm, err := r.store(request).ListMachines()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -218,7 +218,7 @@ func (r *firmwareResource) listFirmwares(request *restful.Request, response *res
 				return
 			}
 		default:
-			_, f, err := getFirmware(r.ds, machineID)
+			_, f, err := getFirmware(r.store(request), machineID)
 			if err != nil {
 				r.sendError(request, response, defaultError(err))
 				return
@@ -238,7 +238,7 @@ func (r *firmwareResource) listFirmwares(request *restful.Request, response *res
 	r.send(request, response, http.StatusOK, mapToFirmwareResponse(rr))
 }
 
-func getFirmware(ds *datastore.RethinkStore, machineID string) (*metal.Machine, *v1.Firmware, error) {
+func getFirmware(ds datastore.Store, machineID string) (*metal.Machine, *v1.Firmware, error) {
 	m, err := ds.FindMachineByID(machineID)
 	if err != nil {
 		return nil, nil, err
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type fsckResource struct {
+	webResource
+	ipamer ipam.IPAMer
+}
+
+// NewFsck returns a webservice for checking the consistency between the entities of the datastore and the ipam.
+func NewFsck(log *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer) *restful.WebService {
+	r := fsckResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+		ipamer: ipamer,
+	}
+	return r.webService()
+}
+
+func (r *fsckResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/fsck").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"fsck"}
+
+	ws.Route(ws.POST("/").
+		To(admin(r.fsck)).
+		Operation("fsck").
+		Doc("checks the consistency between the entities of the datastore and the ipam, repairs must not run while machines or ips are allocated. ips missing in the ipam are only checked when repairing").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.FsckRequest{}).
+		Writes(v1.FsckResponse{}).
+		Returns(http.StatusOK, "OK", v1.FsckResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *fsckResource) fsck(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.FsckRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	checks, err := ParseFsckChecks(requestPayload.Checks)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	resp, err := Fsck(r.store(request), r.ipamer, r.log, checks, requestPayload.Repair)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
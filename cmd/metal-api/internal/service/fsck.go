@@ -0,0 +1,363 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+
+	goipam "github.com/metal-stack/go-ipam"
+)
+
+// FsckCheck is a check for the consistency between the entities of the datastore and the ipam.
+type FsckCheck string
+
+const (
+	// FsckMachineNetworkMissing finds machine networks which reference deleted networks
+	FsckMachineNetworkMissing FsckCheck = "machine-network-missing"
+	// FsckIPMachineMissing finds ips which are tagged with machines that do not exist anymore
+	FsckIPMachineMissing FsckCheck = "ip-machine-missing"
+	// FsckSwitchMachineMissing finds switch connections of machines that do not exist anymore
+	FsckSwitchMachineMissing FsckCheck = "switch-machine-missing"
+	// FsckASNUnused finds asns which are acquired from the asn pool but not used by any machine
+	FsckASNUnused FsckCheck = "asn-unused"
+	// FsckPrefixMissingInIpam finds network prefixes which do not exist in the ipam
+	FsckPrefixMissingInIpam FsckCheck = "prefix-missing-in-ipam"
+	// FsckIPMissingInIpam finds ips which are not acquired in the ipam, it only runs in repair mode
+	FsckIPMissingInIpam FsckCheck = "ip-missing-in-ipam"
+)
+
+// FsckChecks are all available checks in the order they are run.
+var FsckChecks = []FsckCheck{
+	FsckMachineNetworkMissing,
+	FsckIPMachineMissing,
+	FsckSwitchMachineMissing,
+	FsckASNUnused,
+	FsckPrefixMissingInIpam,
+	FsckIPMissingInIpam,
+}
+
+// ParseFsckChecks returns the checks with the given names, all checks are returned if no name is given.
+func ParseFsckChecks(names []string) ([]FsckCheck, error) {
+	if len(names) == 0 {
+		return FsckChecks, nil
+	}
+
+	requested := map[FsckCheck]bool{}
+	for _, name := range names {
+		found := false
+		for _, c := range FsckChecks {
+			if string(c) == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			var all []string
+			for _, c := range FsckChecks {
+				all = append(all, string(c))
+			}
+			return nil, fmt.Errorf("unknown check %q, available checks are: %s", name, strings.Join(all, ", "))
+		}
+		requested[FsckCheck(name)] = true
+	}
+
+	// checks are always run in the order of the catalogue
+	var result []FsckCheck
+	for _, c := range FsckChecks {
+		if requested[c] {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+type fsck struct {
+	log    *zap.SugaredLogger
+	ds     datastore.Store
+	ipamer ipam.IPAMer
+	repair bool
+
+	machines map[string]metal.Machine
+	networks metal.Networks
+	ips      metal.IPs
+
+	findings []v1.FsckFinding
+}
+
+// Fsck runs the given checks against the datastore and the ipam and returns the inconsistencies which were found.
+// If repair is true, the findings which can be fixed safely are repaired. Repairs must not run while machines or
+// ips are allocated concurrently because entities in the middle of an allocation look inconsistent.
+func Fsck(ds datastore.Store, ipamer ipam.IPAMer, logger *zap.SugaredLogger, checks []FsckCheck, repair bool) (*v1.FsckResponse, error) {
+	logger.Infow("consistency check was requested", "checks", checks, "repair", repair)
+
+	f := &fsck{
+		log:      logger,
+		ds:       ds,
+		ipamer:   ipamer,
+		repair:   repair,
+		machines: map[string]metal.Machine{},
+	}
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range machines {
+		f.machines[m.ID] = m
+	}
+
+	f.networks, err = ds.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+
+	f.ips, err = ds.ListIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range checks {
+		var err error
+		switch c {
+		case FsckMachineNetworkMissing:
+			f.checkMachineNetworks()
+		case FsckIPMachineMissing:
+			f.checkIPMachines()
+		case FsckSwitchMachineMissing:
+			err = f.checkSwitchMachines()
+		case FsckASNUnused:
+			err = f.checkASNs()
+		case FsckPrefixMissingInIpam:
+			f.checkPrefixes()
+		case FsckIPMissingInIpam:
+			f.checkIPs()
+		default:
+			err = fmt.Errorf("unknown check %q", c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to run check %s: %w", c, err)
+		}
+	}
+
+	order := map[string]int{}
+	for i, c := range FsckChecks {
+		order[string(c)] = i
+	}
+	sort.SliceStable(f.findings, func(i, j int) bool {
+		a, b := f.findings[i], f.findings[j]
+		if a.Check != b.Check {
+			return order[a.Check] < order[b.Check]
+		}
+		return a.EntityID < b.EntityID
+	})
+
+	repaired := 0
+	for _, finding := range f.findings {
+		if finding.Repaired {
+			repaired++
+		}
+	}
+
+	logger.Infow("consistency check finished", "findings", len(f.findings), "repaired", repaired)
+
+	return &v1.FsckResponse{Findings: f.findings}, nil
+}
+
+// add records a finding, repair is nil if the finding cannot be repaired safely.
+func (f *fsck) add(check FsckCheck, kind, id, message string, repair func() error) {
+	finding := v1.FsckFinding{
+		Check:      string(check),
+		EntityKind: kind,
+		EntityID:   id,
+		Message:    message,
+		Repairable: repair != nil,
+	}
+
+	if f.repair && repair != nil {
+		err := repair()
+		if err != nil {
+			f.log.Errorw("unable to repair finding", "check", check, "kind", kind, "id", id, "error", err)
+			msg := err.Error()
+			finding.RepairError = &msg
+		} else {
+			f.log.Infow("repaired finding", "check", check, "kind", kind, "id", id)
+			finding.Repaired = true
+		}
+	}
+
+	f.findings = append(f.findings, finding)
+}
+
+func (f *fsck) checkMachineNetworks() {
+	networks := map[string]bool{}
+	for _, n := range f.networks {
+		networks[n.ID] = true
+	}
+
+	for _, m := range f.machines {
+		if m.Allocation == nil {
+			continue
+		}
+		for _, mn := range m.Allocation.MachineNetworks {
+			if mn == nil || networks[mn.NetworkID] {
+				continue
+			}
+			f.add(FsckMachineNetworkMissing, "machine", m.ID, fmt.Sprintf("machine network references network %s which does not exist", mn.NetworkID), nil)
+		}
+	}
+}
+
+func (f *fsck) checkIPMachines() {
+	for i := range f.ips {
+		ip := f.ips[i]
+
+		var missing []string
+		for _, id := range ip.GetMachineIds() {
+			if _, ok := f.machines[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		f.add(FsckIPMachineMissing, "ip", ip.IPAddress, fmt.Sprintf("ip is tagged with machines which do not exist: %s", strings.Join(missing, ", ")), func() error {
+			newIP := ip
+			newIP.Tags = append([]string{}, ip.Tags...)
+			for _, id := range missing {
+				newIP.RemoveMachineId(id)
+			}
+			return f.ds.UpdateIP(&ip, &newIP)
+		})
+	}
+}
+
+func (f *fsck) checkSwitchMachines() error {
+	switches, err := f.ds.ListSwitches()
+	if err != nil {
+		return err
+	}
+
+	for i := range switches {
+		sw := switches[i]
+
+		var missing []string
+		for id := range sw.MachineConnections {
+			if _, ok := f.machines[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		sort.Strings(missing)
+
+		f.add(FsckSwitchMachineMissing, "switch", sw.ID, fmt.Sprintf("switch has connections of machines which do not exist: %s", strings.Join(missing, ", ")), func() error {
+			newSwitch := sw
+			newSwitch.MachineConnections = metal.ConnectionMap{}
+			for id, connections := range sw.MachineConnections {
+				newSwitch.MachineConnections[id] = connections
+			}
+			for _, id := range missing {
+				delete(newSwitch.MachineConnections, id)
+			}
+			return f.ds.UpdateSwitch(&sw, &newSwitch)
+		})
+	}
+
+	return nil
+}
+
+func (f *fsck) checkASNs() error {
+	pools := []datastore.UniqueIntegerPool{f.ds.GetASNPool()}
+	for _, pool := range f.ds.GetPartitionASNPools() {
+		pools = append(pools, pool)
+	}
+
+	used := map[string]map[uint]bool{}
+	for _, m := range f.machines {
+		if m.Allocation == nil {
+			continue
+		}
+		for _, mn := range m.Allocation.MachineNetworks {
+			if mn == nil || mn.ASN < ASNBase {
+				continue
+			}
+			i := uint(mn.ASN - ASNBase)
+			pool := asnPool(f.ds, m.PartitionID, i).String()
+			if used[pool] == nil {
+				used[pool] = map[uint]bool{}
+			}
+			used[pool][i] = true
+		}
+	}
+
+	for _, pool := range pools {
+		pool := pool
+
+		acquired, err := pool.AcquiredIntegers()
+		if err != nil {
+			return err
+		}
+
+		for _, i := range acquired {
+			i := i
+			if used[pool.String()][i] {
+				continue
+			}
+			asn := ASNBase + uint32(i)
+			f.add(FsckASNUnused, "asn", strconv.FormatUint(uint64(asn), 10), fmt.Sprintf("asn is acquired from pool %s but not used by any machine", pool), func() error {
+				return pool.ReleaseUniqueInteger(i)
+			})
+		}
+	}
+
+	return nil
+}
+
+func (f *fsck) checkPrefixes() {
+	for _, n := range f.networks {
+		for _, p := range n.Prefixes {
+			_, err := f.ipamer.PrefixUsage(p.String())
+			if err != nil {
+				f.add(FsckPrefixMissingInIpam, "network", n.ID, fmt.Sprintf("prefix %s cannot be found in the ipam: %s", p.String(), err), nil)
+			}
+		}
+	}
+}
+
+// checkIPs acquires the ips of the datastore in the ipam. The ipam offers no read-only lookup of single ips and
+// acquiring ips interferes with concurrent allocations, so the check only runs in repair mode. An ip which could be
+// acquired was missing in the ipam and is reported as repaired.
+func (f *fsck) checkIPs() {
+	if !f.repair {
+		f.log.Infow("skipping check which only runs in repair mode", "check", FsckIPMissingInIpam)
+		return
+	}
+
+	for _, ip := range f.ips {
+		prefix, err := metal.NewPrefixFromCIDR(ip.ParentPrefixCidr)
+		if err != nil {
+			f.add(FsckIPMissingInIpam, "ip", ip.IPAddress, err.Error(), nil)
+			continue
+		}
+
+		_, err = f.ipamer.AllocateSpecificIP(*prefix, ip.IPAddress)
+		if errors.Is(err, goipam.ErrAlreadyAllocated) {
+			continue
+		}
+
+		f.add(FsckIPMissingInIpam, "ip", ip.IPAddress, fmt.Sprintf("ip is not acquired in prefix %s of the ipam", ip.ParentPrefixCidr), func() error {
+			// the ip was acquired above already
+			return err
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestFsck(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+	ipamer := ipam.InitTestIpam(t)
+
+	prefix, err := metal.NewPrefixFromCIDR("10.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, ipamer.CreatePrefix(*prefix))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "n1"}, Prefixes: metal.Prefixes{*prefix}}))
+
+	asn, err := acquireASN(ds, "")
+	require.NoError(t, err)
+	unusedASN, err := acquireASN(ds, "")
+	require.NoError(t, err)
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}}
+	require.NoError(t, ds.CreateMachine(m))
+	allocated := *m
+	allocated.Allocation = &metal.MachineAllocation{
+		MachineNetworks: []*metal.MachineNetwork{
+			{NetworkID: "n1", ASN: *asn},
+			{NetworkID: "deleted", ASN: *asn},
+		},
+	}
+	require.NoError(t, ds.UpdateMachine(m, &allocated))
+
+	_, err = ipamer.AllocateSpecificIP(*prefix, "10.0.0.1")
+	require.NoError(t, err)
+	require.NoError(t, ds.CreateIP(&metal.IP{
+		IPAddress:        "10.0.0.1",
+		ParentPrefixCidr: "10.0.0.0/24",
+		Tags:             []string{metal.IpTag(tag.MachineID, "m1")},
+	}))
+	require.NoError(t, ds.CreateIP(&metal.IP{
+		IPAddress:        "10.0.0.2",
+		ParentPrefixCidr: "10.0.0.0/24",
+		Tags:             []string{metal.IpTag(tag.MachineID, "m2")},
+	}))
+
+	require.NoError(t, ds.CreateSwitch(&metal.Switch{
+		Base: metal.Base{ID: "sw1"},
+		MachineConnections: metal.ConnectionMap{
+			"m1": metal.Connections{{MachineID: "m1"}},
+			"m2": metal.Connections{{MachineID: "m2"}},
+		},
+	}))
+
+	checks := func(findings []v1.FsckFinding) []string {
+		var result []string
+		for _, f := range findings {
+			result = append(result, f.Check+"/"+f.EntityID)
+		}
+		return result
+	}
+
+	want := []string{
+		string(FsckMachineNetworkMissing) + "/m1",
+		string(FsckIPMachineMissing) + "/10.0.0.2",
+		string(FsckSwitchMachineMissing) + "/sw1",
+		string(FsckASNUnused) + "/" + strconv.FormatUint(uint64(*unusedASN), 10),
+	}
+
+	resp, err := Fsck(ds, ipamer, log, FsckChecks, false)
+	require.NoError(t, err)
+	assert.Equal(t, want, checks(resp.Findings), "ips missing in the ipam are only checked in repair mode")
+	for _, f := range resp.Findings {
+		assert.False(t, f.Repaired)
+		assert.Equal(t, f.Check != string(FsckMachineNetworkMissing), f.Repairable, f.Check)
+	}
+
+	resp, err = Fsck(ds, ipamer, log, FsckChecks, true)
+	require.NoError(t, err)
+	assert.Equal(t, append(want, string(FsckIPMissingInIpam)+"/10.0.0.2"), checks(resp.Findings))
+	for _, f := range resp.Findings {
+		assert.Nil(t, f.RepairError, f.Check)
+		assert.Equal(t, f.Repairable, f.Repaired, f.Check)
+	}
+
+	resp, err = Fsck(ds, ipamer, log, FsckChecks, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{string(FsckMachineNetworkMissing) + "/m1"}, checks(resp.Findings), "only findings without repair must remain")
+
+	resp, err = Fsck(ds, ipamer, log, []FsckCheck{FsckIPMissingInIpam}, true)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Findings, "the ips were acquired in the ipam")
+
+	ip, err := ds.FindIPByID("10.0.0.2")
+	require.NoError(t, err)
+	assert.Empty(t, ip.GetMachineIds())
+
+	sw, err := ds.FindSwitch("sw1")
+	require.NoError(t, err)
+	assert.Len(t, sw.MachineConnections, 1)
+
+	_, err = ParseFsckChecks([]string{"unknown"})
+	require.Error(t, err)
+}
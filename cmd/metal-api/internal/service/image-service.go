@@ -24,7 +24,7 @@ type imageResource struct {
 }
 
 // NewImage returns a webservice for image specific endpoints.
-func NewImage(log *zap.SugaredLogger, ds *datastore.RethinkStore) *restful.WebService {
+func NewImage(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
 	ir := imageResource{
 		webResource: webResource{
 			log: log,
@@ -101,9 +101,11 @@ func (ir imageResource) webService() *restful.WebService {
 		Operation("deleteImage").
 		Doc("deletes an image and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the image").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.ImageResponse{}).
 		Returns(http.StatusOK, "OK", v1.ImageResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -120,10 +122,12 @@ func (ir imageResource) webService() *restful.WebService {
 		To(admin(ir.updateImage)).
 		Operation("updateImage").
 		Doc("updates an image. if the image was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.ImageUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.ImageResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	return ws
@@ -132,19 +136,20 @@ func (ir imageResource) webService() *restful.WebService {
 func (r *imageResource) findImage(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	img, err := r.ds.GetImage(id)
+	img, err := r.store(request).GetImage(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, img)
 	r.send(request, response, http.StatusOK, v1.NewImageResponse(img))
 }
 
 func (r *imageResource) queryImages(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	img, err := r.ds.FindImages(id)
+	img, err := r.store(request).FindImages(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -162,7 +167,7 @@ func (r *imageResource) queryImages(request *restful.Request, response *restful.
 func (r *imageResource) findLatestImage(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	img, err := r.ds.FindImage(id)
+	img, err := r.store(request).FindImage(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -172,7 +177,7 @@ func (r *imageResource) findLatestImage(request *restful.Request, response *rest
 }
 
 func (r *imageResource) listImages(request *restful.Request, response *restful.Response) {
-	imgs, err := r.ds.ListImages()
+	imgs, err := r.store(request).ListImages()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -188,7 +193,7 @@ func (r *imageResource) listImages(request *restful.Request, response *restful.R
 		}
 
 		if showUsage {
-			ms, err = r.ds.ListMachines()
+			ms, err = r.store(request).ListMachines()
 			if err != nil {
 				r.sendError(request, response, defaultError(err))
 				return
@@ -220,7 +225,7 @@ func (r *imageResource) findImages(request *restful.Request, response *restful.R
 	}
 
 	var imgs metal.Images
-	err = r.ds.SearchImages(&requestPayload, &imgs)
+	err = r.store(request).SearchImages(&requestPayload, &imgs)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -236,7 +241,7 @@ func (r *imageResource) findImages(request *restful.Request, response *restful.R
 		}
 
 		if showUsage {
-			ms, err = r.ds.ListMachines()
+			ms, err = r.store(request).ListMachines()
 			if err != nil {
 				r.sendError(request, response, defaultError(err))
 				return
@@ -337,7 +342,7 @@ func (r *imageResource) createImage(request *restful.Request, response *restful.
 		Classification: vc,
 	}
 
-	err = r.ds.CreateImage(img)
+	err = r.store(request).CreateImage(img)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -361,13 +366,18 @@ func checkImageURL(id, url string) error {
 func (r *imageResource) deleteImage(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	img, err := r.ds.GetImage(id)
+	img, err := r.store(request).GetImage(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	ms, err := r.ds.ListMachines()
+	if httperr := checkIfMatch(request, img); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	ms, err := r.store(request).ListMachines()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -381,7 +391,7 @@ func (r *imageResource) deleteImage(request *restful.Request, response *restful.
 		}
 	}
 
-	err = r.ds.DeleteImage(img)
+	err = r.store(request).DeleteImage(img)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -398,12 +408,17 @@ func (r *imageResource) updateImage(request *restful.Request, response *restful.
 		return
 	}
 
-	oldImage, err := r.ds.GetImage(requestPayload.ID)
+	oldImage, err := r.store(request).GetImage(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldImage); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newImage := *oldImage
 
 	if requestPayload.Name != nil {
@@ -449,12 +464,13 @@ func (r *imageResource) updateImage(request *restful.Request, response *restful.
 		newImage.ExpirationDate = *requestPayload.ExpirationDate
 	}
 
-	err = r.ds.UpdateImage(oldImage, &newImage)
+	err = r.store(request).UpdateImage(oldImage, &newImage)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newImage)
 	r.send(request, response, http.StatusOK, v1.NewImageResponse(&newImage))
 }
 
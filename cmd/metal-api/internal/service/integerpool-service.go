@@ -0,0 +1,248 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+const (
+	integerPoolKindVRF = "vrf"
+	integerPoolKindASN = "asn"
+)
+
+type integerPoolResource struct {
+	webResource
+}
+
+// integerPool is an integer pool together with the kind of integers it hands out.
+type integerPool struct {
+	pool        datastore.UniqueIntegerPool
+	kind        string
+	partitionID *string
+}
+
+// NewIntegerPool returns a webservice for the usage of the integer pools of vrfs and asns.
+func NewIntegerPool(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
+	r := integerPoolResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+	}
+	return r.webService()
+}
+
+func (r *integerPoolResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/integer-pool").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"integer-pool"}
+
+	ws.Route(ws.GET("/").
+		To(admin(r.listIntegerPools)).
+		Operation("listIntegerPools").
+		Doc("get the usage of all integer pools").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.IntegerPoolResponse{}).
+		Returns(http.StatusOK, "OK", []v1.IntegerPoolResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{name}").
+		To(admin(r.findIntegerPool)).
+		Operation("findIntegerPool").
+		Doc("get the usage of an integer pool").
+		Param(ws.PathParameter("name", "name of the integer pool").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.IntegerPoolResponse{}).
+		Returns(http.StatusOK, "OK", v1.IntegerPoolResponse{}).
+		Returns(http.StatusNotFound, "Not Found", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{name}/{integer}").
+		To(admin(r.findIntegerPoolInteger)).
+		Operation("findIntegerPoolInteger").
+		Doc("get the machines or networks which hold an integer of an integer pool").
+		Param(ws.PathParameter("name", "name of the integer pool").DataType("string")).
+		Param(ws.PathParameter("integer", "the integer of the pool, for asns it is the offset to the asn base").DataType("integer")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.IntegerPoolIntegerResponse{}).
+		Returns(http.StatusOK, "OK", v1.IntegerPoolIntegerResponse{}).
+		Returns(http.StatusNotFound, "Not Found", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *integerPoolResource) listIntegerPools(request *restful.Request, response *restful.Response) {
+	result := []*v1.IntegerPoolResponse{}
+	for _, p := range integerPools(r.ds) {
+		resp, err := makeIntegerPoolResponse(p)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		result = append(result, resp)
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *integerPoolResource) findIntegerPool(request *restful.Request, response *restful.Response) {
+	p, err := findIntegerPool(r.ds, request.PathParameter("name"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makeIntegerPoolResponse(*p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *integerPoolResource) findIntegerPoolInteger(request *restful.Request, response *restful.Response) {
+	p, err := findIntegerPool(r.ds, request.PathParameter("name"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	value, err := strconv.ParseUint(request.PathParameter("integer"), 10, 64)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+	i := uint(value)
+
+	rng := p.pool.Range()
+	if i < rng.Min || i > rng.Max {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("integer %d is not in the range %s of pool %s", i, rng, p.pool)))
+		return
+	}
+
+	acquired, err := p.pool.AcquiredIntegers()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp := &v1.IntegerPoolIntegerResponse{
+		Pool:       p.pool.String(),
+		Integer:    i,
+		Value:      uint64(i),
+		MachineIDs: []string{},
+		NetworkIDs: []string{},
+	}
+	for _, a := range acquired {
+		if a == i {
+			resp.Acquired = true
+			break
+		}
+	}
+
+	switch p.kind {
+	case integerPoolKindVRF:
+		vrf := int64(i)
+		var nws metal.Networks
+		err = r.ds.SearchNetworks(&datastore.NetworkSearchQuery{Vrf: &vrf}, &nws)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		for _, nw := range nws {
+			resp.NetworkIDs = append(resp.NetworkIDs, nw.ID)
+		}
+	case integerPoolKindASN:
+		asn := uint64(ASNBase) + uint64(i)
+		resp.Value = asn
+
+		var ms metal.Machines
+		err = r.ds.SearchMachines(&datastore.MachineSearchQuery{NetworkASNs: []int64{int64(asn)}}, &ms)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		for _, m := range ms {
+			// the same asn can be used in other partitions which have a pool of their own
+			if asnPool(r.ds, m.PartitionID, i).String() != p.pool.String() {
+				continue
+			}
+			resp.MachineIDs = append(resp.MachineIDs, m.ID)
+		}
+	}
+
+	sort.Strings(resp.MachineIDs)
+	sort.Strings(resp.NetworkIDs)
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+// integerPools returns all integer pools, the asn pools of the partitions are sorted by partition.
+func integerPools(ds datastore.Store) []integerPool {
+	result := []integerPool{
+		{pool: ds.GetVRFPool(), kind: integerPoolKindVRF},
+		{pool: ds.GetASNPool(), kind: integerPoolKindASN},
+	}
+
+	partitionPools := ds.GetPartitionASNPools()
+	var partitions []string
+	for partitionID := range partitionPools {
+		partitions = append(partitions, partitionID)
+	}
+	sort.Strings(partitions)
+
+	for _, partitionID := range partitions {
+		partitionID := partitionID
+		result = append(result, integerPool{pool: partitionPools[partitionID], kind: integerPoolKindASN, partitionID: &partitionID})
+	}
+
+	return result
+}
+
+func findIntegerPool(ds datastore.Store, name string) (*integerPool, error) {
+	for _, p := range integerPools(ds) {
+		if p.pool.String() == name {
+			p := p
+			return &p, nil
+		}
+	}
+	return nil, metal.NotFound("no integer pool with name %q found", name)
+}
+
+func makeIntegerPoolResponse(p integerPool) (*v1.IntegerPoolResponse, error) {
+	acquired, err := p.pool.AcquiredIntegers()
+	if err != nil {
+		return nil, err
+	}
+
+	rng := p.pool.Range()
+	used := uint(len(acquired))
+
+	return &v1.IntegerPoolResponse{
+		Name:        p.pool.String(),
+		Kind:        p.kind,
+		PartitionID: p.partitionID,
+		Min:         rng.Min,
+		Max:         rng.Max,
+		Total:       rng.Size(),
+		Used:        used,
+		Free:        rng.Size() - used,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestIntegerPools(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+	ds.SetASNPoolRange(1, 10)
+	ds.SetPartitionASNPoolRange("p1", 11, 12)
+
+	asn, err := acquireASN(ds, "p1")
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, *asn, ASNBase+11, "partitions with a pool of their own must not use the global pool")
+
+	m := &metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1"}
+	require.NoError(t, ds.CreateMachine(m))
+	allocated := *m
+	allocated.Allocation = &metal.MachineAllocation{MachineNetworks: []*metal.MachineNetwork{{NetworkID: "n1", ASN: *asn}}}
+	require.NoError(t, ds.UpdateMachine(m, &allocated))
+
+	_, err = acquireASN(ds, "p2")
+	require.NoError(t, err)
+
+	container := restful.NewContainer().Add(NewIntegerPool(log, ds))
+	get := func(path string, result any) int {
+		req := httptest.NewRequest("GET", path, nil)
+		container = injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	var pools []v1.IntegerPoolResponse
+	require.Equal(t, http.StatusOK, get("/v1/integer-pool", &pools))
+	require.Len(t, pools, 3)
+	assert.Equal(t, "vrf", pools[0].Kind)
+	assert.Equal(t, v1.IntegerPoolResponse{Name: "asnpool", Kind: "asn", Min: 1, Max: 10, Total: 10, Used: 1, Free: 9}, pools[1])
+	p1 := "p1"
+	assert.Equal(t, v1.IntegerPoolResponse{Name: "asnpool_p1", Kind: "asn", PartitionID: &p1, Min: 11, Max: 12, Total: 2, Used: 1, Free: 1}, pools[2])
+
+	var integer v1.IntegerPoolIntegerResponse
+	i := uint(*asn - ASNBase)
+	require.Equal(t, http.StatusOK, get("/v1/integer-pool/asnpool_p1/"+strconv.FormatUint(uint64(i), 10), &integer))
+	assert.True(t, integer.Acquired)
+	assert.Equal(t, uint64(*asn), integer.Value)
+	assert.Equal(t, []string{"m1"}, integer.MachineIDs)
+
+	require.Equal(t, http.StatusBadRequest, get("/v1/integer-pool/asnpool_p1/1", &integer))
+	require.Equal(t, http.StatusNotFound, get("/v1/integer-pool/unknown", &integer))
+
+	require.NoError(t, releaseASN(ds, "p1", *asn))
+	require.Equal(t, http.StatusOK, get("/v1/integer-pool/asnpool_p1", &pools[2]))
+	assert.Equal(t, uint(0), pools[2].Used)
+}
@@ -35,7 +35,7 @@ type ipResource struct {
 }
 
 // NewIP returns a webservice for ip specific endpoints.
-func NewIP(log *zap.SugaredLogger, ds *datastore.RethinkStore, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client) (*restful.WebService, error) {
+func NewIP(log *zap.SugaredLogger, ds datastore.Store, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client) (*restful.WebService, error) {
 	ir := ipResource{
 		webResource: webResource{
 			log: log,
@@ -88,7 +88,7 @@ func (r *ipResource) webService() *restful.WebService {
 		Metadata(auditing.Exclude, true).
 		Reads(v1.IPFindRequest{}).
 		Writes([]v1.IPResponse{}).
-		Returns(http.StatusOK, "OK", []v1.IPResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.IPResponse{}, nextCursorHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/free/{id}").
@@ -97,9 +97,11 @@ func (r *ipResource) webService() *restful.WebService {
 		Doc("frees an ip").
 		Param(ws.PathParameter("id", "identifier of the ip").DataType("string")).
 		Consumes(restful.MIME_JSON, "*/*").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.IPResponse{}).
 		Returns(http.StatusOK, "OK", v1.IPResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}).
 		Deprecate())
 
@@ -108,20 +110,24 @@ func (r *ipResource) webService() *restful.WebService {
 		Operation("freeIP").
 		Doc("frees an ip").
 		Param(ws.PathParameter("id", "identifier of the ip").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.IPResponse{}).
 		Returns(http.StatusOK, "OK", v1.IPResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/").
 		To(editor(r.updateIP)).
 		Operation("updateIP").
 		Doc("updates an ip. if the ip was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.IPUpdateRequest{}).
 		Writes(v1.IPResponse{}).
 		Returns(http.StatusOK, "OK", v1.IPResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/allocate").
@@ -153,17 +159,18 @@ func (r *ipResource) webService() *restful.WebService {
 func (r *ipResource) findIP(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	ip, err := r.ds.FindIPByID(id)
+	ip, err := r.store(request).FindIPByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, ip)
 	r.send(request, response, http.StatusOK, v1.NewIPResponse(ip))
 }
 
 func (r *ipResource) listIPs(request *restful.Request, response *restful.Response) {
-	ips, err := r.ds.ListIPs()
+	ips, err := r.store(request).ListIPs()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -186,11 +193,18 @@ func (r *ipResource) findIPs(request *restful.Request, response *restful.Respons
 	}
 
 	var ips metal.IPs
-	err = r.ds.SearchIPs(&requestPayload, &ips)
+	err = r.store(request).SearchIPs(&requestPayload, &ips)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	next, err := requestPayload.NextCursor(ips)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
+	setNextCursor(response, next)
 
 	result := []*v1.IPResponse{}
 	for i := range ips {
@@ -203,12 +217,17 @@ func (r *ipResource) findIPs(request *restful.Request, response *restful.Respons
 func (r *ipResource) freeIP(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	ip, err := r.ds.FindIPByID(id)
+	ip, err := r.store(request).FindIPByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, ip); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	err = validateIPDelete(ip)
 	if err != nil {
 		r.sendError(request, response, httperrors.BadRequest(err))
@@ -288,7 +307,7 @@ func (r *ipResource) allocateIP(request *restful.Request, response *restful.Resp
 		description = *requestPayload.Description
 	}
 
-	nw, err := r.ds.FindNetworkByID(requestPayload.NetworkID)
+	nw, err := r.store(request).FindNetworkByID(requestPayload.NetworkID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -345,7 +364,7 @@ func (r *ipResource) allocateIP(request *restful.Request, response *restful.Resp
 		Tags:             tags,
 	}
 
-	err = r.ds.CreateIP(ip)
+	err = r.store(request).CreateIP(ip)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -362,12 +381,17 @@ func (r *ipResource) updateIP(request *restful.Request, response *restful.Respon
 		return
 	}
 
-	oldIP, err := r.ds.FindIPByID(requestPayload.IPAddress)
+	oldIP, err := r.store(request).FindIPByID(requestPayload.IPAddress)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldIP); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newIP := *oldIP
 	if requestPayload.Name != nil {
 		newIP.Name = *requestPayload.Name
@@ -389,12 +413,13 @@ func (r *ipResource) updateIP(request *restful.Request, response *restful.Respon
 	}
 	newIP.Tags = processTags(newIP.Tags)
 
-	err = r.ds.UpdateIP(oldIP, &newIP)
+	err = r.store(request).UpdateIP(oldIP, &newIP)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newIP)
 	r.send(request, response, http.StatusOK, v1.NewIPResponse(&newIP))
 }
 
@@ -2,10 +2,12 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -53,6 +55,8 @@ type machineResource struct {
 	reasonMinLength uint
 	headscaleClient *headscale.HeadscaleClient
 	ipmiSuperUser   metal.MachineIPMISuperUser
+	// preemptionGracePeriod is the time a preempted machine is given to finish its work before it is freed.
+	preemptionGracePeriod time.Duration
 }
 
 // machineAllocationSpec is a specification for a machine allocation
@@ -76,6 +80,15 @@ type machineAllocationSpec struct {
 	Role               metal.Role
 	VPN                *metal.MachineVPN
 	PlacementTags      []string
+	Affinity           metal.MachineAffinity
+	LeaseDuration      time.Duration
+	Requirements       []metal.Constraint
+	Preemptible        bool
+	// Priority is the allocation priority of the project, it is resolved when the allocation is assigned.
+	Priority int
+	// Sizes are the sizes which satisfy the hardware requirements in the order they are tried, Size is set to the
+	// size of the allocated machine. Only set for allocations by hardware requirements.
+	Sizes metal.Sizes
 }
 
 // allocationNetwork is intermediate struct to create machine networks from regular networks during machine allocation
@@ -107,7 +120,7 @@ type Allocator func(Allocation) error
 // NewMachine returns a webservice for machine specific endpoints.
 func NewMachine(
 	log *zap.SugaredLogger,
-	ds *datastore.RethinkStore,
+	ds datastore.Store,
 	pub bus.Publisher,
 	ep *bus.Endpoints,
 	ipamer ipam.IPAMer,
@@ -117,20 +130,22 @@ func NewMachine(
 	reasonMinLength uint,
 	headscaleClient *headscale.HeadscaleClient,
 	ipmiSuperUser metal.MachineIPMISuperUser,
+	preemptionGracePeriod time.Duration,
 ) (*restful.WebService, error) {
 	r := machineResource{
 		webResource: webResource{
 			log: log,
 			ds:  ds,
 		},
-		Publisher:       pub,
-		ipamer:          ipamer,
-		mdc:             mdc,
-		s3Client:        s3Client,
-		userGetter:      userGetter,
-		reasonMinLength: reasonMinLength,
-		headscaleClient: headscaleClient,
-		ipmiSuperUser:   ipmiSuperUser,
+		Publisher:             pub,
+		ipamer:                ipamer,
+		mdc:                   mdc,
+		s3Client:              s3Client,
+		userGetter:            userGetter,
+		reasonMinLength:       reasonMinLength,
+		headscaleClient:       headscaleClient,
+		ipmiSuperUser:         ipmiSuperUser,
+		preemptionGracePeriod: preemptionGracePeriod,
 	}
 
 	var err error
@@ -189,28 +204,64 @@ func (r *machineResource) webService() *restful.WebService {
 		Metadata(auditing.Exclude, true).
 		Reads(v1.MachineFindRequest{}).
 		Writes([]v1.MachineResponse{}).
-		Returns(http.StatusOK, "OK", []v1.MachineResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.MachineResponse{}, nextCursorHeaders).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/watch").
+		To(viewer(r.watchMachines)).
+		Operation("watchMachines").
+		Doc("streams the changes of machines matching the given criteria as server-sent events").
+		Param(ws.QueryParameter("resource-version", "resumes the watch after the given resource version, can also be passed with the Last-Event-ID header").DataType("integer").Required(false)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Metadata(auditing.Exclude, true).
+		Produces(restful.MIME_JSON, "text/event-stream").
+		Reads(v1.MachineFindRequest{}).
+		Writes(v1.MachineWatchEvent{}).
+		Returns(http.StatusOK, "OK", v1.MachineWatchEvent{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/").
 		To(admin(r.updateMachine)).
 		Operation("updateMachine").
 		Doc("updates a machine. if the machine was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.MachineUpdateRequest{}).
 		Writes(v1.MachineResponse{}).
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/allocate").
 		To(editor(r.allocateMachine)).
 		Operation("allocateMachine").
-		Doc("allocate a machine").
+		Doc("allocate a machine, if a preemptible machine is freed for the allocation it is queued as a pending allocation").
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.MachineAllocateRequest{}).
 		Writes(v1.MachineResponse{}).
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusAccepted, "Accepted", v1.PendingAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/allocate/dry-run").
+		To(editor(r.allocateMachineDryRun)).
+		Operation("allocateMachineDryRun").
+		Doc("explains which machine would be allocated for the request and why the other machines of the partition were excluded, nothing is allocated").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineAllocateRequest{}).
+		Writes(v1.MachineAllocationDryRunResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineAllocationDryRunResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/allocate/bulk").
+		To(editor(r.allocateMachines)).
+		Operation("allocateMachines").
+		Doc("allocate a number of machines at once, if one of the machines cannot be allocated all machines of the request are released again").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineBulkAllocateRequest{}).
+		Writes([]v1.MachineResponse{}).
+		Returns(http.StatusOK, "OK", []v1.MachineResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/{id}/state").
@@ -229,9 +280,37 @@ func (r *machineResource) webService() *restful.WebService {
 		Operation("freeMachine").
 		Doc("free a machine").
 		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Param(ifMatchParam(ws)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.MachineResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/lease").
+		To(editor(r.extendMachineLease)).
+		Operation("extendMachineLease").
+		Doc("extends the lease of an allocated machine").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Param(ifMatchParam(ws)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineLeaseExtendRequest{}).
+		Writes(v1.MachineResponse{}).
+		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/transfer").
+		To(editor(r.transferMachine)).
+		Operation("transferMachine").
+		Doc("transfers an allocated machine to another project without reinstalling it. the ips of the machine are transferred to the target project and the machine either keeps its private network, which is transferred as well, or is attached to the private network of the target project. a changed private network is only applied to the operating system of the machine with the next reinstallation").
+		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.MachineTransferRequest{}).
 		Writes(v1.MachineResponse{}).
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.DELETE("/{id}").
@@ -239,9 +318,11 @@ func (r *machineResource) webService() *restful.WebService {
 		Operation("deleteMachine").
 		Doc("deletes a machine from the database").
 		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.MachineResponse{}).
 		Returns(http.StatusOK, "OK", v1.MachineResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.GET("/issues").
@@ -299,7 +380,7 @@ func (r *machineResource) webService() *restful.WebService {
 	ws.Route(ws.POST("/{id}/reinstall").
 		To(editor(r.reinstallMachine)).
 		Operation("reinstallMachine").
-		Doc("reinstall this machine").
+		Doc("reinstall this machine, with a patch upgrade the newest supported image of the same operating system and minor version is installed instead of the given image, the image which was chosen is contained in the allocation of the response").
 		Param(ws.PathParameter("id", "identifier of the machine").DataType("string")).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.MachineReinstallRequest{}).
@@ -424,13 +505,13 @@ func (r *machineResource) webService() *restful.WebService {
 }
 
 func (r *machineResource) listMachines(request *restful.Request, response *restful.Response) {
-	ms, err := r.ds.ListMachines()
+	ms, err := r.store(request).ListMachines()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponseList(ms, r.ds)
+	resp, err := makeMachineResponseList(ms, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -442,18 +523,19 @@ func (r *machineResource) listMachines(request *restful.Request, response *restf
 func (r *machineResource) findMachine(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	m, err := r.ds.FindMachineByID(id)
+	m, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	resp, err := makeMachineResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, m)
 	r.send(request, response, http.StatusOK, resp)
 }
 
@@ -465,12 +547,17 @@ func (r *machineResource) updateMachine(request *restful.Request, response *rest
 		return
 	}
 
-	oldMachine, err := r.ds.FindMachineByID(requestPayload.ID)
+	oldMachine, err := r.store(request).FindMachineByID(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldMachine); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	if oldMachine.Allocation == nil {
 		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("only allocated machines can be updated")))
 		return
@@ -490,18 +577,19 @@ func (r *machineResource) updateMachine(request *restful.Request, response *rest
 		newMachine.Allocation.SSHPubKeys = requestPayload.SSHPubKeys
 	}
 
-	err = r.ds.UpdateMachine(oldMachine, &newMachine)
+	err = r.store(request).UpdateMachine(oldMachine, &newMachine)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(&newMachine, r.ds)
+	resp, err := makeMachineResponse(&newMachine, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newMachine)
 	r.send(request, response, http.StatusOK, resp)
 }
 
@@ -581,13 +669,13 @@ func (r *machineResource) issues(request *restful.Request, response *restful.Res
 		lastErrorThreshold = requestPayload.LastErrorThreshold
 	}
 
-	err = r.ds.SearchMachines(&requestPayload.MachineSearchQuery, &ms)
+	err = r.store(request).SearchMachines(&requestPayload.MachineSearchQuery, &ms)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	ecs, err := r.ds.ListProvisioningEventContainers()
+	ecs, err := r.store(request).ListProvisioningEventContainers()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -645,7 +733,7 @@ func (r *machineResource) getMachineConsolePassword(request *restful.Request, re
 		return
 	}
 
-	m, err := r.ds.FindMachineByID(requestPayload.ID)
+	m, err := r.store(request).FindMachineByID(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -675,13 +763,20 @@ func (r *machineResource) findMachines(request *restful.Request, response *restf
 	}
 
 	ms := metal.Machines{}
-	err = r.ds.SearchMachines(&requestPayload, &ms)
+	err = r.store(request).SearchMachines(&requestPayload, &ms)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	next, err := requestPayload.NextCursor(ms)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
+	setNextCursor(response, next)
 
-	resp, err := makeMachineResponseList(ms, r.ds)
+	resp, err := makeMachineResponseList(ms, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -690,6 +785,67 @@ func (r *machineResource) findMachines(request *restful.Request, response *restf
 	r.send(request, response, http.StatusOK, resp)
 }
 
+func (r *machineResource) watchMachines(request *restful.Request, response *restful.Response) {
+	var requestPayload datastore.MachineSearchQuery
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	version := request.QueryParameter("resource-version")
+	if version == "" {
+		version = request.HeaderParameter("Last-Event-ID")
+	}
+
+	var resourceVersion int64
+	if version != "" {
+		resourceVersion, err = strconv.ParseInt(version, 10, 64)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("invalid resource version: %w", err)))
+			return
+		}
+	}
+
+	log := r.logger(request)
+
+	events, err := datastore.WatchMachineEvents(request.Request.Context(), log, r.store(request), &requestPayload, resourceVersion)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+
+	for e := range events {
+		m, err := makeMachineResponse(e.Machine, r.store(request))
+		if err != nil {
+			// referenced entities may already be gone, especially for deleted machines
+			log.Infow("unable to find referenced entities of machine, sending machine without them", "machine", e.Machine.ID, "error", err)
+			m = v1.NewMachineResponse(e.Machine, nil, nil, nil, nil)
+		}
+
+		data, err := json.Marshal(v1.MachineWatchEvent{
+			Type:            string(e.Type),
+			ResourceVersion: e.ResourceVersion,
+			Machine:         m,
+		})
+		if err != nil {
+			log.Errorw("unable to encode machine watch event, closing watch", "machine", e.Machine.ID, "error", err)
+			return
+		}
+
+		_, err = fmt.Fprintf(response, "id: %d\nevent: %s\ndata: %s\n\n", e.ResourceVersion, e.Type, data)
+		if err != nil {
+			return
+		}
+		response.Flush()
+	}
+}
+
 func (r *machineResource) setMachineState(request *restful.Request, response *restful.Response) {
 	var requestPayload v1.MachineState
 	err := request.ReadEntity(&requestPayload)
@@ -710,6 +866,19 @@ func (r *machineResource) setMachineState(request *restful.Request, response *re
 		return
 	}
 
+	window := v1.NewMetalMaintenanceWindow(requestPayload.MaintenanceWindow)
+	if window != nil {
+		if machineState != metal.MaintenanceState {
+			r.sendError(request, response, httperrors.BadRequest(errors.New("a maintenance window can only be given for the maintenance state")))
+			return
+		}
+		err = window.Validate(time.Now())
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+
 	user, err := r.userGetter.User(request.Request)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
@@ -721,7 +890,7 @@ func (r *machineResource) setMachineState(request *restful.Request, response *re
 	}
 
 	id := request.PathParameter("id")
-	oldMachine, err := r.ds.FindMachineByID(id)
+	oldMachine, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -729,19 +898,43 @@ func (r *machineResource) setMachineState(request *restful.Request, response *re
 
 	newMachine := *oldMachine
 
-	newMachine.State = metal.MachineState{
-		Value:       machineState,
-		Description: requestPayload.Description,
-		Issuer:      userEMail,
+	if window != nil && !window.HasStarted(time.Now()) {
+		// the machine keeps its state until the maintenance is applied when the window starts
+		newMachine.State.ScheduledMaintenance = &metal.ScheduledMaintenance{
+			Description: requestPayload.Description,
+			Issuer:      userEMail,
+			Window:      *window,
+		}
+	} else {
+		newMachine.State = metal.MachineState{
+			Value:             machineState,
+			Description:       requestPayload.Description,
+			Issuer:            userEMail,
+			MaintenanceWindow: window,
+		}
 	}
 
-	err = r.ds.UpdateMachine(oldMachine, &newMachine)
+	err = r.store(request).UpdateMachine(oldMachine, &newMachine)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(&newMachine, r.ds)
+	if machineState == metal.MaintenanceState && newMachine.Allocation != nil {
+		// the owning project is asked to drain the machine before the maintenance starts
+		err = r.Publish(metal.TopicDrain.Name, &metal.MachineDrainEvent{
+			MachineID: newMachine.ID,
+			ProjectID: newMachine.Allocation.Project,
+			Reason:    requestPayload.Description,
+			Window:    window,
+		})
+		if err != nil {
+			// the state was changed already, so the request does not fail
+			r.logger(request).Errorw("unable to publish drain event", "machineID", newMachine.ID, "error", err)
+		}
+	}
+
+	resp, err := makeMachineResponse(&newMachine, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -753,13 +946,13 @@ func (r *machineResource) setMachineState(request *restful.Request, response *re
 func (r machineResource) findIPMIMachine(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	m, err := r.ds.FindMachineByID(id)
+	m, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineIPMIResponse(m, r.ds)
+	resp, err := makeMachineIPMIResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -777,13 +970,13 @@ func (r *machineResource) findIPMIMachines(request *restful.Request, response *r
 	}
 
 	ms := metal.Machines{}
-	err = r.ds.SearchMachines(&requestPayload, &ms)
+	err = r.store(request).SearchMachines(&requestPayload, &ms)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineIPMIResponseList(ms, r.ds)
+	resp, err := makeMachineIPMIResponseList(ms, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -808,14 +1001,14 @@ func (r *machineResource) ipmiReport(request *restful.Request, response *restful
 		return
 	}
 
-	p, err := r.ds.FindPartition(requestPayload.PartitionID)
+	p, err := r.store(request).FindPartition(requestPayload.PartitionID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
 	var ms metal.Machines
-	err = r.ds.SearchMachines(&datastore.MachineSearchQuery{
+	err = r.store(request).SearchMachines(&datastore.MachineSearchQuery{
 		PartitionID: &p.ID,
 	}, &ms)
 	if err != nil {
@@ -862,7 +1055,7 @@ func (r *machineResource) ipmiReport(request *restful.Request, response *restful
 		} else {
 			logger.Errorw("unable to decode ledstate", "id", uuid, "ledstate", report.IndicatorLEDState, "error", err)
 		}
-		err = r.ds.CreateMachine(m)
+		err = r.store(request).CreateMachine(m)
 		if err != nil {
 			logger.Errorw("could not create machine", "id", uuid, "ipmi-ip", report.BMCIp, "m", m, "err", err)
 			continue
@@ -936,7 +1129,7 @@ func (r *machineResource) ipmiReport(request *restful.Request, response *restful
 		}
 		newMachine.IPMI.LastUpdated = time.Now()
 
-		err = r.ds.UpdateMachine(&oldMachine, &newMachine)
+		err = r.store(request).UpdateMachine(&oldMachine, &newMachine)
 		if err != nil {
 			logger.Errorw("could not update machine", "id", uuid, "ip", report.BMCIp, "machine", newMachine, "err", err)
 			continue
@@ -976,19 +1169,30 @@ func (r *machineResource) allocateMachine(request *restful.Request, response *re
 		return
 	}
 
-	spec, err := createMachineAllocationSpec(r.ds, requestPayload, metal.RoleMachine, user)
+	spec, err := createMachineAllocationSpec(r.store(request), requestPayload, metal.RoleMachine, user)
 	if err != nil {
 		r.sendError(request, response, httperrors.BadRequest(err))
 		return
 	}
 
-	m, err := allocateMachine(r.logger(request), r.ds, r.ipamer, spec, r.mdc, r.actor, r.Publisher)
+	m, p, err := allocateOrPreemptMachine(r.logger(request), r.store(request), r.ipamer, requestPayload, spec, r.mdc, r.actor, r.Publisher, r.preemptionGracePeriod)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	if p != nil {
+		resp, err := makePendingAllocationResponse(r.store(request), p)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+
+		r.send(request, response, http.StatusAccepted, resp)
+		return
+	}
+
+	resp, err := makeMachineResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -997,7 +1201,198 @@ func (r *machineResource) allocateMachine(request *restful.Request, response *re
 	r.send(request, response, http.StatusOK, resp)
 }
 
-func createMachineAllocationSpec(ds *datastore.RethinkStore, requestPayload v1.MachineAllocateRequest, role metal.Role, user *security.User) (*machineAllocationSpec, error) {
+func (r *machineResource) allocateMachineDryRun(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineAllocateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	spec, err := createMachineAllocationSpec(r.store(request), requestPayload, metal.RoleMachine, user)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = validateAllocationSpec(spec)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	sizes := spec.Sizes
+	if len(sizes) == 0 {
+		err = isSizeAndImageCompatible(r.store(request), *spec.Size, *spec.Image)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+		sizes = metal.Sizes{*spec.Size}
+	}
+
+	var explanation *datastore.WaitingMachineExplanation
+	if spec.Machine != nil {
+		// a specific machine is not elected, it is only checked whether it can be allocated
+		explanation = &datastore.WaitingMachineExplanation{
+			Machines:   metal.Machines{*spec.Machine},
+			Exclusions: map[string]string{},
+		}
+		m, err := findMachineCandidate(r.store(request), spec)
+		if err != nil {
+			explanation.Exclusions[spec.Machine.ID] = err.Error()
+			explanation.Message = err.Error()
+		} else {
+			explanation.Machine = m
+			explanation.Finalists = metal.Machines{*m}
+		}
+	} else {
+		// the sizes are tried in their order like during the allocation, the first size with a machine is explained
+		for _, size := range sizes {
+			if len(spec.Sizes) > 0 && isSizeAndImageCompatible(r.store(request), size, *spec.Image) != nil {
+				continue
+			}
+			e, err := datastore.ExplainWaitingMachine(r.logger(request), r.store(request), spec.ProjectID, spec.PartitionID, size.ID, spec.PlacementTags, spec.Affinity, spec.Requirements)
+			if err != nil {
+				r.sendError(request, response, defaultError(err))
+				return
+			}
+			if explanation == nil || e.Machine != nil {
+				explanation = e
+			}
+			if e.Machine != nil {
+				break
+			}
+		}
+		if explanation == nil {
+			r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("the image %s is not compatible with any size which satisfies the hardware requirements", spec.Image.ID)))
+			return
+		}
+	}
+
+	ecs, err := r.store(request).ListProvisioningEventContainers()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	machinesWithIssues, err := issues.Find(&issues.Config{
+		Machines:        explanation.Machines,
+		EventContainers: ecs,
+		Only:            issues.NotAllocatableIssueTypes(),
+	})
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, makeMachineAllocationDryRunResponse(explanation, machinesWithIssues))
+}
+
+func makeMachineAllocationDryRunResponse(explanation *datastore.WaitingMachineExplanation, machinesWithIssues issues.MachineIssuesMap) *v1.MachineAllocationDryRunResponse {
+	resp := &v1.MachineAllocationDryRunResponse{
+		Finalists: []string{},
+		Machines:  []v1.MachineAllocationCandidate{},
+	}
+	if explanation.Machine != nil {
+		resp.MachineID = &explanation.Machine.ID
+	}
+	if explanation.Message != "" {
+		resp.Message = &explanation.Message
+	}
+	for _, m := range explanation.Finalists {
+		resp.Finalists = append(resp.Finalists, m.ID)
+	}
+
+	for _, m := range explanation.Machines {
+		candidate := v1.MachineAllocationCandidate{
+			ID:     m.ID,
+			RackID: m.RackID,
+			Issues: []string{},
+		}
+		if reason, ok := explanation.Exclusions[m.ID]; ok {
+			candidate.Reason = &reason
+		}
+		if mi, ok := machinesWithIssues[m.ID]; ok {
+			for _, issue := range mi.Issues {
+				candidate.Issues = append(candidate.Issues, string(issue.Type))
+			}
+		}
+		resp.Machines = append(resp.Machines, candidate)
+	}
+
+	return resp
+}
+
+func (r *machineResource) allocateMachines(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineBulkAllocateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	if requestPayload.Count == 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("at least one machine must be allocated")))
+		return
+	}
+	if requestPayload.Count > 1 && requestPayload.Template.UUID != nil {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("a specific machine cannot be allocated more than once")))
+		return
+	}
+	if requestPayload.Count > 1 && len(requestPayload.Template.IPs) > 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("additional ips cannot be attached to more than one machine")))
+		return
+	}
+
+	role := metal.RoleMachine
+	if requestPayload.Role != nil {
+		role = metal.Role(*requestPayload.Role)
+		if !metal.AllRoles[role] {
+			r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("unknown role: %s", role)))
+			return
+		}
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// validate the request before the first machine is allocated
+	_, err = createMachineAllocationSpec(r.store(request), requestPayload.Template, role, user)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	machines, err := allocateMachines(request.Request.Context(), r.logger(request), r.store(request), r.ipamer, requestPayload.Template, requestPayload.Count, role, user, r.mdc, r.actor, r.Publisher, r.headscaleClient)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.MachineResponse{}
+	for i := range machines {
+		resp, err := makeMachineResponse(&machines[i], r.store(request))
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		result = append(result, resp)
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func createMachineAllocationSpec(ds datastore.Store, requestPayload v1.MachineAllocateRequest, role metal.Role, user *security.User) (*machineAllocationSpec, error) {
 	var uuid string
 	if requestPayload.UUID != nil {
 		uuid = *requestPayload.UUID
@@ -1046,8 +1441,16 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, requestPayload v1.M
 		return nil, errors.New("when no machine id is given, a partition id must be specified")
 	}
 
-	if uuid == "" && sizeID == "" {
-		return nil, errors.New("when no machine id is given, a size id must be specified")
+	var requirements []metal.Constraint
+	for _, c := range requestPayload.Requirements {
+		if c.Max < c.Min {
+			return nil, fmt.Errorf("maximum of the %s requirement must not be lower than its minimum", c.Type)
+		}
+		requirements = append(requirements, metal.Constraint{Type: c.Type, Min: c.Min, Max: c.Max})
+	}
+
+	if uuid == "" && sizeID == "" && len(requirements) == 0 {
+		return nil, errors.New("when no machine id is given, a size id or hardware requirements must be specified")
 	}
 
 	var m *metal.Machine
@@ -1059,11 +1462,41 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, requestPayload v1.M
 		}
 		sizeID = m.SizeID
 		partitionID = m.PartitionID
+
+		if _, ok := metal.MatchesConstraints(requirements, m.Hardware); !ok {
+			return nil, fmt.Errorf("machine %s does not satisfy the hardware requirements", uuid)
+		}
+	}
+
+	var (
+		size  *metal.Size
+		sizes metal.Sizes
+	)
+	if sizeID == "" {
+		sizes, err = sizesFromRequirements(ds, partitionID, requirements)
+		if err != nil {
+			return nil, err
+		}
+		size = &sizes[0]
+	} else {
+		size, err = ds.FindSize(sizeID)
+		if err != nil {
+			return nil, fmt.Errorf("size:%s not found err:%w", sizeID, err)
+		}
 	}
 
-	size, err := ds.FindSize(sizeID)
+	affinity := v1.NewMetalMachineAffinity(requestPayload.Affinity)
+	err = affinity.Validate()
 	if err != nil {
-		return nil, fmt.Errorf("size:%s not found err:%w", sizeID, err)
+		return nil, err
+	}
+
+	var leaseDuration time.Duration
+	if requestPayload.LeaseDuration != nil {
+		leaseDuration = *requestPayload.LeaseDuration
+		if leaseDuration <= 0 {
+			return nil, errors.New("lease duration must be positive")
+		}
 	}
 
 	return &machineAllocationSpec{
@@ -1076,6 +1509,7 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, requestPayload v1.M
 		PartitionID:        partitionID,
 		Machine:            m,
 		Size:               size,
+		Sizes:              sizes,
 		Image:              image,
 		SSHPubKeys:         requestPayload.SSHPubKeys,
 		UserData:           userdata,
@@ -1085,72 +1519,372 @@ func createMachineAllocationSpec(ds *datastore.RethinkStore, requestPayload v1.M
 		Role:               role,
 		FilesystemLayoutID: requestPayload.FilesystemLayoutID,
 		PlacementTags:      requestPayload.PlacementTags,
+		Affinity:           affinity,
+		LeaseDuration:      leaseDuration,
+		Requirements:       requirements,
+		Preemptible:        requestPayload.Preemptible,
 	}, nil
 }
 
-func allocateMachine(logger *zap.SugaredLogger, ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher) (*metal.Machine, error) {
-	err := validateAllocationSpec(allocationSpec)
+// sizesFromRequirements returns the sizes of the machines in the partition which satisfy the hardware requirements,
+// ordered by the hardware of their smallest satisfying machine. Machines which are currently allocated or powered off
+// are considered as well, such that allocations by requirements can preempt machines, wake up spare machines and be
+// queued like allocations of a size.
+func sizesFromRequirements(ds datastore.Store, partitionID string, requirements []metal.Constraint) (metal.Sizes, error) {
+	var machines metal.Machines
+	err := ds.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &partitionID}, &machines)
 	if err != nil {
 		return nil, err
 	}
 
-	err = isSizeAndImageCompatible(ds, *allocationSpec.Size, *allocationSpec.Image)
-	if err != nil {
-		return nil, err
+	smallest := map[string]*metal.Machine{}
+	var sizeIDs []string
+	for i := range machines {
+		m := &machines[i]
+		if m.SizeID == "" || m.SizeID == metal.UnknownSize.ID {
+			continue
+		}
+		if _, ok := metal.MatchesConstraints(requirements, m.Hardware); !ok {
+			continue
+		}
+		s, ok := smallest[m.SizeID]
+		if !ok {
+			sizeIDs = append(sizeIDs, m.SizeID)
+		}
+		if !ok || isSmallerHardware(m.Hardware, s.Hardware) {
+			smallest[m.SizeID] = m
+		}
 	}
 
-	projectID := allocationSpec.ProjectID
-	p, err := mdc.Project().Get(context.Background(), &mdmv1.ProjectGetRequest{Id: projectID})
-	if err != nil {
-		return nil, err
+	if len(sizeIDs) == 0 {
+		var reqs []string
+		for _, c := range requirements {
+			reqs = append(reqs, fmt.Sprintf("%s %d-%d", c.Type, c.Min, c.Max))
+		}
+		return nil, fmt.Errorf("%w which satisfies the hardware requirements (%s) in partition %s", datastore.ErrNoMachineAvailable, strings.Join(reqs, ", "), partitionID)
 	}
 
-	// Check if more machine would be allocated than project quota permits
-	if p.GetProject() != nil && p.GetProject().GetQuotas() != nil && p.GetProject().GetQuotas().GetMachine() != nil {
-		mq := p.GetProject().GetQuotas().GetMachine()
-		maxMachines := mq.GetQuota().GetValue()
-		var actualMachines metal.Machines
-		err := ds.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &projectID, AllocationRole: &metal.RoleFirewall}, &actualMachines)
+	sort.SliceStable(sizeIDs, func(i, j int) bool {
+		return isSmallerHardware(smallest[sizeIDs[i]].Hardware, smallest[sizeIDs[j]].Hardware)
+	})
+
+	var sizes metal.Sizes
+	for _, id := range sizeIDs {
+		size, err := ds.FindSize(id)
 		if err != nil {
-			return nil, err
-		}
-		if len(actualMachines) >= int(maxMachines) {
-			return nil, fmt.Errorf("project quota for machines reached max:%d", maxMachines)
+			return nil, fmt.Errorf("size:%s not found err:%w", id, err)
 		}
+		sizes = append(sizes, *size)
 	}
 
-	var fsl *metal.FilesystemLayout
-	if allocationSpec.FilesystemLayoutID == nil {
-		fsls, err := ds.ListFilesystemLayouts()
+	return sizes, nil
+}
+
+// isSmallerHardware compares the cores first, then the memory and the disk capacity.
+func isSmallerHardware(a, b metal.MachineHardware) bool {
+	if a.CPUCores != b.CPUCores {
+		return a.CPUCores < b.CPUCores
+	}
+	if a.Memory != b.Memory {
+		return a.Memory < b.Memory
+	}
+	return a.DiskCapacity() < b.DiskCapacity()
+}
+
+func allocateMachine(logger *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher) (*metal.Machine, error) {
+	machine, err := assignAllocation(logger, ds, ipamer, allocationSpec, mdc, actor)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNoMachineAvailable) && allocationSpec.Machine == nil {
+			// a machine powered off by the power policy of the partition is available for one of the next allocations
+			wakeUpSpareMachinesForAllocation(ds, publisher, logger, allocationSpec, 1)
+		}
+		return nil, err
+	}
+
+	publishAllocationEvent(logger, publisher, machine)
+
+	return machine, nil
+}
+
+// preemptedMachineReclaimTimeout is the time a preempted machine is given to start waiting for the allocation which
+// preempted it after it was freed, afterwards the queued allocation expires.
+const preemptedMachineReclaimTimeout = 30 * time.Minute
+
+// allocateOrPreemptMachine allocates a machine like allocateMachine. If no machine is available for an allocation
+// which is not preemptible itself, a preemptible machine of the partition and size is claimed for the allocation and
+// the allocation is queued as a pending allocation which is bound to the claimed machine. The claimed machine is freed
+// by the pending allocation queue when the grace period passed, the queue then hands the machine to the bound pending
+// allocation only.
+func allocateOrPreemptMachine(logger *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, requestPayload v1.MachineAllocateRequest, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher, gracePeriod time.Duration) (*metal.Machine, *metal.PendingAllocation, error) {
+	machine, err := allocateMachine(logger, ds, ipamer, allocationSpec, mdc, actor, publisher)
+	if err == nil || !errors.Is(err, datastore.ErrNoMachineAvailable) || allocationSpec.Machine != nil || allocationSpec.Preemptible {
+		return machine, nil, err
+	}
+
+	victim, preemptErr := preemptMachine(logger, ds, allocationSpec, publisher, gracePeriod)
+	if preemptErr != nil {
+		if errors.Is(preemptErr, datastore.ErrNoMachineAvailable) {
+			return nil, nil, err
+		}
+		return nil, nil, preemptErr
+	}
+
+	// the allocation is fulfilled first among the pending allocations of a lower priority
+	p := v1.NewPendingAllocation(v1.PendingAllocationCreateRequest{
+		Template: requestPayload,
+		Priority: allocationSpec.Priority,
+		Deadline: victim.Allocation.PreemptionDeadline.Add(preemptedMachineReclaimTimeout),
+	}, allocationSpec.Creator)
+	p.PreemptedMachineID = victim.ID
+
+	err = ds.CreatePendingAllocation(p)
+	if err != nil {
+		resetErr := resetPreemption(ds, victim)
+		if resetErr != nil {
+			logger.Errorw("unable to reset preemption of machine", "machineID", victim.ID, "error", resetErr)
+		}
+		return nil, nil, err
+	}
+
+	return nil, p, nil
+}
+
+// preemptMachine claims the preemptible machine which can be handed to the allocation and publishes a preemption
+// notice. The allocations with the lowest priority are preempted first and among them the ones of the smallest size
+// and the most recent ones, which lose the least amount of work. The machine is freed when the grace period passed.
+func preemptMachine(logger *zap.SugaredLogger, ds datastore.Store, allocationSpec *machineAllocationSpec, publisher bus.Publisher, gracePeriod time.Duration) (*metal.Machine, error) {
+	sizes := allocationSpec.Sizes
+	if len(sizes) == 0 {
+		sizes = metal.Sizes{*allocationSpec.Size}
+	}
+	sizeOrder := map[string]int{}
+	for i, size := range sizes {
+		sizeOrder[size.ID] = i
+	}
+
+	var machines metal.Machines
+	err := ds.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &allocationSpec.PartitionID}, &machines)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates metal.Machines
+	for _, m := range machines {
+		if _, ok := sizeOrder[m.SizeID]; !ok {
+			continue
+		}
+		// preemptible allocations rank below the other allocations of the same priority
+		if m.Allocation == nil || !m.Allocation.Preemptible || m.Allocation.Preempted || m.Allocation.Priority > allocationSpec.Priority {
+			continue
+		}
+		// locked machines cannot be freed and machines in maintenance cannot be allocated afterwards
+		if m.State.Value != metal.AvailableState {
+			continue
+		}
+		if len(allocationSpec.Requirements) > 0 {
+			if _, ok := metal.MatchesConstraints(allocationSpec.Requirements, m.Hardware); !ok {
+				continue
+			}
+		}
+		candidates = append(candidates, m)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Allocation.Priority != candidates[j].Allocation.Priority {
+			return candidates[i].Allocation.Priority < candidates[j].Allocation.Priority
+		}
+		if candidates[i].SizeID != candidates[j].SizeID {
+			return sizeOrder[candidates[i].SizeID] < sizeOrder[candidates[j].SizeID]
+		}
+		return candidates[i].Allocation.Created.After(candidates[j].Allocation.Created)
+	})
+
+	var victim *metal.Machine
+	for i := range candidates {
+		claimed := candidates[i]
+		allocation := *claimed.Allocation
+		allocation.Preempted = true
+		allocation.PreemptionDeadline = time.Now().Add(gracePeriod)
+		claimed.Allocation = &allocation
+
+		err = ds.UpdateMachine(&candidates[i], &claimed)
 		if err != nil {
+			if metal.IsConflict(err) {
+				// the machine was changed concurrently, it might have been claimed by another allocation
+				continue
+			}
 			return nil, err
 		}
+		victim = &claimed
+		break
+	}
+	if victim == nil {
+		return nil, fmt.Errorf("%w which can be preempted", datastore.ErrNoMachineAvailable)
+	}
 
-		fsl, err = fsls.From(allocationSpec.Size.ID, allocationSpec.Image.ID)
+	logger.Infow("preempting machine", "machineID", victim.ID, "project", victim.Allocation.Project, "gracePeriod", gracePeriod)
+
+	err = publisher.Publish(metal.TopicMachine.GetFQN(victim.PartitionID), &metal.MachineEvent{Type: metal.PREEMPT, OldMachineID: victim.ID})
+	if err != nil {
+		resetErr := resetPreemption(ds, victim)
+		if resetErr != nil {
+			logger.Errorw("unable to reset preemption of machine", "machineID", victim.ID, "error", resetErr)
+		}
+		return nil, fmt.Errorf("unable to publish preemption notice: %w", err)
+	}
+
+	return victim, nil
+}
+
+// resetPreemption releases the claim of a preempted machine, such that it can be preempted again.
+func resetPreemption(ds datastore.Store, m *metal.Machine) error {
+	allocation := *m.Allocation
+	allocation.Preempted = false
+	allocation.PreemptionDeadline = time.Time{}
+	reset := *m
+	reset.Allocation = &allocation
+	return ds.UpdateMachine(m, &reset)
+}
+
+// allocateMachines allocates count machines with the same allocation request. Either all machines are allocated
+// or none, the machines which were already allocated are released again if one allocation fails. The machines are
+// only informed about their allocation when all machines were allocated.
+func allocateMachines(ctx context.Context, logger *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, requestPayload v1.MachineAllocateRequest, count uint, role metal.Role, user *security.User, mdc mdm.Client, actor *asyncActor, publisher bus.Publisher, headscaleClient *headscale.HeadscaleClient) (metal.Machines, error) {
+	var machines metal.Machines
+	rollbackOnError := func(err error) error {
+		for i := range machines {
+			releaseAllocation(logger, ds, actor, &machines[i])
+		}
+		return fmt.Errorf("unable to allocate machine %d of %d, all machines of the request were released again: %w", len(machines)+1, count, err)
+	}
+
+	for i := uint(0); i < count; i++ {
+		// the allocation spec is completed during the allocation, so every machine requires a spec of its own
+		spec, err := createMachineAllocationSpec(ds, requestPayload, role, user)
 		if err != nil {
-			return nil, err
+			return nil, rollbackOnError(err)
 		}
-	} else {
-		fsl, err = ds.FindFilesystemLayout(*allocationSpec.FilesystemLayoutID)
+		if count > 1 {
+			// the machines must not share their hostname
+			spec.Hostname = fmt.Sprintf("%s-%d", spec.Hostname, i)
+		}
+		if role == metal.RoleFirewall {
+			err = setVPNConfigInSpec(ctx, headscaleClient, spec)
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+		}
+
+		m, err := assignAllocation(logger, ds, ipamer, spec, mdc, actor)
 		if err != nil {
-			return nil, err
+			if errors.Is(err, datastore.ErrNoMachineAvailable) {
+				// the machines powered off by the power policy of the partition are available for a retry of the request
+				wakeUpSpareMachinesForAllocation(ds, publisher, logger, spec, int(count-i))
+			}
+			return nil, rollbackOnError(err)
 		}
+
+		machines = append(machines, *m)
 	}
 
-	var machineCandidate *metal.Machine
-	err = retry.Do(
-		func() error {
-			var err2 error
-			machineCandidate, err2 = findMachineCandidate(ds, allocationSpec)
-			return err2
-		},
-		retry.Attempts(10),
-		retry.RetryIf(func(err error) bool {
-			return metal.IsConflict(err)
-		}),
-		retry.DelayType(retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)),
-		retry.LastErrorOnly(true),
-	)
+	for i := range machines {
+		publishAllocationEvent(logger, publisher, &machines[i])
+	}
+
+	return machines, nil
+}
+
+// releaseAllocation releases the networks, ips and the asn of a machine whose allocation was not published yet
+// and makes the machine available for allocations again.
+func releaseAllocation(logger *zap.SugaredLogger, ds datastore.Store, actor *asyncActor, machine *metal.Machine) {
+	err := actor.machineNetworkReleaser(machine)
+	if err != nil {
+		logger.Errorw("cannot call async machine cleanup", "machineID", machine.ID, "error", err)
+	}
+
+	m, err := ds.FindMachineByID(machine.ID)
+	if err != nil {
+		logger.Errorw("cannot find machine to reset allocation", "machineID", machine.ID, "error", err)
+		return
+	}
+
+	old := *m
+	m.Allocation = nil
+	m.Tags = nil
+	m.PreAllocated = false
+
+	err = ds.UpdateMachine(&old, m)
+	if err != nil {
+		logger.Errorw("cannot update machine to reset allocation", "machineID", machine.ID, "error", err)
+	}
+}
+
+// checkMachineQuota returns an error if the project does not exist or if more machines of the given role would be
+// allocated than the project quota permits.
+func checkMachineQuota(ds datastore.Store, mdc mdm.Client, projectID string, role metal.Role) error {
+	p, err := mdc.Project().Get(context.Background(), &mdmv1.ProjectGetRequest{Id: projectID})
+	if err != nil {
+		return err
+	}
+
+	if p.GetProject() != nil && p.GetProject().GetQuotas() != nil && p.GetProject().GetQuotas().GetMachine() != nil {
+		mq := p.GetProject().GetQuotas().GetMachine()
+		maxMachines := mq.GetQuota().GetValue()
+		var actualMachines metal.Machines
+		err := ds.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &projectID, AllocationRole: &role}, &actualMachines)
+		if err != nil {
+			return err
+		}
+		if len(actualMachines) >= int(maxMachines) {
+			return fmt.Errorf("project quota for machines reached max:%d", maxMachines)
+		}
+	}
+
+	return nil
+}
+
+// allocationPriorityAnnotation is the project annotation which holds the priority of the allocations of the project.
+// Preemptible machines are only freed for the allocations of projects with the same or a higher priority.
+const allocationPriorityAnnotation = "metal-stack.io/allocation-priority"
+
+// projectAllocationPriority returns the allocation priority of the project, projects without a priority have the
+// priority 0.
+func projectAllocationPriority(mdc mdm.Client, projectID string) (int, error) {
+	p, err := mdc.Project().Get(context.Background(), &mdmv1.ProjectGetRequest{Id: projectID})
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := p.GetProject().GetMeta().GetAnnotations()[allocationPriorityAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("project %s has an invalid %s annotation: %w", projectID, allocationPriorityAnnotation, err)
+	}
+
+	return priority, nil
+}
+
+// assignAllocation allocates a machine without publishing the allocation to the machine.
+func assignAllocation(logger *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, mdc mdm.Client, actor *asyncActor) (*metal.Machine, error) {
+	err := validateAllocationSpec(allocationSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID := allocationSpec.ProjectID
+	err = checkMachineQuota(ds, mdc, projectID, allocationSpec.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	allocationSpec.Priority, err = projectAllocationPriority(mdc, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	machineCandidate, fsl, err := findSizedMachineCandidate(ds, allocationSpec)
 	if err != nil {
 		return nil, err
 	}
@@ -1170,6 +1904,13 @@ func allocateMachine(logger *zap.SugaredLogger, ds *datastore.RethinkStore, ipam
 		MachineNetworks: []*metal.MachineNetwork{},
 		Role:            allocationSpec.Role,
 		VPN:             allocationSpec.VPN,
+		Preemptible:     allocationSpec.Preemptible,
+		Priority:        allocationSpec.Priority,
+	}
+	if allocationSpec.LeaseDuration > 0 {
+		alloc.Lease = &metal.MachineLease{
+			Expires: alloc.Created.Add(allocationSpec.LeaseDuration),
+		}
 	}
 	rollbackOnError := func(err error) error {
 		if err != nil {
@@ -1230,15 +1971,17 @@ func allocateMachine(logger *zap.SugaredLogger, ds *datastore.RethinkStore, ipam
 		return nil, rollbackOnError(fmt.Errorf("error when allocating machine %q, %w", machine.ID, err))
 	}
 
+	return machine, nil
+}
+
+func publishAllocationEvent(logger *zap.SugaredLogger, publisher bus.Publisher, machine *metal.Machine) {
 	// TODO: can be removed after metal-core refactoring
-	err = publisher.Publish(metal.TopicAllocation.Name, &metal.AllocationEvent{MachineID: machine.ID})
+	err := publisher.Publish(metal.TopicAllocation.Name, &metal.AllocationEvent{MachineID: machine.ID})
 	if err != nil {
 		logger.Errorw("failed to publish machine allocation event, fallback should trigger on metal-hammer", "topic", metal.TopicAllocation.Name, "machineID", machine.ID, "error", err)
 	} else {
 		logger.Debugw("published machine allocation event", "topic", metal.TopicAllocation.Name, "machineID", machine.ID)
 	}
-
-	return machine, nil
 }
 
 func validateAllocationSpec(allocationSpec *machineAllocationSpec) error {
@@ -1281,7 +2024,74 @@ func validateAllocationSpec(allocationSpec *machineAllocationSpec) error {
 	return nil
 }
 
-func findMachineCandidate(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
+// findSizedMachineCandidate finds the machine candidate and the filesystem layout of the allocation. For allocations
+// by hardware requirements, the sizes which satisfy the requirements are tried in their order until a machine is
+// found and the size of the allocation spec is set to the size of this machine.
+func findSizedMachineCandidate(ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, *metal.FilesystemLayout, error) {
+	if len(allocationSpec.Sizes) == 0 {
+		return findMachineCandidateOfSize(ds, allocationSpec)
+	}
+
+	var errs []error
+	for i := range allocationSpec.Sizes {
+		allocationSpec.Size = &allocationSpec.Sizes[i]
+		machine, fsl, err := findMachineCandidateOfSize(ds, allocationSpec)
+		if err == nil {
+			return machine, fsl, nil
+		}
+		errs = append(errs, fmt.Errorf("size %s: %w", allocationSpec.Size.ID, err))
+	}
+	allocationSpec.Size = &allocationSpec.Sizes[0]
+
+	return nil, nil, errors.Join(errs...)
+}
+
+func findMachineCandidateOfSize(ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, *metal.FilesystemLayout, error) {
+	err := isSizeAndImageCompatible(ds, *allocationSpec.Size, *allocationSpec.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fsl *metal.FilesystemLayout
+	if allocationSpec.FilesystemLayoutID == nil {
+		fsls, err := ds.ListFilesystemLayouts()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		fsl, err = fsls.From(allocationSpec.Size.ID, allocationSpec.Image.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+	} else {
+		fsl, err = ds.FindFilesystemLayout(*allocationSpec.FilesystemLayoutID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var machineCandidate *metal.Machine
+	err = retry.Do(
+		func() error {
+			var err2 error
+			machineCandidate, err2 = findMachineCandidate(ds, allocationSpec)
+			return err2
+		},
+		retry.Attempts(10),
+		retry.RetryIf(func(err error) bool {
+			return metal.IsConflict(err)
+		}),
+		retry.DelayType(retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)),
+		retry.LastErrorOnly(true),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return machineCandidate, fsl, nil
+}
+
+func findMachineCandidate(ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
 	var err error
 	var machine *metal.Machine
 	if allocationSpec.Machine == nil {
@@ -1296,6 +2106,9 @@ func findMachineCandidate(ds *datastore.RethinkStore, allocationSpec *machineAll
 		if machine.Allocation != nil {
 			return nil, errors.New("machine is already allocated")
 		}
+		if machine.State.Value == metal.MaintenanceState {
+			return nil, fmt.Errorf("machine %q is in maintenance", machine.ID)
+		}
 		if allocationSpec.PartitionID != "" && machine.PartitionID != allocationSpec.PartitionID {
 			return nil, fmt.Errorf("machine %q is not in the requested partition: %s", machine.ID, allocationSpec.PartitionID)
 		}
@@ -1307,7 +2120,7 @@ func findMachineCandidate(ds *datastore.RethinkStore, allocationSpec *machineAll
 	return machine, err
 }
 
-func findWaitingMachine(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
+func findWaitingMachine(ds datastore.Store, allocationSpec *machineAllocationSpec) (*metal.Machine, error) {
 	size, err := ds.FindSize(allocationSpec.Size.ID)
 	if err != nil {
 		return nil, fmt.Errorf("size cannot be found: %w", err)
@@ -1317,7 +2130,7 @@ func findWaitingMachine(ds *datastore.RethinkStore, allocationSpec *machineAlloc
 		return nil, fmt.Errorf("partition cannot be found: %w", err)
 	}
 
-	machine, err := ds.FindWaitingMachine(allocationSpec.ProjectID, partition.ID, size.ID, allocationSpec.PlacementTags)
+	machine, err := ds.FindWaitingMachine(allocationSpec.ProjectID, partition.ID, size.ID, allocationSpec.PlacementTags, allocationSpec.Affinity, allocationSpec.Requirements)
 	if err != nil {
 		return nil, err
 	}
@@ -1327,7 +2140,7 @@ func findWaitingMachine(ds *datastore.RethinkStore, allocationSpec *machineAlloc
 // makeNetworks creates network entities and ip addresses as specified in the allocation network map.
 // created networks are added to the machine allocation directly after their creation. This way, the rollback mechanism
 // is enabled to clean up networks that were already created.
-func makeNetworks(ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, networks allocationNetworkMap, alloc *metal.MachineAllocation) error {
+func makeNetworks(ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, networks allocationNetworkMap, alloc *metal.MachineAllocation) error {
 	for _, n := range networks {
 		machineNetwork, err := makeMachineNetwork(ds, ipamer, allocationSpec, n)
 		if err != nil {
@@ -1337,7 +2150,7 @@ func makeNetworks(ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec
 	}
 
 	// the metal-networker expects to have the same unique ASN on all networks of this machine
-	asn, err := acquireASN(ds)
+	asn, err := acquireASN(ds, allocationSpec.PartitionID)
 	if err != nil {
 		return err
 	}
@@ -1348,7 +2161,7 @@ func makeNetworks(ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec
 	return nil
 }
 
-func gatherNetworks(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec) (allocationNetworkMap, error) {
+func gatherNetworks(ds datastore.Store, allocationSpec *machineAllocationSpec) (allocationNetworkMap, error) {
 	partition, err := ds.FindPartition(allocationSpec.PartitionID)
 	if err != nil {
 		return nil, fmt.Errorf("partition cannot be found: %w", err)
@@ -1383,7 +2196,7 @@ func gatherNetworks(ds *datastore.RethinkStore, allocationSpec *machineAllocatio
 	return result, nil
 }
 
-func gatherNetworksFromSpec(ds *datastore.RethinkStore, allocationSpec *machineAllocationSpec, partition *metal.Partition, privateSuperNetworks metal.Networks) (allocationNetworkMap, error) {
+func gatherNetworksFromSpec(ds datastore.Store, allocationSpec *machineAllocationSpec, partition *metal.Partition, privateSuperNetworks metal.Networks) (allocationNetworkMap, error) {
 	var partitionPrivateSuperNetwork *metal.Network
 	for i := range privateSuperNetworks {
 		psn := privateSuperNetworks[i]
@@ -1521,7 +2334,7 @@ func gatherNetworksFromSpec(ds *datastore.RethinkStore, allocationSpec *machineA
 	return specNetworks, nil
 }
 
-func gatherUnderlayNetwork(ds *datastore.RethinkStore, partition *metal.Partition) (*allocationNetwork, error) {
+func gatherUnderlayNetwork(ds datastore.Store, partition *metal.Partition) (*allocationNetwork, error) {
 	boolTrue := true
 	var underlays metal.Networks
 	err := ds.SearchNetworks(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, Underlay: &boolTrue}, &underlays)
@@ -1543,7 +2356,7 @@ func gatherUnderlayNetwork(ds *datastore.RethinkStore, partition *metal.Partitio
 	}, nil
 }
 
-func makeMachineNetwork(ds *datastore.RethinkStore, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, n *allocationNetwork) (*metal.MachineNetwork, error) {
+func makeMachineNetwork(ds datastore.Store, ipamer ipam.IPAMer, allocationSpec *machineAllocationSpec, n *allocationNetwork) (*metal.MachineNetwork, error) {
 	if n.auto {
 		ipAddress, ipParentCidr, err := allocateIP(n.network, "", ipamer)
 		if err != nil {
@@ -1665,78 +2478,449 @@ func uniqueTags(tags []string) []string {
 	return uniqueTags
 }
 
-func (r machineResource) freeMachine(request *restful.Request, response *restful.Response) {
-	id := request.PathParameter("id")
-	m, err := r.ds.FindMachineByID(id)
+func (r *machineResource) extendMachineLease(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineLeaseExtendRequest
+	err := request.ReadEntity(&requestPayload)
 	if err != nil {
-		r.sendError(request, response, defaultError(err))
+		r.sendError(request, response, httperrors.BadRequest(err))
 		return
 	}
 
-	logger := r.logger(request)
-
-	err = publishMachineCmd(logger, m, r.Publisher, metal.ChassisIdentifyLEDOffCmd)
-	if err != nil {
-		logger.Error("unable to publish machine command", zap.String("command", string(metal.ChassisIdentifyLEDOffCmd)), zap.String("machineID", m.ID), zap.Error(err))
+	if requestPayload.Duration <= 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("lease duration must be positive")))
+		return
 	}
 
-	err = r.actor.freeMachine(request.Request.Context(), r.Publisher, m, r.headscaleClient, logger)
+	oldMachine, err := r.store(request).FindMachineByID(request.PathParameter("id"))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
-	if err != nil {
-		r.sendError(request, response, defaultError(err))
+	if httperr := checkIfMatch(request, oldMachine); httperr != nil {
+		r.sendError(request, response, httperr)
 		return
 	}
 
-	r.send(request, response, http.StatusOK, resp)
-
-	ev := metal.ProvisioningEvent{
-		Time:    time.Now(),
-		Event:   metal.ProvisioningEventMachineReclaim,
-		Message: "free machine called",
+	if oldMachine.Allocation == nil || oldMachine.Allocation.Lease == nil {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("machine %s is not allocated with a lease", oldMachine.ID)))
+		return
 	}
-	_, err = r.ds.ProvisioningEventForMachine(logger, &ev, id)
-	if err != nil {
-		r.log.Errorw("error sending provisioning event after machine free", "error", err)
+
+	newMachine := *oldMachine
+	allocation := *oldMachine.Allocation
+	newMachine.Allocation = &allocation
+	// the expiry is warned about again
+	newMachine.Allocation.Lease = &metal.MachineLease{
+		Expires: oldMachine.Allocation.Lease.Expires.Add(requestPayload.Duration),
 	}
-}
 
-func (r *machineResource) deleteMachine(request *restful.Request, response *restful.Response) {
-	id := request.PathParameter("id")
-	m, err := r.ds.FindMachineByID(id)
+	err = r.store(request).UpdateMachine(oldMachine, &newMachine)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	if m.Allocation != nil {
-		r.sendError(request, response, defaultError(errors.New("cannot delete machine that is allocated")))
+	resp, err := makeMachineResponse(&newMachine, r.store(request))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	ec, err := r.ds.FindProvisioningEventContainer(id)
+	setEntityTag(response, &newMachine)
+	r.send(request, response, http.StatusOK, resp)
+}
 
-	// when there's no event container, we delete the machine anyway
-	if err != nil && !metal.IsNotFound(err) {
-		r.sendError(request, response, defaultError(err))
-		return
-	}
-	if err == nil && ec.Liveliness != metal.MachineLivelinessDead {
-		r.sendError(request, response, defaultError(errors.New("can only delete dead machines")))
+func (r *machineResource) transferMachine(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.MachineTransferRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
 		return
 	}
 
-	switches, err := r.ds.SearchSwitchesConnectedToMachine(m)
+	oldMachine, err := r.store(request).FindMachineByID(request.PathParameter("id"))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	for i := range switches {
+	if httperr := checkIfMatch(request, oldMachine); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	switch {
+	case oldMachine.Allocation == nil:
+		err = fmt.Errorf("machine %s is not allocated", oldMachine.ID)
+	case oldMachine.Allocation.Role != metal.RoleMachine:
+		err = errors.New("only machines can be transferred, firewalls have to be recreated in the target project")
+	case oldMachine.Allocation.VPN != nil:
+		err = errors.New("machines which are connected to a vpn cannot be transferred")
+	case oldMachine.State.Value == metal.LockedState:
+		err = errors.New("machine is locked")
+	case requestPayload.ProjectID == oldMachine.Allocation.Project:
+		err = fmt.Errorf("machine is already allocated to project %s", requestPayload.ProjectID)
+	}
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = checkMachineQuota(r.store(request), r.mdc, requestPayload.ProjectID, metal.RoleMachine)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	var privateNetwork *metal.Network
+	if requestPayload.PrivateNetworkID != nil {
+		privateNetwork, err = r.store(request).FindNetworkByID(*requestPayload.PrivateNetworkID)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		err = validateTransferPrivateNetwork(r.store(request), privateNetwork, oldMachine.PartitionID, requestPayload.ProjectID)
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+
+	newMachine, err := transferAllocation(r.logger(request), r.store(request), r.ipamer, r.actor, oldMachine, requestPayload.ProjectID, privateNetwork)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if newMachine.Allocation.Succeeded {
+		err = setPrivateVrfAtSwitches(r.store(request), newMachine)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+	}
+
+	r.logger(request).Infow("transferred machine", "machineID", newMachine.ID, "from", oldMachine.Allocation.Project, "to", newMachine.Allocation.Project)
+
+	resp, err := makeMachineResponse(newMachine, r.store(request))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	setEntityTag(response, newMachine)
+	r.send(request, response, http.StatusOK, resp)
+}
+
+// transferAllocation moves the allocation of the machine to the target project. The project scoped ips of the
+// machine are transferred along. Without a private network the private network of the machine is transferred
+// as well, otherwise the machine is attached to the given private network and its former private ips are released.
+// If the transfer fails, the acquired ips are released and the network and the ips are handed back to the source
+// project.
+func transferAllocation(logger *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, actor *asyncActor, m *metal.Machine, projectID string, privateNetwork *metal.Network) (*metal.Machine, error) {
+	sourceProjectID := m.Allocation.Project
+
+	var primary *metal.MachineNetwork
+	for _, mn := range m.Allocation.MachineNetworks {
+		if mn.PrivatePrimary {
+			primary = mn
+			break
+		}
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("machine %s has no primary private network", m.ID)
+	}
+
+	newMachine := *m
+	allocation := *m.Allocation
+	newMachine.Allocation = &allocation
+	newMachine.Allocation.Project = projectID
+	newMachine.Allocation.MachineNetworks = nil
+
+	var (
+		transferredNetworkID string
+		attached             *metal.MachineNetwork
+		transferredNetwork   *metal.Network
+		transferredIPs       metal.IPs
+	)
+	rollbackOnError := func(err error) error {
+		if attached != nil {
+			for _, address := range attached.IPs {
+				ip, rollbackErr := ds.FindIPByID(address)
+				if rollbackErr == nil {
+					rollbackErr = actor.disassociateIP(ip, m)
+				}
+				if rollbackErr != nil {
+					logger.Errorw("cannot release acquired ip of failed transfer", "ip", address, "error", rollbackErr)
+				}
+			}
+		}
+		for i := range transferredIPs {
+			ip := transferredIPs[i]
+			old := ip
+			old.ProjectID = sourceProjectID
+			rollbackErr := ds.UpdateIP(&ip, &old)
+			if rollbackErr != nil {
+				logger.Errorw("cannot hand ip of failed transfer back to its project", "ip", ip.IPAddress, "error", rollbackErr)
+			}
+		}
+		if transferredNetwork != nil {
+			old := *transferredNetwork
+			old.ProjectID = sourceProjectID
+			rollbackErr := ds.UpdateNetwork(transferredNetwork, &old)
+			if rollbackErr != nil {
+				logger.Errorw("cannot hand network of failed transfer back to its project", "network", transferredNetwork.ID, "error", rollbackErr)
+			}
+		}
+		return err
+	}
+
+	for _, mn := range m.Allocation.MachineNetworks {
+		if mn != primary {
+			newMachine.Allocation.MachineNetworks = append(newMachine.Allocation.MachineNetworks, mn)
+			continue
+		}
+
+		switch {
+		case privateNetwork != nil:
+			// the ips of the former private network are released after the machine was updated
+			var err error
+			attached, err = makeMachineNetwork(ds, ipamer, &machineAllocationSpec{UUID: m.ID, Name: m.Allocation.Name, ProjectID: projectID}, &allocationNetwork{
+				network:     privateNetwork,
+				auto:        true,
+				networkType: metal.PrivatePrimaryUnshared,
+			})
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+			attached.ASN = primary.ASN
+			newMachine.Allocation.MachineNetworks = append(newMachine.Allocation.MachineNetworks, attached)
+		case primary.Shared:
+			// shared private networks are not owned by the project of the machine
+			newMachine.Allocation.MachineNetworks = append(newMachine.Allocation.MachineNetworks, mn)
+		default:
+			var others metal.Machines
+			err := ds.SearchMachines(&datastore.MachineSearchQuery{NetworkIDs: []string{mn.NetworkID}}, &others)
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+			for _, other := range others {
+				if other.ID != m.ID {
+					return nil, rollbackOnError(metal.Conflict("private network %s is used by other machines, a private network of the target project must be given", mn.NetworkID))
+				}
+			}
+
+			network, err := ds.FindNetworkByID(mn.NetworkID)
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+			newNetwork := *network
+			newNetwork.ProjectID = projectID
+			err = ds.UpdateNetwork(network, &newNetwork)
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+			transferredNetwork = &newNetwork
+			transferredNetworkID = network.ID
+			newMachine.Allocation.MachineNetworks = append(newMachine.Allocation.MachineNetworks, mn)
+		}
+	}
+
+	var ips metal.IPs
+	for _, mn := range newMachine.Allocation.MachineNetworks {
+		for _, address := range mn.IPs {
+			ip, err := ds.FindIPByID(address)
+			if err != nil {
+				return nil, rollbackOnError(err)
+			}
+			ips = append(ips, *ip)
+		}
+	}
+	if transferredNetworkID != "" {
+		// the ips of the project which are not attached to the machine are transferred with the network
+		var networkIPs metal.IPs
+		err := ds.SearchIPs(&datastore.IPSearchQuery{NetworkID: &transferredNetworkID, ProjectID: &sourceProjectID}, &networkIPs)
+		if err != nil {
+			return nil, rollbackOnError(err)
+		}
+		ips = append(ips, networkIPs...)
+	}
+
+	transferred := map[string]bool{}
+	for i := range ips {
+		ip := ips[i]
+		if ip.ProjectID != sourceProjectID || transferred[ip.IPAddress] {
+			continue
+		}
+		newIP := ip
+		newIP.ProjectID = projectID
+		err := ds.UpdateIP(&ip, &newIP)
+		if err != nil {
+			return nil, rollbackOnError(err)
+		}
+		transferredIPs = append(transferredIPs, newIP)
+		transferred[ip.IPAddress] = true
+	}
+
+	err := ds.UpdateMachine(m, &newMachine)
+	if err != nil {
+		return nil, rollbackOnError(err)
+	}
+
+	if privateNetwork != nil {
+		for _, address := range primary.IPs {
+			ip, err := ds.FindIPByID(address)
+			if err != nil {
+				if metal.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			err = actor.disassociateIP(ip, m)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &newMachine, nil
+}
+
+// validateTransferPrivateNetwork returns an error if the network is not an unshared private network of the
+// project in the partition.
+func validateTransferPrivateNetwork(ds datastore.Store, network *metal.Network, partitionID, projectID string) error {
+	if network.ProjectID != projectID {
+		return fmt.Errorf("private network %s does not belong to project %s", network.ID, projectID)
+	}
+	if network.PartitionID != partitionID {
+		return fmt.Errorf("private network %s is not located in partition %s", network.ID, partitionID)
+	}
+	if network.Shared {
+		return fmt.Errorf("private network %s is shared", network.ID)
+	}
+	if network.ParentNetworkID == "" {
+		return fmt.Errorf("network %s is not a private network", network.ID)
+	}
+	parent, err := ds.FindNetworkByID(network.ParentNetworkID)
+	if err != nil {
+		return err
+	}
+	if !parent.PrivateSuper {
+		return fmt.Errorf("network %s is not a private network", network.ID)
+	}
+	return nil
+}
+
+// setPrivateVrfAtSwitches puts the switch ports of an installed machine into the vrf of its private network.
+func setPrivateVrfAtSwitches(ds datastore.Store, m *metal.Machine) error {
+	vrf := ""
+	for _, mn := range m.Allocation.MachineNetworks {
+		if mn.Private {
+			vrf = fmt.Sprintf("vrf%d", mn.Vrf)
+			break
+		}
+	}
+	if vrf == "" {
+		return fmt.Errorf("the machine %q could not be enslaved into the vrf because no vrf was found", m.ID)
+	}
+
+	return retry.Do(
+		func() error {
+			_, err := ds.SetVrfAtSwitches(m, vrf)
+			return err
+		},
+		retry.Attempts(10),
+		retry.RetryIf(func(err error) bool {
+			return metal.IsConflict(err)
+		}),
+		retry.DelayType(retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)),
+		retry.LastErrorOnly(true),
+	)
+}
+
+func (r machineResource) freeMachine(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	m, err := r.store(request).FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if httperr := checkIfMatch(request, m); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	logger := r.logger(request)
+
+	err = publishMachineCmd(logger, m, r.Publisher, metal.ChassisIdentifyLEDOffCmd)
+	if err != nil {
+		logger.Error("unable to publish machine command", zap.String("command", string(metal.ChassisIdentifyLEDOffCmd)), zap.String("machineID", m.ID), zap.Error(err))
+	}
+
+	err = r.actor.freeMachine(request.Request.Context(), r.Publisher, m, r.headscaleClient, logger)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makeMachineResponse(m, r.store(request))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+
+	ev := metal.ProvisioningEvent{
+		Time:    time.Now(),
+		Event:   metal.ProvisioningEventMachineReclaim,
+		Message: "free machine called",
+	}
+	_, err = r.store(request).ProvisioningEventForMachine(logger, &ev, id)
+	if err != nil {
+		r.log.Errorw("error sending provisioning event after machine free", "error", err)
+	}
+}
+
+func (r *machineResource) deleteMachine(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+	m, err := r.store(request).FindMachineByID(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if httperr := checkIfMatch(request, m); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	if m.Allocation != nil {
+		r.sendError(request, response, defaultError(errors.New("cannot delete machine that is allocated")))
+		return
+	}
+
+	ec, err := r.store(request).FindProvisioningEventContainer(id)
+
+	// when there's no event container, we delete the machine anyway
+	if err != nil && !metal.IsNotFound(err) {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+	if err == nil && ec.Liveliness != metal.MachineLivelinessDead {
+		r.sendError(request, response, defaultError(errors.New("can only delete dead machines")))
+		return
+	}
+
+	switches, err := r.store(request).SearchSwitchesConnectedToMachine(m)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	for i := range switches {
 		old := switches[i]
 		_, ok := old.MachineConnections[m.ID]
 		if !ok {
@@ -1751,7 +2935,7 @@ func (r *machineResource) deleteMachine(request *restful.Request, response *rest
 		}
 		delete(newIP.MachineConnections, m.ID)
 
-		err = r.ds.UpdateSwitch(&old, &newIP)
+		err = r.store(request).UpdateSwitch(&old, &newIP)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -1759,13 +2943,13 @@ func (r *machineResource) deleteMachine(request *restful.Request, response *rest
 
 	}
 
-	err = r.ds.DeleteMachine(m)
+	err = r.store(request).DeleteMachine(m)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	resp, err := makeMachineResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -1787,7 +2971,7 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 	}
 
 	id := request.PathParameter("id")
-	m, err := r.ds.FindMachineByID(id)
+	m, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -1798,37 +2982,42 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 	if m.Allocation != nil && m.State.Value != metal.LockedState {
 		old := *m
 
-		if m.Allocation.FilesystemLayout == nil {
-			fsls, err := r.ds.ListFilesystemLayouts()
+		fsl, err := reinstallableFilesystemLayout(r.store(request), m)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		m.Allocation.FilesystemLayout = fsl
+		m.Allocation.Reinstall = true
+		m.Allocation.ImageID = requestPayload.ImageID
+
+		patchUpgrade := requestPayload.PatchUpgrade
+		if patchUpgrade == nil {
+			enabled, err := projectImagePatchUpgrade(r.mdc, m.Allocation.Project)
 			if err != nil {
-				r.sendError(request, response, defaultError(err))
-				return
+				// the reinstallation must not depend on the availability of the masterdata
+				logger.Errorw("unable to lookup patch upgrade default of project, reinstalling without patch upgrade", "machineID", m.ID, "project", m.Allocation.Project, "error", err)
 			}
-
-			fsl, err := fsls.From(m.SizeID, m.Allocation.ImageID)
+			patchUpgrade = &enabled
+		}
+		if *patchUpgrade {
+			image, err := imagePatchUpgrade(r.store(request), m, fsl, requestPayload.ImageID)
 			if err != nil {
-				r.sendError(request, response, defaultError(err))
+				r.sendError(request, response, httperrors.BadRequest(err))
 				return
 			}
-
-			m.Allocation.FilesystemLayout = fsl
+			logger.Infow("reinstalling machine with patch upgrade of image", "machineID", m.ID, "requested", requestPayload.ImageID, "image", image.ID)
+			m.Allocation.ImageID = image.ID
 		}
 
-		if !m.Allocation.FilesystemLayout.IsReinstallable() {
-			r.sendError(request, response, defaultError(fmt.Errorf("filesystemlayout:%s is not reinstallable, abort reinstallation", m.Allocation.FilesystemLayout.ID)))
-			return
-		}
-		m.Allocation.Reinstall = true
-		m.Allocation.ImageID = requestPayload.ImageID
-
-		resp, err := makeMachineResponse(m, r.ds)
+		resp, err := makeMachineResponse(m, r.store(request))
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
 		}
 
 		if resp.Allocation.Image != nil {
-			err = r.ds.UpdateMachine(&old, m)
+			err = r.store(request).UpdateMachine(&old, m)
 			if err != nil {
 				r.sendError(request, response, defaultError(err))
 				return
@@ -1836,23 +3025,12 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 
 			logger.Info("marked machine to get reinstalled", zap.String("machineID", m.ID))
 
-			err = deleteVRFSwitches(r.ds, m, logger.Desugar())
+			err = rebootIntoReinstallation(r.store(request), r.Publisher, logger, m)
 			if err != nil {
 				r.sendError(request, response, defaultError(err))
 				return
 			}
 
-			err = publishDeleteEvent(r.Publisher, m, logger.Desugar())
-			if err != nil {
-				r.sendError(request, response, defaultError(err))
-				return
-			}
-
-			err = publishMachineCmd(logger, m, r.Publisher, metal.MachineReinstallCmd)
-			if err != nil {
-				logger.Error("unable to publish machine command", zap.String("command", string(metal.MachineReinstallCmd)), zap.String("machineID", m.ID), zap.Error(err))
-			}
-
 			r.send(request, response, http.StatusOK, resp)
 
 			return
@@ -1862,7 +3040,96 @@ func (r *machineResource) reinstallMachine(request *restful.Request, response *r
 	r.sendError(request, response, httperrors.BadRequest(errors.New("machine either locked, not allocated yet or invalid image ID specified")))
 }
 
-func deleteVRFSwitches(ds *datastore.RethinkStore, m *metal.Machine, logger *zap.Logger) error {
+// reinstallableFilesystemLayout returns the filesystem layout of the allocated machine, it is an error if the
+// layout does not allow a reinstallation.
+func reinstallableFilesystemLayout(ds datastore.Store, m *metal.Machine) (*metal.FilesystemLayout, error) {
+	fsl := m.Allocation.FilesystemLayout
+	if fsl == nil {
+		fsls, err := ds.ListFilesystemLayouts()
+		if err != nil {
+			return nil, err
+		}
+
+		fsl, err = fsls.From(m.SizeID, m.Allocation.ImageID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !fsl.IsReinstallable() {
+		return nil, fmt.Errorf("filesystemlayout:%s is not reinstallable, abort reinstallation", fsl.ID)
+	}
+
+	return fsl, nil
+}
+
+// imagePatchUpgradeAnnotation is the project annotation which enables patch upgrades of the image for all
+// reinstallations of the machines of the project which do not explicitly choose otherwise.
+const imagePatchUpgradeAnnotation = "metal-stack.io/image-patch-upgrade"
+
+// projectImagePatchUpgrade returns true if the project enables patch upgrades of the image on reinstallations by default.
+func projectImagePatchUpgrade(mdc mdm.Client, projectID string) (bool, error) {
+	p, err := mdc.Project().Get(context.Background(), &mdmv1.ProjectGetRequest{Id: projectID})
+	if err != nil {
+		return false, err
+	}
+
+	return p.GetProject().GetMeta().GetAnnotations()[imagePatchUpgradeAnnotation] == "true", nil
+}
+
+// imagePatchUpgrade returns the newest supported image with the same operating system and minor version as the
+// given image which is compatible with the size of the machine and the filesystem layout it is reinstalled with.
+func imagePatchUpgrade(ds datastore.Store, m *metal.Machine, fsl *metal.FilesystemLayout, imageID string) (*metal.Image, error) {
+	image, err := ds.FindImage(imageID)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := ds.ListImages()
+	if err != nil {
+		return nil, err
+	}
+
+	size, err := ds.FindSize(m.SizeID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range images.PatchUpgradesFor(*image) {
+		if !fsl.Supports(m.SizeID, candidate.ID) {
+			continue
+		}
+		if isSizeAndImageCompatible(ds, *size, candidate) != nil {
+			continue
+		}
+		return &candidate, nil
+	}
+
+	return nil, fmt.Errorf("no supported image of %s %s is compatible with size:%s and filesystemlayout:%s", image.OS, image.Version, m.SizeID, fsl.ID)
+}
+
+// rebootIntoReinstallation detaches a machine which was marked to get reinstalled from its private vrf and
+// reboots it into the reinstallation.
+func rebootIntoReinstallation(ds datastore.Store, publisher bus.Publisher, logger *zap.SugaredLogger, m *metal.Machine) error {
+	err := deleteVRFSwitches(ds, m, logger.Desugar())
+	if err != nil {
+		return err
+	}
+
+	err = publishDeleteEvent(publisher, m, logger.Desugar())
+	if err != nil {
+		return err
+	}
+
+	err = publishMachineCmd(logger, m, publisher, metal.MachineReinstallCmd)
+	if err != nil {
+		logger.Error("unable to publish machine command", zap.String("command", string(metal.MachineReinstallCmd)), zap.String("machineID", m.ID), zap.Error(err))
+	}
+
+	return nil
+}
+
+func deleteVRFSwitches(ds datastore.Store, m *metal.Machine, logger *zap.Logger) error {
 	logger.Info("set VRF at switch", zap.String("machineID", m.ID))
 	err := retry.Do(
 		func() error {
@@ -1895,7 +3162,7 @@ func publishDeleteEvent(publisher bus.Publisher, m *metal.Machine, logger *zap.L
 }
 
 // MachineLiveliness evaluates whether machines are still alive or if they have died
-func MachineLiveliness(ds *datastore.RethinkStore, logger *zap.SugaredLogger) error {
+func MachineLiveliness(ds datastore.Store, logger *zap.SugaredLogger) error {
 	logger.Info("machine liveliness was requested")
 
 	machines, err := ds.ListMachines()
@@ -1929,7 +3196,7 @@ func MachineLiveliness(ds *datastore.RethinkStore, logger *zap.SugaredLogger) er
 	return nil
 }
 
-func evaluateMachineLiveliness(ds *datastore.RethinkStore, m metal.Machine) (metal.MachineLiveliness, error) {
+func evaluateMachineLiveliness(ds datastore.Store, m metal.Machine) (metal.MachineLiveliness, error) {
 	provisioningEvents, err := ds.FindProvisioningEventContainer(m.ID)
 	if err != nil {
 		// we have no provisioning events... we cannot tell
@@ -1943,6 +3210,9 @@ func evaluateMachineLiveliness(ds *datastore.RethinkStore, m metal.Machine) (met
 			if m.Allocation != nil {
 				// the machine is either dead or the customer did turn off the phone home service
 				provisioningEvents.Liveliness = metal.MachineLivelinessUnknown
+			} else if m.PowerSaving {
+				// the machine was powered off by the power policy of its partition and must not be resurrected
+				provisioningEvents.Liveliness = metal.MachineLivelinessUnknown
 			} else {
 				// the machine is just dead
 				provisioningEvents.Liveliness = metal.MachineLivelinessDead
@@ -1961,7 +3231,7 @@ func evaluateMachineLiveliness(ds *datastore.RethinkStore, m metal.Machine) (met
 }
 
 // ResurrectMachines attempts to resurrect machines that are obviously dead
-func ResurrectMachines(ctx context.Context, ds *datastore.RethinkStore, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, logger *zap.SugaredLogger) error {
+func ResurrectMachines(ctx context.Context, ds datastore.Store, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, logger *zap.SugaredLogger) error {
 	logger.Info("machine resurrection was requested")
 
 	machines, err := ds.ListMachines()
@@ -2015,6 +3285,147 @@ func ResurrectMachines(ctx context.Context, ds *datastore.RethinkStore, publishe
 	return nil
 }
 
+// ExpireMachineLeases frees the machines whose lease expired and publishes a warning for the leases which
+// expire within the given duration.
+func ExpireMachineLeases(ctx context.Context, ds datastore.Store, publisher bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, headscaleClient *headscale.HeadscaleClient, logger *zap.SugaredLogger, warnBefore time.Duration) error {
+	logger.Info("machine lease expiry was requested")
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	act, err := newAsyncActor(logger, ep, ds, ipamer)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	freed := 0
+	for i := range machines {
+		m := machines[i]
+		if m.Allocation == nil || m.Allocation.Lease == nil {
+			continue
+		}
+		lease := m.Allocation.Lease
+
+		if lease.IsExpired(now) {
+			logger.Infow("freeing machine with expired lease", "machineID", m.ID, "expired", lease.Expires)
+
+			err = publishMachineCmd(logger, &m, publisher, metal.ChassisIdentifyLEDOffCmd)
+			if err != nil {
+				logger.Errorw("unable to publish machine command", "command", metal.ChassisIdentifyLEDOffCmd, "machineID", m.ID, "error", err)
+			}
+
+			err = act.freeMachine(ctx, publisher, &m, headscaleClient, logger)
+			if err != nil {
+				logger.Errorw("unable to free machine with expired lease", "machineID", m.ID, "error", err)
+				continue
+			}
+			freed++
+
+			ev := metal.ProvisioningEvent{
+				Time:    time.Now(),
+				Event:   metal.ProvisioningEventMachineReclaim,
+				Message: "machine lease expired",
+			}
+			_, err = ds.ProvisioningEventForMachine(logger, &ev, m.ID)
+			if err != nil {
+				logger.Errorw("error sending provisioning event after machine free", "machineID", m.ID, "error", err)
+			}
+			continue
+		}
+
+		if lease.WarningSent || lease.Expires.Sub(now) > warnBefore {
+			continue
+		}
+
+		err = publisher.Publish(metal.TopicLease.Name, &metal.LeaseExpiryEvent{
+			MachineID: m.ID,
+			ProjectID: m.Allocation.Project,
+			Expires:   lease.Expires,
+		})
+		if err != nil {
+			logger.Errorw("unable to publish lease expiry warning", "machineID", m.ID, "error", err)
+			continue
+		}
+
+		warned := m
+		allocation := *m.Allocation
+		warned.Allocation = &allocation
+		warned.Allocation.Lease = &metal.MachineLease{
+			Expires:     lease.Expires,
+			WarningSent: true,
+		}
+		err = ds.UpdateMachine(&m, &warned)
+		if err != nil {
+			logger.Errorw("unable to mark lease expiry warning as sent", "machineID", m.ID, "error", err)
+		}
+	}
+
+	logger.Infow("finished machine lease expiry", "freed", freed)
+
+	return nil
+}
+
+// ApplyMachineMaintenanceWindows puts the machines into maintenance whose scheduled maintenance window started and
+// makes the machines available again whose maintenance window ended. The transitions are done by the system, so
+// they have no issuer.
+func ApplyMachineMaintenanceWindows(ds datastore.Store, logger *zap.SugaredLogger) error {
+	logger.Info("applying machine maintenance windows was requested")
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	started := 0
+	finished := 0
+	for i := range machines {
+		m := machines[i]
+
+		switch {
+		case m.State.ScheduledMaintenance != nil && m.State.ScheduledMaintenance.Window.HasStarted(now):
+			scheduled := m.State.ScheduledMaintenance
+			window := scheduled.Window
+			maintenance := m
+			maintenance.State = metal.MachineState{
+				Value:              metal.MaintenanceState,
+				Description:        scheduled.Description,
+				Issuer:             scheduled.Issuer,
+				MetalHammerVersion: m.State.MetalHammerVersion,
+				MaintenanceWindow:  &window,
+			}
+			err = ds.UpdateMachine(&m, &maintenance)
+			if err != nil {
+				logger.Errorw("unable to start machine maintenance", "machineID", m.ID, "error", err)
+				continue
+			}
+			logger.Infow("started machine maintenance", "machineID", m.ID, "reason", scheduled.Description)
+			started++
+		case m.State.Value == metal.MaintenanceState && m.State.MaintenanceWindow.IsOver(now):
+			available := m
+			available.State = metal.MachineState{
+				Value:              metal.AvailableState,
+				Description:        fmt.Sprintf("maintenance window ended at %s", m.State.MaintenanceWindow.End.Format(time.RFC3339)),
+				MetalHammerVersion: m.State.MetalHammerVersion,
+			}
+			err = ds.UpdateMachine(&m, &available)
+			if err != nil {
+				logger.Errorw("unable to finish machine maintenance", "machineID", m.ID, "error", err)
+				continue
+			}
+			logger.Infow("finished machine maintenance", "machineID", m.ID, "reason", m.State.Description)
+			finished++
+		}
+	}
+
+	logger.Infow("applied machine maintenance windows", "started", started, "finished", finished)
+
+	return nil
+}
+
 func (r *machineResource) machineOn(request *restful.Request, response *restful.Response) {
 	r.machineCmd(metal.MachineOnCmd, request, response)
 }
@@ -2065,7 +3476,7 @@ func (r *machineResource) updateFirmware(request *restful.Request, response *res
 	}
 
 	id := request.PathParameter("id")
-	m, f, err := getFirmware(r.ds, id)
+	m, f, err := getFirmware(r.store(request), id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -2132,7 +3543,7 @@ func (r *machineResource) updateFirmware(request *restful.Request, response *res
 		return
 	}
 
-	resp, err := makeMachineResponse(m, r.ds)
+	resp, err := makeMachineResponse(m, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -2147,7 +3558,7 @@ func (r *machineResource) machineCmd(cmd metal.MachineCommand, request *restful.
 	id := request.PathParameter("id")
 	description := request.QueryParameter("description")
 
-	newMachine, err := r.ds.FindMachineByID(id)
+	newMachine, err := r.store(request).FindMachineByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -2162,7 +3573,7 @@ func (r *machineResource) machineCmd(cmd metal.MachineCommand, request *restful.
 			Event:   metal.ProvisioningEventPlannedReboot,
 			Message: string(cmd),
 		}
-		_, err = r.ds.ProvisioningEventForMachine(logger, &ev, id)
+		_, err = r.store(request).ProvisioningEventForMachine(logger, &ev, id)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -2182,7 +3593,7 @@ func (r *machineResource) machineCmd(cmd metal.MachineCommand, request *restful.
 	}
 
 	if needsUpdate {
-		err = r.ds.UpdateMachine(&old, newMachine)
+		err = r.store(request).UpdateMachine(&old, newMachine)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -2204,7 +3615,7 @@ func (r *machineResource) machineCmd(cmd metal.MachineCommand, request *restful.
 		return
 	}
 
-	resp, err := makeMachineResponse(newMachine, r.ds)
+	resp, err := makeMachineResponse(newMachine, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -2232,7 +3643,7 @@ func publishMachineCmd(logger *zap.SugaredLogger, m *metal.Machine, publisher bu
 	return nil
 }
 
-func makeMachineResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.MachineResponse, error) {
+func makeMachineResponse(m *metal.Machine, ds datastore.Store) (*v1.MachineResponse, error) {
 	s, p, i, ec, err := findMachineReferencedEntities(m, ds)
 	if err != nil {
 		return nil, err
@@ -2240,7 +3651,7 @@ func makeMachineResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.Mach
 	return v1.NewMachineResponse(m, s, p, i, ec), nil
 }
 
-func makeMachineResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*v1.MachineResponse, error) {
+func makeMachineResponseList(ms metal.Machines, ds datastore.Store) ([]*v1.MachineResponse, error) {
 	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds)
 	if err != nil {
 		return nil, err
@@ -2273,7 +3684,7 @@ func makeMachineResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*
 	return result, nil
 }
 
-func makeMachineIPMIResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.MachineIPMIResponse, error) {
+func makeMachineIPMIResponse(m *metal.Machine, ds datastore.Store) (*v1.MachineIPMIResponse, error) {
 	s, p, i, ec, err := findMachineReferencedEntities(m, ds)
 	if err != nil {
 		return nil, err
@@ -2281,7 +3692,7 @@ func makeMachineIPMIResponse(m *metal.Machine, ds *datastore.RethinkStore) (*v1.
 	return v1.NewMachineIPMIResponse(m, s, p, i, ec), nil
 }
 
-func makeMachineIPMIResponseList(ms metal.Machines, ds *datastore.RethinkStore) ([]*v1.MachineIPMIResponse, error) {
+func makeMachineIPMIResponseList(ms metal.Machines, ds datastore.Store) ([]*v1.MachineIPMIResponse, error) {
 	sMap, pMap, iMap, ecMap, err := getMachineReferencedEntityMaps(ds)
 	if err != nil {
 		return nil, err
@@ -2314,7 +3725,7 @@ func makeMachineIPMIResponseList(ms metal.Machines, ds *datastore.RethinkStore)
 	return result, nil
 }
 
-func findMachineReferencedEntities(m *metal.Machine, ds *datastore.RethinkStore) (*metal.Size, *metal.Partition, *metal.Image, *metal.ProvisioningEventContainer, error) {
+func findMachineReferencedEntities(m *metal.Machine, ds datastore.Store) (*metal.Size, *metal.Partition, *metal.Image, *metal.ProvisioningEventContainer, error) {
 	var err error
 
 	var s *metal.Size
@@ -2358,7 +3769,7 @@ func findMachineReferencedEntities(m *metal.Machine, ds *datastore.RethinkStore)
 	return s, p, i, ec, nil
 }
 
-func getMachineReferencedEntityMaps(ds *datastore.RethinkStore) (metal.SizeMap, metal.PartitionMap, metal.ImageMap, metal.ProvisioningEventContainerMap, error) {
+func getMachineReferencedEntityMaps(ds datastore.Store) (metal.SizeMap, metal.PartitionMap, metal.ImageMap, metal.ProvisioningEventContainerMap, error) {
 	s, err := ds.ListSizes()
 	if err != nil {
 		return nil, nil, nil, nil, fmt.Errorf("sizes could not be listed: %w", err)
@@ -1,27 +1,37 @@
 package service
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/emicklei/go-restful/v3"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"golang.org/x/crypto/ssh"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 	r "gopkg.in/rethinkdb/rethinkdb-go.v6"
 
 	goipam "github.com/metal-stack/go-ipam"
+	mdmv1 "github.com/metal-stack/masterdata-api/api/v1"
+	mdmv1mock "github.com/metal-stack/masterdata-api/api/v1/mocks"
+	mdm "github.com/metal-stack/masterdata-api/pkg/client"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
 	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/testdata"
 	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
 	"github.com/metal-stack/security"
 )
 
@@ -59,7 +69,7 @@ func TestGetMachines(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := zaptest.NewLogger(t).Sugar()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 	container := restful.NewContainer().Add(machineservice)
 	req := httptest.NewRequest("GET", "/v1/machine", nil)
@@ -122,7 +132,7 @@ func TestMachineIPMIReport(t *testing.T) {
 	for i := range tests {
 		tt := tests[i]
 		t.Run(tt.name, func(t *testing.T) {
-			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 			require.NoError(t, err)
 			container := restful.NewContainer().Add(machineservice)
 			js, err := json.Marshal(tt.input)
@@ -173,7 +183,7 @@ func TestMachineFindIPMI(t *testing.T) {
 			mock.On(r.DB("mockdb").Table("machine").Filter(r.MockAnything())).Return([]interface{}{*tt.machine}, nil)
 			testdata.InitMockDBData(mock)
 
-			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 			require.NoError(t, err)
 			container := restful.NewContainer().Add(machineservice)
 
@@ -229,7 +239,7 @@ func TestSetMachineState(t *testing.T) {
 		Name:  "anonymous",
 	}}
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -270,7 +280,7 @@ func TestSetMachineStateIssuerResetWhenAvailable(t *testing.T) {
 		Name:  "anonymous",
 	}}
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -306,7 +316,7 @@ func TestGetMachine(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := zaptest.NewLogger(t).Sugar()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -334,7 +344,7 @@ func TestGetMachineNotFound(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := zaptest.NewLogger(t).Sugar()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -370,7 +380,7 @@ func TestFreeMachine(t *testing.T) {
 		return nil
 	}
 
-	machineservice, err := NewMachine(log, ds, pub, bus.NewEndpoints(nil, pub), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, pub, bus.NewEndpoints(nil, pub), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -397,7 +407,7 @@ func TestSearchMachine(t *testing.T) {
 	testdata.InitMockDBData(mock)
 	log := zaptest.NewLogger(t).Sugar()
 
-	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 	require.NoError(t, err)
 
 	container := restful.NewContainer().Add(machineservice)
@@ -424,6 +434,50 @@ func TestSearchMachine(t *testing.T) {
 	require.Equal(t, testdata.Partition1.Name, *result.Partition.Name)
 }
 
+func TestWatchMachines(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+
+	machineservice, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+
+	container := restful.NewContainer().Add(machineservice)
+	server := httptest.NewServer(container)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/v1/machine/watch", bytes.NewBufferString(`{"partition_id":"p1"}`))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/json")
+	injectViewer(log, container, req)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m0"}, PartitionID: "p0"}))
+	require.NoError(t, ds.CreateMachine(&metal.Machine{Base: metal.Base{ID: "m1"}, PartitionID: "p1"}))
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() && scanner.Text() != "" {
+		lines = append(lines, scanner.Text())
+	}
+	require.Len(t, lines, 3)
+	assert.True(t, strings.HasPrefix(lines[0], "id: "))
+	assert.Equal(t, "event: create", lines[1])
+
+	var event v1.MachineWatchEvent
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &event))
+	assert.Equal(t, "create", event.Type)
+	assert.Equal(t, "m1", event.Machine.ID)
+	assert.Equal(t, lines[0], fmt.Sprintf("id: %d", event.ResourceVersion))
+}
+
 func TestOnMachine(t *testing.T) {
 	log := zaptest.NewLogger(t).Sugar()
 
@@ -489,7 +543,7 @@ func TestOnMachine(t *testing.T) {
 				return nil
 			}
 
-			machineservice, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser())
+			machineservice, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipam.New(goipam.New()), nil, nil, nil, 0, nil, metal.DisabledIPMISuperUser(), 0)
 			require.NoError(t, err)
 
 			js, err := json.Marshal([]string{tt.param})
@@ -1341,3 +1395,862 @@ func Test_gatherNetworksFromSpec(t *testing.T) {
 		})
 	}
 }
+
+// setupAllocation returns a datastore with the given amount of waiting machines of size s1 in partition p1 and everything
+// else which is required to allocate them for project pr1.
+func setupAllocation(t *testing.T, machineCount int) (*datastore.MemoryStore, ipam.IPAMer, *asyncActor, mdm.Client) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+	ipamer := ipam.InitTestIpam(t)
+
+	for i := 0; i < machineCount; i++ {
+		require.NoError(t, ds.CreateMachine(&metal.Machine{
+			Base:        metal.Base{ID: fmt.Sprintf("m%d", i)},
+			SizeID:      "s1",
+			PartitionID: "p1",
+			Waiting:     true,
+			State:       metal.MachineState{Value: metal.AvailableState},
+		}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{Base: metal.Base{ID: fmt.Sprintf("m%d", i)}, Liveliness: metal.MachineLivelinessAlive}))
+	}
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+	require.NoError(t, ds.CreateSize(&metal.Size{Base: metal.Base{ID: "s1"}}))
+	require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "i-1.0.0"}, OS: "i", Version: "1.0.0", Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+	require.NoError(t, ds.CreateFilesystemLayout(&metal.FilesystemLayout{Base: metal.Base{ID: "fsl1"}, Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{"s1"}, Images: map[string]string{"i": "*"}}}))
+
+	super, err := metal.NewPrefixFromCIDR("10.0.0.0/20")
+	require.NoError(t, err)
+	require.NoError(t, ipamer.CreatePrefix(*super))
+	private, err := ipamer.AllocateChildPrefix(*super, 22)
+	require.NoError(t, err)
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "super"}, PrivateSuper: true, PartitionID: "p1", Prefixes: metal.Prefixes{*super}}))
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "private"}, ParentNetworkID: "super", ProjectID: "pr1", PartitionID: "p1", Prefixes: metal.Prefixes{*private}}))
+
+	actor, err := newAsyncActor(log, bus.DirectEndpoints(), ds, ipamer)
+	require.NoError(t, err)
+
+	psc := &mdmv1mock.ProjectServiceClient{}
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr1"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{}}, nil)
+
+	return ds, ipamer, actor, mdm.NewMock(psc, nil)
+}
+
+func TestAllocateMachines(t *testing.T) {
+	request := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	user := &security.User{EMail: testEmail}
+
+	t.Run("all machines are allocated", func(t *testing.T) {
+		ds, ipamer, actor, mdc := setupAllocation(t, 3)
+
+		var published []string
+		pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+			published = append(published, data.(*metal.AllocationEvent).MachineID)
+			return nil
+		}}
+
+		machines, err := allocateMachines(context.Background(), zaptest.NewLogger(t).Sugar(), ds, ipamer, request, 3, metal.RoleMachine, user, mdc, actor, pub, nil)
+		require.NoError(t, err)
+		require.Len(t, machines, 3)
+		assert.Len(t, published, 3)
+
+		asns := map[uint32]bool{}
+		var hostnames []string
+		for _, m := range machines {
+			require.NotNil(t, m.Allocation)
+			asns[m.Allocation.MachineNetworks[0].ASN] = true
+			hostnames = append(hostnames, m.Allocation.Hostname)
+		}
+		assert.Len(t, asns, 3, "every machine must have an asn of its own")
+		assert.Equal(t, []string{"metal-0", "metal-1", "metal-2"}, hostnames, "the hostnames are suffixed with the index of the machine")
+	})
+
+	t.Run("no machine is allocated if capacity runs out", func(t *testing.T) {
+		ds, ipamer, actor, mdc := setupAllocation(t, 2)
+
+		pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+			t.Errorf("no allocation must be published, got %v", data)
+			return nil
+		}}
+
+		_, err := allocateMachines(context.Background(), zaptest.NewLogger(t).Sugar(), ds, ipamer, request, 3, metal.RoleMachine, user, mdc, actor, pub, nil)
+		require.ErrorContains(t, err, "unable to allocate machine 3 of 3")
+
+		machines, err := ds.ListMachines()
+		require.NoError(t, err)
+		for _, m := range machines {
+			assert.Nil(t, m.Allocation, m.ID)
+			assert.False(t, m.PreAllocated, m.ID)
+		}
+
+		// networks are released asynchronously
+		assert.Eventually(t, func() bool {
+			ips, err := ds.ListIPs()
+			require.NoError(t, err)
+			asns, err := ds.GetASNPool().AcquiredIntegers()
+			require.NoError(t, err)
+			return len(ips) == 0 && len(asns) == 0
+		}, 5*time.Second, 10*time.Millisecond, "ips and asns of the machines must be released")
+	})
+}
+
+func TestAllocateMachineDryRun(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, mdc := setupAllocation(t, 3)
+
+	m1, err := ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	notWaiting := *m1
+	notWaiting.Waiting = false
+	require.NoError(t, ds.UpdateMachine(m1, &notWaiting))
+
+	ec, err := ds.FindProvisioningEventContainer("m2")
+	require.NoError(t, err)
+	dead := *ec
+	dead.Liveliness = metal.MachineLivelinessDead
+	require.NoError(t, ds.UpdateProvisioningEventContainer(ec, &dead))
+
+	userGetter := mockUserGetter{&security.User{EMail: testEmail}}
+	ws, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil, userGetter, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	dryRun := func(request v1.MachineAllocateRequest) (int, *v1.MachineAllocationDryRunResponse) {
+		js, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/allocate/dry-run", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+
+		var result v1.MachineAllocationDryRunResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		}
+		return w.Code, &result
+	}
+
+	request := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+
+	code, result := dryRun(request)
+	require.Equal(t, http.StatusOK, code)
+	require.NotNil(t, result.MachineID)
+	assert.Equal(t, "m0", *result.MachineID)
+	assert.Equal(t, []string{"m0"}, result.Finalists)
+	assert.Nil(t, result.Message)
+
+	reasons := map[string]string{}
+	for _, c := range result.Machines {
+		if c.Reason != nil {
+			reasons[c.ID] = *c.Reason
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"m1": "machine is not waiting for an allocation",
+		"m2": "machine is not alive, liveliness is Dead",
+	}, reasons)
+
+	m0, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	assert.False(t, m0.PreAllocated, "a dry run must not preallocate the machine")
+	assert.Nil(t, m0.Allocation)
+
+	request.Affinity = &v1.MachineAffinity{RackAffinity: []v1.MachineAffinityTerm{{Tags: []string{"storage"}, Required: true}}}
+	code, result = dryRun(request)
+	require.Equal(t, http.StatusOK, code)
+	assert.Nil(t, result.MachineID)
+	assert.Empty(t, result.Finalists)
+	require.NotNil(t, result.Message)
+	assert.Equal(t, "no machine available which satisfies the required rack affinity to machines tagged storage", *result.Message)
+
+	request.Affinity = nil
+	request.SSHPubKeys = []string{"not a key"}
+	code, _ = dryRun(request)
+	assert.Equal(t, http.StatusBadRequest, code, "the allocation spec is validated")
+}
+
+func TestMachineLeases(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 1)
+
+	var warnings []*metal.LeaseExpiryEvent
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if topic == metal.TopicLease.Name {
+			warnings = append(warnings, data.(*metal.LeaseExpiryEvent))
+		}
+		return nil
+	}}
+
+	spec, err := createMachineAllocationSpec(ds, v1.MachineAllocateRequest{
+		SizeID:        "s1",
+		PartitionID:   "p1",
+		ProjectID:     "pr1",
+		ImageID:       "i-1.0.0",
+		Networks:      v1.MachineAllocationNetworks{{NetworkID: "private"}},
+		LeaseDuration: pointer.Pointer(2 * time.Hour),
+	}, metal.RoleMachine, &security.User{EMail: testEmail})
+	require.NoError(t, err)
+
+	m, err := allocateMachine(log, ds, ipamer, spec, mdc, actor, pub)
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation.Lease)
+	assert.Equal(t, m.Allocation.Created.Add(2*time.Hour), m.Allocation.Lease.Expires)
+
+	ws, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipamer, mdc, nil, mockUserGetter{&security.User{EMail: testEmail}}, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	js, err := json.Marshal(v1.MachineLeaseExtendRequest{Duration: time.Hour})
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/v1/machine/m0/lease", bytes.NewBuffer(js))
+	req.Header.Add("Content-Type", "application/json")
+	container = injectEditor(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp v1.MachineResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	require.NotNil(t, resp.Allocation.Lease)
+	assert.True(t, m.Allocation.Created.Add(3*time.Hour).Equal(resp.Allocation.Lease.Expires))
+
+	// the warning is only published once
+	for i := 0; i < 2; i++ {
+		require.NoError(t, ExpireMachineLeases(context.Background(), ds, pub, bus.DirectEndpoints(), ipamer, nil, log, 4*time.Hour))
+	}
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "m0", warnings[0].MachineID)
+	assert.Equal(t, "pr1", warnings[0].ProjectID)
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation)
+	expired := *m
+	allocation := *m.Allocation
+	expired.Allocation = &allocation
+	expired.Allocation.Lease = &metal.MachineLease{Expires: time.Now().Add(-time.Minute), WarningSent: true}
+	require.NoError(t, ds.UpdateMachine(m, &expired))
+
+	require.NoError(t, ExpireMachineLeases(context.Background(), ds, pub, bus.DirectEndpoints(), ipamer, nil, log, 4*time.Hour))
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	assert.Nil(t, m.Allocation, "machine must be freed when its lease expired")
+}
+
+func TestAllocateMachineByHardwareRequirements(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 3)
+	require.NoError(t, ds.CreateSize(&metal.Size{Base: metal.Base{ID: "s2"}}))
+
+	hardware := map[string]struct {
+		size   string
+		cores  int
+		memory uint64
+	}{
+		"m0": {size: "s1", cores: 32, memory: 512},
+		"m1": {size: "s1", cores: 16, memory: 256},
+		"m2": {size: "s2", cores: 32, memory: 256},
+	}
+	for id, hw := range hardware {
+		m, err := ds.FindMachineByID(id)
+		require.NoError(t, err)
+		newMachine := *m
+		newMachine.SizeID = hw.size
+		newMachine.Hardware = metal.MachineHardware{CPUCores: hw.cores, Memory: hw.memory}
+		require.NoError(t, ds.UpdateMachine(m, &newMachine))
+	}
+
+	user := &security.User{EMail: testEmail}
+	request := v1.MachineAllocateRequest{
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+
+	_, err := createMachineAllocationSpec(ds, request, metal.RoleMachine, user)
+	require.EqualError(t, err, "when no machine id is given, a size id or hardware requirements must be specified")
+
+	request.Requirements = []v1.SizeConstraint{{Type: metal.CoreConstraint, Min: 64, Max: 128}}
+	_, err = createMachineAllocationSpec(ds, request, metal.RoleMachine, user)
+	require.ErrorIs(t, err, datastore.ErrNoMachineAvailable)
+
+	// the smallest machine with at least 32 cores is of size s2, the sizes are tried from the smallest to the largest
+	request.Requirements = []v1.SizeConstraint{{Type: metal.CoreConstraint, Min: 32, Max: 128}}
+	spec, err := createMachineAllocationSpec(ds, request, metal.RoleMachine, user)
+	require.NoError(t, err)
+	assert.Equal(t, "s2", spec.Size.ID)
+	require.Len(t, spec.Sizes, 2)
+	assert.Equal(t, "s1", spec.Sizes[1].ID)
+
+	// only m0 of size s1 satisfies both requirements
+	request.Requirements = []v1.SizeConstraint{{Type: metal.CoreConstraint, Min: 32, Max: 128}, {Type: metal.MemoryConstraint, Min: 512, Max: 1024}}
+	bothSpec, err := createMachineAllocationSpec(ds, request, metal.RoleMachine, user)
+	require.NoError(t, err)
+	require.Equal(t, "s1", bothSpec.Size.ID)
+	require.Len(t, bothSpec.Sizes, 1)
+
+	// there is no filesystem layout for s2, so the allocation falls back to s1 and the filesystem layout of s1 is applied
+	m, err := allocateMachine(log, ds, ipamer, spec, mdc, actor, &emptyPublisher{})
+	require.NoError(t, err)
+	assert.Equal(t, "m0", m.ID, "m1 has the same size but does not satisfy the requirements")
+	assert.Equal(t, "s1", spec.Size.ID)
+	require.NotNil(t, m.Allocation.FilesystemLayout)
+	assert.Equal(t, "fsl1", m.Allocation.FilesystemLayout.ID)
+
+	// a machine powered off by the power policy is powered on when it satisfies the requirements
+	m, err = ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	poweredOff := *m
+	poweredOff.PowerSaving = true
+	poweredOff.Waiting = false
+	require.NoError(t, ds.UpdateMachine(m, &poweredOff))
+
+	request.Requirements = []v1.SizeConstraint{{Type: metal.CoreConstraint, Min: 16, Max: 16}}
+	spec, err = createMachineAllocationSpec(ds, request, metal.RoleMachine, user)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", spec.Size.ID)
+
+	_, err = allocateMachine(log, ds, ipamer, spec, mdc, actor, &emptyPublisher{})
+	require.ErrorIs(t, err, datastore.ErrNoMachineAvailable)
+
+	m, err = ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	assert.False(t, m.PowerSaving, "the spare machine is powered on for the next allocation")
+}
+
+func TestAllocateMachineWithPreemption(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, _ := setupAllocation(t, 1)
+
+	project := &mdmv1.Project{Meta: &mdmv1.Meta{Annotations: map[string]string{}}}
+	psc := &mdmv1mock.ProjectServiceClient{}
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr1"}).Return(&mdmv1.ProjectResponse{Project: project}, nil)
+	mdc := mdm.NewMock(psc, nil)
+
+	var notices []*metal.MachineEvent
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if e, ok := data.(*metal.MachineEvent); ok && e.Type == metal.PREEMPT {
+			notices = append(notices, e)
+		}
+		return nil
+	}}
+
+	ws, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipamer, mdc, nil, mockUserGetter{&security.User{EMail: testEmail}}, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	template := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	allocate := func(preemptible bool, priority string, result any) int {
+		project.Meta.Annotations[allocationPriorityAnnotation] = priority
+		request := template
+		request.Preemptible = preemptible
+		js, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/allocate", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && (w.Code == http.StatusOK || w.Code == http.StatusAccepted) {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+	setState := func(state metal.MState) {
+		m, err := ds.FindMachineByID("m0")
+		require.NoError(t, err)
+		updated := *m
+		updated.State = metal.MachineState{Value: state}
+		require.NoError(t, ds.UpdateMachine(m, &updated))
+	}
+
+	var resp v1.MachineResponse
+	code := allocate(true, "5", &resp)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "m0", resp.ID)
+	assert.True(t, resp.Allocation.Preemptible)
+	assert.Equal(t, 5, resp.Allocation.Priority, "the priority of the project is recorded in the allocation")
+
+	code = allocate(true, "10", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "preemptible allocations do not preempt other machines")
+	code = allocate(false, "4", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "projects of a lower priority do not preempt machines")
+	code = allocate(false, "invalid", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "the priority of the project must be a number")
+
+	setState(metal.MaintenanceState)
+	code = allocate(false, "5", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "machines in maintenance are not preempted")
+	setState(metal.AvailableState)
+	assert.Empty(t, notices)
+
+	// another allocation which is queued first must not take the preempted machine
+	rival := v1.NewPendingAllocation(v1.PendingAllocationCreateRequest{Template: template, Priority: 100, Deadline: time.Now().Add(time.Hour)}, testEmail)
+	require.NoError(t, ds.CreatePendingAllocation(rival))
+
+	var pending v1.PendingAllocationResponse
+	code = allocate(false, "5", &pending)
+	require.Equal(t, http.StatusAccepted, code, "preemptible machines are preempted by the other allocations of the same priority")
+	assert.Equal(t, "pending", pending.State)
+	assert.Equal(t, 5, pending.Priority)
+	require.NotNil(t, pending.Preempted)
+	assert.Equal(t, "m0", *pending.Preempted)
+	require.Len(t, notices, 1)
+	assert.Equal(t, "m0", notices[0].OldMachineID)
+
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation)
+	assert.True(t, m.Allocation.Preempted)
+
+	code = allocate(false, "20", nil)
+	assert.Equal(t, http.StatusUnprocessableEntity, code, "a preempted machine is not claimed twice")
+	require.Len(t, notices, 1)
+
+	// the priority is resolved again when the machine is handed to the allocation
+	project.Meta.Annotations[allocationPriorityAnnotation] = "5"
+	queue, err := NewPendingAllocationQueue(log, ds, pub, bus.DirectEndpoints(), ipamer, mdc, nil)
+	require.NoError(t, err)
+	require.NoError(t, queue.Process())
+
+	p, err := ds.FindPendingAllocation(pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStatePending, p.State, "the allocation waits until the preempted machine is freed")
+
+	require.NoError(t, queue.reclaimPreemptedMachines(context.Background()))
+
+	ec, err := ds.FindProvisioningEventContainer("m0")
+	require.NoError(t, err)
+	require.NotEmpty(t, ec.Events)
+	assert.Equal(t, metal.ProvisioningEventMachineReclaim, ec.Events[len(ec.Events)-1].Event)
+
+	p, err = ds.FindPendingAllocation(pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStateFulfilled, p.State, "the freed machine is handed to the allocation which preempted it")
+	assert.Equal(t, "m0", p.MachineID)
+
+	p, err = ds.FindPendingAllocation(rival.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStatePending, p.State)
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation)
+	assert.False(t, m.Allocation.Preemptible)
+	assert.False(t, m.Allocation.Preempted)
+	assert.Equal(t, 5, m.Allocation.Priority)
+}
+
+func TestReclaimPreemptedMachineResetsPreemptionOnFailure(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, mdc := setupAllocation(t, 1)
+
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	allocated := *m
+	allocated.Allocation = &metal.MachineAllocation{
+		Project:            "pr1",
+		Preemptible:        true,
+		Preempted:          true,
+		PreemptionDeadline: time.Now().Add(-time.Second),
+	}
+	require.NoError(t, ds.UpdateMachine(m, &allocated))
+
+	p := v1.NewPendingAllocation(v1.PendingAllocationCreateRequest{
+		Template: v1.MachineAllocateRequest{SizeID: "s1", PartitionID: "p1", ProjectID: "pr1", ImageID: "i-1.0.0", Networks: v1.MachineAllocationNetworks{{NetworkID: "private"}}},
+		Deadline: time.Now().Add(time.Hour),
+	}, testEmail)
+	p.PreemptedMachineID = "m0"
+	require.NoError(t, ds.CreatePendingAllocation(p))
+
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		return errors.New("bus is down")
+	}}
+	queue, err := NewPendingAllocationQueue(log, ds, pub, bus.DirectEndpoints(), ipamer, mdc, nil)
+	require.NoError(t, err)
+	require.NoError(t, queue.reclaimPreemptedMachines(context.Background()))
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	require.NotNil(t, m.Allocation, "the machine could not be freed")
+	assert.False(t, m.Allocation.Preempted, "the machine can be preempted again")
+	assert.True(t, m.Allocation.PreemptionDeadline.IsZero())
+
+	require.NoError(t, queue.Process())
+
+	p, err = ds.FindPendingAllocation(p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStatePending, p.State)
+	assert.Empty(t, p.PreemptedMachineID, "the allocation is queued like any other allocation")
+}
+
+func TestMachineMaintenance(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 2)
+
+	var drains []*metal.MachineDrainEvent
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if topic == metal.TopicDrain.Name {
+			drains = append(drains, data.(*metal.MachineDrainEvent))
+		}
+		return nil
+	}}
+
+	spec, err := createMachineAllocationSpec(ds, v1.MachineAllocateRequest{
+		UUID:        pointer.Pointer("m0"),
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}, metal.RoleMachine, &security.User{EMail: testEmail})
+	require.NoError(t, err)
+	_, err = allocateMachine(log, ds, ipamer, spec, mdc, actor, pub)
+	require.NoError(t, err)
+
+	ws, err := NewMachine(log, ds, pub, bus.DirectEndpoints(), ipamer, mdc, nil, mockUserGetter{&security.User{EMail: testEmail}}, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	setState := func(id string, state v1.MachineState) int {
+		js, err := json.Marshal(state)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/"+id+"/state", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	end := time.Now().Add(time.Hour)
+	window := &v1.MachineMaintenanceWindow{End: &end}
+
+	require.Equal(t, http.StatusBadRequest, setState("m0", v1.MachineState{Value: string(metal.ReservedState), Description: "disk replacement", MaintenanceWindow: window}))
+	past := time.Now().Add(-time.Minute)
+	require.Equal(t, http.StatusBadRequest, setState("m0", v1.MachineState{Value: string(metal.MaintenanceState), Description: "disk replacement", MaintenanceWindow: &v1.MachineMaintenanceWindow{End: &past}}))
+	assert.Empty(t, drains)
+
+	for _, id := range []string{"m0", "m1"} {
+		require.Equal(t, http.StatusOK, setState(id, v1.MachineState{Value: string(metal.MaintenanceState), Description: "disk replacement", MaintenanceWindow: window}))
+	}
+
+	require.Len(t, drains, 1, "only allocated machines are drained")
+	assert.Equal(t, "m0", drains[0].MachineID)
+	assert.Equal(t, "pr1", drains[0].ProjectID)
+	assert.Equal(t, "disk replacement", drains[0].Reason)
+
+	_, err = ds.FindWaitingMachine("pr1", "p1", "s1", nil, metal.MachineAffinity{}, nil)
+	require.ErrorIs(t, err, datastore.ErrNoMachineAvailable, "machines in maintenance are not allocated")
+
+	// the window of m1 ends
+	m, err := ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	ended := *m
+	ended.State.MaintenanceWindow = &metal.MaintenanceWindow{End: &past}
+	require.NoError(t, ds.UpdateMachine(m, &ended))
+
+	require.NoError(t, ApplyMachineMaintenanceWindows(ds, log))
+
+	m, err = ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	assert.Equal(t, metal.AvailableState, m.State.Value)
+	assert.Empty(t, m.State.Issuer, "the machine is made available by the system")
+	assert.Nil(t, m.State.MaintenanceWindow)
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	assert.Equal(t, metal.MaintenanceState, m.State.Value)
+	assert.Equal(t, testEmail, m.State.Issuer)
+
+	// a maintenance which starts in the future is scheduled, the machine keeps its state until then
+	start := time.Now().Add(time.Hour)
+	end = start.Add(time.Hour)
+	require.Equal(t, http.StatusOK, setState("m1", v1.MachineState{Value: string(metal.MaintenanceState), Description: "firmware update", MaintenanceWindow: &v1.MachineMaintenanceWindow{Start: &start, End: &end}}))
+
+	require.NoError(t, ApplyMachineMaintenanceWindows(ds, log))
+
+	m, err = ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	assert.Equal(t, metal.AvailableState, m.State.Value)
+	require.NotNil(t, m.State.ScheduledMaintenance)
+	assert.Equal(t, "firmware update", m.State.ScheduledMaintenance.Description)
+
+	// the window of m1 starts
+	started := *m
+	scheduled := *m.State.ScheduledMaintenance
+	scheduled.Window.Start = &past
+	started.State.ScheduledMaintenance = &scheduled
+	require.NoError(t, ds.UpdateMachine(m, &started))
+
+	require.NoError(t, ApplyMachineMaintenanceWindows(ds, log))
+
+	m, err = ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	assert.Equal(t, metal.MaintenanceState, m.State.Value)
+	assert.Equal(t, "firmware update", m.State.Description)
+	assert.Equal(t, testEmail, m.State.Issuer)
+	assert.Nil(t, m.State.ScheduledMaintenance)
+	require.NotNil(t, m.State.MaintenanceWindow)
+	assert.WithinDuration(t, end, *m.State.MaintenanceWindow.End, time.Second)
+
+	// the state was changed already, so a failing drain event does not fail the request
+	pub.doPublish = func(topic string, data interface{}) error {
+		return errors.New("bus is down")
+	}
+	require.Equal(t, http.StatusOK, setState("m0", v1.MachineState{Value: string(metal.MaintenanceState), Description: "cooling"}))
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	assert.Equal(t, "cooling", m.State.Description)
+}
+
+func TestTransferMachine(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, _ := setupAllocation(t, 3)
+
+	psc := &mdmv1mock.ProjectServiceClient{}
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr1"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{}}, nil)
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr2"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{}}, nil)
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr3"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{
+		Quotas: &mdmv1.QuotaSet{Machine: &mdmv1.Quota{Quota: wrapperspb.Int32(1)}},
+	}}, nil)
+	mdc := mdm.NewMock(psc, nil)
+
+	super, err := metal.NewPrefixFromCIDR("10.0.0.0/20")
+	require.NoError(t, err)
+	private, err := ipamer.AllocateChildPrefix(*super, 22)
+	require.NoError(t, err)
+	require.NoError(t, ds.CreateNetwork(&metal.Network{Base: metal.Base{ID: "private2"}, ParentNetworkID: "super", ProjectID: "pr2", PartitionID: "p1", Prefixes: metal.Prefixes{*private}, Vrf: 42}))
+	require.NoError(t, ds.CreateSwitch(&metal.Switch{
+		Base:               metal.Base{ID: "sw1"},
+		Nics:               metal.Nics{{Name: "swp1", MacAddress: "aa:aa:aa:aa:aa:aa"}},
+		MachineConnections: metal.ConnectionMap{"m0": {{MachineID: "m0", Nic: metal.Nic{Name: "swp1", MacAddress: "aa:aa:aa:aa:aa:aa"}}}},
+	}))
+
+	for _, id := range []string{"m0", "m1"} {
+		spec, err := createMachineAllocationSpec(ds, v1.MachineAllocateRequest{
+			UUID:        pointer.Pointer(id),
+			PartitionID: "p1",
+			ProjectID:   "pr1",
+			ImageID:     "i-1.0.0",
+			Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+		}, metal.RoleMachine, &security.User{EMail: testEmail})
+		require.NoError(t, err)
+		_, err = allocateMachine(log, ds, ipamer, spec, mdc, actor, &emptyPublisher{})
+		require.NoError(t, err)
+	}
+
+	// m0 is installed already
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	installed := *m
+	allocation := *m.Allocation
+	installed.Allocation = &allocation
+	installed.Allocation.Succeeded = true
+	require.NoError(t, ds.UpdateMachine(m, &installed))
+	formerIP := m.Allocation.MachineNetworks[0].IPs[0]
+
+	// m2 uses up the quota of pr3
+	m, err = ds.FindMachineByID("m2")
+	require.NoError(t, err)
+	used := *m
+	used.Allocation = &metal.MachineAllocation{Project: "pr3", Role: metal.RoleMachine}
+	require.NoError(t, ds.UpdateMachine(m, &used))
+
+	ws, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil, mockUserGetter{&security.User{EMail: testEmail}}, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	transfer := func(id string, request v1.MachineTransferRequest) (int, *v1.MachineResponse) {
+		js, err := json.Marshal(request)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/"+id+"/transfer", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			return w.Code, nil
+		}
+		var resp v1.MachineResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return w.Code, &resp
+	}
+
+	code, _ := transfer("m0", v1.MachineTransferRequest{ProjectID: "pr3"})
+	assert.Equal(t, http.StatusBadRequest, code, "the quota of the target project is exceeded")
+	code, _ = transfer("m0", v1.MachineTransferRequest{ProjectID: "pr2", PrivateNetworkID: pointer.Pointer("private")})
+	assert.Equal(t, http.StatusBadRequest, code, "the private network does not belong to the target project")
+	code, _ = transfer("m0", v1.MachineTransferRequest{ProjectID: "pr2"})
+	assert.Equal(t, http.StatusConflict, code, "the private network is used by m1 as well")
+
+	code, resp := transfer("m0", v1.MachineTransferRequest{ProjectID: "pr2", PrivateNetworkID: pointer.Pointer("private2")})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "pr2", resp.Allocation.Project)
+	require.Len(t, resp.Allocation.MachineNetworks, 1)
+	assert.Equal(t, "private2", resp.Allocation.MachineNetworks[0].NetworkID)
+	require.Len(t, resp.Allocation.MachineNetworks[0].IPs, 1)
+
+	ip, err := ds.FindIPByID(resp.Allocation.MachineNetworks[0].IPs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "pr2", ip.ProjectID)
+	assert.True(t, ip.HasMachineId("m0"))
+	// ips are released asynchronously
+	assert.Eventually(t, func() bool {
+		_, err := ds.FindIPByID(formerIP)
+		return metal.IsNotFound(err)
+	}, 5*time.Second, 50*time.Millisecond, "the ephemeral ip of the former private network is released")
+
+	sw, err := ds.FindSwitch("sw1")
+	require.NoError(t, err)
+	assert.Equal(t, "vrf42", sw.Nics[0].Vrf)
+
+	// m1 is the only machine in the private network now, so the network is transferred along
+	code, resp = transfer("m1", v1.MachineTransferRequest{ProjectID: "pr2"})
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "private", resp.Allocation.MachineNetworks[0].NetworkID)
+
+	network, err := ds.FindNetworkByID("private")
+	require.NoError(t, err)
+	assert.Equal(t, "pr2", network.ProjectID)
+	ip, err = ds.FindIPByID(resp.Allocation.MachineNetworks[0].IPs[0])
+	require.NoError(t, err)
+	assert.Equal(t, "pr2", ip.ProjectID)
+}
+
+func TestReinstallMachineWithPatchUpgrade(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, _ := setupAllocation(t, 2)
+
+	psc := &mdmv1mock.ProjectServiceClient{}
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr1"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{}}, nil)
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr2"}).Return(&mdmv1.ProjectResponse{Project: &mdmv1.Project{
+		Meta: &mdmv1.Meta{Id: "pr2", Annotations: map[string]string{imagePatchUpgradeAnnotation: "true"}},
+	}}, nil)
+	psc.On("Get", context.Background(), &mdmv1.ProjectGetRequest{Id: "pr3"}).Return(nil, errors.New("masterdata is unavailable"))
+	mdc := mdm.NewMock(psc, nil)
+
+	for _, id := range []string{"m0", "m1"} {
+		spec, err := createMachineAllocationSpec(ds, v1.MachineAllocateRequest{
+			UUID:        pointer.Pointer(id),
+			PartitionID: "p1",
+			ProjectID:   "pr1",
+			ImageID:     "i-1.0.0",
+			Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+		}, metal.RoleMachine, &security.User{EMail: testEmail})
+		require.NoError(t, err)
+		m, err := allocateMachine(log, ds, ipamer, spec, mdc, actor, &emptyPublisher{})
+		require.NoError(t, err)
+
+		reinstallable := *m
+		allocation := *m.Allocation
+		allocation.FilesystemLayout = &metal.FilesystemLayout{
+			Base:        metal.Base{ID: "fsl1"},
+			Disks:       []metal.Disk{{Device: "/dev/sda", WipeOnReinstall: true}},
+			Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{"s1"}, Images: map[string]string{"i": "*"}},
+		}
+		if id == "m1" {
+			allocation.Project = "pr2"
+		}
+		reinstallable.Allocation = &allocation
+		require.NoError(t, ds.UpdateMachine(m, &reinstallable))
+	}
+
+	for _, img := range []struct {
+		version        string
+		classification metal.VersionClassification
+	}{
+		{version: "1.0.1", classification: metal.ClassificationDeprecated},
+		{version: "1.0.2", classification: metal.ClassificationSupported},
+		{version: "1.0.3", classification: metal.ClassificationSupported},
+		{version: "1.0.4", classification: metal.ClassificationPreview},
+		{version: "1.1.1", classification: metal.ClassificationSupported},
+	} {
+		require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "i-" + img.version}, OS: "i", Version: img.version, Classification: img.classification, Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+	}
+	// the newest supported patch version is not permitted for the size
+	require.NoError(t, ds.CreateSizeImageConstraint(&metal.SizeImageConstraint{Base: metal.Base{ID: "s1"}, Images: map[string]string{"i": "< 1.0.3"}}))
+
+	ws, err := NewMachine(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil, mockUserGetter{&security.User{EMail: testEmail}}, 0, nil, metal.DisabledIPMISuperUser(), 0)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(ws)
+
+	reinstall := func(id string, patchUpgrade *bool) (int, string) {
+		js, err := json.Marshal(v1.MachineReinstallRequest{ImageID: "i-1.0.1", PatchUpgrade: patchUpgrade})
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", "/v1/machine/"+id+"/reinstall", bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			return w.Code, ""
+		}
+		var result v1.MachineResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&result))
+		require.NotNil(t, result.Allocation.Image)
+		return w.Code, result.Allocation.Image.ID
+	}
+
+	code, image := reinstall("m0", pointer.Pointer(true))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "i-1.0.2", image)
+
+	code, image = reinstall("m0", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "i-1.0.1", image, "patch upgrades are disabled for the project")
+
+	code, image = reinstall("m1", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "i-1.0.2", image, "patch upgrades are enabled for the project")
+
+	code, image = reinstall("m1", pointer.Pointer(false))
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "i-1.0.1", image)
+
+	m, err := ds.FindMachineByID("m1")
+	require.NoError(t, err)
+	upgradeable := *m
+	allocation := *m.Allocation
+	allocation.FilesystemLayout = &metal.FilesystemLayout{
+		Base:        metal.Base{ID: "fsl1"},
+		Disks:       []metal.Disk{{Device: "/dev/sda", WipeOnReinstall: true}},
+		Constraints: metal.FilesystemLayoutConstraints{Sizes: []string{"s1"}, Images: map[string]string{"i": "< 1.0.2"}},
+	}
+	upgradeable.Allocation = &allocation
+	require.NoError(t, ds.UpdateMachine(m, &upgradeable))
+
+	code, _ = reinstall("m1", nil)
+	assert.Equal(t, http.StatusBadRequest, code, "no supported patch version is compatible with the filesystem layout")
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	unknown := *m
+	allocation = *m.Allocation
+	allocation.Project = "pr3"
+	unknown.Allocation = &allocation
+	require.NoError(t, ds.UpdateMachine(m, &unknown))
+
+	code, image = reinstall("m0", nil)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "i-1.0.1", image, "patch upgrades are disabled if the project cannot be looked up")
+}
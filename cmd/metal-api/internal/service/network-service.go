@@ -29,7 +29,7 @@ type networkResource struct {
 }
 
 // NewNetwork returns a webservice for network specific endpoints.
-func NewNetwork(log *zap.SugaredLogger, ds *datastore.RethinkStore, ipamer ipam.IPAMer, mdc mdm.Client) *restful.WebService {
+func NewNetwork(log *zap.SugaredLogger, ds datastore.Store, ipamer ipam.IPAMer, mdc mdm.Client) *restful.WebService {
 	r := networkResource{
 		webResource: webResource{
 			log: log,
@@ -78,7 +78,7 @@ func (r *networkResource) webService() *restful.WebService {
 		Metadata(auditing.Exclude, true).
 		Reads(v1.NetworkFindRequest{}).
 		Writes([]v1.NetworkResponse{}).
-		Returns(http.StatusOK, "OK", []v1.NetworkResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.NetworkResponse{}, nextCursorHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.DELETE("/{id}").
@@ -86,9 +86,11 @@ func (r *networkResource) webService() *restful.WebService {
 		Operation("deleteNetwork").
 		Doc("deletes a network and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the network").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.NetworkResponse{}).
 		Returns(http.StatusOK, "OK", v1.NetworkResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -105,10 +107,12 @@ func (r *networkResource) webService() *restful.WebService {
 		To(admin(r.updateNetwork)).
 		Operation("updateNetwork").
 		Doc("updates a network. if the network was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.NetworkUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.NetworkResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/allocate").
@@ -125,11 +129,13 @@ func (r *networkResource) webService() *restful.WebService {
 		To(editor(r.freeNetwork)).
 		Operation("freeNetworkDeprecated").
 		Doc("free a network").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Consumes(restful.MIME_JSON, "*/*").
 		Param(ws.PathParameter("id", "identifier of the network").DataType("string")).
 		Returns(http.StatusOK, "OK", v1.NetworkResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}).
 		Deprecate())
 
@@ -137,10 +143,12 @@ func (r *networkResource) webService() *restful.WebService {
 		To(editor(r.freeNetwork)).
 		Operation("freeNetwork").
 		Doc("free a network").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Param(ws.PathParameter("id", "identifier of the network").DataType("string")).
 		Returns(http.StatusOK, "OK", v1.NetworkResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	return ws
@@ -149,7 +157,7 @@ func (r *networkResource) webService() *restful.WebService {
 func (r *networkResource) findNetwork(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	nw, err := r.ds.FindNetworkByID(id)
+	nw, err := r.store(request).FindNetworkByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -157,11 +165,12 @@ func (r *networkResource) findNetwork(request *restful.Request, response *restfu
 
 	usage := getNetworkUsage(nw, r.ipamer)
 
+	setEntityTag(response, nw)
 	r.send(request, response, http.StatusOK, v1.NewNetworkResponse(nw, usage))
 }
 
 func (r *networkResource) listNetworks(request *restful.Request, response *restful.Response) {
-	nws, err := r.ds.ListNetworks()
+	nws, err := r.store(request).ListNetworks()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -185,11 +194,18 @@ func (r *networkResource) findNetworks(request *restful.Request, response *restf
 	}
 
 	var nws metal.Networks
-	err = r.ds.SearchNetworks(&requestPayload, &nws)
+	err = r.store(request).SearchNetworks(&requestPayload, &nws)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	next, err := requestPayload.NextCursor(nws)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
+	setNextCursor(response, next)
 
 	result := []*v1.NetworkResponse{}
 	for i := range nws {
@@ -278,7 +294,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 		destPrefixes = append(destPrefixes, *prefix)
 	}
 
-	allNws, err := r.ds.ListNetworks()
+	allNws, err := r.store(request).ListNetworks()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -313,7 +329,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 
 	var partitionID string
 	if requestPayload.PartitionID != nil {
-		partition, err := r.ds.FindPartition(*requestPayload.PartitionID)
+		partition, err := r.store(request).FindPartition(*requestPayload.PartitionID)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -321,7 +337,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 
 		if privateSuper {
 			boolTrue := true
-			err := r.ds.FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, PrivateSuper: &boolTrue}, &metal.Network{})
+			err := r.store(request).FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, PrivateSuper: &boolTrue}, &metal.Network{})
 			if err != nil {
 				if !metal.IsNotFound(err) {
 					r.sendError(request, response, defaultError(err))
@@ -334,7 +350,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 		}
 		if underlay {
 			boolTrue := true
-			err := r.ds.FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, Underlay: &boolTrue}, &metal.Network{})
+			err := r.store(request).FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, Underlay: &boolTrue}, &metal.Network{})
 			if err != nil {
 				if !metal.IsNotFound(err) {
 					r.sendError(request, response, defaultError(err))
@@ -354,7 +370,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 	}
 
 	if vrf != 0 {
-		err = acquireVRF(r.ds, vrf)
+		err = acquireVRF(r.store(request), vrf)
 		if err != nil {
 			if !metal.IsConflict(err) {
 				r.sendError(request, response, defaultError(fmt.Errorf("could not acquire vrf: %w", err)))
@@ -392,7 +408,7 @@ func (r *networkResource) createNetwork(request *restful.Request, response *rest
 		}
 	}
 
-	err = r.ds.CreateNetwork(nw)
+	err = r.store(request).CreateNetwork(nw)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -451,7 +467,7 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 		return
 	}
 
-	partition, err := r.ds.FindPartition(partitionID)
+	partition, err := r.store(request).FindPartition(partitionID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -459,7 +475,7 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 
 	var superNetwork metal.Network
 	boolTrue := true
-	err = r.ds.FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, PrivateSuper: &boolTrue}, &superNetwork)
+	err = r.store(request).FindNetwork(&datastore.NetworkSearchQuery{PartitionID: &partition.ID, PrivateSuper: &boolTrue}, &superNetwork)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -489,7 +505,7 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 		Nat:                 nat,
 	}
 
-	nw, err := createChildNetwork(r.ds, r.ipamer, nwSpec, &superNetwork, partition.PrivateNetworkPrefixLength)
+	nw, err := createChildNetwork(r.store(request), r.ipamer, nwSpec, &superNetwork, partition.PrivateNetworkPrefixLength)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -500,7 +516,7 @@ func (r *networkResource) allocateNetwork(request *restful.Request, response *re
 	r.send(request, response, http.StatusCreated, v1.NewNetworkResponse(nw, usage))
 }
 
-func createChildNetwork(ds *datastore.RethinkStore, ipamer ipam.IPAMer, nwSpec *metal.Network, parent *metal.Network, childLength uint8) (*metal.Network, error) {
+func createChildNetwork(ds datastore.Store, ipamer ipam.IPAMer, nwSpec *metal.Network, parent *metal.Network, childLength uint8) (*metal.Network, error) {
 	vrf, err := acquireRandomVRF(ds)
 	if err != nil {
 		return nil, fmt.Errorf("could not acquire a vrf: %w", err)
@@ -544,12 +560,17 @@ func createChildNetwork(ds *datastore.RethinkStore, ipamer ipam.IPAMer, nwSpec *
 func (r *networkResource) freeNetwork(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	nw, err := r.ds.FindNetworkByID(id)
+	nw, err := r.store(request).FindNetworkByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, nw); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	for _, prefix := range nw.Prefixes {
 		usage, err := r.ipamer.PrefixUsage(prefix.String())
 		if err != nil {
@@ -574,14 +595,14 @@ func (r *networkResource) freeNetwork(request *restful.Request, response *restfu
 	}
 
 	if nw.Vrf != 0 {
-		err = releaseVRF(r.ds, nw.Vrf)
+		err = releaseVRF(r.store(request), nw.Vrf)
 		if err != nil {
 			r.sendError(request, response, defaultError(fmt.Errorf("could not release vrf: %w", err)))
 			return
 		}
 	}
 
-	err = r.ds.DeleteNetwork(nw)
+	err = r.store(request).DeleteNetwork(nw)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -598,12 +619,17 @@ func (r *networkResource) updateNetwork(request *restful.Request, response *rest
 		return
 	}
 
-	oldNetwork, err := r.ds.FindNetworkByID(requestPayload.ID)
+	oldNetwork, err := r.store(request).FindNetworkByID(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldNetwork); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newNetwork := *oldNetwork
 
 	if requestPayload.Name != nil {
@@ -637,7 +663,7 @@ func (r *networkResource) updateNetwork(request *restful.Request, response *rest
 		prefixesToBeRemoved = oldNetwork.SubstractPrefixes(newNetwork.Prefixes...)
 
 		// now validate if there are ips which have a prefix to be removed as a parent
-		allIPs, err := r.ds.ListIPs()
+		allIPs, err := r.store(request).ListIPs()
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -676,7 +702,7 @@ func (r *networkResource) updateNetwork(request *restful.Request, response *rest
 		}
 	}
 
-	err = r.ds.UpdateNetwork(oldNetwork, &newNetwork)
+	err = r.store(request).UpdateNetwork(oldNetwork, &newNetwork)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -684,6 +710,7 @@ func (r *networkResource) updateNetwork(request *restful.Request, response *rest
 
 	usage := getNetworkUsage(&newNetwork, r.ipamer)
 
+	setEntityTag(response, &newNetwork)
 	r.send(request, response, http.StatusOK, v1.NewNetworkResponse(&newNetwork, usage))
 }
 
@@ -702,14 +729,19 @@ func prefixesFromCidr(PrefixesCidr []string) (metal.Prefixes, error) {
 func (r *networkResource) deleteNetwork(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	nw, err := r.ds.FindNetworkByID(id)
+	nw, err := r.store(request).FindNetworkByID(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, nw); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	var children metal.Networks
-	err = r.ds.SearchNetworks(&datastore.NetworkSearchQuery{ParentNetworkID: &nw.ID}, &children)
+	err = r.store(request).SearchNetworks(&datastore.NetworkSearchQuery{ParentNetworkID: &nw.ID}, &children)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -722,7 +754,7 @@ func (r *networkResource) deleteNetwork(request *restful.Request, response *rest
 		}
 	}
 
-	allIPs, err := r.ds.ListIPs()
+	allIPs, err := r.store(request).ListIPs()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -743,14 +775,14 @@ func (r *networkResource) deleteNetwork(request *restful.Request, response *rest
 	}
 
 	if nw.Vrf != 0 {
-		err = releaseVRF(r.ds, nw.Vrf)
+		err = releaseVRF(r.store(request), nw.Vrf)
 		if err != nil {
 			r.sendError(request, response, defaultError(fmt.Errorf("could not release vrf: %w", err)))
 			return
 		}
 	}
 
-	err = r.ds.DeleteNetwork(nw)
+	err = r.store(request).DeleteNetwork(nw)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
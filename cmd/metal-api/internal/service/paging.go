@@ -0,0 +1,24 @@
+package service
+
+import (
+	restful "github.com/emicklei/go-restful/v3"
+)
+
+const nextCursorHeader = "X-Next-Cursor"
+
+// nextCursorHeaders documents the cursor header of find routes which support paging.
+var nextCursorHeaders = map[string]restful.Header{
+	nextCursorHeader: {
+		Items: &restful.Items{
+			Type: "string",
+		},
+		Description: "the cursor to pass as next in the search for the following page, it is only returned if the search was limited and further entities may exist",
+	},
+}
+
+// setNextCursor adds the cursor for the next page of a search to the response.
+func setNextCursor(response *restful.Response, next *string) {
+	if next != nil {
+		response.AddHeader(nextCursorHeader, *next)
+	}
+}
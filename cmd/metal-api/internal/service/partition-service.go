@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/issues"
@@ -30,7 +31,7 @@ type partitionResource struct {
 }
 
 // NewPartition returns a webservice for partition specific endpoints.
-func NewPartition(log *zap.SugaredLogger, ds *datastore.RethinkStore, tc TopicCreator) *restful.WebService {
+func NewPartition(log *zap.SugaredLogger, ds datastore.Store, tc TopicCreator) *restful.WebService {
 	r := partitionResource{
 		webResource: webResource{
 			log: log,
@@ -75,9 +76,11 @@ func (r *partitionResource) webService() *restful.WebService {
 		Operation("deletePartition").
 		Doc("deletes a Partition and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the Partition").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.PartitionResponse{}).
 		Returns(http.StatusOK, "OK", v1.PartitionResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -94,10 +97,12 @@ func (r *partitionResource) webService() *restful.WebService {
 		To(admin(r.updatePartition)).
 		Operation("updatePartition").
 		Doc("updates a Partition. if the Partition was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.PartitionUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.PartitionResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/capacity").
@@ -111,23 +116,46 @@ func (r *partitionResource) webService() *restful.WebService {
 		Returns(http.StatusOK, "OK", []v1.PartitionCapacity{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
+	ws.Route(ws.POST("/power-consumption").
+		To(r.partitionPowerConsumption).
+		Operation("partitionPowerConsumption").
+		Doc("get the current power consumption of the machines by partition, rack, size and project, machines whose power metric is outdated are listed as stale instead of being counted").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Metadata(auditing.Exclude, true).
+		Reads(v1.PowerConsumptionRequest{}).
+		Writes([]v1.PartitionPowerConsumption{}).
+		Returns(http.StatusOK, "OK", []v1.PartitionPowerConsumption{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/energy-usage").
+		To(r.partitionEnergyUsage).
+		Operation("partitionEnergyUsage").
+		Doc("get the energy used by the machines within a period by partition, size and project, calculated from the recorded power consumption").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Metadata(auditing.Exclude, true).
+		Reads(v1.EnergyUsageRequest{}).
+		Writes([]v1.EnergyUsage{}).
+		Returns(http.StatusOK, "OK", []v1.EnergyUsage{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
 	return ws
 }
 
 func (r *partitionResource) findPartition(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	p, err := r.ds.FindPartition(id)
+	p, err := r.store(request).FindPartition(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, p)
 	r.send(request, response, http.StatusOK, v1.NewPartitionResponse(p))
 }
 
 func (r *partitionResource) listPartitions(request *restful.Request, response *restful.Response) {
-	ps, err := r.ds.ListPartitions()
+	ps, err := r.store(request).ListPartitions()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -201,6 +229,26 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 		commandLine = *requestPayload.PartitionBootConfiguration.CommandLine
 	}
 
+	var placementStrategy metal.PlacementStrategy
+	if requestPayload.PlacementStrategy != nil {
+		placementStrategy = v1.NewPlacementStrategy(*requestPayload.PlacementStrategy)
+		err = placementStrategy.Validate()
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+
+	var powerPolicies metal.PowerPolicies
+	if requestPayload.PowerPolicies != nil {
+		powerPolicies = v1.NewPowerPolicies(requestPayload.PowerPolicies)
+		err = powerPolicies.Validate()
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+	}
+
 	p := &metal.Partition{
 		Base: metal.Base{
 			ID:          requestPayload.ID,
@@ -214,6 +262,8 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 			KernelURL:   kernelURL,
 			CommandLine: commandLine,
 		},
+		PlacementStrategy: placementStrategy,
+		PowerPolicies:     powerPolicies,
 	}
 
 	fqn := metal.TopicMachine.GetFQN(p.GetID())
@@ -222,7 +272,7 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 		return
 	}
 
-	err = r.ds.CreatePartition(p)
+	err = r.store(request).CreatePartition(p)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -234,13 +284,18 @@ func (r *partitionResource) createPartition(request *restful.Request, response *
 func (r *partitionResource) deletePartition(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	p, err := r.ds.FindPartition(id)
+	p, err := r.store(request).FindPartition(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = r.ds.DeletePartition(p)
+	if httperr := checkIfMatch(request, p); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeletePartition(p)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -257,12 +312,17 @@ func (r *partitionResource) updatePartition(request *restful.Request, response *
 		return
 	}
 
-	oldPartition, err := r.ds.FindPartition(requestPayload.ID)
+	oldPartition, err := r.store(request).FindPartition(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldPartition); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newPartition := *oldPartition
 
 	if requestPayload.Name != nil {
@@ -295,13 +355,32 @@ func (r *partitionResource) updatePartition(request *restful.Request, response *
 	if requestPayload.PartitionBootConfiguration.CommandLine != nil {
 		newPartition.BootConfiguration.CommandLine = *requestPayload.PartitionBootConfiguration.CommandLine
 	}
+	if requestPayload.PlacementStrategy != nil {
+		placementStrategy := v1.NewPlacementStrategy(*requestPayload.PlacementStrategy)
+		err = placementStrategy.Validate()
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+		newPartition.PlacementStrategy = placementStrategy
+	}
+	if requestPayload.PowerPolicies != nil {
+		powerPolicies := v1.NewPowerPolicies(requestPayload.PowerPolicies)
+		err = powerPolicies.Validate()
+		if err != nil {
+			r.sendError(request, response, httperrors.BadRequest(err))
+			return
+		}
+		newPartition.PowerPolicies = powerPolicies
+	}
 
-	err = r.ds.UpdatePartition(oldPartition, &newPartition)
+	err = r.store(request).UpdatePartition(oldPartition, &newPartition)
 	if err != nil {
 		r.sendError(request, response, httperrors.BadRequest(err))
 		return
 	}
 
+	setEntityTag(response, &newPartition)
 	r.send(request, response, http.StatusOK, v1.NewPartitionResponse(&newPartition))
 }
 
@@ -322,6 +401,40 @@ func (r *partitionResource) partitionCapacity(request *restful.Request, response
 	r.send(request, response, http.StatusOK, partitionCapacities)
 }
 
+func (r *partitionResource) partitionPowerConsumption(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.PowerConsumptionRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	result, err := calcPowerConsumption(r.store(request), &requestPayload)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *partitionResource) partitionEnergyUsage(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.EnergyUsageRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	result, err := calcEnergyUsage(r.store(request), &requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
 func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityRequest) ([]v1.PartitionCapacity, error) {
 	var (
 		ps metal.Partitions
@@ -424,12 +537,24 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 			continue
 		}
 
+		if m.State.Value == metal.MaintenanceState {
+			cap.Maintenance++
+			cap.MaintenanceMachines = append(cap.MaintenanceMachines, m.ID)
+			continue
+		}
+
 		if _, ok := machinesWithIssues[m.ID]; ok {
 			cap.Faulty++
 			cap.FaultyMachines = append(cap.FaultyMachines, m.ID)
 			continue
 		}
 
+		if m.State.Value == metal.AvailableState && m.PowerSaving {
+			cap.Free++
+			cap.PoweredOff++
+			continue
+		}
+
 		if m.State.Value == metal.AvailableState && metal.ProvisioningEventWaiting == pointer.FirstOrZero(ec.Events).Event {
 			cap.Free++
 			continue
@@ -439,6 +564,34 @@ func (r *partitionResource) calcPartitionCapacity(pcr *v1.PartitionCapacityReque
 		cap.OtherMachines = append(cap.OtherMachines, m.ID)
 	}
 
+	reservations, err := r.ds.ListReservations()
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := reservations.RemainingByID(ms, time.Now())
+	for _, rv := range reservations {
+		pc, ok := pcs[rv.PartitionID]
+		if !ok {
+			continue
+		}
+		cap := pc.ServerCapacities.FindBySize(rv.SizeID)
+		if cap == nil {
+			continue
+		}
+		cap.Reservations += remaining[rv.ID]
+	}
+
+	for _, pc := range pcs {
+		for _, cap := range pc.ServerCapacities {
+			cap.Reserved = cap.Reservations
+			if cap.Reserved > cap.Free {
+				cap.Reserved = cap.Free
+			}
+			cap.Free -= cap.Reserved
+		}
+	}
+
 	res := []v1.PartitionCapacity{}
 	for _, pc := range pcs {
 		pc := pc
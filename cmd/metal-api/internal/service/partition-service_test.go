@@ -220,6 +220,9 @@ func TestUpdatePartition(t *testing.T) {
 		PartitionBootConfiguration: &v1.PartitionBootConfiguration{
 			ImageURL: &downloadableFile,
 		},
+		PlacementStrategy: &v1.PartitionPlacementStrategy{
+			Scorers: []v1.PartitionPlacementScorer{{Name: "crash-loops", Weight: 2}},
+		},
 	}
 	js, err := json.Marshal(updateRequest)
 	require.NoError(t, err)
@@ -242,6 +245,7 @@ func TestUpdatePartition(t *testing.T) {
 	require.Equal(t, testdata.Partition2.Description, *result.Description)
 	require.Equal(t, mgmtService, *result.MgmtServiceAddress)
 	require.Equal(t, downloadableFile, *result.PartitionBootConfiguration.ImageURL)
+	require.Equal(t, []v1.PartitionPlacementScorer{{Name: "crash-loops", Weight: 2}}, result.PlacementStrategy.Scorers)
 }
 
 func TestPartitionCapacity(t *testing.T) {
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/security"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type pendingAllocationResource struct {
+	webResource
+	queue      *PendingAllocationQueue
+	userGetter security.UserGetter
+}
+
+// NewPendingAllocation returns a webservice for machine allocations which are queued until a machine becomes available.
+func NewPendingAllocation(log *zap.SugaredLogger, ds datastore.Store, queue *PendingAllocationQueue, userGetter security.UserGetter) *restful.WebService {
+	r := pendingAllocationResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+		queue:      queue,
+		userGetter: userGetter,
+	}
+	return r.webService()
+}
+
+func (r *pendingAllocationResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/pending-allocation").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"pending-allocation"}
+
+	ws.Route(ws.GET("/{id}").
+		To(viewer(r.findPendingAllocation)).
+		Operation("findPendingAllocation").
+		Doc("get pending allocation by id").
+		Param(ws.PathParameter("id", "identifier of the pending allocation").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PendingAllocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.PendingAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/").
+		To(viewer(r.listPendingAllocations)).
+		Operation("listPendingAllocations").
+		Doc("get all pending allocations in the order they are fulfilled").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.PendingAllocationResponse{}).
+		Returns(http.StatusOK, "OK", []v1.PendingAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.PUT("/").
+		To(editor(r.createPendingAllocation)).
+		Operation("createPendingAllocation").
+		Doc("queues a machine allocation which is fulfilled as soon as a machine of the requested size or with the requested hardware becomes available in the partition, the allocation is fulfilled immediately if a machine is available already").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.PendingAllocationCreateRequest{}).
+		Returns(http.StatusCreated, "Created", v1.PendingAllocationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/{id}").
+		To(editor(r.deletePendingAllocation)).
+		Operation("deletePendingAllocation").
+		Doc("cancels a pending allocation or removes an allocation which is done, the allocated machine is not freed. an allocation which is currently fulfilled can only be cancelled when its claim is stale").
+		Param(ws.PathParameter("id", "identifier of the pending allocation").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.PendingAllocationResponse{}).
+		Returns(http.StatusOK, "OK", v1.PendingAllocationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *pendingAllocationResource) findPendingAllocation(request *restful.Request, response *restful.Response) {
+	p, err := r.store(request).FindPendingAllocation(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makePendingAllocationResponse(r.store(request), p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, resp)
+}
+
+func (r *pendingAllocationResource) listPendingAllocations(request *restful.Request, response *restful.Response) {
+	ps, err := r.store(request).ListPendingAllocations()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+	ps.Sort()
+
+	positions := pendingAllocationPositions(ps)
+
+	result := []*v1.PendingAllocationResponse{}
+	for i := range ps {
+		result = append(result, v1.NewPendingAllocationResponse(&ps[i], positions[ps[i].ID]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *pendingAllocationResource) createPendingAllocation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.PendingAllocationCreateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	if requestPayload.Template.UUID != nil {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("a specific machine cannot be queued for allocation")))
+		return
+	}
+	if !requestPayload.Deadline.After(time.Now()) {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("deadline of the allocation must be in the future")))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// validates the request before it is queued
+	_, err = createMachineAllocationSpec(r.store(request), requestPayload.Template, metal.RoleMachine, user)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	p := v1.NewPendingAllocation(requestPayload, user.EMail)
+
+	err = r.store(request).CreatePendingAllocation(p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// the queue is processed in order, so the allocation is only fulfilled immediately if it does not overtake others
+	err = r.queue.Process()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	p, err = r.store(request).FindPendingAllocation(p.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makePendingAllocationResponse(r.store(request), p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, resp)
+}
+
+func (r *pendingAllocationResource) deletePendingAllocation(request *restful.Request, response *restful.Response) {
+	p, err := r.store(request).FindPendingAllocation(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if p.State == metal.PendingAllocationStateAllocating && !p.IsStale(time.Now()) {
+		r.sendError(request, response, defaultError(metal.Conflict("pending allocation %s is currently fulfilled", p.ID)))
+		return
+	}
+
+	if !p.IsDone() {
+		// a pending allocation is marked as failed first, such that it cannot be claimed by a queue anymore
+		cancelled := *p
+		cancelled.State = metal.PendingAllocationStateFailed
+		cancelled.Message = "the allocation was cancelled"
+		err = r.store(request).UpdatePendingAllocation(p, &cancelled)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		p = &cancelled
+	}
+
+	err = r.store(request).DeletePendingAllocation(p)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewPendingAllocationResponse(p, nil))
+}
+
+func makePendingAllocationResponse(ds datastore.Store, p *metal.PendingAllocation) (*v1.PendingAllocationResponse, error) {
+	ps, err := ds.ListPendingAllocations()
+	if err != nil {
+		return nil, err
+	}
+
+	return v1.NewPendingAllocationResponse(p, pendingAllocationPositions(ps)[p.ID]), nil
+}
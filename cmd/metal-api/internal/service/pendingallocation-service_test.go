@@ -0,0 +1,231 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPendingAllocations(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, mdc := setupAllocation(t, 1)
+
+	// the machine does not wait for an allocation yet
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	notWaiting := *m
+	notWaiting.Waiting = false
+	require.NoError(t, ds.UpdateMachine(m, &notWaiting))
+
+	queue, err := NewPendingAllocationQueue(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil)
+	require.NoError(t, err)
+
+	userGetter := mockUserGetter{&security.User{EMail: testEmail}}
+	container := restful.NewContainer().Add(NewPendingAllocation(log, ds, queue, userGetter))
+	call := func(method, path string, body, result any) int {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && w.Code < 300 {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	template := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	deadline := time.Now().Add(time.Hour)
+
+	specific := template
+	specific.UUID = pointer.Pointer("m0")
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/pending-allocation", v1.PendingAllocationCreateRequest{Template: specific, Deadline: deadline}, nil))
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/pending-allocation", v1.PendingAllocationCreateRequest{Template: template, Deadline: time.Now()}, nil))
+
+	var low, high v1.PendingAllocationResponse
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/pending-allocation", v1.PendingAllocationCreateRequest{Template: template, Deadline: deadline}, &low))
+	assert.Equal(t, string(metal.PendingAllocationStatePending), low.State)
+	assert.Equal(t, testEmail, low.Creator)
+	require.NotNil(t, low.Position)
+	assert.Equal(t, 1, *low.Position)
+
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/pending-allocation", v1.PendingAllocationCreateRequest{Template: template, Deadline: deadline, Priority: 10}, &high))
+	require.NotNil(t, high.Position)
+	assert.Equal(t, 1, *high.Position, "allocations with a higher priority are fulfilled first")
+
+	expired := v1.NewPendingAllocation(v1.PendingAllocationCreateRequest{Template: template, Deadline: time.Now().Add(-time.Minute)}, testEmail)
+	require.NoError(t, ds.CreatePendingAllocation(expired))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go queue.Run(ctx, time.Hour)
+
+	// the queue is processed as soon as the machine starts waiting, the watch is started asynchronously
+	assert.Eventually(t, func() bool {
+		m, err := ds.FindMachineByID("m0")
+		require.NoError(t, err)
+		if m.Allocation != nil {
+			return true
+		}
+		if !m.Waiting {
+			waiting := *m
+			waiting.Waiting = true
+			require.NoError(t, ds.UpdateMachine(m, &waiting))
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, http.StatusOK, call("GET", "/v1/pending-allocation/"+high.ID, nil, &high))
+	assert.Equal(t, string(metal.PendingAllocationStateFulfilled), high.State)
+	assert.Equal(t, pointer.Pointer("m0"), high.MachineID)
+	assert.Nil(t, high.Position)
+
+	p, err := ds.FindPendingAllocation(expired.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStateExpired, p.State)
+
+	var all []v1.PendingAllocationResponse
+	require.Equal(t, http.StatusOK, call("GET", "/v1/pending-allocation", nil, &all))
+	require.Len(t, all, 3)
+	assert.Equal(t, high.ID, all[0].ID)
+	assert.Equal(t, low.ID, all[1].ID)
+	require.NotNil(t, all[1].Position)
+	assert.Equal(t, 1, *all[1].Position)
+
+	require.Equal(t, http.StatusOK, call("DELETE", "/v1/pending-allocation/"+low.ID, nil, &low))
+	assert.Equal(t, string(metal.PendingAllocationStateFailed), low.State)
+	require.Equal(t, http.StatusNotFound, call("GET", "/v1/pending-allocation/"+low.ID, nil, nil))
+}
+
+func TestStalePendingAllocations(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, mdc := setupAllocation(t, 1)
+
+	// the machine does not wait for an allocation, so the allocations stay pending
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	notWaiting := *m
+	notWaiting.Waiting = false
+	require.NoError(t, ds.UpdateMachine(m, &notWaiting))
+
+	queue, err := NewPendingAllocationQueue(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(NewPendingAllocation(log, ds, queue, mockUserGetter{&security.User{EMail: testEmail}}))
+	deletePendingAllocation := func(id string) int {
+		req := httptest.NewRequest("DELETE", "/v1/pending-allocation/"+id, nil)
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	claimed := func(at time.Time) *metal.PendingAllocation {
+		p := v1.NewPendingAllocation(v1.PendingAllocationCreateRequest{
+			Template: v1.MachineAllocateRequest{
+				SizeID:      "s1",
+				PartitionID: "p1",
+				ProjectID:   "pr1",
+				ImageID:     "i-1.0.0",
+				Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+			},
+			Deadline: time.Now().Add(time.Hour),
+		}, testEmail)
+		p.State = metal.PendingAllocationStateAllocating
+		p.Claimed = at
+		require.NoError(t, ds.CreatePendingAllocation(p))
+		return p
+	}
+
+	fresh := claimed(time.Now())
+	stale := claimed(time.Now().Add(-time.Hour))
+
+	assert.Equal(t, http.StatusConflict, deletePendingAllocation(fresh.ID), "the allocation is currently fulfilled")
+
+	require.NoError(t, queue.Process())
+
+	p, err := ds.FindPendingAllocation(stale.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStatePending, p.State, "the stale allocation is queued again")
+	p, err = ds.FindPendingAllocation(fresh.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStateAllocating, p.State)
+
+	abandoned := claimed(time.Now().Add(-time.Hour))
+	assert.Equal(t, http.StatusOK, deletePendingAllocation(abandoned.ID), "a stale allocation can be cancelled")
+	_, err = ds.FindPendingAllocation(abandoned.ID)
+	assert.True(t, metal.IsNotFound(err))
+}
+
+func TestPendingAllocationByHardwareRequirements(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, _, mdc := setupAllocation(t, 1)
+
+	m, err := ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	notWaiting := *m
+	notWaiting.Waiting = false
+	notWaiting.Hardware = metal.MachineHardware{CPUCores: 8}
+	require.NoError(t, ds.UpdateMachine(m, &notWaiting))
+
+	queue, err := NewPendingAllocationQueue(log, ds, &emptyPublisher{}, bus.DirectEndpoints(), ipamer, mdc, nil)
+	require.NoError(t, err)
+	container := restful.NewContainer().Add(NewPendingAllocation(log, ds, queue, mockUserGetter{&security.User{EMail: testEmail}}))
+
+	js, err := json.Marshal(v1.PendingAllocationCreateRequest{
+		Template: v1.MachineAllocateRequest{
+			PartitionID:  "p1",
+			ProjectID:    "pr1",
+			ImageID:      "i-1.0.0",
+			Networks:     v1.MachineAllocationNetworks{{NetworkID: "private"}},
+			Requirements: []v1.SizeConstraint{{Type: metal.CoreConstraint, Min: 8, Max: 8}},
+		},
+		Deadline: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest("PUT", "/v1/pending-allocation", bytes.NewBuffer(js))
+	req.Header.Add("Content-Type", "application/json")
+	container = injectEditor(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var resp v1.PendingAllocationResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Equal(t, string(metal.PendingAllocationStatePending), resp.State)
+	assert.Len(t, resp.Template.Requirements, 1)
+
+	m, err = ds.FindMachineByID("m0")
+	require.NoError(t, err)
+	waiting := *m
+	waiting.Waiting = true
+	require.NoError(t, ds.UpdateMachine(m, &waiting))
+
+	require.NoError(t, queue.Process())
+
+	p, err := ds.FindPendingAllocation(resp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, metal.PendingAllocationStateFulfilled, p.State)
+	assert.Equal(t, "m0", p.MachineID)
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/headscale"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/ipam"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+	"go.uber.org/zap"
+
+	mdm "github.com/metal-stack/masterdata-api/pkg/client"
+)
+
+// PendingAllocationQueue allocates machines for the queued allocation requests as soon as machines of the
+// requested size or hardware start waiting in the partition.
+type PendingAllocationQueue struct {
+	log       *zap.SugaredLogger
+	ds        datastore.Store
+	ipamer    ipam.IPAMer
+	mdc       mdm.Client
+	actor     *asyncActor
+	publisher bus.Publisher
+	headscale *headscale.HeadscaleClient
+
+	mu sync.Mutex
+}
+
+// NewPendingAllocationQueue returns a queue which fulfills the pending allocations.
+func NewPendingAllocationQueue(log *zap.SugaredLogger, ds datastore.Store, pub bus.Publisher, ep *bus.Endpoints, ipamer ipam.IPAMer, mdc mdm.Client, headscaleClient *headscale.HeadscaleClient) (*PendingAllocationQueue, error) {
+	actor, err := newAsyncActor(log, ep, ds, ipamer)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create async actor: %w", err)
+	}
+
+	return &PendingAllocationQueue{
+		log:       log,
+		ds:        ds,
+		ipamer:    ipamer,
+		mdc:       mdc,
+		actor:     actor,
+		publisher: pub,
+		headscale: headscaleClient,
+	}, nil
+}
+
+// Run processes the queue whenever a machine starts waiting for an allocation. The queue is also processed in the
+// given interval, such that allocations expire when they pass their deadline and machine changes which were missed
+// while the watch was interrupted are caught up. Preempted machines are freed in the interval as well when their
+// grace period passed. Run returns when the context is done.
+func (q *PendingAllocationQueue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	watch := func() <-chan datastore.Change[metal.Machine] {
+		changes, err := q.ds.WatchMachines(ctx)
+		if err != nil {
+			q.log.Errorw("unable to watch machines, retrying with the next interval", "error", err)
+			return nil
+		}
+		return changes
+	}
+
+	changes := watch()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if changes == nil {
+				changes = watch()
+			}
+			err := q.reclaimPreemptedMachines(ctx)
+			if err != nil {
+				q.log.Errorw("unable to reclaim preempted machines", "error", err)
+			}
+		case c, ok := <-changes:
+			if !ok {
+				// the watch is restarted with the next interval
+				changes = nil
+				continue
+			}
+			if c.Type == datastore.ChangeTypeDelete || !isAllocatable(c.Entity) {
+				continue
+			}
+		}
+
+		err := q.Process()
+		if err != nil {
+			q.log.Errorw("unable to process pending allocations", "error", err)
+		}
+	}
+}
+
+// isAllocatable returns true if the machine waits for an allocation.
+func isAllocatable(m *metal.Machine) bool {
+	return m.Allocation == nil && m.Waiting && !m.PreAllocated && m.State.Value == metal.AvailableState
+}
+
+// Process tries to fulfill the pending allocations in their order and expires the allocations which passed
+// their deadline. Allocations whose claim is stale are queued again.
+func (q *PendingAllocationQueue) Process() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ps, err := q.ds.ListPendingAllocations()
+	if err != nil {
+		return err
+	}
+	ps.Sort()
+
+	type key struct{ project, partition, size string }
+	exhausted := map[key]bool{}
+
+	for i := range ps {
+		p := ps[i]
+		if p.IsStale(time.Now()) {
+			// the claim was interrupted before the allocation was assigned, so the allocation is queued again
+			q.log.Warnw("resetting stale pending allocation", "id", p.ID, "claimed", p.Claimed)
+			err := q.finish(&p, metal.PendingAllocationStatePending, "", "")
+			if err != nil {
+				q.log.Errorw("unable to reset stale pending allocation", "id", p.ID, "error", err)
+				continue
+			}
+		}
+		if p.State != metal.PendingAllocationStatePending {
+			continue
+		}
+
+		if !time.Now().Before(p.Deadline) {
+			err := q.finish(&p, metal.PendingAllocationStateExpired, "", "no machine became available before the deadline")
+			if err != nil {
+				q.log.Errorw("unable to expire pending allocation", "id", p.ID, "error", err)
+			}
+			continue
+		}
+
+		if p.PreemptedMachineID != "" {
+			bound, err := q.bindPreemptedMachine(&p)
+			if err != nil {
+				q.log.Errorw("unable to check preempted machine of pending allocation", "id", p.ID, "error", err)
+				continue
+			}
+			if bound {
+				continue
+			}
+		}
+
+		// the following allocations of the same project, partition and size will not find a machine either,
+		// allocations by hardware requirements are tried one by one
+		k := key{project: p.ProjectID, partition: p.PartitionID, size: p.SizeID}
+		if p.SizeID != "" && exhausted[k] {
+			continue
+		}
+
+		result, err := q.allocate(&p)
+		if err != nil {
+			q.log.Errorw("unable to process pending allocation", "id", p.ID, "error", err)
+			continue
+		}
+		if result.State == metal.PendingAllocationStatePending {
+			exhausted[k] = true
+		}
+	}
+
+	return nil
+}
+
+// bindPreemptedMachine returns true if the pending allocation waits for its preempted machine or was just handed the
+// machine. The machine is usually handed to the allocation right after it was freed, this is caught up here in case
+// the handover was interrupted. If the preemption was reset, the allocation is queued like any other allocation.
+func (q *PendingAllocationQueue) bindPreemptedMachine(p *metal.PendingAllocation) (bool, error) {
+	m, err := q.ds.FindMachineByID(p.PreemptedMachineID)
+	if err != nil && !metal.IsNotFound(err) {
+		return false, err
+	}
+
+	switch {
+	case m != nil && m.Allocation != nil && m.Allocation.Preempted:
+		return true, nil
+	case m != nil && m.Allocation == nil:
+		_, err = q.allocate(p)
+		return true, err
+	}
+
+	q.log.Infow("preemption of machine was reset, queueing pending allocation", "id", p.ID, "machineID", p.PreemptedMachineID)
+	old := *p
+	p.PreemptedMachineID = ""
+	return false, q.ds.UpdatePendingAllocation(&old, p)
+}
+
+// allocate tries to allocate a machine for the given pending allocation and returns the allocation in its new
+// state. The allocation is claimed first, such that it is not fulfilled twice by concurrent queues. An allocation
+// which preempted a machine is only fulfilled with this machine.
+func (q *PendingAllocationQueue) allocate(p *metal.PendingAllocation) (*metal.PendingAllocation, error) {
+	claimed := *p
+	claimed.State = metal.PendingAllocationStateAllocating
+	claimed.Claimed = time.Now()
+	err := q.ds.UpdatePendingAllocation(p, &claimed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to claim pending allocation: %w", err)
+	}
+
+	logger := q.log.With("pendingallocation", p.ID)
+
+	template := v1.NewPendingAllocationTemplate(&claimed)
+	if claimed.PreemptedMachineID != "" {
+		template.UUID = &claimed.PreemptedMachineID
+	}
+
+	spec, err := createMachineAllocationSpec(q.ds, template, metal.RoleMachine, &security.User{EMail: claimed.Creator})
+	if err != nil {
+		return &claimed, q.finish(&claimed, metal.PendingAllocationStateFailed, "", err.Error())
+	}
+
+	m, err := allocateMachine(logger, q.ds, q.ipamer, spec, q.mdc, q.actor, q.publisher)
+	if err != nil {
+		if errors.Is(err, datastore.ErrNoMachineAvailable) {
+			return &claimed, q.finish(&claimed, metal.PendingAllocationStatePending, "", "")
+		}
+		logger.Errorw("pending allocation failed", "error", err)
+		return &claimed, q.finish(&claimed, metal.PendingAllocationStateFailed, "", err.Error())
+	}
+
+	logger.Infow("pending allocation fulfilled", "machineID", m.ID)
+
+	return &claimed, q.finish(&claimed, metal.PendingAllocationStateFulfilled, m.ID, "")
+}
+
+// reclaimPreemptedMachines frees the preempted machines whose grace period passed and hands them to the pending
+// allocations which preempted them. A freed machine does not wait for allocations until it rebooted, so no other
+// allocation can take it in between. The claim of a machine which cannot be freed is reset, such that it can be
+// preempted again.
+func (q *PendingAllocationQueue) reclaimPreemptedMachines(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	machines, err := q.ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	ps, err := q.ds.ListPendingAllocations()
+	if err != nil {
+		return err
+	}
+
+	for i := range machines {
+		m := &machines[i]
+		if m.Allocation == nil || !m.Allocation.Preempted || time.Now().Before(m.Allocation.PreemptionDeadline) {
+			continue
+		}
+
+		logger := q.log.With("machineID", m.ID, "project", m.Allocation.Project)
+
+		old := *m
+		err := q.actor.freeMachine(ctx, q.publisher, m, q.headscale, logger)
+		if err != nil {
+			logger.Errorw("unable to free preempted machine, resetting preemption", "error", err)
+			err = resetPreemption(q.ds, &old)
+			if err != nil {
+				logger.Errorw("unable to reset preemption of machine", "error", err)
+			}
+			continue
+		}
+
+		ev := metal.ProvisioningEvent{
+			Time:    time.Now(),
+			Event:   metal.ProvisioningEventMachineReclaim,
+			Message: "machine was preempted",
+		}
+		_, err = q.ds.ProvisioningEventForMachine(logger, &ev, m.ID)
+		if err != nil {
+			logger.Errorw("error sending provisioning event after machine free", "error", err)
+		}
+
+		for i := range ps {
+			p := ps[i]
+			if p.PreemptedMachineID != m.ID || p.State != metal.PendingAllocationStatePending {
+				continue
+			}
+			_, err = q.allocate(&p)
+			if err != nil {
+				logger.Errorw("unable to hand preempted machine to pending allocation", "id", p.ID, "error", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// finish updates the state of the given pending allocation in place.
+func (q *PendingAllocationQueue) finish(p *metal.PendingAllocation, state metal.PendingAllocationState, machineID, message string) error {
+	old := *p
+	p.State = state
+	p.MachineID = machineID
+	p.Message = message
+	return q.ds.UpdatePendingAllocation(&old, p)
+}
+
+// pendingAllocationPositions returns the positions of the pending allocations in the queues of their partition and size.
+func pendingAllocationPositions(ps metal.PendingAllocations) map[string]*int {
+	sorted := append(metal.PendingAllocations{}, ps...)
+	sorted.Sort()
+
+	type key struct{ partition, size string }
+	positions := map[key]int{}
+
+	result := map[string]*int{}
+	for _, p := range sorted {
+		if p.State != metal.PendingAllocationStatePending {
+			continue
+		}
+		k := key{partition: p.PartitionID, size: p.SizeID}
+		positions[k]++
+		result[p.ID] = pointer.Pointer(positions[k])
+	}
+	return result
+}
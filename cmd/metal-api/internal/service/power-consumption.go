@@ -0,0 +1,194 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+)
+
+// RecordPowerConsumption records the current power consumption of the machines by partition, size and project.
+// The recording accounts for the energy used in the given interval, which has to match the interval in which
+// the recording is scheduled. Samples older than the retention are deleted.
+func RecordPowerConsumption(ds datastore.Store, logger *zap.SugaredLogger, interval, retention time.Duration) error {
+	logger.Info("power consumption recording was requested")
+
+	if interval <= 0 || interval > metal.PowerSampleMaxInterval {
+		return fmt.Errorf("interval must be positive and must not exceed %s", metal.PowerSampleMaxInterval)
+	}
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range metal.NewPowerSamples(machines, time.Now(), interval) {
+		s := s
+		err := ds.CreatePowerSample(&s)
+		if err != nil {
+			return err
+		}
+	}
+
+	if retention > 0 {
+		expired, err := ds.SearchPowerSamples(time.Time{}, time.Now().Add(-retention))
+		if err != nil {
+			return err
+		}
+		for i := range expired {
+			err := ds.DeletePowerSample(&expired[i])
+			if err != nil {
+				return err
+			}
+		}
+		logger.Infow("deleted expired power samples", "count", len(expired))
+	}
+
+	logger.Info("finished power consumption recording")
+
+	return nil
+}
+
+// calcPowerConsumption sums up the current power consumption of the machines per partition by rack, size and project.
+func calcPowerConsumption(ds datastore.MachineStore, req *v1.PowerConsumptionRequest) ([]v1.PartitionPowerConsumption, error) {
+	q := datastore.MachineSearchQuery{
+		PartitionID:       req.PartitionID,
+		SizeID:            req.SizeID,
+		AllocationProject: req.ProjectID,
+	}
+	var machines metal.Machines
+	err := ds.SearchMachines(&q, &machines)
+	if err != nil {
+		return nil, err
+	}
+
+	type consumptions struct {
+		total    metal.PowerConsumption
+		racks    map[string]*metal.PowerConsumption
+		sizes    map[string]*metal.PowerConsumption
+		projects map[string]*metal.PowerConsumption
+	}
+	add := func(groups map[string]*metal.PowerConsumption, id string, m *metal.Machine) {
+		pc, ok := groups[id]
+		if !ok {
+			pc = &metal.PowerConsumption{}
+			groups[id] = pc
+		}
+		pc.Add(m)
+	}
+
+	partitions := map[string]*consumptions{}
+	for i := range machines {
+		m := &machines[i]
+		c, ok := partitions[m.PartitionID]
+		if !ok {
+			c = &consumptions{
+				racks:    map[string]*metal.PowerConsumption{},
+				sizes:    map[string]*metal.PowerConsumption{},
+				projects: map[string]*metal.PowerConsumption{},
+			}
+			partitions[m.PartitionID] = c
+		}
+
+		c.total.Add(m)
+		add(c.racks, m.RackID, m)
+		add(c.sizes, m.SizeID, m)
+		if m.Allocation != nil {
+			add(c.projects, m.Allocation.Project, m)
+		}
+	}
+
+	toResponse := func(groups map[string]*metal.PowerConsumption) map[string]v1.PowerConsumption {
+		result := map[string]v1.PowerConsumption{}
+		for id, pc := range groups {
+			result[id] = v1.NewPowerConsumption(pc)
+		}
+		return result
+	}
+
+	result := []v1.PartitionPowerConsumption{}
+	for id, c := range partitions {
+		result = append(result, v1.PartitionPowerConsumption{
+			ID:       id,
+			Total:    v1.NewPowerConsumption(&c.total),
+			Racks:    toResponse(c.racks),
+			Sizes:    toResponse(c.sizes),
+			Projects: toResponse(c.projects),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+
+	return result, nil
+}
+
+// calcEnergyUsage sums up the energy used within the requested period from the recorded power samples.
+func calcEnergyUsage(ds datastore.PowerSampleStore, req *v1.EnergyUsageRequest) ([]v1.EnergyUsage, error) {
+	from := req.From
+	to := time.Now()
+	if req.To != nil {
+		to = *req.To
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("the end of the period must be after its start")
+	}
+
+	// samples recorded after the period still account for energy used within the period
+	samples, err := ds.SearchPowerSamples(from, to.Add(metal.PowerSampleMaxInterval))
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ partition, size, project string }
+	usages := map[key]*v1.EnergyUsage{}
+	for i := range samples {
+		s := &samples[i]
+		if req.PartitionID != nil && *req.PartitionID != s.PartitionID {
+			continue
+		}
+		if req.SizeID != nil && *req.SizeID != s.SizeID {
+			continue
+		}
+		if req.ProjectID != nil && *req.ProjectID != s.ProjectID {
+			continue
+		}
+		if !s.Timestamp.Add(-s.Interval).Before(to) {
+			continue
+		}
+
+		k := key{partition: s.PartitionID, size: s.SizeID, project: s.ProjectID}
+		u, ok := usages[k]
+		if !ok {
+			u = &v1.EnergyUsage{
+				PartitionID: s.PartitionID,
+				SizeID:      s.SizeID,
+				ProjectID:   s.ProjectID,
+			}
+			usages[k] = u
+		}
+		u.WattHours += s.WattHours(from, to)
+		if s.StaleMachines > 0 {
+			u.Incomplete = true
+		}
+	}
+
+	result := []v1.EnergyUsage{}
+	for _, u := range usages {
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.PartitionID != b.PartitionID {
+			return a.PartitionID < b.PartitionID
+		}
+		if a.SizeID != b.SizeID {
+			return a.SizeID < b.SizeID
+		}
+		return a.ProjectID < b.ProjectID
+	})
+
+	return result, nil
+}
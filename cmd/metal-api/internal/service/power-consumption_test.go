@@ -0,0 +1,111 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestPowerConsumption(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+
+	now := time.Now()
+	for _, m := range []metal.Machine{
+		{Base: metal.Base{ID: "m1"}, PartitionID: "p1", RackID: "r1", SizeID: "s1", Allocation: &metal.MachineAllocation{Project: "pr1"}, IPMI: metal.IPMI{LastUpdated: now, PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 100, MinConsumedWatts: 90, MaxConsumedWatts: 110}}},
+		{Base: metal.Base{ID: "m2"}, PartitionID: "p1", RackID: "r2", SizeID: "s1", Allocation: &metal.MachineAllocation{Project: "pr2"}, IPMI: metal.IPMI{LastUpdated: now, PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 200, MinConsumedWatts: 180, MaxConsumedWatts: 220}}},
+		{Base: metal.Base{ID: "m3"}, PartitionID: "p1", RackID: "r2", SizeID: "s1", Allocation: &metal.MachineAllocation{Project: "pr2"}, IPMI: metal.IPMI{LastUpdated: now.Add(-time.Hour), PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 300}}},
+		{Base: metal.Base{ID: "m4"}, PartitionID: "p1", RackID: "r1", SizeID: "s2", IPMI: metal.IPMI{LastUpdated: now, PowerMetric: &metal.PowerMetric{AverageConsumedWatts: 50, MinConsumedWatts: 40, MaxConsumedWatts: 60}}},
+	} {
+		m := m
+		allocation := m.Allocation
+		m.Allocation = nil
+		require.NoError(t, ds.CreateMachine(&m))
+		if allocation != nil {
+			allocated := m
+			allocated.Allocation = allocation
+			require.NoError(t, ds.UpdateMachine(&m, &allocated))
+		}
+	}
+
+	container := restful.NewContainer().Add(NewPartition(log, ds, &nopTopicCreater{}))
+	call := func(path string, body, result any) int {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest("POST", path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectViewer(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && w.Code < 300 {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	var consumptions []v1.PartitionPowerConsumption
+	require.Equal(t, http.StatusOK, call("/v1/partition/power-consumption", v1.PowerConsumptionRequest{}, &consumptions))
+	require.Len(t, consumptions, 1)
+	pc := consumptions[0]
+	assert.Equal(t, "p1", pc.ID)
+	assert.Equal(t, v1.PowerConsumption{Machines: 4, ReportingMachines: 3, StaleMachines: []string{"m3"}, AverageConsumedWatts: 350, MinConsumedWatts: 310, MaxConsumedWatts: 390}, pc.Total)
+	assert.Equal(t, 150.0, pc.Racks["r1"].AverageConsumedWatts)
+	assert.Equal(t, []string{"m3"}, pc.Racks["r2"].StaleMachines)
+	assert.Equal(t, 300.0, pc.Sizes["s1"].AverageConsumedWatts)
+	assert.Equal(t, 50.0, pc.Sizes["s2"].AverageConsumedWatts)
+	assert.Len(t, pc.Projects, 2, "machines which are not allocated are not counted for any project")
+	assert.Equal(t, 200.0, pc.Projects["pr2"].AverageConsumedWatts)
+
+	require.Equal(t, http.StatusOK, call("/v1/partition/power-consumption", v1.PowerConsumptionRequest{ProjectID: pointer.Pointer("pr1")}, &consumptions))
+	require.Len(t, consumptions, 1)
+	assert.Equal(t, 100.0, consumptions[0].Total.AverageConsumedWatts)
+
+	// two recordings of the same consumption half an hour apart account for one hour of energy usage
+	require.NoError(t, RecordPowerConsumption(ds, log, 30*time.Minute, 0))
+	samples, err := ds.ListPowerSamples()
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	for i := range samples {
+		old := samples[i]
+		require.NoError(t, ds.DeletePowerSample(&old))
+		samples[i].ID = ""
+		samples[i].Timestamp = samples[i].Timestamp.Add(-30 * time.Minute)
+		require.NoError(t, ds.CreatePowerSample(&samples[i]))
+	}
+	require.NoError(t, RecordPowerConsumption(ds, log, 30*time.Minute, 0))
+
+	var usages []v1.EnergyUsage
+	require.Equal(t, http.StatusOK, call("/v1/partition/energy-usage", v1.EnergyUsageRequest{From: now.Add(-2 * time.Hour)}, &usages))
+	require.Len(t, usages, 3)
+	assert.Equal(t, "pr1", usages[0].ProjectID)
+	assert.InDelta(t, 100, usages[0].WattHours, 0.01)
+	assert.False(t, usages[0].Incomplete)
+	assert.Equal(t, "pr2", usages[1].ProjectID)
+	assert.InDelta(t, 200, usages[1].WattHours, 0.01)
+	assert.True(t, usages[1].Incomplete, "the stale machine was left out")
+	assert.Equal(t, "s2", usages[2].SizeID)
+	assert.Empty(t, usages[2].ProjectID)
+
+	require.Equal(t, http.StatusOK, call("/v1/partition/energy-usage", v1.EnergyUsageRequest{From: now.Add(-2 * time.Hour), ProjectID: pointer.Pointer("pr1")}, &usages))
+	require.Len(t, usages, 1)
+
+	require.Equal(t, http.StatusBadRequest, call("/v1/partition/energy-usage", v1.EnergyUsageRequest{From: now, To: pointer.Pointer(now.Add(-time.Hour))}, nil))
+
+	// recordings older than the retention are deleted
+	require.NoError(t, RecordPowerConsumption(ds, log, 30*time.Minute, 15*time.Minute))
+	samples, err = ds.ListPowerSamples()
+	require.NoError(t, err)
+	assert.Len(t, samples, 6)
+}
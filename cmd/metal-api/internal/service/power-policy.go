@@ -0,0 +1,203 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"go.uber.org/zap"
+)
+
+// EnforcePowerPolicies powers off the waiting machines which idled for the given period and are not needed as hot
+// spares according to the power policies of their partitions. Machines are powered on again when a partition
+// runs short of hot spares.
+func EnforcePowerPolicies(ds datastore.Store, publisher bus.Publisher, logger *zap.SugaredLogger, idlePeriod time.Duration) error {
+	logger.Info("power policy enforcement was requested")
+
+	partitions, err := ds.ListPartitions()
+	if err != nil {
+		return err
+	}
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return err
+	}
+
+	ecs, err := ds.ListProvisioningEventContainers()
+	if err != nil {
+		return err
+	}
+	ecMap := ecs.ByID()
+
+	type key struct{ partition, size string }
+	spares := map[key][]*metal.Machine{}
+	var keys []key
+	for i := range machines {
+		m := &machines[i]
+		if m.Allocation != nil || m.PreAllocated || m.State.Value != metal.AvailableState {
+			continue
+		}
+		k := key{partition: m.PartitionID, size: m.SizeID}
+		if _, ok := spares[k]; !ok {
+			keys = append(keys, k)
+		}
+		spares[k] = append(spares[k], m)
+	}
+
+	partitionMap := partitions.ByID()
+	for _, k := range keys {
+		p, ok := partitionMap[k.partition]
+		if !ok {
+			continue
+		}
+		policy := p.PowerPolicies.For(k.size)
+		if policy == nil {
+			continue
+		}
+
+		var (
+			hot  []*metal.Machine
+			idle []*metal.Machine
+			off  []*metal.Machine
+		)
+		for _, m := range spares[k] {
+			if m.PowerSaving {
+				off = append(off, m)
+				continue
+			}
+
+			ec, ok := ecMap[m.ID]
+			if !ok || ec.Liveliness == metal.MachineLivelinessDead {
+				continue
+			}
+			hot = append(hot, m)
+
+			latest := pointer.FirstOrZero(ec.Events)
+			if isAllocatable(m) && latest.Event == metal.ProvisioningEventWaiting && time.Since(latest.Time) > idlePeriod {
+				idle = append(idle, m)
+			}
+		}
+
+		log := logger.With("partition", k.partition, "size", k.size, "hotspares", policy.HotSpares, "hot", len(hot), "off", len(off))
+
+		if len(hot) < policy.HotSpares {
+			for _, m := range off[:min(policy.HotSpares-len(hot), len(off))] {
+				log.Infow("powering on machine to keep enough hot spares", "machineID", m.ID)
+				err := switchPowerSaving(ds, publisher, logger, m, false)
+				if err != nil {
+					log.Errorw("unable to power on machine", "machineID", m.ID, "error", err)
+				}
+			}
+			continue
+		}
+
+		// the machines which idled the longest are powered off first
+		sort.SliceStable(idle, func(i, j int) bool {
+			return ecMap[idle[i].ID].Events[0].Time.Before(ecMap[idle[j].ID].Events[0].Time)
+		})
+		for _, m := range idle[:min(len(hot)-policy.HotSpares, len(idle))] {
+			log.Infow("powering off idle machine", "machineID", m.ID)
+			err := switchPowerSaving(ds, publisher, logger, m, true)
+			if err != nil {
+				log.Errorw("unable to power off machine", "machineID", m.ID, "error", err)
+			}
+		}
+	}
+
+	logger.Info("finished power policy enforcement")
+
+	return nil
+}
+
+// wakeUpSpareMachinesForAllocation powers on up to the given number of spare machines which can be allocated for the
+// allocation spec. For allocations by hardware requirements, the spare machines of the satisfying sizes are powered on
+// in the order of the sizes. Errors are only logged because the allocation failed already.
+func wakeUpSpareMachinesForAllocation(ds datastore.Store, publisher bus.Publisher, logger *zap.SugaredLogger, allocationSpec *machineAllocationSpec, count int) {
+	sizes := allocationSpec.Sizes
+	if len(sizes) == 0 {
+		if allocationSpec.Size == nil {
+			return
+		}
+		sizes = metal.Sizes{*allocationSpec.Size}
+	}
+
+	for _, size := range sizes {
+		if count <= 0 {
+			return
+		}
+		woken, err := wakeUpSpareMachines(ds, publisher, logger, allocationSpec.PartitionID, size.ID, allocationSpec.Requirements, count)
+		if err != nil {
+			logger.Errorw("unable to power on spare machines", "partition", allocationSpec.PartitionID, "size", size.ID, "error", err)
+		}
+		count -= woken
+	}
+}
+
+// wakeUpSpareMachines powers on up to the given number of machines of the partition and size which were powered
+// off by the power policy of the partition and satisfy the hardware requirements, such that they start waiting for an
+// allocation again. It returns the number of machines which were powered on.
+func wakeUpSpareMachines(ds datastore.Store, publisher bus.Publisher, logger *zap.SugaredLogger, partitionID, sizeID string, requirements []metal.Constraint, count int) (int, error) {
+	var machines metal.Machines
+	err := ds.SearchMachines(&datastore.MachineSearchQuery{PartitionID: &partitionID, SizeID: &sizeID}, &machines)
+	if err != nil {
+		return 0, err
+	}
+
+	woken := 0
+	for i := range machines {
+		if woken >= count {
+			break
+		}
+		m := &machines[i]
+		if !m.PowerSaving || m.Allocation != nil || m.State.Value != metal.AvailableState {
+			continue
+		}
+		if _, ok := metal.MatchesConstraints(requirements, m.Hardware); !ok {
+			continue
+		}
+
+		logger.Infow("powering on machine because no waiting machine is available", "machineID", m.ID, "partition", partitionID, "size", sizeID)
+		err := switchPowerSaving(ds, publisher, logger, m, false)
+		if err != nil {
+			return woken, err
+		}
+		woken++
+	}
+
+	return woken, nil
+}
+
+// switchPowerSaving marks the machine as powered off by a power policy or not and powers it off or on accordingly.
+// The machine is restored if the power command cannot be published.
+func switchPowerSaving(ds datastore.Store, publisher bus.Publisher, logger *zap.SugaredLogger, m *metal.Machine, powerSaving bool) error {
+	updated := *m
+	updated.PowerSaving = powerSaving
+	cmd := metal.MachineOnCmd
+	if powerSaving {
+		// a powered off machine must not be allocated anymore, it starts waiting again when it is powered on
+		updated.Waiting = false
+		cmd = metal.MachineOffCmd
+	}
+
+	err := ds.UpdateMachine(m, &updated)
+	if err != nil {
+		return err
+	}
+
+	err = publishMachineCmd(logger, &updated, publisher, cmd)
+	if err != nil {
+		restored := *m
+		restoreErr := ds.UpdateMachine(&updated, &restored)
+		if restoreErr != nil {
+			logger.Errorw("unable to restore machine", "machineID", m.ID, "error", restoreErr)
+		}
+		return fmt.Errorf("unable to publish %s command: %w", cmd, err)
+	}
+
+	return nil
+}
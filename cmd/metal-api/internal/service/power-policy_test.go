@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestEnforcePowerPolicies(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 3)
+
+	// m0 and m1 idle for hours, m2 just started waiting
+	for i, waitingSince := range []time.Duration{2 * time.Hour, 3 * time.Hour, time.Minute} {
+		ec, err := ds.FindProvisioningEventContainer(fmt.Sprintf("m%d", i))
+		require.NoError(t, err)
+		ec.Events = []metal.ProvisioningEvent{{Time: time.Now().Add(-waitingSince), Event: metal.ProvisioningEventWaiting}}
+		require.NoError(t, ds.UpsertProvisioningEventContainer(ec))
+	}
+
+	old, err := ds.FindPartition("p1")
+	require.NoError(t, err)
+	p := *old
+	p.PowerPolicies = metal.PowerPolicies{{SizeID: "s1", HotSpares: 1}}
+	require.NoError(t, ds.UpdatePartition(old, &p))
+
+	commands := map[string]metal.MachineCommand{}
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if evt, ok := data.(metal.MachineEvent); ok && evt.Cmd != nil {
+			commands[evt.Cmd.TargetMachineID] = evt.Cmd.Command
+		}
+		return nil
+	}}
+
+	require.NoError(t, EnforcePowerPolicies(ds, pub, log, time.Hour))
+	assert.Equal(t, map[string]metal.MachineCommand{"m0": metal.MachineOffCmd, "m1": metal.MachineOffCmd}, commands)
+	for _, id := range []string{"m0", "m1"} {
+		m, err := ds.FindMachineByID(id)
+		require.NoError(t, err)
+		assert.True(t, m.PowerSaving)
+		assert.False(t, m.Waiting, "powered off machines must not be allocated")
+	}
+
+	// powered off machines are free capacity
+	pr := partitionResource{webResource: webResource{log: log, ds: ds}}
+	capacities, err := pr.calcPartitionCapacity(nil)
+	require.NoError(t, err)
+	require.Len(t, capacities, 1)
+	c := capacities[0].ServerCapacities.FindBySize("s1")
+	require.NotNil(t, c)
+	assert.Equal(t, 3, c.Free)
+	assert.Equal(t, 2, c.PoweredOff)
+	assert.Empty(t, c.FaultyMachines)
+
+	// the policy is satisfied
+	commands = map[string]metal.MachineCommand{}
+	require.NoError(t, EnforcePowerPolicies(ds, pub, log, time.Hour))
+	assert.Empty(t, commands)
+
+	// an allocation which finds no waiting machine powers on a spare machine
+	allocation := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	_, err = allocateMachines(context.Background(), log, ds, ipamer, allocation, 1, metal.RoleMachine, &security.User{EMail: testEmail}, mdc, actor, pub, nil)
+	require.NoError(t, err)
+	_, err = allocateMachines(context.Background(), log, ds, ipamer, allocation, 1, metal.RoleMachine, &security.User{EMail: testEmail}, mdc, actor, pub, nil)
+	require.ErrorIs(t, err, datastore.ErrNoMachineAvailable)
+
+	var woken []string
+	for id, cmd := range commands {
+		if cmd == metal.MachineOnCmd {
+			woken = append(woken, id)
+		}
+	}
+	require.Len(t, woken, 1)
+	m, err := ds.FindMachineByID(woken[0])
+	require.NoError(t, err)
+	assert.False(t, m.PowerSaving)
+
+	// the remaining powered off machine is powered on as soon as more hot spares are required
+	old, err = ds.FindPartition("p1")
+	require.NoError(t, err)
+	p = *old
+	p.PowerPolicies = metal.PowerPolicies{{HotSpares: 2}}
+	require.NoError(t, ds.UpdatePartition(old, &p))
+
+	commands = map[string]metal.MachineCommand{}
+	require.NoError(t, EnforcePowerPolicies(ds, pub, log, time.Hour))
+	require.Len(t, commands, 1)
+	for id, cmd := range commands {
+		assert.NotEqual(t, woken[0], id)
+		assert.Equal(t, metal.MachineOnCmd, cmd)
+	}
+}
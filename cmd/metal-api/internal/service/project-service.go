@@ -26,7 +26,7 @@ type projectResource struct {
 }
 
 // NewProject returns a webservice for project specific endpoints.
-func NewProject(log *zap.SugaredLogger, ds *datastore.RethinkStore, mdc mdm.Client) *restful.WebService {
+func NewProject(log *zap.SugaredLogger, ds datastore.Store, mdc mdm.Client) *restful.WebService {
 	r := projectResource{
 		webResource: webResource{
 			log: log,
@@ -212,7 +212,7 @@ func (r *projectResource) deleteProject(request *restful.Request, response *rest
 	}
 
 	var ms metal.Machines
-	err = r.ds.SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &id}, &ms)
+	err = r.store(request).SearchMachines(&datastore.MachineSearchQuery{AllocationProject: &id}, &ms)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -223,7 +223,7 @@ func (r *projectResource) deleteProject(request *restful.Request, response *rest
 	}
 
 	var ns metal.Networks
-	err = r.ds.SearchNetworks(&datastore.NetworkSearchQuery{ProjectID: &id}, &ns)
+	err = r.store(request).SearchNetworks(&datastore.NetworkSearchQuery{ProjectID: &id}, &ns)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -234,7 +234,7 @@ func (r *projectResource) deleteProject(request *restful.Request, response *rest
 	}
 
 	var ips metal.IPs
-	err = r.ds.SearchIPs(&datastore.IPSearchQuery{ProjectID: &id}, &ips)
+	err = r.store(request).SearchIPs(&datastore.IPSearchQuery{ProjectID: &id}, &ips)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type reservationResource struct {
+	webResource
+}
+
+// NewReservation returns a webservice for the capacity reservations of projects.
+func NewReservation(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
+	r := reservationResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+	}
+	return r.webService()
+}
+
+func (r *reservationResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/reservation").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"reservation"}
+
+	ws.Route(ws.GET("/{id}").
+		To(r.findReservation).
+		Operation("findReservation").
+		Doc("get reservation by id").
+		Param(ws.PathParameter("id", "identifier of the reservation").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.ReservationResponse{}).
+		Returns(http.StatusOK, "OK", v1.ReservationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/").
+		To(r.listReservations).
+		Operation("listReservations").
+		Doc("get all reservations").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.ReservationResponse{}).
+		Returns(http.StatusOK, "OK", []v1.ReservationResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.DELETE("/{id}").
+		To(admin(r.deleteReservation)).
+		Operation("deleteReservation").
+		Doc("deletes a reservation and returns the deleted entity, the reserved machines are released immediately").
+		Param(ws.PathParameter("id", "identifier of the reservation").DataType("string")).
+		Param(ifMatchParam(ws)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.ReservationResponse{}).
+		Returns(http.StatusOK, "OK", v1.ReservationResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.PUT("/").
+		To(admin(r.createReservation)).
+		Operation("createReservation").
+		Doc("create a reservation of machines of a size in a partition for a project. if the given ID already exists a conflict is returned").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.ReservationCreateRequest{}).
+		Returns(http.StatusCreated, "Created", v1.ReservationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/").
+		To(admin(r.updateReservation)).
+		Operation("updateReservation").
+		Doc("updates the amount or the expiration of a reservation. if the reservation was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.ReservationUpdateRequest{}).
+		Returns(http.StatusOK, "OK", v1.ReservationResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *reservationResource) findReservation(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	res, err := r.store(request).FindReservation(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makeReservationResponses(r.store(request), metal.Reservations{*res})
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	setEntityTag(response, res)
+	r.send(request, response, http.StatusOK, resp[0])
+}
+
+func (r *reservationResource) listReservations(request *restful.Request, response *restful.Response) {
+	rs, err := r.store(request).ListReservations()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result, err := makeReservationResponses(r.store(request), rs)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *reservationResource) createReservation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.ReservationCreateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	res := v1.NewReservation(requestPayload)
+
+	err = res.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	_, err = r.store(request).FindPartition(res.PartitionID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	_, err = r.store(request).FindSize(res.SizeID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	err = r.store(request).CreateReservation(res)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makeReservationResponses(r.store(request), metal.Reservations{*res})
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, resp[0])
+}
+
+func (r *reservationResource) deleteReservation(request *restful.Request, response *restful.Response) {
+	id := request.PathParameter("id")
+
+	res, err := r.store(request).FindReservation(id)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if httperr := checkIfMatch(request, res); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeleteReservation(res)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewReservationResponse(res, 0, time.Now()))
+}
+
+func (r *reservationResource) updateReservation(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.ReservationUpdateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	old, err := r.store(request).FindReservation(requestPayload.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if httperr := checkIfMatch(request, old); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	newReservation := *old
+
+	if requestPayload.Name != nil {
+		newReservation.Name = *requestPayload.Name
+	}
+	if requestPayload.Description != nil {
+		newReservation.Description = *requestPayload.Description
+	}
+	if requestPayload.Amount != nil {
+		newReservation.Amount = *requestPayload.Amount
+	}
+	if requestPayload.Expires != nil {
+		newReservation.Expires = *requestPayload.Expires
+	}
+
+	err = newReservation.Validate()
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	err = r.store(request).UpdateReservation(old, &newReservation)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	resp, err := makeReservationResponses(r.store(request), metal.Reservations{newReservation})
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	setEntityTag(response, &newReservation)
+	r.send(request, response, http.StatusOK, resp[0])
+}
+
+// makeReservationResponses returns the responses of the given reservations together with the amount of machines
+// which they still hold back. As allocations use up all reservations of their project, partition and size, these
+// are taken into account as well.
+func makeReservationResponses(ds datastore.Store, rs metal.Reservations) ([]*v1.ReservationResponse, error) {
+	result := []*v1.ReservationResponse{}
+	if len(rs) == 0 {
+		return result, nil
+	}
+
+	all, err := ds.ListReservations()
+	if err != nil {
+		return nil, err
+	}
+
+	machines, err := ds.ListMachines()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	remaining := all.RemainingByID(machines, now)
+
+	for i := range rs {
+		result = append(result, v1.NewReservationResponse(&rs[i], remaining[rs[i].ID], now))
+	}
+
+	return result, nil
+}
+
+// ReleaseExpiredReservations deletes the reservations which have expired. Expired reservations do not hold back any
+// machines anymore, deleting them just keeps the reservations of the projects tidy.
+func ReleaseExpiredReservations(ds datastore.Store, logger *zap.SugaredLogger) error {
+	logger.Info("release of expired reservations was requested")
+
+	rs, err := ds.ListReservations()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	released := 0
+	errs := 0
+	for i := range rs {
+		res := rs[i]
+		if !res.IsExpired(now) {
+			continue
+		}
+
+		err := ds.DeleteReservation(&res)
+		if err != nil {
+			logger.Errorw("cannot release expired reservation", "error", err, "reservation", res.ID)
+			errs++
+			// fall through, so the rest of the reservations is getting released
+			continue
+		}
+
+		logger.Infow("released expired reservation", "reservation", res.ID, "project", res.ProjectID, "partition", res.PartitionID, "size", res.SizeID, "expires", res.Expires)
+		released++
+	}
+
+	logger.Infow("expired reservations released", "released", released, "errors", errs)
+
+	return nil
+}
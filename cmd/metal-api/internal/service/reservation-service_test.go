@@ -0,0 +1,119 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestReservations(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+
+	require.NoError(t, ds.CreatePartition(&metal.Partition{Base: metal.Base{ID: "p1"}}))
+	require.NoError(t, ds.CreateSize(&metal.Size{Base: metal.Base{ID: "s1"}}))
+	for i := 0; i < 2; i++ {
+		id := fmt.Sprintf("m%d", i)
+		require.NoError(t, ds.CreateMachine(&metal.Machine{
+			Base:        metal.Base{ID: id},
+			SizeID:      "s1",
+			PartitionID: "p1",
+			Waiting:     true,
+			State:       metal.MachineState{Value: metal.AvailableState},
+		}))
+		require.NoError(t, ds.CreateProvisioningEventContainer(&metal.ProvisioningEventContainer{
+			Base:       metal.Base{ID: id},
+			Liveliness: metal.MachineLivelinessAlive,
+			Events:     metal.ProvisioningEvents{{Time: time.Now(), Event: metal.ProvisioningEventWaiting}},
+		}))
+	}
+
+	container := restful.NewContainer().Add(NewReservation(log, ds))
+	call := func(method, path string, body, result any) int {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectAdmin(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && w.Code < 300 {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	id := "r1"
+	createRequest := v1.ReservationCreateRequest{
+		ID:                   &id,
+		ReservationBase:      v1.ReservationBase{Amount: 1, Expires: time.Now().Add(time.Hour)},
+		ReservationImmutable: v1.ReservationImmutable{ProjectID: "owner", PartitionID: "p1", SizeID: "s1"},
+	}
+
+	invalid := createRequest
+	invalid.Amount = 0
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/reservation", invalid, nil))
+
+	unknownSize := createRequest
+	unknownSize.SizeID = "unknown"
+	require.Equal(t, http.StatusNotFound, call("PUT", "/v1/reservation", unknownSize, nil))
+
+	var reservation v1.ReservationResponse
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/reservation", createRequest, &reservation))
+	assert.Equal(t, 1, reservation.Remaining)
+	assert.False(t, reservation.Expired)
+
+	p := partitionResource{webResource: webResource{log: log, ds: ds}}
+	capacities, err := p.calcPartitionCapacity(nil)
+	require.NoError(t, err)
+	require.Len(t, capacities, 1)
+	require.Len(t, capacities[0].ServerCapacities, 1)
+	c := capacities[0].ServerCapacities[0]
+	assert.Equal(t, 1, c.Free)
+	assert.Equal(t, 1, c.Reserved)
+	assert.Equal(t, 1, c.Reservations)
+
+	_, err = ds.FindWaitingMachine("other", "p1", "s1", nil, metal.MachineAffinity{}, nil)
+	require.NoError(t, err, "one machine is not reserved")
+	_, err = ds.FindWaitingMachine("other", "p1", "s1", nil, metal.MachineAffinity{}, nil)
+	require.ErrorContains(t, err, "reserved for other projects")
+	owned, err := ds.FindWaitingMachine("owner", "p1", "s1", nil, metal.MachineAffinity{}, nil)
+	require.NoError(t, err, "the owner must be able to allocate against its reservation")
+
+	// the reservation is used up as soon as the owner has allocated a machine
+	allocated := *owned
+	allocated.Allocation = &metal.MachineAllocation{Project: "owner"}
+	require.NoError(t, ds.UpdateMachine(owned, &allocated))
+	require.Equal(t, http.StatusOK, call("GET", "/v1/reservation/r1", nil, &reservation))
+	assert.Equal(t, 0, reservation.Remaining)
+
+	expires := time.Now().Add(-time.Minute)
+	require.Equal(t, http.StatusOK, call("POST", "/v1/reservation", v1.ReservationUpdateRequest{
+		Common:  v1.Common{Identifiable: v1.Identifiable{ID: "r1"}},
+		Amount:  pointer.Pointer(2),
+		Expires: &expires,
+	}, &reservation))
+	assert.Equal(t, 2, reservation.Amount)
+	assert.True(t, reservation.Expired)
+	assert.Equal(t, 0, reservation.Remaining)
+
+	require.NoError(t, ReleaseExpiredReservations(ds, log))
+	require.Equal(t, http.StatusNotFound, call("GET", "/v1/reservation/r1", nil, nil))
+
+	var reservations []v1.ReservationResponse
+	require.Equal(t, http.StatusOK, call("GET", "/v1/reservation", nil, &reservations))
+	assert.Empty(t, reservations)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+type revisionResource struct {
+	webResource
+}
+
+// NewRevision returns a webservice for the revision history of entities.
+func NewRevision(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
+	r := revisionResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+	}
+	return r.webService()
+}
+
+func (r *revisionResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/revision").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"revision"}
+
+	kindParam := ws.PathParameter("kind", "the kind of the entity ["+strings.Join(datastore.RevisionKinds, "|")+"]").DataType("string")
+
+	ws.Route(ws.GET("/{kind}/{id}").
+		To(admin(r.listRevisions)).
+		Operation("listRevisions").
+		Doc("get all revisions of an entity, sorted by their timestamp").
+		Param(kindParam).
+		Param(ws.PathParameter("id", "identifier of the entity").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.RevisionResponse{}).
+		Returns(http.StatusOK, "OK", []v1.RevisionResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/{kind}/{id}/at").
+		To(admin(r.findRevisionAt)).
+		Operation("findRevisionAt").
+		Doc("get the state of an entity at the given point in time").
+		Param(kindParam).
+		Param(ws.PathParameter("id", "identifier of the entity").DataType("string")).
+		Param(ws.QueryParameter("timestamp", "the point in time in RFC3339 format").DataType("string").Required(true)).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.RevisionResponse{}).
+		Returns(http.StatusOK, "OK", v1.RevisionResponse{}).
+		Returns(http.StatusNotFound, "Not Found", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *revisionResource) listRevisions(request *restful.Request, response *restful.Response) {
+	kind, err := revisionKind(request)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	revisions, err := r.ds.ListRevisions(kind, request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.RevisionResponse{}
+	for i := range revisions {
+		result = append(result, v1.NewRevisionResponse(&revisions[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *revisionResource) findRevisionAt(request *restful.Request, response *restful.Response) {
+	kind, err := revisionKind(request)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, request.QueryParameter("timestamp"))
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(fmt.Errorf("invalid timestamp: %w", err)))
+		return
+	}
+
+	revision, err := datastore.FindRevisionAt(r.ds, kind, request.PathParameter("id"), at)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewRevisionResponse(revision))
+}
+
+func revisionKind(request *restful.Request) (string, error) {
+	kind := request.PathParameter("kind")
+	for _, k := range datastore.RevisionKinds {
+		if k == kind {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("revisions of %q are not recorded, kind must be one of %s", kind, strings.Join(datastore.RevisionKinds, ", "))
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestListRevisions(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds := datastore.NewMemory(log)
+
+	container := restful.NewContainer().Add(NewSize(log, ds)).Add(NewRevision(log, ds))
+
+	js, err := json.Marshal(v1.SizeCreateRequest{
+		Common: v1.Common{Identifiable: v1.Identifiable{ID: "s1"}},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest("PUT", "/v1/size", bytes.NewBuffer(js))
+	req.Header.Add("Content-Type", "application/json")
+	container = injectAdmin(log, container, req)
+	w := httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/v1/revision/size/s1", nil)
+	container = injectAdmin(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode, w.Body.String())
+	var result []v1.RevisionResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+
+	require.Len(t, result, 1)
+	require.Equal(t, "s1", result[0].EntityID)
+	require.Equal(t, string(metal.RevisionOperationCreate), result[0].Operation)
+	require.Equal(t, adminUserEmail, result[0].User)
+
+	req = httptest.NewRequest("GET", "/v1/revision/switchstatus/s1", nil)
+	container = injectAdmin(log, container, req)
+	w = httptest.NewRecorder()
+	container.ServeHTTP(w, req)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
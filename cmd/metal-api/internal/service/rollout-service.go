@@ -0,0 +1,339 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/security"
+	"go.uber.org/zap"
+
+	restfulspec "github.com/emicklei/go-restful-openapi/v2"
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-lib/httperrors"
+)
+
+// defaultRolloutTimeout is the duration after which the reinstallation of a machine failed if no timeout is given.
+const defaultRolloutTimeout = time.Hour
+
+type rolloutResource struct {
+	webResource
+	runner     *RolloutRunner
+	userGetter security.UserGetter
+}
+
+// NewRollout returns a webservice for rollouts which reinstall groups of machines in waves.
+func NewRollout(log *zap.SugaredLogger, ds datastore.Store, runner *RolloutRunner, userGetter security.UserGetter) *restful.WebService {
+	r := rolloutResource{
+		webResource: webResource{
+			log: log,
+			ds:  ds,
+		},
+		runner:     runner,
+		userGetter: userGetter,
+	}
+	return r.webService()
+}
+
+func (r *rolloutResource) webService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.
+		Path(BasePath + "v1/rollout").
+		Consumes(restful.MIME_JSON).
+		Produces(restful.MIME_JSON)
+
+	tags := []string{"rollout"}
+
+	ws.Route(ws.GET("/{id}").
+		To(viewer(r.findRollout)).
+		Operation("findRollout").
+		Doc("get rollout by id").
+		Param(ws.PathParameter("id", "identifier of the rollout").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.RolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.RolloutResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.GET("/").
+		To(viewer(r.listRollouts)).
+		Operation("listRollouts").
+		Doc("get all rollouts").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes([]v1.RolloutResponse{}).
+		Returns(http.StatusOK, "OK", []v1.RolloutResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.PUT("/").
+		To(editor(r.createRollout)).
+		Operation("createRollout").
+		Doc("creates a rollout which reinstalls the selected machines with the given image in waves, the next wave is started as soon as all machines of the previous wave phoned home after their reinstallation").
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Reads(v1.RolloutCreateRequest{}).
+		Returns(http.StatusCreated, "Created", v1.RolloutResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/pause").
+		To(editor(r.pauseRollout)).
+		Operation("pauseRollout").
+		Doc("pauses a running rollout, machines which are reinstalled currently are not interrupted but no further machines are reinstalled").
+		Param(ws.PathParameter("id", "identifier of the rollout").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.RolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.RolloutResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/resume").
+		To(editor(r.resumeRollout)).
+		Operation("resumeRollout").
+		Doc("resumes a paused rollout, machines whose reinstallation failed are reinstalled again").
+		Param(ws.PathParameter("id", "identifier of the rollout").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.RolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.RolloutResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	ws.Route(ws.POST("/{id}/abort").
+		To(editor(r.abortRollout)).
+		Operation("abortRollout").
+		Doc("aborts a rollout, machines which are reinstalled currently are not interrupted but no further machines are reinstalled and the rollout cannot be resumed anymore").
+		Param(ws.PathParameter("id", "identifier of the rollout").DataType("string")).
+		Metadata(restfulspec.KeyOpenAPITags, tags).
+		Writes(v1.RolloutResponse{}).
+		Returns(http.StatusOK, "OK", v1.RolloutResponse{}).
+		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
+
+	return ws
+}
+
+func (r *rolloutResource) findRollout(request *restful.Request, response *restful.Response) {
+	rollout, err := r.store(request).FindRollout(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewRolloutResponse(rollout))
+}
+
+func (r *rolloutResource) listRollouts(request *restful.Request, response *restful.Response) {
+	rollouts, err := r.store(request).ListRollouts()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	result := []*v1.RolloutResponse{}
+	for i := range rollouts {
+		result = append(result, v1.NewRolloutResponse(&rollouts[i]))
+	}
+
+	r.send(request, response, http.StatusOK, result)
+}
+
+func (r *rolloutResource) createRollout(request *restful.Request, response *restful.Response) {
+	var requestPayload v1.RolloutCreateRequest
+	err := request.ReadEntity(&requestPayload)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	if requestPayload.MaxUnavailable < 1 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("at least one machine must be allowed to be unavailable")))
+		return
+	}
+	timeout := defaultRolloutTimeout
+	if requestPayload.Timeout != nil {
+		timeout = *requestPayload.Timeout
+	}
+	if timeout <= 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("timeout must be positive")))
+		return
+	}
+
+	image, err := r.store(request).FindImage(requestPayload.ImageID)
+	if err != nil {
+		r.sendError(request, response, httperrors.BadRequest(err))
+		return
+	}
+
+	var ms metal.Machines
+	err = r.store(request).SearchMachines(&requestPayload.Selector, &ms)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rollouts, err := r.store(request).ListRollouts()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// a machine must not be reinstalled by two rollouts at the same time
+	active := map[string]string{}
+	for _, rollout := range rollouts {
+		if rollout.IsDone() {
+			continue
+		}
+		for _, m := range rollout.Machines {
+			active[m.MachineID] = rollout.ID
+		}
+	}
+
+	var machines []metal.RolloutMachine
+	for _, m := range ms {
+		if m.Allocation == nil || m.State.Value == metal.LockedState || m.State.Value == metal.MaintenanceState {
+			continue
+		}
+		if id, ok := active[m.ID]; ok {
+			r.sendError(request, response, defaultError(metal.Conflict("machine %s is already reinstalled by rollout %s", m.ID, id)))
+			return
+		}
+		machines = append(machines, metal.RolloutMachine{
+			MachineID: m.ID,
+			State:     metal.RolloutMachineStatePending,
+		})
+	}
+	if len(machines) == 0 {
+		r.sendError(request, response, httperrors.BadRequest(errors.New("selector does not match any allocated machine which is neither locked nor in maintenance")))
+		return
+	}
+
+	user, err := r.userGetter.User(request.Request)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	var (
+		name        string
+		description string
+	)
+	if requestPayload.Name != nil {
+		name = *requestPayload.Name
+	}
+	if requestPayload.Description != nil {
+		description = *requestPayload.Description
+	}
+
+	rollout := &metal.Rollout{
+		Base: metal.Base{
+			Name:        name,
+			Description: description,
+		},
+		Creator:        user.EMail,
+		ImageID:        image.ID,
+		MaxUnavailable: requestPayload.MaxUnavailable,
+		Timeout:        timeout,
+		State:          metal.RolloutStateRunning,
+		Machines:       machines,
+	}
+
+	err = r.store(request).CreateRollout(rollout)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	// the first wave is started immediately
+	err = r.runner.Process()
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rollout, err = r.store(request).FindRollout(rollout.ID)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	r.send(request, response, http.StatusCreated, v1.NewRolloutResponse(rollout))
+}
+
+func (r *rolloutResource) pauseRollout(request *restful.Request, response *restful.Response) {
+	r.transition(request, response, func(rollout *metal.Rollout) error {
+		if rollout.State != metal.RolloutStateRunning {
+			return metal.Conflict("only running rollouts can be paused, rollout is %s", rollout.State)
+		}
+		rollout.State = metal.RolloutStatePaused
+		rollout.Message = "the rollout was paused"
+		return nil
+	})
+}
+
+func (r *rolloutResource) resumeRollout(request *restful.Request, response *restful.Response) {
+	r.transition(request, response, func(rollout *metal.Rollout) error {
+		if rollout.State != metal.RolloutStatePaused {
+			return metal.Conflict("only paused rollouts can be resumed, rollout is %s", rollout.State)
+		}
+		rollout.State = metal.RolloutStateRunning
+		rollout.Message = ""
+		for i := range rollout.Machines {
+			if rollout.Machines[i].State == metal.RolloutMachineStateFailed {
+				rollout.Machines[i].State = metal.RolloutMachineStatePending
+			}
+		}
+		return nil
+	})
+}
+
+func (r *rolloutResource) abortRollout(request *restful.Request, response *restful.Response) {
+	r.transition(request, response, func(rollout *metal.Rollout) error {
+		if rollout.IsDone() {
+			return metal.Conflict("rollout is %s already", rollout.State)
+		}
+		rollout.State = metal.RolloutStateAborted
+		rollout.Message = "the rollout was aborted"
+		return nil
+	})
+}
+
+// transition applies a state change to the rollout of the request, a running rollout is processed immediately.
+func (r *rolloutResource) transition(request *restful.Request, response *restful.Response, change func(rollout *metal.Rollout) error) {
+	old, err := r.store(request).FindRollout(request.PathParameter("id"))
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	rollout := *old
+	rollout.Machines = append([]metal.RolloutMachine{}, old.Machines...)
+
+	err = change(&rollout)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	err = r.store(request).UpdateRollout(old, &rollout)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	if rollout.State == metal.RolloutStateRunning {
+		err = r.runner.Process()
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+
+		updated, err := r.store(request).FindRollout(rollout.ID)
+		if err != nil {
+			r.sendError(request, response, defaultError(err))
+			return
+		}
+		rollout = *updated
+	}
+
+	r.send(request, response, http.StatusOK, v1.NewRolloutResponse(&rollout))
+}
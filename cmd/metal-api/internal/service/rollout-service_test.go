@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	restful "github.com/emicklei/go-restful/v3"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	v1 "github.com/metal-stack/metal-api/cmd/metal-api/internal/service/v1"
+	"github.com/metal-stack/metal-lib/pkg/pointer"
+	"github.com/metal-stack/security"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRollout(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 3)
+
+	allocation := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	machines, err := allocateMachines(context.Background(), log, ds, ipamer, allocation, 3, metal.RoleMachine, &security.User{EMail: testEmail}, mdc, actor, &emptyPublisher{}, nil)
+	require.NoError(t, err)
+	for i := range machines {
+		m := &machines[i]
+		reinstallable := *m
+		alloc := *m.Allocation
+		alloc.FilesystemLayout = &metal.FilesystemLayout{Base: metal.Base{ID: "fsl1"}, Disks: []metal.Disk{{Device: "/dev/sda", WipeOnReinstall: true}}}
+		reinstallable.Allocation = &alloc
+		require.NoError(t, ds.UpdateMachine(m, &reinstallable))
+	}
+	require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "i-2.0.0"}, OS: "i", Version: "2.0.0", Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+
+	var reinstalled []string
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if evt, ok := data.(metal.MachineEvent); ok && evt.Cmd != nil && evt.Cmd.Command == metal.MachineReinstallCmd {
+			reinstalled = append(reinstalled, evt.Cmd.TargetMachineID)
+		}
+		return nil
+	}}
+
+	runner := NewRolloutRunner(log, ds, pub)
+	userGetter := mockUserGetter{&security.User{EMail: testEmail}}
+	container := restful.NewContainer().Add(NewRollout(log, ds, runner, userGetter))
+	call := func(method, path string, body, result any) int {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && w.Code < 300 {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	// reports the given provisioning events of the machine, the events are given from the newest to the oldest
+	report := func(machineID string, events ...metal.ProvisioningEventType) {
+		ec, err := ds.FindProvisioningEventContainer(machineID)
+		require.NoError(t, err)
+		for i := len(events) - 1; i >= 0; i-- {
+			ec.Events = append([]metal.ProvisioningEvent{{Time: time.Now(), Event: events[i]}}, ec.Events...)
+		}
+		require.NoError(t, ds.UpsertProvisioningEventContainer(ec))
+	}
+
+	selector := datastore.MachineSearchQuery{AllocationProject: pointer.Pointer("pr1")}
+
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0"}, nil))
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: datastore.MachineSearchQuery{AllocationProject: pointer.Pointer("pr2")}, ImageID: "i-2.0.0", MaxUnavailable: 1}, nil))
+
+	var rollout v1.RolloutResponse
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0", MaxUnavailable: 2}, &rollout))
+	assert.Equal(t, string(metal.RolloutStateRunning), rollout.State)
+	assert.Equal(t, testEmail, rollout.Creator)
+	assert.Equal(t, time.Hour, rollout.Timeout)
+	assert.Equal(t, v1.RolloutProgress{Pending: 1, Reinstalling: 2}, rollout.Progress)
+	require.Len(t, rollout.Machines, 3)
+	first, second, last := rollout.Machines[0].MachineID, rollout.Machines[1].MachineID, rollout.Machines[2].MachineID
+	assert.ElementsMatch(t, []string{first, second}, reinstalled)
+
+	m, err := ds.FindMachineByID(first)
+	require.NoError(t, err)
+	assert.Equal(t, "i-2.0.0", m.Allocation.ImageID)
+	assert.True(t, m.Allocation.Reinstall)
+
+	require.Equal(t, http.StatusConflict, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0", MaxUnavailable: 1}, nil), "machines must not be reinstalled by two rollouts")
+
+	// the next wave is only started when all machines of the current wave phoned home with the new operating system
+	report(first, metal.ProvisioningEventPhonedHome, metal.ProvisioningEventBootingNewKernel)
+	report(second, metal.ProvisioningEventInstalling)
+	require.NoError(t, runner.Process())
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout/"+rollout.ID, nil, &rollout))
+	assert.Equal(t, v1.RolloutProgress{Pending: 1, Reinstalling: 1, Succeeded: 1}, rollout.Progress)
+
+	report(second, metal.ProvisioningEventPhonedHome, metal.ProvisioningEventBootingNewKernel)
+	stale, err := ds.FindRollout(rollout.ID)
+	require.NoError(t, err)
+	require.NoError(t, runner.Process())
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout/"+rollout.ID, nil, &rollout))
+	assert.Equal(t, v1.RolloutProgress{Reinstalling: 1, Succeeded: 2}, rollout.Progress)
+	assert.Contains(t, reinstalled, last)
+
+	// the runner of another api instance cannot claim the same wave, so the machines are not rebooted twice
+	count := len(reinstalled)
+	require.Error(t, NewRolloutRunner(log, ds, pub).process(stale))
+	assert.Len(t, reinstalled, count)
+
+	// a failed reinstallation pauses the rollout
+	report(last, metal.ProvisioningEventCrashed)
+	require.NoError(t, runner.Process())
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout/"+rollout.ID, nil, &rollout))
+	assert.Equal(t, string(metal.RolloutStatePaused), rollout.State)
+	assert.Equal(t, v1.RolloutProgress{Failed: 1, Succeeded: 2}, rollout.Progress)
+	require.NotNil(t, rollout.Message)
+	assert.Contains(t, *rollout.Message, last)
+
+	require.Equal(t, http.StatusConflict, call("POST", "/v1/rollout/"+rollout.ID+"/pause", nil, nil))
+
+	// resuming reinstalls the failed machines again
+	reinstalled = nil
+	require.Equal(t, http.StatusOK, call("POST", "/v1/rollout/"+rollout.ID+"/resume", nil, &rollout))
+	assert.Equal(t, string(metal.RolloutStateRunning), rollout.State)
+	assert.Equal(t, v1.RolloutProgress{Reinstalling: 1, Succeeded: 2}, rollout.Progress)
+	assert.Equal(t, []string{last}, reinstalled)
+
+	require.Equal(t, http.StatusOK, call("POST", "/v1/rollout/"+rollout.ID+"/pause", nil, &rollout))
+	assert.Equal(t, string(metal.RolloutStatePaused), rollout.State)
+
+	require.Equal(t, http.StatusOK, call("POST", "/v1/rollout/"+rollout.ID+"/abort", nil, &rollout))
+	assert.Equal(t, string(metal.RolloutStateAborted), rollout.State)
+	require.Equal(t, http.StatusConflict, call("POST", "/v1/rollout/"+rollout.ID+"/resume", nil, nil))
+
+	var all []v1.RolloutResponse
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout", nil, &all))
+	require.Len(t, all, 1)
+
+	// machines of aborted rollouts can be reinstalled by another rollout
+	var next v1.RolloutResponse
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0", MaxUnavailable: 3}, &next))
+	report(first, metal.ProvisioningEventPhonedHome, metal.ProvisioningEventBootingNewKernel)
+	report(second, metal.ProvisioningEventPhonedHome, metal.ProvisioningEventBootingNewKernel)
+	report(last, metal.ProvisioningEventPhonedHome, metal.ProvisioningEventBootingNewKernel)
+	require.NoError(t, runner.Process())
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout/"+next.ID, nil, &next))
+	assert.Equal(t, string(metal.RolloutStateFinished), next.State)
+	assert.Equal(t, v1.RolloutProgress{Succeeded: 3}, next.Progress)
+}
+
+func TestRolloutSkipsUnavailableMachines(t *testing.T) {
+	log := zaptest.NewLogger(t).Sugar()
+	ds, ipamer, actor, mdc := setupAllocation(t, 3)
+
+	allocation := v1.MachineAllocateRequest{
+		SizeID:      "s1",
+		PartitionID: "p1",
+		ProjectID:   "pr1",
+		ImageID:     "i-1.0.0",
+		Networks:    v1.MachineAllocationNetworks{{NetworkID: "private"}},
+	}
+	machines, err := allocateMachines(context.Background(), log, ds, ipamer, allocation, 3, metal.RoleMachine, &security.User{EMail: testEmail}, mdc, actor, &emptyPublisher{}, nil)
+	require.NoError(t, err)
+	for i := range machines {
+		m := &machines[i]
+		reinstallable := *m
+		alloc := *m.Allocation
+		alloc.FilesystemLayout = &metal.FilesystemLayout{Base: metal.Base{ID: "fsl1"}, Disks: []metal.Disk{{Device: "/dev/sda", WipeOnReinstall: true}}}
+		reinstallable.Allocation = &alloc
+		require.NoError(t, ds.UpdateMachine(m, &reinstallable))
+	}
+	require.NoError(t, ds.CreateImage(&metal.Image{Base: metal.Base{ID: "i-2.0.0"}, OS: "i", Version: "2.0.0", Features: map[metal.ImageFeatureType]bool{metal.ImageFeatureMachine: true}}))
+
+	setState := func(machineID string, state metal.MState) {
+		m, err := ds.FindMachineByID(machineID)
+		require.NoError(t, err)
+		updated := *m
+		updated.State = metal.MachineState{Value: state, Description: "test"}
+		require.NoError(t, ds.UpdateMachine(m, &updated))
+	}
+
+	var reinstalled []string
+	pub := &emptyPublisher{doPublish: func(topic string, data interface{}) error {
+		if evt, ok := data.(metal.MachineEvent); ok && evt.Cmd != nil && evt.Cmd.Command == metal.MachineReinstallCmd {
+			reinstalled = append(reinstalled, evt.Cmd.TargetMachineID)
+		}
+		return nil
+	}}
+
+	runner := NewRolloutRunner(log, ds, pub)
+	container := restful.NewContainer().Add(NewRollout(log, ds, runner, mockUserGetter{&security.User{EMail: testEmail}}))
+	call := func(method, path string, body, result any) int {
+		js, err := json.Marshal(body)
+		require.NoError(t, err)
+		req := httptest.NewRequest(method, path, bytes.NewBuffer(js))
+		req.Header.Add("Content-Type", "application/json")
+		container = injectEditor(log, container, req)
+		w := httptest.NewRecorder()
+		container.ServeHTTP(w, req)
+		if result != nil && w.Code < 300 {
+			require.NoError(t, json.NewDecoder(w.Body).Decode(result))
+		}
+		return w.Code
+	}
+
+	// locked machines and machines in maintenance are not selected
+	setState(machines[0].ID, metal.LockedState)
+	setState(machines[1].ID, metal.MaintenanceState)
+	setState(machines[2].ID, metal.MaintenanceState)
+	selector := datastore.MachineSearchQuery{AllocationProject: pointer.Pointer("pr1")}
+	require.Equal(t, http.StatusBadRequest, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0", MaxUnavailable: 1}, nil))
+
+	setState(machines[1].ID, metal.AvailableState)
+	setState(machines[2].ID, metal.AvailableState)
+
+	var rollout v1.RolloutResponse
+	require.Equal(t, http.StatusCreated, call("PUT", "/v1/rollout", v1.RolloutCreateRequest{Selector: selector, ImageID: "i-2.0.0", MaxUnavailable: 1}, &rollout))
+	require.Len(t, rollout.Machines, 2, "the locked machine is not selected")
+	first, second := rollout.Machines[0].MachineID, rollout.Machines[1].MachineID
+	assert.Equal(t, []string{first}, reinstalled)
+
+	// a machine which is put into maintenance after the rollout was created is not reinstalled
+	setState(second, metal.MaintenanceState)
+
+	ec, err := ds.FindProvisioningEventContainer(first)
+	require.NoError(t, err)
+	ec.Events = append([]metal.ProvisioningEvent{{Time: time.Now(), Event: metal.ProvisioningEventPhonedHome}, {Time: time.Now(), Event: metal.ProvisioningEventBootingNewKernel}}, ec.Events...)
+	require.NoError(t, ds.UpsertProvisioningEventContainer(ec))
+
+	require.NoError(t, runner.Process())
+	require.Equal(t, http.StatusOK, call("GET", "/v1/rollout/"+rollout.ID, nil, &rollout))
+	assert.Equal(t, string(metal.RolloutStatePaused), rollout.State)
+	assert.Equal(t, v1.RolloutProgress{Failed: 1, Succeeded: 1}, rollout.Progress)
+	require.NotNil(t, rollout.Message)
+	assert.Contains(t, *rollout.Message, "machine is in maintenance")
+	assert.Equal(t, []string{first}, reinstalled)
+
+	m, err := ds.FindMachineByID(second)
+	require.NoError(t, err)
+	assert.Equal(t, "i-1.0.0", m.Allocation.ImageID)
+	assert.False(t, m.Allocation.Reinstall)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+	"github.com/metal-stack/metal-lib/bus"
+	"go.uber.org/zap"
+)
+
+// RolloutRunner drives the reinstallations of the running rollouts in waves.
+type RolloutRunner struct {
+	log       *zap.SugaredLogger
+	ds        datastore.Store
+	publisher bus.Publisher
+
+	mu sync.Mutex
+}
+
+// NewRolloutRunner returns a runner which drives the rollouts.
+func NewRolloutRunner(log *zap.SugaredLogger, ds datastore.Store, pub bus.Publisher) *RolloutRunner {
+	return &RolloutRunner{
+		log:       log,
+		ds:        ds,
+		publisher: pub,
+	}
+}
+
+// Run processes the rollouts in the given interval until the context is done.
+func (rr *RolloutRunner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := rr.Process()
+		if err != nil {
+			rr.log.Errorw("unable to process rollouts", "error", err)
+		}
+	}
+}
+
+// Process checks the progress of the machines which are reinstalled by the rollouts. As soon as all machines
+// of a wave are reinstalled successfully, the next wave of a running rollout is started. A rollout is paused
+// when the reinstallation of one of its machines failed. A wave is claimed in the rollout before its machines are
+// rebooted, such that the runners of several api instances do not start the same wave.
+func (rr *RolloutRunner) Process() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rollouts, err := rr.ds.ListRollouts()
+	if err != nil {
+		return err
+	}
+
+	for i := range rollouts {
+		if rollouts[i].IsDone() {
+			continue
+		}
+
+		err := rr.process(&rollouts[i])
+		if err != nil {
+			rr.log.Errorw("unable to process rollout", "id", rollouts[i].ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (rr *RolloutRunner) process(r *metal.Rollout) error {
+	updated := *r
+	updated.Machines = append([]metal.RolloutMachine{}, r.Machines...)
+
+	logger := rr.log.With("rollout", r.ID)
+
+	// machines which are reinstalled while the rollout is paused are still tracked
+	var failures []string
+	changed := false
+	for i := range updated.Machines {
+		m := &updated.Machines[i]
+		if m.State != metal.RolloutMachineStateReinstalling {
+			continue
+		}
+
+		state, message, err := rr.reinstallationState(r, m)
+		if err != nil {
+			return err
+		}
+		if state == metal.RolloutMachineStateReinstalling {
+			continue
+		}
+
+		logger.Infow("reinstallation of machine completed", "machineID", m.MachineID, "state", state, "message", message)
+
+		m.State = state
+		m.Message = message
+		m.Finished = time.Now()
+		changed = true
+		if state == metal.RolloutMachineStateFailed {
+			failures = append(failures, fmt.Sprintf("%s: %s", m.MachineID, message))
+		}
+	}
+
+	switch {
+	case len(failures) > 0:
+		updated.State = metal.RolloutStatePaused
+		updated.Message = fmt.Sprintf("rollout was paused because reinstallations failed: %s", strings.Join(failures, ", "))
+	case updated.State != metal.RolloutStateRunning:
+	case updated.Count(metal.RolloutMachineStatePending) == 0 && updated.Count(metal.RolloutMachineStateReinstalling) == 0:
+		updated.State = metal.RolloutStateFinished
+		changed = true
+	default:
+		wave := updated.NextWave()
+		if len(wave) == 0 {
+			break
+		}
+
+		// the wave is claimed before the machines are rebooted, such that the runners of the other api instances
+		// do not start the wave a second time
+		for _, i := range wave {
+			m := &updated.Machines[i]
+			m.State = metal.RolloutMachineStateReinstalling
+			m.Started = time.Now()
+			m.Message = ""
+		}
+		err := rr.ds.UpdateRollout(r, &updated)
+		if err != nil {
+			return fmt.Errorf("unable to claim next wave: %w", err)
+		}
+
+		claimed := updated
+		updated.Machines = append([]metal.RolloutMachine{}, claimed.Machines...)
+		r = &claimed
+		changed = false
+
+		for j, i := range wave {
+			m := &updated.Machines[i]
+
+			err := rr.reinstall(r, m.MachineID)
+			if err != nil {
+				logger.Errorw("unable to reinstall machine", "machineID", m.MachineID, "error", err)
+				m.State = metal.RolloutMachineStateFailed
+				m.Message = err.Error()
+				m.Finished = time.Now()
+				updated.State = metal.RolloutStatePaused
+				updated.Message = fmt.Sprintf("rollout was paused because reinstallations failed: %s: %s", m.MachineID, err)
+				// the remaining machines of the wave were not rebooted yet
+				for _, k := range wave[j+1:] {
+					updated.Machines[k].State = metal.RolloutMachineStatePending
+					updated.Machines[k].Started = time.Time{}
+				}
+				changed = true
+				break
+			}
+
+			logger.Infow("reinstalling machine", "machineID", m.MachineID, "image", r.ImageID)
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return rr.ds.UpdateRollout(r, &updated)
+}
+
+// reinstallationState returns the state of a machine which is reinstalled by the given rollout. The reinstallation
+// succeeded when the machine phoned home after booting into the new operating system.
+func (rr *RolloutRunner) reinstallationState(r *metal.Rollout, rm *metal.RolloutMachine) (metal.RolloutMachineState, string, error) {
+	m, err := rr.ds.FindMachineByID(rm.MachineID)
+	if err != nil {
+		if metal.IsNotFound(err) {
+			return metal.RolloutMachineStateFailed, "machine does not exist anymore", nil
+		}
+		return "", "", err
+	}
+	if m.Allocation == nil {
+		return metal.RolloutMachineStateFailed, "machine was freed during the reinstallation", nil
+	}
+
+	ec, err := rr.ds.FindProvisioningEventContainer(rm.MachineID)
+	if err != nil && !metal.IsNotFound(err) {
+		return "", "", err
+	}
+
+	if ec != nil {
+		bootedNewKernel := false
+		// the events are ordered from the newest to the oldest
+		for _, e := range ec.Events {
+			if e.Time.Before(rm.Started) {
+				break
+			}
+			switch e.Event { //nolint:exhaustive
+			case metal.ProvisioningEventCrashed:
+				return metal.RolloutMachineStateFailed, fmt.Sprintf("machine crashed during the reinstallation: %s", e.Message), nil
+			case metal.ProvisioningEventBootingNewKernel:
+				bootedNewKernel = true
+			}
+		}
+
+		if bootedNewKernel && ec.Events[0].Event == metal.ProvisioningEventPhonedHome {
+			return metal.RolloutMachineStateSucceeded, "", nil
+		}
+	}
+
+	if time.Since(rm.Started) > r.Timeout {
+		return metal.RolloutMachineStateFailed, fmt.Sprintf("machine did not phone home within %s", r.Timeout), nil
+	}
+
+	return metal.RolloutMachineStateReinstalling, "", nil
+}
+
+// reinstall marks the given machine to get reinstalled with the image of the rollout and reboots it. Rollouts always
+// install exactly the image of the rollout, the patch upgrade defaults of the projects do not apply.
+func (rr *RolloutRunner) reinstall(r *metal.Rollout, machineID string) error {
+	m, err := rr.ds.FindMachineByID(machineID)
+	if err != nil {
+		return err
+	}
+	if m.Allocation == nil {
+		return errors.New("machine is not allocated anymore")
+	}
+	switch m.State.Value {
+	case metal.LockedState:
+		return errors.New("machine is locked")
+	case metal.MaintenanceState:
+		return errors.New("machine is in maintenance")
+	}
+
+	fsl, err := reinstallableFilesystemLayout(rr.ds, m)
+	if err != nil {
+		return err
+	}
+
+	old := *m
+	alloc := *m.Allocation
+	alloc.FilesystemLayout = fsl
+	alloc.Reinstall = true
+	alloc.ImageID = r.ImageID
+	m.Allocation = &alloc
+
+	err = rr.ds.UpdateMachine(&old, m)
+	if err != nil {
+		return err
+	}
+
+	return rebootIntoReinstallation(rr.ds, rr.publisher, rr.log.With("rollout", r.ID), m)
+}
@@ -28,7 +28,7 @@ var BasePath = "/"
 
 type webResource struct {
 	log *zap.SugaredLogger
-	ds  *datastore.RethinkStore
+	ds  datastore.Store
 }
 
 // logger returns the request logger from the request.
@@ -37,6 +37,22 @@ func (w *webResource) logger(rq *restful.Request) *zap.SugaredLogger {
 	return requestLogger.WithOptions(zap.AddCallerSkip(1))
 }
 
+// store returns the datastore which records the user and the request id of the request with every change.
+func (w *webResource) store(rq *restful.Request) datastore.Store {
+	user := security.GetUser(rq.Request)
+	name := ""
+	if user != nil {
+		name = user.EMail
+		if name == "" {
+			name = user.Name
+		}
+	}
+
+	requestID, _ := rq.Request.Context().Value(rest.RequestIDKey).(string)
+
+	return w.ds.WithRevisionInfo(datastore.RevisionInfo{User: name, RequestID: requestID})
+}
+
 func (w *webResource) sendError(rq *restful.Request, rsp *restful.Response, httperr *httperrors.HTTPErrorResponse) {
 	w.logger(rq).Errorw("service error", "status", httperr.StatusCode, "error", httperr.Message)
 	w.send(rq, rsp, httperr.StatusCode, httperr)
@@ -21,7 +21,7 @@ type sizeResource struct {
 }
 
 // NewSize returns a webservice for size specific endpoints.
-func NewSize(log *zap.SugaredLogger, ds *datastore.RethinkStore) *restful.WebService {
+func NewSize(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
 	r := sizeResource{
 		webResource: webResource{
 			log: log,
@@ -74,9 +74,11 @@ func (r *sizeResource) webService() *restful.WebService {
 		Operation("deleteSize").
 		Doc("deletes an size and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the size").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.SizeResponse{}).
 		Returns(http.StatusOK, "OK", v1.SizeResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -93,10 +95,12 @@ func (r *sizeResource) webService() *restful.WebService {
 		To(admin(r.updateSize)).
 		Operation("updateSize").
 		Doc("updates a size. if the size was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.SizeUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.SizeResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/from-hardware").
@@ -115,12 +119,13 @@ func (r *sizeResource) webService() *restful.WebService {
 func (r *sizeResource) findSize(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSize(id)
+	s, err := r.store(request).FindSize(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, s)
 	r.send(request, response, http.StatusOK, v1.NewSizeResponse(s))
 }
 
@@ -137,7 +142,7 @@ func (r *sizeResource) suggestSize(request *restful.Request, response *restful.R
 		return
 	}
 
-	m, err := r.ds.FindMachineByID(requestPayload.MachineID)
+	m, err := r.store(request).FindMachineByID(requestPayload.MachineID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -164,7 +169,7 @@ func (r *sizeResource) suggestSize(request *restful.Request, response *restful.R
 }
 
 func (r *sizeResource) listSizes(request *restful.Request, response *restful.Response) {
-	ss, err := r.ds.ListSizes()
+	ss, err := r.store(request).ListSizes()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -223,7 +228,7 @@ func (r *sizeResource) createSize(request *restful.Request, response *restful.Re
 		Constraints: constraints,
 	}
 
-	ss, err := r.ds.ListSizes()
+	ss, err := r.store(request).ListSizes()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -234,7 +239,7 @@ func (r *sizeResource) createSize(request *restful.Request, response *restful.Re
 		return
 	}
 
-	err = r.ds.CreateSize(s)
+	err = r.store(request).CreateSize(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -246,13 +251,18 @@ func (r *sizeResource) createSize(request *restful.Request, response *restful.Re
 func (r *sizeResource) deleteSize(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSize(id)
+	s, err := r.store(request).FindSize(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = r.ds.DeleteSize(s)
+	if httperr := checkIfMatch(request, s); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeleteSize(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -269,12 +279,17 @@ func (r *sizeResource) updateSize(request *restful.Request, response *restful.Re
 		return
 	}
 
-	oldSize, err := r.ds.FindSize(requestPayload.ID)
+	oldSize, err := r.store(request).FindSize(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldSize); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newSize := *oldSize
 
 	if requestPayload.Name != nil {
@@ -297,7 +312,7 @@ func (r *sizeResource) updateSize(request *restful.Request, response *restful.Re
 		newSize.Constraints = constraints
 	}
 
-	ss, err := r.ds.ListSizes()
+	ss, err := r.store(request).ListSizes()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -308,12 +323,13 @@ func (r *sizeResource) updateSize(request *restful.Request, response *restful.Re
 		return
 	}
 
-	err = r.ds.UpdateSize(oldSize, &newSize)
+	err = r.store(request).UpdateSize(oldSize, &newSize)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newSize)
 	r.send(request, response, http.StatusOK, v1.NewSizeResponse(&newSize))
 }
 
@@ -326,7 +342,7 @@ func (r *sizeResource) fromHardware(request *restful.Request, response *restful.
 	}
 
 	hw := v1.NewMetalMachineHardware(&requestPayload)
-	_, lg, err := r.ds.FromHardware(hw)
+	_, lg, err := r.store(request).FromHardware(hw)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
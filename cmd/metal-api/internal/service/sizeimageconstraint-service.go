@@ -21,7 +21,7 @@ type sizeImageConstraintResource struct {
 }
 
 // NewSize returns a webservice for size specific endpoints.
-func NewSizeImageConstraint(log *zap.SugaredLogger, ds *datastore.RethinkStore) *restful.WebService {
+func NewSizeImageConstraint(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
 	r := sizeImageConstraintResource{
 		webResource: webResource{
 			log: log,
@@ -64,9 +64,11 @@ func (r *sizeImageConstraintResource) webService() *restful.WebService {
 		Operation("deleteSizeImageConstraint").
 		Doc("deletes an sizeimageconstraint and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the size").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.SizeImageConstraintResponse{}).
 		Returns(http.StatusOK, "OK", v1.SizeImageConstraintResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.PUT("/").
@@ -83,10 +85,12 @@ func (r *sizeImageConstraintResource) webService() *restful.WebService {
 		To(admin(r.updateSizeImageConstraint)).
 		Operation("updateSizeImageConstraint").
 		Doc("updates a sizeimageconstraint. if the sizeimageconstraint was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.SizeImageConstraintUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.SizeImageConstraintResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/try").
@@ -105,17 +109,18 @@ func (r *sizeImageConstraintResource) webService() *restful.WebService {
 func (r *sizeImageConstraintResource) findSizeImageConstraint(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSizeImageConstraint(id)
+	s, err := r.store(request).FindSizeImageConstraint(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, s)
 	r.send(request, response, http.StatusOK, v1.NewSizeImageConstraintResponse(s))
 }
 
 func (r *sizeImageConstraintResource) listSizeImageConstraints(request *restful.Request, response *restful.Response) {
-	ss, err := r.ds.ListSizeImageConstraints()
+	ss, err := r.store(request).ListSizeImageConstraints()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -150,7 +155,7 @@ func (r *sizeImageConstraintResource) createSizeImageConstraint(request *restful
 		return
 	}
 
-	err = r.ds.CreateSizeImageConstraint(s)
+	err = r.store(request).CreateSizeImageConstraint(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -162,13 +167,18 @@ func (r *sizeImageConstraintResource) createSizeImageConstraint(request *restful
 func (r *sizeImageConstraintResource) deleteSizeImageConstraint(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSizeImageConstraint(id)
+	s, err := r.store(request).FindSizeImageConstraint(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = r.ds.DeleteSizeImageConstraint(s)
+	if httperr := checkIfMatch(request, s); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeleteSizeImageConstraint(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -185,12 +195,17 @@ func (r *sizeImageConstraintResource) updateSizeImageConstraint(request *restful
 		return
 	}
 
-	old, err := r.ds.FindSizeImageConstraint(requestPayload.ID)
+	old, err := r.store(request).FindSizeImageConstraint(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, old); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newSizeImageConstraint := *old
 
 	if requestPayload.Name != nil {
@@ -208,12 +223,13 @@ func (r *sizeImageConstraintResource) updateSizeImageConstraint(request *restful
 		return
 	}
 
-	err = r.ds.UpdateSizeImageConstraint(old, &newSizeImageConstraint)
+	err = r.store(request).UpdateSizeImageConstraint(old, &newSizeImageConstraint)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newSizeImageConstraint)
 	r.send(request, response, http.StatusOK, v1.NewSizeImageConstraintResponse(&newSizeImageConstraint))
 }
 
@@ -230,19 +246,19 @@ func (r *sizeImageConstraintResource) trySizeImageConstraint(request *restful.Re
 		return
 	}
 
-	size, err := r.ds.FindSize(requestPayload.SizeID)
+	size, err := r.store(request).FindSize(requestPayload.SizeID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	image, err := r.ds.FindImage(requestPayload.ImageID)
+	image, err := r.store(request).FindImage(requestPayload.ImageID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = isSizeAndImageCompatible(r.ds, *size, *image)
+	err = isSizeAndImageCompatible(r.store(request), *size, *image)
 	if err != nil {
 		r.sendError(request, response, httperrors.UnprocessableEntity(err))
 		return
@@ -251,7 +267,7 @@ func (r *sizeImageConstraintResource) trySizeImageConstraint(request *restful.Re
 	r.send(request, response, http.StatusOK, v1.EmptyBody{})
 }
 
-func isSizeAndImageCompatible(ds *datastore.RethinkStore, size metal.Size, image metal.Image) error {
+func isSizeAndImageCompatible(ds datastore.Store, size metal.Size, image metal.Image) error {
 	sic, err := ds.FindSizeImageConstraint(size.ID)
 	if err != nil && !metal.IsNotFound(err) {
 		return err
@@ -25,7 +25,7 @@ type switchResource struct {
 }
 
 // NewSwitch returns a webservice for switch specific endpoints.
-func NewSwitch(log *zap.SugaredLogger, ds *datastore.RethinkStore) *restful.WebService {
+func NewSwitch(log *zap.SugaredLogger, ds datastore.Store) *restful.WebService {
 	r := switchResource{
 		webResource: webResource{
 			log: log,
@@ -71,7 +71,7 @@ func (r *switchResource) webService() *restful.WebService {
 		Metadata(auditing.Exclude, true).
 		Reads(v1.SwitchFindRequest{}).
 		Writes([]v1.SwitchResponse{}).
-		Returns(http.StatusOK, "OK", []v1.SwitchResponse{}).
+		ReturnsWithHeaders(http.StatusOK, "OK", []v1.SwitchResponse{}, nextCursorHeaders).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.DELETE("/{id}").
@@ -79,9 +79,11 @@ func (r *switchResource) webService() *restful.WebService {
 		Operation("deleteSwitch").
 		Doc("deletes an switch and returns the deleted entity").
 		Param(ws.PathParameter("id", "identifier of the switch").DataType("string")).
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Writes(v1.SwitchResponse{}).
 		Returns(http.StatusOK, "OK", v1.SwitchResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/register").
@@ -98,10 +100,12 @@ func (r *switchResource) webService() *restful.WebService {
 		To(admin(r.updateSwitch)).
 		Operation("updateSwitch").
 		Doc("updates a switch. if the switch was changed since this one was read, a conflict is returned").
+		Param(ifMatchParam(ws)).
 		Metadata(restfulspec.KeyOpenAPITags, tags).
 		Reads(v1.SwitchUpdateRequest{}).
 		Returns(http.StatusOK, "OK", v1.SwitchResponse{}).
 		Returns(http.StatusConflict, "Conflict", httperrors.HTTPErrorResponse{}).
+		Returns(http.StatusPreconditionFailed, "Precondition Failed", httperrors.HTTPErrorResponse{}).
 		DefaultReturns("Error", httperrors.HTTPErrorResponse{}))
 
 	ws.Route(ws.POST("/{id}/notify").
@@ -121,29 +125,30 @@ func (r *switchResource) webService() *restful.WebService {
 func (r *switchResource) findSwitch(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSwitch(id)
+	s, err := r.store(request).FindSwitch(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeSwitchResponse(s, r.ds)
+	resp, err := makeSwitchResponse(s, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, s)
 	r.send(request, response, http.StatusOK, resp)
 }
 
 func (r *switchResource) listSwitches(request *restful.Request, response *restful.Response) {
-	ss, err := r.ds.ListSwitches()
+	ss, err := r.store(request).ListSwitches()
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeSwitchResponseList(ss, r.ds)
+	resp, err := makeSwitchResponseList(ss, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -161,13 +166,20 @@ func (r *switchResource) findSwitches(request *restful.Request, response *restfu
 	}
 
 	var ss metal.Switches
-	err = r.ds.SearchSwitches(&requestPayload, &ss)
+	err = r.store(request).SearchSwitches(&requestPayload, &ss)
+	if err != nil {
+		r.sendError(request, response, defaultError(err))
+		return
+	}
+
+	next, err := requestPayload.NextCursor(ss)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
+	setNextCursor(response, next)
 
-	resp, err := makeSwitchResponseList(ss, r.ds)
+	resp, err := makeSwitchResponseList(ss, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -179,19 +191,24 @@ func (r *switchResource) findSwitches(request *restful.Request, response *restfu
 func (r *switchResource) deleteSwitch(request *restful.Request, response *restful.Response) {
 	id := request.PathParameter("id")
 
-	s, err := r.ds.FindSwitch(id)
+	s, err := r.store(request).FindSwitch(id)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	err = r.ds.DeleteSwitch(s)
+	if httperr := checkIfMatch(request, s); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
+	err = r.store(request).DeleteSwitch(s)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	resp, err := makeSwitchResponse(s, r.ds)
+	resp, err := makeSwitchResponse(s, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -211,7 +228,7 @@ func (r *switchResource) notifySwitch(request *restful.Request, response *restfu
 
 	id := request.PathParameter("id")
 
-	ss, err := r.ds.GetSwitchStatus(id)
+	ss, err := r.store(request).GetSwitchStatus(id)
 	if err != nil {
 		if !metal.IsNotFound(err) {
 			r.sendError(request, response, defaultError(err))
@@ -238,7 +255,7 @@ func (r *switchResource) notifySwitch(request *restful.Request, response *restfu
 		}
 	}
 
-	err = r.ds.SetSwitchStatus(&newSS)
+	err = r.store(request).SetSwitchStatus(&newSS)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -255,12 +272,17 @@ func (r *switchResource) updateSwitch(request *restful.Request, response *restfu
 		return
 	}
 
-	oldSwitch, err := r.ds.FindSwitch(requestPayload.ID)
+	oldSwitch, err := r.store(request).FindSwitch(requestPayload.ID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	if httperr := checkIfMatch(request, oldSwitch); httperr != nil {
+		r.sendError(request, response, httperr)
+		return
+	}
+
 	newSwitch := *oldSwitch
 
 	if requestPayload.Description != nil {
@@ -272,7 +294,7 @@ func (r *switchResource) updateSwitch(request *restful.Request, response *restfu
 
 	err = retry.Do(
 		func() error {
-			err := r.ds.UpdateSwitch(oldSwitch, &newSwitch)
+			err := r.store(request).UpdateSwitch(oldSwitch, &newSwitch)
 			return err
 		},
 		retry.Attempts(10),
@@ -287,12 +309,13 @@ func (r *switchResource) updateSwitch(request *restful.Request, response *restfu
 		return
 	}
 
-	resp, err := makeSwitchResponse(&newSwitch, r.ds)
+	resp, err := makeSwitchResponse(&newSwitch, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
+	setEntityTag(response, &newSwitch)
 	r.send(request, response, http.StatusOK, resp)
 }
 
@@ -309,13 +332,13 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 		return
 	}
 
-	_, err = r.ds.FindPartition(requestPayload.PartitionID)
+	_, err = r.store(request).FindPartition(requestPayload.PartitionID)
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
 	}
 
-	s, err := r.ds.FindSwitch(requestPayload.ID)
+	s, err := r.store(request).FindSwitch(requestPayload.ID)
 	if err != nil && !metal.IsNotFound(err) {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -329,7 +352,7 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 			return
 		}
 
-		err = r.ds.CreateSwitch(s)
+		err = r.store(request).CreateSwitch(s)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -338,7 +361,7 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 		returnCode = http.StatusCreated
 	} else if s.Mode == metal.SwitchReplace {
 		spec := v1.NewSwitch(requestPayload)
-		err = r.replaceSwitch(s, spec)
+		err = r.replaceSwitch(r.store(request), s, spec)
 		if err != nil {
 			r.sendError(request, response, defaultError(err))
 			return
@@ -385,7 +408,7 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 
 		err = retry.Do(
 			func() error {
-				err := r.ds.UpdateSwitch(&old, s)
+				err := r.store(request).UpdateSwitch(&old, s)
 				return err
 			},
 			retry.Attempts(10),
@@ -403,7 +426,7 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 
 	}
 
-	resp, err := makeSwitchResponse(s, r.ds)
+	resp, err := makeSwitchResponse(s, r.store(request))
 	if err != nil {
 		r.sendError(request, response, defaultError(err))
 		return
@@ -424,7 +447,7 @@ func (r *switchResource) registerSwitch(request *restful.Request, response *rest
 //   - new switch needs all the nics of the twin-brother switch
 //   - new switch gets the same vrf configuration as the twin-brother switch based on the switch port name
 //   - new switch gets the same machine connections as the twin-brother switch based on the switch port name
-func (r *switchResource) replaceSwitch(old, new *metal.Switch) error {
+func (r *switchResource) replaceSwitch(ds datastore.Store, old, new *metal.Switch) error {
 	twin, err := r.findTwinSwitch(new)
 	if err != nil {
 		return fmt.Errorf("could not determine twin brother for switch %s, err: %w", new.Name, err)
@@ -435,7 +458,7 @@ func (r *switchResource) replaceSwitch(old, new *metal.Switch) error {
 		return err
 	}
 
-	return r.ds.UpdateSwitch(old, s)
+	return ds.UpdateSwitch(old, s)
 }
 
 // findTwinSwitch finds the neighboring twin of a switch for the given partition and rack
@@ -622,7 +645,7 @@ func updateSwitchNics(oldNics, newNics map[string]*metal.Nic, currentConnections
 	return finalNics, nil
 }
 
-func makeSwitchResponse(s *metal.Switch, ds *datastore.RethinkStore) (*v1.SwitchResponse, error) {
+func makeSwitchResponse(s *metal.Switch, ds datastore.Store) (*v1.SwitchResponse, error) {
 	p, ips, machines, ss, err := findSwitchReferencedEntites(s, ds)
 	if err != nil {
 		return nil, err
@@ -769,7 +792,7 @@ func makeSwitchCons(s *metal.Switch) []v1.SwitchConnection {
 	return cons
 }
 
-func findSwitchReferencedEntites(s *metal.Switch, ds *datastore.RethinkStore) (*metal.Partition, metal.IPsMap, metal.Machines, *metal.SwitchStatus, error) {
+func findSwitchReferencedEntites(s *metal.Switch, ds datastore.Store) (*metal.Partition, metal.IPsMap, metal.Machines, *metal.SwitchStatus, error) {
 	var err error
 	var p *metal.Partition
 	var m metal.Machines
@@ -799,7 +822,7 @@ func findSwitchReferencedEntites(s *metal.Switch, ds *datastore.RethinkStore) (*
 	return p, ips.ByProjectID(), m, ss, nil
 }
 
-func makeSwitchResponseList(ss metal.Switches, ds *datastore.RethinkStore) ([]*v1.SwitchResponse, error) {
+func makeSwitchResponseList(ss metal.Switches, ds datastore.Store) ([]*v1.SwitchResponse, error) {
 	pMap, ips, err := getSwitchReferencedEntityMaps(ds)
 	if err != nil {
 		return nil, err
@@ -831,7 +854,7 @@ func makeSwitchResponseList(ss metal.Switches, ds *datastore.RethinkStore) ([]*v
 	return result, nil
 }
 
-func getSwitchReferencedEntityMaps(ds *datastore.RethinkStore) (metal.PartitionMap, metal.IPsMap, error) {
+func getSwitchReferencedEntityMaps(ds datastore.Store) (metal.PartitionMap, metal.IPsMap, error) {
 	p, err := ds.ListPartitions()
 	if err != nil {
 		return nil, nil, fmt.Errorf("partitions could not be listed: %w", err)
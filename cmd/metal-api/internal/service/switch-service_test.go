@@ -268,6 +268,7 @@ func TestConnectMachineWithSwitches(t *testing.T) {
 		ds, mock := datastore.InitMockDB(t)
 		mock.On(r.DB("mockdb").Table("switch")).Return(testSwitches, nil)
 		mock.On(r.DB("mockdb").Table("switch").Get(r.MockAnything()).Replace(r.MockAnything())).Return(testdata.EmptyResult, nil)
+		mock.On(r.DB("mockdb").Table("revision").Insert(r.MockAnything())).Return(testdata.EmptyResult, nil)
 
 		t.Run(tt.name, func(t *testing.T) {
 			if err := ds.ConnectMachineWithSwitches(tt.machine); (err != nil) != tt.wantErr {
@@ -303,6 +304,7 @@ func TestSetVrfAtSwitch(t *testing.T) {
 	sws := []metal.Switch{sw}
 	mock.On(r.DB("mockdb").Table("switch").Filter(r.MockAnything())).Return(sws, nil)
 	mock.On(r.DB("mockdb").Table("switch").Get(r.MockAnything()).Replace(r.MockAnything())).Return(testdata.EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("revision").Insert(r.MockAnything())).Return(testdata.EmptyResult, nil)
 
 	vrf := "123"
 	m := &metal.Machine{
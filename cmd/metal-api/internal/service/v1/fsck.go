@@ -0,0 +1,20 @@
+package v1
+
+type FsckRequest struct {
+	Repair bool     `json:"repair" description:"apply the safe repairs for the findings" optional:"true"`
+	Checks []string `json:"checks" description:"the checks to run, all checks are run if none is given" optional:"true"`
+}
+
+type FsckFinding struct {
+	Check       string  `json:"check" description:"the check which reported the finding"`
+	EntityKind  string  `json:"entity_kind" description:"the kind of the inconsistent entity, e.g. machine or ip"`
+	EntityID    string  `json:"entity_id" description:"the ID of the inconsistent entity"`
+	Message     string  `json:"message" description:"describes the inconsistency"`
+	Repairable  bool    `json:"repairable" description:"true if the finding can be repaired safely"`
+	Repaired    bool    `json:"repaired" description:"true if the finding was repaired"`
+	RepairError *string `json:"repair_error" description:"the error which occurred during the repair" optional:"true"`
+}
+
+type FsckResponse struct {
+	Findings []FsckFinding `json:"findings" description:"the inconsistencies found between the entities of the datastore and the ipam"`
+}
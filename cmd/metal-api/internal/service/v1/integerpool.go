@@ -0,0 +1,21 @@
+package v1
+
+type IntegerPoolResponse struct {
+	Name        string  `json:"name" description:"the name of the integer pool"`
+	Kind        string  `json:"kind" description:"the kind of the integers of the pool" enum:"vrf|asn"`
+	PartitionID *string `json:"partition_id" description:"the partition which has the pool of its own, pools without partition are used by all other partitions" optional:"true"`
+	Min         uint    `json:"min" description:"the smallest integer of the pool"`
+	Max         uint    `json:"max" description:"the largest integer of the pool"`
+	Total       uint    `json:"total" description:"the amount of integers in the pool"`
+	Used        uint    `json:"used" description:"the amount of acquired integers"`
+	Free        uint    `json:"free" description:"the amount of integers which can still be acquired"`
+}
+
+type IntegerPoolIntegerResponse struct {
+	Pool       string   `json:"pool" description:"the name of the integer pool"`
+	Integer    uint     `json:"integer" description:"the integer of the pool"`
+	Value      uint64   `json:"value" description:"the vrf or asn which is derived from the integer"`
+	Acquired   bool     `json:"acquired" description:"true if the integer is acquired from the pool"`
+	MachineIDs []string `json:"machine_ids" description:"the machines which use the asn of the integer"`
+	NetworkIDs []string `json:"network_ids" description:"the networks which use the vrf of the integer"`
+}
@@ -21,6 +21,7 @@ type MachineBase struct {
 	State                    MachineState                    `json:"state" rethinkdb:"state" description:"the state of this machine"`
 	LEDState                 ChassisIdentifyLEDState         `json:"ledstate" rethinkdb:"ledstate" description:"the state of this chassis identify LED"`
 	Liveliness               string                          `json:"liveliness" description:"the liveliness of this machine"`
+	PowerSaving              bool                            `json:"powersaving" description:"if the waiting machine was powered off by the power policy of its partition, it is powered on as soon as it is needed"`
 	RecentProvisioningEvents MachineRecentProvisioningEvents `json:"events" description:"recent events of this machine during provisioning"`
 	Tags                     []string                        `json:"tags" description:"tags for this machine"`
 }
@@ -42,6 +43,23 @@ type MachineAllocation struct {
 	BootInfo         *BootInfo                 `json:"boot_info" description:"information required for booting the machine from HD" optional:"true"`
 	Role             string                    `json:"role" enum:"machine|firewall" description:"the role of the machine"`
 	VPN              *MachineVPN               `json:"vpn" description:"vpn connection info for machine" optional:"true"`
+	Lease            *MachineLease             `json:"lease" description:"the lease of the machine, the machine is freed when it expires" optional:"true"`
+	Preemptible      bool                      `json:"preemptible" description:"if set, the machine is freed for allocations of a higher priority when no other machine is available"`
+	Priority         int                       `json:"priority" description:"the priority of the project at the time of the allocation"`
+	Preempted        bool                      `json:"preempted" description:"if set, the machine was claimed by another allocation and is freed after the preemption grace period"`
+}
+
+type MachineLease struct {
+	Expires time.Time `json:"expires" description:"the point in time when the machine is freed"`
+}
+
+type MachineTransferRequest struct {
+	ProjectID        string  `json:"projectid" description:"the project the machine is transferred to"`
+	PrivateNetworkID *string `json:"privatenetworkid,omitempty" description:"the private network of the target project the machine is attached to. if not set, the private network of the machine is transferred to the target project as well, which requires that no other machine is placed in it" optional:"true"`
+}
+
+type MachineLeaseExtendRequest struct {
+	Duration time.Duration `json:"duration" description:"the duration by which the lease is extended"`
 }
 
 type BootInfo struct {
@@ -86,10 +104,24 @@ type MachineHardware struct {
 }
 
 type MachineState struct {
-	Value              string `json:"value" enum:"RESERVED|LOCKED|" description:"the state of this machine. empty means available for all"`
-	Description        string `json:"description" description:"a description why this machine is in the given state"`
-	Issuer             string `json:"issuer,omitempty" optional:"true" description:"the user that changed the state"`
-	MetalHammerVersion string `json:"metal_hammer_version" description:"the version of metal hammer which put the machine in waiting state"`
+	Value              string                    `json:"value" enum:"RESERVED|LOCKED|MAINTENANCE|" description:"the state of this machine. empty means available for all"`
+	Description        string                    `json:"description" description:"a description why this machine is in the given state"`
+	Issuer             string                    `json:"issuer,omitempty" optional:"true" description:"the user that changed the state"`
+	MetalHammerVersion string                    `json:"metal_hammer_version" description:"the version of metal hammer which put the machine in waiting state"`
+	MaintenanceWindow  *MachineMaintenanceWindow `json:"maintenance_window,omitempty" optional:"true" description:"the period of the maintenance, only applicable to the maintenance state. if the window starts in the future, the machine keeps its state until the window starts"`
+	Scheduled          *MachineScheduledState    `json:"scheduled,omitempty" optional:"true" description:"the maintenance which is applied when its window starts, it is cancelled when the state is changed. ignored in state change requests"`
+}
+
+type MachineScheduledState struct {
+	Value             string                   `json:"value" enum:"MAINTENANCE" description:"the state which is applied when the window starts"`
+	Description       string                   `json:"description" description:"a description why this machine is put into the state"`
+	Issuer            string                   `json:"issuer,omitempty" optional:"true" description:"the user that scheduled the state"`
+	MaintenanceWindow MachineMaintenanceWindow `json:"maintenance_window" description:"the period of the maintenance"`
+}
+
+type MachineMaintenanceWindow struct {
+	Start *time.Time `json:"start,omitempty" optional:"true" description:"the start of the maintenance, the maintenance starts immediately if not set. until the start the machine keeps its current state"`
+	End   *time.Time `json:"end,omitempty" optional:"true" description:"the end of the maintenance, the machine becomes available again automatically at this point in time. the maintenance lasts until the state is changed if not set"`
 }
 
 type ChassisIdentifyLEDState struct {
@@ -188,7 +220,7 @@ type MachineAllocateRequest struct {
 	Hostname           *string                   `json:"hostname" description:"the hostname for the allocated machine (defaults to metal)" optional:"true"`
 	ProjectID          string                    `json:"projectid" description:"the project id to assign this machine to"`
 	PartitionID        string                    `json:"partitionid" description:"the partition id to assign this machine to"`
-	SizeID             string                    `json:"sizeid" description:"the size id to assign this machine to"`
+	SizeID             string                    `json:"sizeid" description:"the size id to assign this machine to, can be omitted if hardware requirements are given" optional:"true"`
 	ImageID            string                    `json:"imageid" description:"the image id to assign this machine to"`
 	FilesystemLayoutID *string                   `json:"filesystemlayoutid" description:"the filesystemlayout id to assing to this machine" optional:"true"`
 	SSHPubKeys         []string                  `json:"ssh_pub_keys" description:"the public ssh keys to access the machine with"`
@@ -197,6 +229,41 @@ type MachineAllocateRequest struct {
 	Networks           MachineAllocationNetworks `json:"networks" description:"the networks that this machine will be placed in." optional:"true"`
 	IPs                []string                  `json:"ips" description:"the ips to attach to this machine additionally" optional:"true"`
 	PlacementTags      []string                  `json:"placement_tags,omitempty" description:"by default machines are spread across the racks inside a partition for every project. if placement tags are provided, the machine candidate has an additional anti-affinity to other machines having the same tags"`
+	Affinity           *MachineAffinity          `json:"affinity,omitempty" description:"places the machine in or apart from the racks of other machines of the project in this partition" optional:"true"`
+	LeaseDuration      *time.Duration            `json:"lease_duration,omitempty" description:"if set, the machine is freed automatically when the lease of this duration expires" optional:"true"`
+	Requirements       []SizeConstraint          `json:"hardware_requirements,omitempty" description:"the hardware the machine must have, without a size id the sizes of the machines which satisfy the requirements are tried from the smallest to the largest" optional:"true"`
+	Preemptible        bool                      `json:"preemptible" description:"if set, the machine is freed for the allocations of projects with the same or a higher priority when no other machine is available, the priority of a project is taken from its metal-stack.io/allocation-priority annotation" optional:"true"`
+}
+
+type MachineAffinity struct {
+	RackAffinity     []MachineAffinityTerm `json:"rack_affinity,omitempty" description:"the machine is placed in a rack with machines matching these terms" optional:"true"`
+	RackAntiAffinity []MachineAffinityTerm `json:"rack_anti_affinity,omitempty" description:"the machine is placed in a rack without machines matching these terms" optional:"true"`
+}
+
+type MachineAffinityTerm struct {
+	Tags      []string `json:"tags,omitempty" description:"matches the machines of the project carrying all of these tags" optional:"true"`
+	MachineID *string  `json:"machineid,omitempty" description:"matches the machine with this id, it must be allocated to the project" optional:"true"`
+	Required  bool     `json:"required" description:"if set, the allocation fails when the term cannot be satisfied, otherwise the term is only preferred" optional:"true"`
+}
+
+type MachineAllocationDryRunResponse struct {
+	MachineID *string                      `json:"machineid" description:"the machine which would have been allocated, it is elected randomly among the finalists" optional:"true"`
+	Finalists []string                     `json:"finalists" description:"the machines which are suited equally well for the allocation"`
+	Message   *string                      `json:"message" description:"the reason why no machine is available" optional:"true"`
+	Machines  []MachineAllocationCandidate `json:"machines" description:"the machines which were considered for the allocation"`
+}
+
+type MachineAllocationCandidate struct {
+	ID     string   `json:"id" description:"the id of the machine"`
+	RackID string   `json:"rackid" description:"the rack of the machine"`
+	Reason *string  `json:"reason" description:"the reason why the machine was excluded from the allocation, not set for finalists" optional:"true"`
+	Issues []string `json:"issues" description:"the issues of the machine which prevent it from being allocated"`
+}
+
+type MachineBulkAllocateRequest struct {
+	Count    uint                   `json:"count" description:"the number of machines to allocate"`
+	Role     *string                `json:"role,omitempty" enum:"machine|firewall" description:"the role of the allocated machines, defaults to machine" optional:"true"`
+	Template MachineAllocateRequest `json:"template" description:"the allocation request which is used for every machine, a specific machine or additional ips can only be requested for a single machine. if more than one machine is allocated, the hostname is suffixed with the index of the machine"`
 }
 
 type MachineAllocationNetworks []MachineAllocationNetwork
@@ -224,6 +291,13 @@ type MachineResponse struct {
 	Timestamps
 }
 
+// MachineWatchEvent is sent to clients watching machines.
+type MachineWatchEvent struct {
+	Type            string           `json:"type" enum:"create|update|delete" description:"the type of the change"`
+	ResourceVersion int64            `json:"resource_version" description:"the version of this change, can be used to resume watching after this event"`
+	Machine         *MachineResponse `json:"machine" description:"the machine after the change, for deletions the last known state of the machine"`
+}
+
 type MachineConsolePasswordRequest struct {
 	ID     string `json:"id" description:"id of the machine to get the consolepassword for"`
 	Reason string `json:"reason" description:"reason why the consolepassword is requested, typically a incident number with short description"`
@@ -263,7 +337,8 @@ type MachineIpmiReportResponse struct {
 
 type MachineReinstallRequest struct {
 	Common
-	ImageID string `json:"imageid" description:"the image id to be installed"`
+	ImageID      string `json:"imageid" description:"the image id to be installed"`
+	PatchUpgrade *bool  `json:"patch_upgrade" description:"installs the newest supported image of the same operating system and minor version as the given image which is compatible with the size and the filesystem layout of the machine, defaults to the project annotation metal-stack.io/image-patch-upgrade" optional:"true"`
 }
 
 type MachineIssuesRequest struct {
@@ -526,6 +601,10 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 			FilesystemLayout: NewFilesystemLayoutResponse(m.Allocation.FilesystemLayout),
 			Role:             string(m.Allocation.Role),
 			VPN:              NewMachineVPN(m.Allocation.VPN),
+			Lease:            NewMachineLease(m.Allocation.Lease),
+			Preemptible:      m.Allocation.Preemptible,
+			Priority:         m.Allocation.Priority,
+			Preempted:        m.Allocation.Preempted,
 		}
 
 		allocation.Reinstall = m.Allocation.Reinstall
@@ -577,12 +656,15 @@ func NewMachineResponse(m *metal.Machine, s *metal.Size, p *metal.Partition, i *
 				Description:        m.State.Description,
 				Issuer:             m.State.Issuer,
 				MetalHammerVersion: m.State.MetalHammerVersion,
+				MaintenanceWindow:  NewMachineMaintenanceWindow(m.State.MaintenanceWindow),
+				Scheduled:          NewMachineScheduledState(m.State.ScheduledMaintenance),
 			},
 			LEDState: ChassisIdentifyLEDState{
 				Value:       string(m.LEDState.Value),
 				Description: m.LEDState.Description,
 			},
 			Liveliness:               liveliness,
+			PowerSaving:              m.PowerSaving,
 			RecentProvisioningEvents: *NewMachineRecentProvisioningEvents(ec),
 			Tags:                     tags,
 		},
@@ -633,6 +715,48 @@ func NewMachineRecentProvisioningEvents(ec *metal.ProvisioningEventContainer) *M
 	}
 }
 
+func NewMachineMaintenanceWindow(w *metal.MaintenanceWindow) *MachineMaintenanceWindow {
+	if w == nil {
+		return nil
+	}
+	return &MachineMaintenanceWindow{
+		Start: w.Start,
+		End:   w.End,
+	}
+}
+
+func NewMachineScheduledState(s *metal.ScheduledMaintenance) *MachineScheduledState {
+	if s == nil {
+		return nil
+	}
+	return &MachineScheduledState{
+		Value:             string(metal.MaintenanceState),
+		Description:       s.Description,
+		Issuer:            s.Issuer,
+		MaintenanceWindow: *NewMachineMaintenanceWindow(&s.Window),
+	}
+}
+
+func NewMetalMaintenanceWindow(w *MachineMaintenanceWindow) *metal.MaintenanceWindow {
+	if w == nil {
+		return nil
+	}
+	return &metal.MaintenanceWindow{
+		Start: w.Start,
+		End:   w.End,
+	}
+}
+
+func NewMachineLease(l *metal.MachineLease) *MachineLease {
+	if l == nil {
+		return nil
+	}
+
+	return &MachineLease{
+		Expires: l.Expires,
+	}
+}
+
 func NewMachineVPN(m *metal.MachineVPN) *MachineVPN {
 	if m == nil {
 		return nil
@@ -644,3 +768,57 @@ func NewMachineVPN(m *metal.MachineVPN) *MachineVPN {
 		Connected:           m.Connected,
 	}
 }
+
+func NewMetalMachineAffinity(r *MachineAffinity) metal.MachineAffinity {
+	if r == nil {
+		return metal.MachineAffinity{}
+	}
+
+	terms := func(ts []MachineAffinityTerm) []metal.AffinityTerm {
+		var result []metal.AffinityTerm
+		for _, t := range ts {
+			var machineID string
+			if t.MachineID != nil {
+				machineID = *t.MachineID
+			}
+			result = append(result, metal.AffinityTerm{
+				Tags:      t.Tags,
+				MachineID: machineID,
+				Required:  t.Required,
+			})
+		}
+		return result
+	}
+
+	return metal.MachineAffinity{
+		RackAffinity:     terms(r.RackAffinity),
+		RackAntiAffinity: terms(r.RackAntiAffinity),
+	}
+}
+
+func NewMachineAffinity(a metal.MachineAffinity) *MachineAffinity {
+	if a.IsEmpty() {
+		return nil
+	}
+
+	terms := func(ts []metal.AffinityTerm) []MachineAffinityTerm {
+		var result []MachineAffinityTerm
+		for _, t := range ts {
+			term := MachineAffinityTerm{
+				Tags:     t.Tags,
+				Required: t.Required,
+			}
+			if t.MachineID != "" {
+				machineID := t.MachineID
+				term.MachineID = &machineID
+			}
+			result = append(result, term)
+		}
+		return result
+	}
+
+	return &MachineAffinity{
+		RackAffinity:     terms(a.RackAffinity),
+		RackAntiAffinity: terms(a.RackAntiAffinity),
+	}
+}
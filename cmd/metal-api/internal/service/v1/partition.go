@@ -5,8 +5,24 @@ import (
 )
 
 type PartitionBase struct {
-	MgmtServiceAddress         *string `json:"mgmtserviceaddress" description:"the address to the management service of this partition" optional:"true"`
-	PrivateNetworkPrefixLength *int    `json:"privatenetworkprefixlength" description:"the length of private networks for the machine's child networks in this partition, default 22" optional:"true" minimum:"16" maximum:"30"`
+	MgmtServiceAddress         *string                     `json:"mgmtserviceaddress" description:"the address to the management service of this partition" optional:"true"`
+	PrivateNetworkPrefixLength *int                        `json:"privatenetworkprefixlength" description:"the length of private networks for the machine's child networks in this partition, default 22" optional:"true" minimum:"16" maximum:"30"`
+	PlacementStrategy          *PartitionPlacementStrategy `json:"placementstrategy" description:"the strategy which elects the machine for an allocation in this partition, machines are spread across racks and picked randomly if no scorers are configured" optional:"true"`
+	PowerPolicies              []PartitionPowerPolicy      `json:"powerpolicies" description:"the power policies which power off waiting machines which are not needed as hot spares, a policy without size applies to all sizes without a policy of their own" optional:"true"`
+}
+
+type PartitionPlacementStrategy struct {
+	Scorers []PartitionPlacementScorer `json:"scorers" description:"the scorers which rate the available machines, the machine with the highest sum of weighted scores is allocated"`
+}
+
+type PartitionPlacementScorer struct {
+	Name   string  `json:"name" description:"the name of the scorer" enum:"rack-spread|bios-version|bmc-version|power-consumption|crash-loops|switch-capacity"`
+	Weight float64 `json:"weight" description:"the weight of the score compared to the other scorers, must be positive"`
+}
+
+type PartitionPowerPolicy struct {
+	SizeID    *string `json:"sizeid" description:"the size the policy applies to, applies to all sizes without a policy of their own if not given" optional:"true"`
+	HotSpares int     `json:"hotspares" description:"the number of waiting machines of the size which are kept powered on, the other waiting machines are powered off until they are needed"`
 }
 
 type PartitionBootConfiguration struct {
@@ -25,6 +41,8 @@ type PartitionUpdateRequest struct {
 	Common
 	MgmtServiceAddress         *string                     `json:"mgmtserviceaddress" description:"the address to the management service of this partition" optional:"true"`
 	PartitionBootConfiguration *PartitionBootConfiguration `json:"bootconfig" description:"the boot configuration of this partition" optional:"true"`
+	PlacementStrategy          *PartitionPlacementStrategy `json:"placementstrategy" description:"the strategy which elects the machine for an allocation in this partition, machines are spread across racks and picked randomly if no scorers are configured" optional:"true"`
+	PowerPolicies              []PartitionPowerPolicy      `json:"powerpolicies" description:"the power policies which power off waiting machines which are not needed as hot spares, a policy without size applies to all sizes without a policy of their own" optional:"true"`
 }
 
 type PartitionResponse struct {
@@ -47,14 +65,19 @@ type PartitionCapacity struct {
 }
 
 type ServerCapacity struct {
-	Size           string   `json:"size" description:"the size of the server"`
-	Total          int      `json:"total" description:"total amount of servers with this size"`
-	Free           int      `json:"free" description:"free servers with this size"`
-	Allocated      int      `json:"allocated" description:"allocated servers with this size"`
-	Faulty         int      `json:"faulty" description:"servers with issues with this size"`
-	FaultyMachines []string `json:"faultymachines" description:"servers with issues with this size"`
-	Other          int      `json:"other" description:"servers neither free, allocated or faulty with this size"`
-	OtherMachines  []string `json:"othermachines" description:"servers neither free, allocated or faulty with this size"`
+	Size                string   `json:"size" description:"the size of the server"`
+	Total               int      `json:"total" description:"total amount of servers with this size"`
+	Free                int      `json:"free" description:"free servers with this size which can be allocated by any project"`
+	Reserved            int      `json:"reserved" description:"free servers with this size which are held back for the projects owning a reservation"`
+	Reservations        int      `json:"reservations" description:"the amount of servers with this size which reservations still hold back, this can exceed the free servers"`
+	Allocated           int      `json:"allocated" description:"allocated servers with this size"`
+	Faulty              int      `json:"faulty" description:"servers with issues with this size"`
+	FaultyMachines      []string `json:"faultymachines" description:"servers with issues with this size"`
+	Maintenance         int      `json:"maintenance" description:"servers in maintenance with this size which are not allocated, allocated servers in maintenance are drained and counted as allocated"`
+	MaintenanceMachines []string `json:"maintenancemachines" description:"servers in maintenance with this size which are not allocated"`
+	PoweredOff          int      `json:"poweredoff" description:"free servers with this size which are powered off by the power policy of the partition, they are powered on as soon as they are needed"`
+	Other               int      `json:"other" description:"servers neither free, allocated, in maintenance or faulty with this size"`
+	OtherMachines       []string `json:"othermachines" description:"servers neither free, allocated, in maintenance or faulty with this size"`
 }
 
 func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
@@ -75,6 +98,8 @@ func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
 		PartitionBase: PartitionBase{
 			MgmtServiceAddress:         &p.MgmtServiceAddress,
 			PrivateNetworkPrefixLength: &prefixLength,
+			PlacementStrategy:          NewPartitionPlacementStrategy(p.PlacementStrategy),
+			PowerPolicies:              NewPartitionPowerPolicies(p.PowerPolicies),
 		},
 		PartitionBootConfiguration: PartitionBootConfiguration{
 			ImageURL:    &p.BootConfiguration.ImageURL,
@@ -88,6 +113,61 @@ func NewPartitionResponse(p *metal.Partition) *PartitionResponse {
 	}
 }
 
+// NewPlacementStrategy returns the placement strategy of a partition from its request.
+func NewPlacementStrategy(s PartitionPlacementStrategy) metal.PlacementStrategy {
+	var result metal.PlacementStrategy
+	for _, sc := range s.Scorers {
+		result.Scorers = append(result.Scorers, metal.PlacementScorer{
+			Name:   metal.PlacementScorerName(sc.Name),
+			Weight: sc.Weight,
+		})
+	}
+	return result
+}
+
+func NewPartitionPlacementStrategy(s metal.PlacementStrategy) *PartitionPlacementStrategy {
+	result := &PartitionPlacementStrategy{
+		Scorers: []PartitionPlacementScorer{},
+	}
+	for _, sc := range s.Scorers {
+		result.Scorers = append(result.Scorers, PartitionPlacementScorer{
+			Name:   string(sc.Name),
+			Weight: sc.Weight,
+		})
+	}
+	return result
+}
+
+// NewPowerPolicies returns the power policies of a partition from its request.
+func NewPowerPolicies(ps []PartitionPowerPolicy) metal.PowerPolicies {
+	var result metal.PowerPolicies
+	for _, p := range ps {
+		policy := metal.PowerPolicy{
+			HotSpares: p.HotSpares,
+		}
+		if p.SizeID != nil {
+			policy.SizeID = *p.SizeID
+		}
+		result = append(result, policy)
+	}
+	return result
+}
+
+func NewPartitionPowerPolicies(ps metal.PowerPolicies) []PartitionPowerPolicy {
+	result := []PartitionPowerPolicy{}
+	for i := range ps {
+		p := ps[i]
+		policy := PartitionPowerPolicy{
+			HotSpares: p.HotSpares,
+		}
+		if p.SizeID != "" {
+			policy.SizeID = &p.SizeID
+		}
+		result = append(result, policy)
+	}
+	return result
+}
+
 func (s ServerCapacities) FindBySize(size string) *ServerCapacity {
 	for _, sc := range s {
 		sc := sc
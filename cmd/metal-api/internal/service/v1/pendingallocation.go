@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type PendingAllocationCreateRequest struct {
+	Template MachineAllocateRequest `json:"template" description:"the allocation request which is fulfilled as soon as a machine becomes available, a specific machine cannot be requested"`
+	Priority int                    `json:"priority" description:"allocations with a higher priority are fulfilled first, allocations with the same priority in the order they were queued" optional:"true"`
+	Deadline time.Time              `json:"deadline" description:"the point in time until which the allocation must be fulfilled, afterwards it expires"`
+}
+
+type PendingAllocationResponse struct {
+	Identifiable
+	Template  MachineAllocateRequest `json:"template" description:"the allocation request which is fulfilled as soon as a machine becomes available"`
+	Priority  int                    `json:"priority" description:"allocations with a higher priority are fulfilled first, allocations with the same priority in the order they were queued"`
+	Deadline  time.Time              `json:"deadline" description:"the point in time until which the allocation must be fulfilled, afterwards it expires"`
+	Creator   string                 `json:"creator" description:"the user who queued the allocation"`
+	State     string                 `json:"state" description:"the state of the allocation" enum:"pending|allocating|fulfilled|failed|expired"`
+	Position  *int                   `json:"position" description:"the position of the allocation in the queue of its partition and size, starting at 1, only set while the allocation is pending" optional:"true"`
+	MachineID *string                `json:"machineid" description:"the machine which was allocated for the request" optional:"true"`
+	Preempted *string                `json:"preempted_machineid" description:"the preempted machine which is allocated for the request as soon as it was freed" optional:"true"`
+	Message   *string                `json:"message" description:"the reason why the allocation failed" optional:"true"`
+	Timestamps
+}
+
+func NewPendingAllocation(r PendingAllocationCreateRequest, creator string) *metal.PendingAllocation {
+	var (
+		name          string
+		description   string
+		hostname      = "metal"
+		networks      []metal.PendingAllocationNetwork
+		leaseDuration time.Duration
+		requirements  []metal.Constraint
+	)
+	if r.Template.Name != nil {
+		name = *r.Template.Name
+	}
+	if r.Template.Description != nil {
+		description = *r.Template.Description
+	}
+	if r.Template.Hostname != nil {
+		hostname = *r.Template.Hostname
+	}
+	if r.Template.LeaseDuration != nil {
+		leaseDuration = *r.Template.LeaseDuration
+	}
+	for _, c := range r.Template.Requirements {
+		requirements = append(requirements, metal.Constraint{Type: c.Type, Min: c.Min, Max: c.Max})
+	}
+	for _, n := range r.Template.Networks {
+		networks = append(networks, metal.PendingAllocationNetwork{
+			NetworkID:     n.NetworkID,
+			AutoAcquireIP: n.AutoAcquireIP,
+		})
+	}
+
+	return &metal.PendingAllocation{
+		Base: metal.Base{
+			Name:        name,
+			Description: description,
+		},
+		Creator:            creator,
+		Hostname:           hostname,
+		ProjectID:          r.Template.ProjectID,
+		PartitionID:        r.Template.PartitionID,
+		SizeID:             r.Template.SizeID,
+		Requirements:       requirements,
+		ImageID:            r.Template.ImageID,
+		FilesystemLayoutID: r.Template.FilesystemLayoutID,
+		SSHPubKeys:         r.Template.SSHPubKeys,
+		UserData:           r.Template.UserData,
+		Tags:               r.Template.Tags,
+		Networks:           networks,
+		IPs:                r.Template.IPs,
+		PlacementTags:      r.Template.PlacementTags,
+		Affinity:           NewMetalMachineAffinity(r.Template.Affinity),
+		LeaseDuration:      leaseDuration,
+		Preemptible:        r.Template.Preemptible,
+		Priority:           r.Priority,
+		Deadline:           r.Deadline,
+		State:              metal.PendingAllocationStatePending,
+	}
+}
+
+// NewPendingAllocationTemplate returns the allocation request of a pending allocation.
+func NewPendingAllocationTemplate(p *metal.PendingAllocation) MachineAllocateRequest {
+	var networks MachineAllocationNetworks
+	for _, n := range p.Networks {
+		networks = append(networks, MachineAllocationNetwork{
+			NetworkID:     n.NetworkID,
+			AutoAcquireIP: n.AutoAcquireIP,
+		})
+	}
+
+	var leaseDuration *time.Duration
+	if p.LeaseDuration > 0 {
+		leaseDuration = &p.LeaseDuration
+	}
+
+	var requirements []SizeConstraint
+	for _, c := range p.Requirements {
+		requirements = append(requirements, SizeConstraint{Type: c.Type, Min: c.Min, Max: c.Max})
+	}
+
+	hostname := p.Hostname
+	return MachineAllocateRequest{
+		Describable: Describable{
+			Name:        &p.Name,
+			Description: &p.Description,
+		},
+		Hostname:           &hostname,
+		ProjectID:          p.ProjectID,
+		PartitionID:        p.PartitionID,
+		SizeID:             p.SizeID,
+		Requirements:       requirements,
+		ImageID:            p.ImageID,
+		FilesystemLayoutID: p.FilesystemLayoutID,
+		SSHPubKeys:         p.SSHPubKeys,
+		UserData:           p.UserData,
+		Tags:               p.Tags,
+		Networks:           networks,
+		IPs:                p.IPs,
+		PlacementTags:      p.PlacementTags,
+		Affinity:           NewMachineAffinity(p.Affinity),
+		LeaseDuration:      leaseDuration,
+		Preemptible:        p.Preemptible,
+	}
+}
+
+// NewPendingAllocationResponse returns the response of a pending allocation, position is the position in the queue
+// of its partition and size and only set for allocations which are still pending.
+func NewPendingAllocationResponse(p *metal.PendingAllocation, position *int) *PendingAllocationResponse {
+	resp := &PendingAllocationResponse{
+		Identifiable: Identifiable{ID: p.ID},
+		Template:     NewPendingAllocationTemplate(p),
+		Priority:     p.Priority,
+		Deadline:     p.Deadline,
+		Creator:      p.Creator,
+		State:        string(p.State),
+		Position:     position,
+		Timestamps: Timestamps{
+			Created: p.Created,
+			Changed: p.Changed,
+		},
+	}
+	if p.MachineID != "" {
+		resp.MachineID = &p.MachineID
+	}
+	if p.Message != "" {
+		resp.Message = &p.Message
+	}
+	if p.PreemptedMachineID != "" {
+		resp.Preempted = &p.PreemptedMachineID
+	}
+	return resp
+}
@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type PowerConsumptionRequest struct {
+	PartitionID *string `json:"partitionid" description:"the partition to filter for" optional:"true"`
+	SizeID      *string `json:"sizeid" description:"the size to filter for" optional:"true"`
+	ProjectID   *string `json:"projectid" description:"the allocation project to filter for" optional:"true"`
+}
+
+type PowerConsumption struct {
+	Machines             int      `json:"machines" description:"the number of machines"`
+	ReportingMachines    int      `json:"reportingmachines" description:"the number of machines whose power consumption is counted"`
+	StaleMachines        []string `json:"stalemachines" description:"the machines whose power metric was not reported recently, they are not counted"`
+	AverageConsumedWatts float64  `json:"averageconsumedwatts" description:"the summed up average power consumption of the counted machines in watts"`
+	MinConsumedWatts     float64  `json:"minconsumedwatts" description:"the summed up minimum power consumption of the counted machines in watts"`
+	MaxConsumedWatts     float64  `json:"maxconsumedwatts" description:"the summed up maximum power consumption of the counted machines in watts"`
+}
+
+type PartitionPowerConsumption struct {
+	ID       string                      `json:"id" description:"the id of the partition"`
+	Total    PowerConsumption            `json:"total" description:"the power consumption of all machines of the partition"`
+	Racks    map[string]PowerConsumption `json:"racks" description:"the power consumption of the machines by rack"`
+	Sizes    map[string]PowerConsumption `json:"sizes" description:"the power consumption of the machines by size"`
+	Projects map[string]PowerConsumption `json:"projects" description:"the power consumption of the allocated machines by project"`
+}
+
+type EnergyUsageRequest struct {
+	From        time.Time  `json:"from" description:"the start of the period"`
+	To          *time.Time `json:"to" description:"the end of the period, defaults to now" optional:"true"`
+	PartitionID *string    `json:"partitionid" description:"the partition to filter for" optional:"true"`
+	SizeID      *string    `json:"sizeid" description:"the size to filter for" optional:"true"`
+	ProjectID   *string    `json:"projectid" description:"the allocation project to filter for" optional:"true"`
+}
+
+type EnergyUsage struct {
+	PartitionID string  `json:"partitionid" description:"the partition of the machines"`
+	SizeID      string  `json:"sizeid" description:"the size of the machines"`
+	ProjectID   string  `json:"projectid" description:"the project which allocated the machines, empty for machines which were not allocated"`
+	WattHours   float64 `json:"watthours" description:"the energy used by the machines within the period in watt hours"`
+	Incomplete  bool    `json:"incomplete" description:"if machines whose power metric was not reported recently were left out of at least one recording"`
+}
+
+func NewPowerConsumption(pc *metal.PowerConsumption) PowerConsumption {
+	stale := []string{}
+	if len(pc.StaleMachines) > 0 {
+		stale = pc.StaleMachines
+	}
+	return PowerConsumption{
+		Machines:             pc.Machines,
+		ReportingMachines:    pc.ReportingMachines,
+		StaleMachines:        stale,
+		AverageConsumedWatts: pc.AverageConsumedWatts,
+		MinConsumedWatts:     pc.MinConsumedWatts,
+		MaxConsumedWatts:     pc.MaxConsumedWatts,
+	}
+}
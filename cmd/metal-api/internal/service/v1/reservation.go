@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type ReservationBase struct {
+	Amount  int       `json:"amount" description:"the amount of machines which are reserved"`
+	Expires time.Time `json:"expires" description:"the point in time when the reserved machines are released"`
+}
+
+type ReservationImmutable struct {
+	ProjectID   string `json:"projectid" description:"the project for which the machines are reserved"`
+	PartitionID string `json:"partitionid" description:"the partition in which the machines are reserved"`
+	SizeID      string `json:"sizeid" description:"the size of the reserved machines"`
+}
+
+type ReservationCreateRequest struct {
+	ID *string `json:"id" description:"the unique ID of this entity, auto-generated if left empty" unique:"true" optional:"true"`
+	Describable
+	ReservationBase
+	ReservationImmutable
+}
+
+type ReservationUpdateRequest struct {
+	Common
+	Amount  *int       `json:"amount" description:"the amount of machines which are reserved" optional:"true"`
+	Expires *time.Time `json:"expires" description:"the point in time when the reserved machines are released" optional:"true"`
+}
+
+type ReservationResponse struct {
+	Common
+	ReservationBase
+	ReservationImmutable
+	Remaining int  `json:"remaining" description:"the amount of reserved machines which are not yet allocated by the project"`
+	Expired   bool `json:"expired" description:"true if the reservation has expired and does not hold back any machines anymore"`
+	Timestamps
+}
+
+func NewReservation(r ReservationCreateRequest) *metal.Reservation {
+	var (
+		id          string
+		name        string
+		description string
+	)
+	if r.ID != nil {
+		id = *r.ID
+	}
+	if r.Name != nil {
+		name = *r.Name
+	}
+	if r.Description != nil {
+		description = *r.Description
+	}
+	return &metal.Reservation{
+		Base: metal.Base{
+			ID:          id,
+			Name:        name,
+			Description: description,
+		},
+		ProjectID:   r.ProjectID,
+		PartitionID: r.PartitionID,
+		SizeID:      r.SizeID,
+		Amount:      r.Amount,
+		Expires:     r.Expires,
+	}
+}
+
+// NewReservationResponse returns the response of a reservation, remaining is the amount of machines which
+// the reservation still holds back.
+func NewReservationResponse(r *metal.Reservation, remaining int, now time.Time) *ReservationResponse {
+	return &ReservationResponse{
+		Common: Common{
+			Identifiable: Identifiable{ID: r.ID},
+			Describable:  Describable{Name: &r.Name, Description: &r.Description},
+		},
+		ReservationBase: ReservationBase{
+			Amount:  r.Amount,
+			Expires: r.Expires,
+		},
+		ReservationImmutable: ReservationImmutable{
+			ProjectID:   r.ProjectID,
+			PartitionID: r.PartitionID,
+			SizeID:      r.SizeID,
+		},
+		Remaining: remaining,
+		Expired:   r.IsExpired(now),
+		Timestamps: Timestamps{
+			Created: r.Created,
+			Changed: r.Changed,
+		},
+	}
+}
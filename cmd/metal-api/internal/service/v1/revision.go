@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type RevisionResponse struct {
+	ID         string    `json:"id" description:"the unique ID of this revision"`
+	EntityKind string    `json:"entity_kind" description:"the kind of the entity, e.g. machine or switch"`
+	EntityID   string    `json:"entity_id" description:"the ID of the entity"`
+	Operation  string    `json:"operation" description:"the operation which led to this revision" enum:"create|update|delete"`
+	Timestamp  time.Time `json:"timestamp" description:"the point in time when the change happened"`
+	User       string    `json:"user" description:"the user who caused the change" optional:"true"`
+	RequestId  string    `json:"rqid" description:"the id of the request which caused the change, can be used to find the request in the audit log" optional:"true"`
+	// the snapshot is the stored representation of the entity and not the one of the api
+	Snapshot map[string]any `json:"snapshot" description:"the state of the entity after the change, for deletions the last state of the entity"`
+}
+
+func NewRevisionResponse(r *metal.Revision) *RevisionResponse {
+	return &RevisionResponse{
+		ID:         r.ID,
+		EntityKind: r.EntityKind,
+		EntityID:   r.EntityID,
+		Operation:  string(r.Operation),
+		Timestamp:  r.Timestamp,
+		User:       r.User,
+		RequestId:  r.RequestID,
+		Snapshot:   r.Snapshot,
+	}
+}
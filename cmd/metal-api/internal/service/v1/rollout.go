@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"time"
+
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/datastore"
+	"github.com/metal-stack/metal-api/cmd/metal-api/internal/metal"
+)
+
+type RolloutCreateRequest struct {
+	Describable
+	Selector       datastore.MachineSearchQuery `json:"selector" description:"selects the machines which are reinstalled, only allocated machines which are neither locked nor in maintenance are selected"`
+	ImageID        string                       `json:"imageid" description:"the image the machines are reinstalled with, the patch upgrade defaults of the projects do not apply to rollouts"`
+	MaxUnavailable int                          `json:"max_unavailable" description:"the maximum number of machines which are reinstalled at the same time"`
+	Timeout        *time.Duration               `json:"timeout" description:"the duration after which the reinstallation of a machine failed if it did not phone home, defaults to one hour" optional:"true"`
+}
+
+type RolloutResponse struct {
+	Common
+	ImageID        string           `json:"imageid" description:"the image the machines are reinstalled with"`
+	MaxUnavailable int              `json:"max_unavailable" description:"the maximum number of machines which are reinstalled at the same time"`
+	Timeout        time.Duration    `json:"timeout" description:"the duration after which the reinstallation of a machine failed if it did not phone home"`
+	Creator        string           `json:"creator" description:"the user who created the rollout"`
+	State          string           `json:"state" description:"the state of the rollout" enum:"running|paused|aborted|finished"`
+	Message        *string          `json:"message" description:"the reason why the rollout was paused" optional:"true"`
+	Progress       RolloutProgress  `json:"progress" description:"the number of machines of the rollout by the state of their reinstallation"`
+	Machines       []RolloutMachine `json:"machines" description:"the machines of the rollout in the order they are reinstalled"`
+	Timestamps
+}
+
+type RolloutProgress struct {
+	Pending      int `json:"pending" description:"the number of machines which were not reinstalled yet"`
+	Reinstalling int `json:"reinstalling" description:"the number of machines which are reinstalled currently"`
+	Succeeded    int `json:"succeeded" description:"the number of machines which phoned home after their reinstallation"`
+	Failed       int `json:"failed" description:"the number of machines whose reinstallation failed"`
+}
+
+type RolloutMachine struct {
+	MachineID string     `json:"machineid" description:"the id of the machine"`
+	State     string     `json:"state" description:"the state of the reinstallation of the machine" enum:"pending|reinstalling|succeeded|failed"`
+	Started   *time.Time `json:"started" description:"the point in time the reinstallation was started" optional:"true"`
+	Finished  *time.Time `json:"finished" description:"the point in time the reinstallation succeeded or failed" optional:"true"`
+	Message   *string    `json:"message" description:"the reason why the reinstallation failed" optional:"true"`
+}
+
+func NewRolloutResponse(r *metal.Rollout) *RolloutResponse {
+	resp := &RolloutResponse{
+		Common: Common{
+			Identifiable: Identifiable{ID: r.ID},
+			Describable: Describable{
+				Name:        &r.Name,
+				Description: &r.Description,
+			},
+		},
+		ImageID:        r.ImageID,
+		MaxUnavailable: r.MaxUnavailable,
+		Timeout:        r.Timeout,
+		Creator:        r.Creator,
+		State:          string(r.State),
+		Progress: RolloutProgress{
+			Pending:      r.Count(metal.RolloutMachineStatePending),
+			Reinstalling: r.Count(metal.RolloutMachineStateReinstalling),
+			Succeeded:    r.Count(metal.RolloutMachineStateSucceeded),
+			Failed:       r.Count(metal.RolloutMachineStateFailed),
+		},
+		Machines: []RolloutMachine{},
+		Timestamps: Timestamps{
+			Created: r.Created,
+			Changed: r.Changed,
+		},
+	}
+	if r.Message != "" {
+		resp.Message = &r.Message
+	}
+
+	for i := range r.Machines {
+		m := r.Machines[i]
+		rm := RolloutMachine{
+			MachineID: m.MachineID,
+			State:     string(m.State),
+		}
+		if !m.Started.IsZero() {
+			rm.Started = &m.Started
+		}
+		if !m.Finished.IsZero() {
+			rm.Finished = &m.Finished
+		}
+		if m.Message != "" {
+			rm.Message = &m.Message
+		}
+		resp.Machines = append(resp.Machines, rm)
+	}
+
+	return resp
+}
@@ -879,6 +879,7 @@ func InitMockDBData(mock *r.Mock) {
 	mock.On(r.DB("mockdb").Table("switch")).Return(TestSwitches, nil)
 	mock.On(r.DB("mockdb").Table("switchstatus")).Return(TestSwitchStates, nil)
 	mock.On(r.DB("mockdb").Table("event")).Return(TestEvents, nil)
+	mock.On(r.DB("mockdb").Table("reservation")).Return([]metal.Reservation{}, nil)
 
 	// X.Delete
 	mock.On(r.DB("mockdb").Table("machine").Get(r.MockAnything()).Delete()).Return(EmptyResult, nil)
@@ -910,6 +911,7 @@ func InitMockDBData(mock *r.Mock) {
 	mock.On(r.DB("mockdb").Table("switch").Insert(r.MockAnything())).Return(EmptyResult, nil)
 	mock.On(r.DB("mockdb").Table("switchstatus").Insert(r.MockAnything())).Return(EmptyResult, nil)
 	mock.On(r.DB("mockdb").Table("wait").Insert(r.MockAnything())).Return(EmptyResult, nil)
+	mock.On(r.DB("mockdb").Table("revision").Insert(r.MockAnything())).Return(EmptyResult, nil)
 
 	mock.On(r.DB("mockdb").Table("machine").Insert(r.MockAnything(), r.InsertOpts{
 		Conflict: "replace",
@@ -11,6 +11,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"google.golang.org/protobuf/types/known/wrapperspb"
@@ -18,6 +19,7 @@ import (
 	v1 "github.com/metal-stack/masterdata-api/api/v1"
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/service/s3client"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/metal-stack/metal-api/cmd/metal-api/internal/grpc"
@@ -69,12 +71,14 @@ const (
 var (
 	logger *zap.SugaredLogger
 
-	ds                 *datastore.RethinkStore
+	ds                 datastore.Store
 	ipamer             *ipam.Ipam
 	publisherTLSConfig *bus.TLSConfig
 	nsqer              *eventbus.NSQClient
 	mdc                mdm.Client
 	headscaleClient    *headscale.HeadscaleClient
+	pendingAllocations *service.PendingAllocationQueue
+	rollouts           *service.RolloutRunner
 )
 
 var rootCmd = &cobra.Command{
@@ -125,6 +129,149 @@ var migrateDatabase = &cobra.Command{
 	},
 }
 
+var migrateStatus = &cobra.Command{
+	Use:     "status",
+	Short:   "shows the applied database version and the pending migrations",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		err := connectDataStore(DataStoreConnectNoDemotion)
+		if err != nil {
+			return err
+		}
+
+		current, err := ds.MigrationVersion()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("applied version: %d\n\n", current)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tSTATUS\tREVERSIBLE\tDESCRIPTION")
+		for _, m := range datastore.RegisteredMigrations() {
+			status := "pending"
+			if m.Version <= current {
+				status = "applied"
+			}
+			reversible := "no"
+			if m.Reversible() {
+				reversible = "yes"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, status, reversible, m.Name)
+		}
+
+		return w.Flush()
+	},
+}
+
+var migrateFromRethinkDB = &cobra.Command{
+	Use:     "migrate-from-rethinkdb",
+	Short:   "copies all data from rethinkdb into the configured postgres database",
+	Long:    "copies all entities and integer pools from rethinkdb into postgres, the command can be repeated as existing entities are overwritten",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		if viper.GetString("db") != "postgres" {
+			return errors.New("the target database adapter must be postgres")
+		}
+
+		err := connectDataStore(DataStoreConnectTableInit, DataStoreConnectNoDemotion)
+		if err != nil {
+			return err
+		}
+
+		source := datastore.New(
+			logger.Named("rethinkdb"),
+			viper.GetString("rethinkdb-addr"),
+			viper.GetString("rethinkdb-name"),
+			viper.GetString("rethinkdb-user"),
+			viper.GetString("rethinkdb-password"),
+		)
+		err = source.Connect()
+		if err != nil {
+			return fmt.Errorf("cannot connect to rethinkdb: %w", err)
+		}
+		defer func() {
+			_ = source.Close()
+		}()
+
+		return datastore.CopyRethinkToPostgres(logger, source, ds.(*datastore.PostgresStore))
+	},
+}
+
+var exportDatabase = &cobra.Command{
+	Use:     "export",
+	Short:   "exports the entire database into an archive",
+	Long:    "writes all entities, the integer pools and the migration version of the database into a gzipped tar archive which can be restored with the import command",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		err := connectDataStore()
+		if err != nil {
+			return err
+		}
+
+		path, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		meta, err := datastore.Export(logger, ds, f)
+		if err != nil {
+			return fmt.Errorf("unable to export database: %w", err)
+		}
+
+		logger.Infow("database exported", "file", f.Name(), "migration-version", meta.MigrationVersion)
+
+		return f.Close()
+	},
+}
+
+var importDatabase = &cobra.Command{
+	Use:     "import",
+	Short:   "imports an archive written by the export command into an empty database",
+	Long:    "restores all entities and integer pools of an archive, the database must be empty and migrated to the migration version of the archive",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		err := connectDataStore(DataStoreConnectTableInit, DataStoreConnectNoDemotion)
+		if err != nil {
+			return err
+		}
+
+		path, err := cmd.Flags().GetString("file")
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		meta, err := datastore.Import(logger, ds, f)
+		if err != nil {
+			return fmt.Errorf("unable to import database: %w", err)
+		}
+
+		logger.Infow("database imported", "file", f.Name(), "created", meta.Created, "migration-version", meta.MigrationVersion)
+
+		return nil
+	},
+}
+
 var dumpSwagger = &cobra.Command{
 	Use:     "dump-swagger",
 	Short:   "dump the current swagger configuration",
@@ -167,6 +314,62 @@ var machineLiveliness = &cobra.Command{
 		return evaluateLiveliness()
 	},
 }
+var releaseExpiredReservationsCmd = &cobra.Command{
+	Use:     "release-expired-reservations",
+	Short:   "releases the reservations of projects which have expired",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return releaseExpiredReservations()
+	},
+}
+
+var expireMachineLeasesCmd = &cobra.Command{
+	Use:     "expire-machine-leases",
+	Short:   "frees the machines whose lease expired and warns about leases which expire soon",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+		initHeadscale()
+
+		return expireMachineLeases(cmd)
+	},
+}
+
+var applyMachineMaintenanceWindowsCmd = &cobra.Command{
+	Use:     "apply-machine-maintenance-windows",
+	Short:   "puts the machines into maintenance whose scheduled maintenance window started and makes the machines available again whose maintenance window ended",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return applyMachineMaintenanceWindows()
+	},
+}
+
+var enforcePowerPoliciesCmd = &cobra.Command{
+	Use:     "enforce-power-policies",
+	Short:   "powers off idle waiting machines which are not needed as hot spares and powers machines on again when hot spares are missing",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return enforcePowerPolicies(cmd)
+	},
+}
+
+var recordPowerConsumptionCmd = &cobra.Command{
+	Use:     "record-power-consumption",
+	Short:   "records the power consumption of the machines by partition, size and project to account their energy usage",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return recordPowerConsumption(cmd)
+	},
+}
+
 var machineConnectedToVPN = &cobra.Command{
 	Use:     "machines-vpn-connected",
 	Short:   "evaluates whether machines connected to vpn",
@@ -196,6 +399,18 @@ var deleteOrphanImagesCmd = &cobra.Command{
 	},
 }
 
+var fsckCmd = &cobra.Command{
+	Use:     "fsck",
+	Short:   "checks the consistency between the entities of the database and the ipam",
+	Long:    "runs a catalogue of checks for dangling references between entities and prints the findings as json, repairs must not run while machines or ips are allocated",
+	Version: v.V.String(),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		initLogging()
+
+		return fsck(cmd)
+	},
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
 		logger.Fatalw("failed executing root command", "error", err)
@@ -209,10 +424,19 @@ func init() {
 		dumpSwagger,
 		initDatabase,
 		migrateDatabase,
+		migrateFromRethinkDB,
+		exportDatabase,
+		importDatabase,
 		resurrectMachines,
 		machineLiveliness,
+		releaseExpiredReservationsCmd,
+		expireMachineLeasesCmd,
+		applyMachineMaintenanceWindowsCmd,
+		enforcePowerPoliciesCmd,
+		recordPowerConsumptionCmd,
 		deleteOrphanImagesCmd,
 		machineConnectedToVPN,
+		fsckCmd,
 	)
 
 	rootCmd.Flags().StringP("config", "c", "", "alternative path to config file")
@@ -223,6 +447,9 @@ func init() {
 	rootCmd.Flags().IntP("grpc-port", "", 50051, "the port to serve gRPC on")
 	rootCmd.Flags().Bool("init-data-store", true, "initializes the data store on start (can be switched off when running the init command before starting instances)")
 	rootCmd.Flags().UintP("password-reason-minlength", "", 0, "if machine console password is requested this defines if and how long the given reason must be")
+	rootCmd.Flags().Duration("preemption-grace-period", 30*time.Second, "the time a preemptible machine is given to finish its work before it is freed for another allocation, the machine is freed with the next pending allocation interval afterwards")
+	rootCmd.Flags().Duration("pending-allocation-interval", time.Minute, "the interval in which pending allocations are expired and retried in addition to when machines start waiting")
+	rootCmd.Flags().Duration("rollout-interval", 30*time.Second, "the interval in which the progress of the rollouts is checked and their next waves are started")
 
 	rootCmd.Flags().StringP("base-path", "", "/", "the base path of the api server")
 
@@ -231,11 +458,17 @@ func init() {
 	rootCmd.Flags().StringP("s3-secret", "", "", "the secret of the s3 server that provides firmwares")
 	rootCmd.Flags().StringP("s3-firmware-bucket", "", "", "the bucket that contains the firmwares")
 
-	rootCmd.PersistentFlags().StringP("db", "", "rethinkdb", "the database adapter to use")
+	rootCmd.PersistentFlags().StringP("db", "", "rethinkdb", "the database adapter to use (rethinkdb|postgres|memory)")
 	rootCmd.PersistentFlags().StringP("db-name", "", "metalapi", "the database name to use")
 	rootCmd.PersistentFlags().StringP("db-addr", "", "", "the database address string to use")
 	rootCmd.PersistentFlags().StringP("db-user", "", "", "the database user to use")
 	rootCmd.PersistentFlags().StringP("db-password", "", "", "the database password to use")
+	rootCmd.PersistentFlags().StringP("db-sslmode", "", "disable", "the ssl mode to connect to the database, only used by postgres")
+	rootCmd.PersistentFlags().Uint("vrf-pool-range-min", datastore.DefaultVRFPoolRangeMin, "the smallest integer of the vrf pool, only applied when the pool is initialized")
+	rootCmd.PersistentFlags().Uint("vrf-pool-range-max", datastore.DefaultVRFPoolRangeMax, "the largest integer of the vrf pool, only applied when the pool is initialized")
+	rootCmd.PersistentFlags().Uint("asn-pool-range-min", datastore.DefaultASNPoolRangeMin, "the smallest integer of the asn pool, only applied when the pool is initialized")
+	rootCmd.PersistentFlags().Uint("asn-pool-range-max", datastore.DefaultASNPoolRangeMax, "the largest integer of the asn pool, only applied when the pool is initialized")
+	rootCmd.PersistentFlags().StringSlice("asn-pool-partition-ranges", nil, "gives partitions an asn pool of their own in the form partition=min-max, the ranges must not overlap with the range of the asn pool")
 
 	rootCmd.Flags().StringP("ipam-db", "", "postgres", "the database adapter to use")
 	rootCmd.Flags().StringP("ipam-db-name", "", "metal-ipam", "the database name to use")
@@ -293,10 +526,31 @@ func init() {
 	must(viper.BindPFlags(rootCmd.Flags()))
 	must(viper.BindPFlags(rootCmd.PersistentFlags()))
 
-	migrateDatabase.Flags().Int("target-version", -1, "the target version of the migration, when set to -1 will migrate to latest version")
+	migrateDatabase.AddCommand(migrateStatus)
+	migrateDatabase.Flags().Int("target-version", -1, "the target version of the migration, when set to -1 will migrate to latest version, lower versions than the current one revert migrations")
 	migrateDatabase.Flags().Bool("dry-run", false, "only shows which migrations would run, but does not execute them")
 
 	must(viper.BindPFlags(migrateDatabase.Flags()))
+
+	migrateFromRethinkDB.Flags().String("rethinkdb-name", "metalapi", "the rethinkdb database name to copy from")
+	migrateFromRethinkDB.Flags().String("rethinkdb-addr", "", "the rethinkdb database address to copy from")
+	migrateFromRethinkDB.Flags().String("rethinkdb-user", "", "the rethinkdb database user")
+	migrateFromRethinkDB.Flags().String("rethinkdb-password", "", "the rethinkdb database password")
+
+	must(viper.BindPFlags(migrateFromRethinkDB.Flags()))
+
+	exportDatabase.Flags().String("file", "metal-api-export.tar.gz", "the path of the archive to write")
+	importDatabase.Flags().String("file", "metal-api-export.tar.gz", "the path of the archive to read")
+
+	fsckCmd.Flags().Bool("repair", false, "apply the safe repairs for the findings")
+	fsckCmd.Flags().StringSlice("checks", nil, "the checks to run, all checks are run if none is given")
+
+	expireMachineLeasesCmd.Flags().Duration("warn-before", time.Hour, "the duration before the expiry of a lease in which a warning is published")
+
+	enforcePowerPoliciesCmd.Flags().Duration("idle-period", time.Hour, "the duration a machine has to wait for an allocation before it is powered off")
+
+	recordPowerConsumptionCmd.Flags().Duration("interval", 5*time.Minute, "the interval in which the recording is scheduled, every recording accounts for the energy used in this interval")
+	recordPowerConsumptionCmd.Flags().Duration("retention", 400*24*time.Hour, "the duration after which recordings are deleted, recordings are kept forever if zero")
 }
 
 func must(err error) {
@@ -455,16 +709,47 @@ func waitForPartitions() metal.Partitions {
 }
 
 func connectDataStore(opts ...dsConnectOpt) error {
+	vrfRange, asnRange, partitionASNRanges, err := integerPoolRanges()
+	if err != nil {
+		return err
+	}
+
 	dbAdapter := viper.GetString("db")
-	if dbAdapter == "rethinkdb" {
-		ds = datastore.New(
+	switch dbAdapter {
+	case "rethinkdb":
+		rs := datastore.New(
 			logger.Named("datastore"),
 			viper.GetString("db-addr"),
 			viper.GetString("db-name"),
 			viper.GetString("db-user"),
 			viper.GetString("db-password"),
 		)
-	} else {
+		rs.VRFPoolRangeMin, rs.VRFPoolRangeMax = vrfRange.Min, vrfRange.Max
+		rs.ASNPoolRangeMin, rs.ASNPoolRangeMax = asnRange.Min, asnRange.Max
+		rs.PartitionASNPoolRanges = partitionASNRanges
+		ds = rs
+	case "postgres":
+		ps := datastore.NewPostgres(
+			logger.Named("datastore"),
+			viper.GetString("db-addr"),
+			viper.GetString("db-name"),
+			viper.GetString("db-user"),
+			viper.GetString("db-password"),
+			viper.GetString("db-sslmode"),
+		)
+		ps.VRFPoolRangeMin, ps.VRFPoolRangeMax = vrfRange.Min, vrfRange.Max
+		ps.ASNPoolRangeMin, ps.ASNPoolRangeMax = asnRange.Min, asnRange.Max
+		ps.PartitionASNPoolRanges = partitionASNRanges
+		ds = ps
+	case "memory":
+		ms := datastore.NewMemory(logger.Named("datastore"))
+		ms.SetVRFPoolRange(vrfRange.Min, vrfRange.Max)
+		ms.SetASNPoolRange(asnRange.Min, asnRange.Max)
+		for partitionID, rng := range partitionASNRanges {
+			ms.SetPartitionASNPoolRange(partitionID, rng.Min, rng.Max)
+		}
+		ds = ms
+	default:
 		return fmt.Errorf("database not supported: %v", dbAdapter)
 	}
 
@@ -482,7 +767,7 @@ func connectDataStore(opts ...dsConnectOpt) error {
 		}
 	}
 
-	err := ds.Connect()
+	err = ds.Connect()
 	if err != nil {
 		return fmt.Errorf("cannot connect to data store: %w", err)
 	}
@@ -504,6 +789,47 @@ func connectDataStore(opts ...dsConnectOpt) error {
 	return nil
 }
 
+// integerPoolRanges returns the configured ranges of the vrf pool, the asn pool and the asn pools of the partitions.
+func integerPoolRanges() (vrf datastore.IntegerRange, asn datastore.IntegerRange, partitions map[string]datastore.IntegerRange, err error) {
+	vrf = datastore.IntegerRange{Min: viper.GetUint("vrf-pool-range-min"), Max: viper.GetUint("vrf-pool-range-max")}
+	err = vrf.Validate()
+	if err != nil {
+		return vrf, asn, nil, fmt.Errorf("invalid vrf pool range: %w", err)
+	}
+
+	asn = datastore.IntegerRange{Min: viper.GetUint("asn-pool-range-min"), Max: viper.GetUint("asn-pool-range-max")}
+	err = asn.Validate()
+	if err != nil {
+		return vrf, asn, nil, fmt.Errorf("invalid asn pool range: %w", err)
+	}
+	if uint64(service.ASNBase)+uint64(asn.Max) > uint64(service.ASNMax) {
+		return vrf, asn, nil, fmt.Errorf("asn pool range exceeds the maximum asn %d", service.ASNMax)
+	}
+
+	partitions = map[string]datastore.IntegerRange{}
+	for _, spec := range viper.GetStringSlice("asn-pool-partition-ranges") {
+		partitionID, rangeSpec, found := strings.Cut(spec, "=")
+		if !found || partitionID == "" {
+			return vrf, asn, nil, fmt.Errorf("asn pool range of partition %q must be in the form partition=min-max", spec)
+		}
+		rng, err := datastore.ParseIntegerRange(rangeSpec)
+		if err != nil {
+			return vrf, asn, nil, fmt.Errorf("invalid asn pool range of partition %s: %w", partitionID, err)
+		}
+		if uint64(service.ASNBase)+uint64(rng.Max) > uint64(service.ASNMax) {
+			return vrf, asn, nil, fmt.Errorf("asn pool range of partition %s exceeds the maximum asn %d", partitionID, service.ASNMax)
+		}
+		partitions[partitionID] = rng
+	}
+
+	err = datastore.ValidatePartitionASNPoolRanges(asn, partitions)
+	if err != nil {
+		return vrf, asn, nil, err
+	}
+
+	return vrf, asn, partitions, nil
+}
+
 func initMasterData() {
 	hmacKey := viper.GetString("masterdata-hmac")
 	if hmacKey == "" {
@@ -724,12 +1050,20 @@ func initRestServices(audit auditing.Auditing, withauth bool, ipmiSuperUser meta
 		userGetter = initAuth(logger)
 	}
 	reasonMinLength := viper.GetUint("password-reason-minlength")
+	preemptionGracePeriod := viper.GetDuration("preemption-grace-period")
+
+	machineService, err := service.NewMachine(logger.Named("machine-service"), ds, p, ep, ipamer, mdc, s3Client, userGetter, reasonMinLength, headscaleClient, ipmiSuperUser, preemptionGracePeriod)
+	if err != nil {
+		logger.Fatal(err)
+	}
 
-	machineService, err := service.NewMachine(logger.Named("machine-service"), ds, p, ep, ipamer, mdc, s3Client, userGetter, reasonMinLength, headscaleClient, ipmiSuperUser)
+	pendingAllocations, err = service.NewPendingAllocationQueue(logger.Named("pending-allocation-queue"), ds, p, ep, ipamer, mdc, headscaleClient)
 	if err != nil {
 		logger.Fatal(err)
 	}
 
+	rollouts = service.NewRolloutRunner(logger.Named("rollout-runner"), ds, p)
+
 	firewallService, err := service.NewFirewall(logger.Named("firewall-service"), ds, p, ipamer, ep, mdc, userGetter, headscaleClient)
 	if err != nil {
 		logger.Fatal(err)
@@ -744,6 +1078,9 @@ func initRestServices(audit auditing.Auditing, withauth bool, ipmiSuperUser meta
 	restful.DefaultContainer.Add(service.NewImage(logger.Named("image-service"), ds))
 	restful.DefaultContainer.Add(service.NewSize(logger.Named("size-service"), ds))
 	restful.DefaultContainer.Add(service.NewSizeImageConstraint(logger.Named("size-image-constraint-service"), ds))
+	restful.DefaultContainer.Add(service.NewReservation(logger.Named("reservation-service"), ds))
+	restful.DefaultContainer.Add(service.NewPendingAllocation(logger.Named("pending-allocation-service"), ds, pendingAllocations, userGetter))
+	restful.DefaultContainer.Add(service.NewRollout(logger.Named("rollout-service"), ds, rollouts, userGetter))
 	restful.DefaultContainer.Add(service.NewNetwork(logger.Named("network-service"), ds, ipamer, mdc))
 	restful.DefaultContainer.Add(ipService)
 	restful.DefaultContainer.Add(firmwareService)
@@ -754,6 +1091,9 @@ func initRestServices(audit auditing.Auditing, withauth bool, ipmiSuperUser meta
 	restful.DefaultContainer.Add(firewallService)
 	restful.DefaultContainer.Add(service.NewFilesystemLayout(logger.Named("filesystem-layout-service"), ds))
 	restful.DefaultContainer.Add(service.NewSwitch(logger.Named("switch-service"), ds))
+	restful.DefaultContainer.Add(service.NewRevision(logger.Named("revision-service"), ds))
+	restful.DefaultContainer.Add(service.NewFsck(logger.Named("fsck-service"), ds, ipamer))
+	restful.DefaultContainer.Add(service.NewIntegerPool(logger.Named("integer-pool-service"), ds))
 	restful.DefaultContainer.Add(healthService)
 	restful.DefaultContainer.Add(service.NewVPN(logger.Named("vpn-service"), headscaleClient))
 	restful.DefaultContainer.Add(rest.NewVersion(moduleName, service.BasePath))
@@ -801,6 +1141,9 @@ func initHeadscale() {
 }
 
 func dumpSwaggerJSON() {
+	// the services are only created for building the spec and never access the datastore
+	ds = datastore.NewMemory(logger.Named("datastore"))
+
 	cfg := initRestServices(nil, false, metal.DisabledIPMISuperUser())
 	actual := restfulspec.BuildSwagger(*cfg)
 
@@ -852,6 +1195,65 @@ func resurrectDeadMachines() error {
 	return nil
 }
 
+func expireMachineLeases(cmd *cobra.Command) error {
+	warnBefore, err := cmd.Flags().GetDuration("warn-before")
+	if err != nil {
+		return err
+	}
+
+	err = connectDataStore()
+	if err != nil {
+		return err
+	}
+	initEventBus()
+	initIpam()
+
+	var p bus.Publisher
+	ep := bus.DirectEndpoints()
+	if nsqer != nil {
+		p = nsqer.Publisher
+		ep = nsqer.Endpoints
+	}
+	err = service.ExpireMachineLeases(context.Background(), ds, p, ep, ipamer, headscaleClient, logger, warnBefore)
+	if err != nil {
+		return fmt.Errorf("unable to expire machine leases: %w", err)
+	}
+
+	return nil
+}
+
+func fsck(cmd *cobra.Command) error {
+	repair, err := cmd.Flags().GetBool("repair")
+	if err != nil {
+		return err
+	}
+	names, err := cmd.Flags().GetStringSlice("checks")
+	if err != nil {
+		return err
+	}
+	checks, err := service.ParseFsckChecks(names)
+	if err != nil {
+		return err
+	}
+
+	err = connectDataStore()
+	if err != nil {
+		return err
+	}
+	initIpam()
+
+	store := ds.WithRevisionInfo(datastore.RevisionInfo{User: "metal-api fsck"})
+
+	result, err := service.Fsck(store, ipamer, logger, checks, repair)
+	if err != nil {
+		return fmt.Errorf("unable to check consistency: %w", err)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(result)
+}
+
 func evaluateLiveliness() error {
 	err := connectDataStore()
 	if err != nil {
@@ -866,6 +1268,88 @@ func evaluateLiveliness() error {
 	return nil
 }
 
+func releaseExpiredReservations() error {
+	err := connectDataStore()
+	if err != nil {
+		return err
+	}
+
+	store := ds.WithRevisionInfo(datastore.RevisionInfo{User: "metal-api release-expired-reservations"})
+
+	err = service.ReleaseExpiredReservations(store, logger)
+	if err != nil {
+		return fmt.Errorf("unable to release expired reservations: %w", err)
+	}
+
+	return nil
+}
+
+func applyMachineMaintenanceWindows() error {
+	err := connectDataStore()
+	if err != nil {
+		return err
+	}
+
+	store := ds.WithRevisionInfo(datastore.RevisionInfo{User: "metal-api apply-machine-maintenance-windows"})
+
+	err = service.ApplyMachineMaintenanceWindows(store, logger)
+	if err != nil {
+		return fmt.Errorf("unable to apply machine maintenance windows: %w", err)
+	}
+
+	return nil
+}
+
+func enforcePowerPolicies(cmd *cobra.Command) error {
+	idlePeriod, err := cmd.Flags().GetDuration("idle-period")
+	if err != nil {
+		return err
+	}
+
+	err = connectDataStore()
+	if err != nil {
+		return err
+	}
+	initEventBus()
+
+	var p bus.Publisher
+	if nsqer != nil {
+		p = nsqer.Publisher
+	}
+
+	store := ds.WithRevisionInfo(datastore.RevisionInfo{User: "metal-api enforce-power-policies"})
+
+	err = service.EnforcePowerPolicies(store, p, logger, idlePeriod)
+	if err != nil {
+		return fmt.Errorf("unable to enforce power policies: %w", err)
+	}
+
+	return nil
+}
+
+func recordPowerConsumption(cmd *cobra.Command) error {
+	interval, err := cmd.Flags().GetDuration("interval")
+	if err != nil {
+		return err
+	}
+	retention, err := cmd.Flags().GetDuration("retention")
+	if err != nil {
+		return err
+	}
+
+	err = connectDataStore()
+	if err != nil {
+		return err
+	}
+
+	err = service.RecordPowerConsumption(ds, logger, interval, retention)
+	if err != nil {
+		return fmt.Errorf("unable to record power consumption: %w", err)
+	}
+
+	return nil
+}
+
 func evaluateVPNConnected() error {
 	err := connectDataStore()
 	if err != nil {
@@ -938,6 +1422,11 @@ func run() error {
 	}
 	initRestServices(audit, true, ipmiSuperUser)
 
+	prometheus.MustRegister(metrics.NewPowerConsumptionCollector(logger.Named("power-consumption"), ds))
+
+	go pendingAllocations.Run(context.Background(), viper.GetDuration("pending-allocation-interval"))
+	go rollouts.Run(context.Background(), viper.GetDuration("rollout-interval"))
+
 	// enable OPTIONS-request so clients can query CORS information
 	restful.DefaultContainer.Filter(restful.DefaultContainer.OPTIONSFilter)
 
@@ -947,7 +1436,8 @@ func run() error {
 	// because customers should have ONE token for many products.
 	// ExposeHeaders:  []string{"X-TOKEN"},
 	cors := restful.CrossOriginResourceSharing{
-		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization"},
+		AllowedHeaders: []string{"Content-Type", "Accept", "Authorization", "If-Match"},
+		ExposeHeaders:  []string{"ETag", "X-Next-Cursor"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
 		CookiesAllowed: false,
 		Container:      restful.DefaultContainer,
@@ -0,0 +1,1287 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        (unknown)
+// source: api/v1/machine.proto
+
+package v1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type MachineEventType int32
+
+const (
+	MachineEventType_MACHINE_EVENT_TYPE_UNSPECIFIED MachineEventType = 0
+	MachineEventType_MACHINE_EVENT_TYPE_CREATE      MachineEventType = 1
+	MachineEventType_MACHINE_EVENT_TYPE_UPDATE      MachineEventType = 2
+	MachineEventType_MACHINE_EVENT_TYPE_DELETE      MachineEventType = 3
+)
+
+// Enum value maps for MachineEventType.
+var (
+	MachineEventType_name = map[int32]string{
+		0: "MACHINE_EVENT_TYPE_UNSPECIFIED",
+		1: "MACHINE_EVENT_TYPE_CREATE",
+		2: "MACHINE_EVENT_TYPE_UPDATE",
+		3: "MACHINE_EVENT_TYPE_DELETE",
+	}
+	MachineEventType_value = map[string]int32{
+		"MACHINE_EVENT_TYPE_UNSPECIFIED": 0,
+		"MACHINE_EVENT_TYPE_CREATE":      1,
+		"MACHINE_EVENT_TYPE_UPDATE":      2,
+		"MACHINE_EVENT_TYPE_DELETE":      3,
+	}
+)
+
+func (x MachineEventType) Enum() *MachineEventType {
+	p := new(MachineEventType)
+	*p = x
+	return p
+}
+
+func (x MachineEventType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (MachineEventType) Descriptor() protoreflect.EnumDescriptor {
+	return file_api_v1_machine_proto_enumTypes[0].Descriptor()
+}
+
+func (MachineEventType) Type() protoreflect.EnumType {
+	return &file_api_v1_machine_proto_enumTypes[0]
+}
+
+func (x MachineEventType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use MachineEventType.Descriptor instead.
+func (MachineEventType) EnumDescriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{0}
+}
+
+type MachineServiceWatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the query the machines must match, all machines are watched if not given
+	Query *MachineQuery `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	// resumes the watch after the given resource version, machines which were created or updated
+	// after this version are sent first
+	ResourceVersion int64 `protobuf:"varint,2,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+}
+
+func (x *MachineServiceWatchRequest) Reset() {
+	*x = MachineServiceWatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineServiceWatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineServiceWatchRequest) ProtoMessage() {}
+
+func (x *MachineServiceWatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineServiceWatchRequest.ProtoReflect.Descriptor instead.
+func (*MachineServiceWatchRequest) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *MachineServiceWatchRequest) GetQuery() *MachineQuery {
+	if x != nil {
+		return x.Query
+	}
+	return nil
+}
+
+func (x *MachineServiceWatchRequest) GetResourceVersion() int64 {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return 0
+}
+
+type MachineServiceWatchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// the type of the change
+	Type MachineEventType `protobuf:"varint,1,opt,name=type,proto3,enum=api.v1.MachineEventType" json:"type,omitempty"`
+	// the version of this change, can be used to resume watching after this event
+	ResourceVersion int64 `protobuf:"varint,2,opt,name=resource_version,json=resourceVersion,proto3" json:"resource_version,omitempty"`
+	// the machine after the change, for deletions the last known state of the machine
+	Machine *Machine `protobuf:"bytes,3,opt,name=machine,proto3" json:"machine,omitempty"`
+}
+
+func (x *MachineServiceWatchResponse) Reset() {
+	*x = MachineServiceWatchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineServiceWatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineServiceWatchResponse) ProtoMessage() {}
+
+func (x *MachineServiceWatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineServiceWatchResponse.ProtoReflect.Descriptor instead.
+func (*MachineServiceWatchResponse) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *MachineServiceWatchResponse) GetType() MachineEventType {
+	if x != nil {
+		return x.Type
+	}
+	return MachineEventType_MACHINE_EVENT_TYPE_UNSPECIFIED
+}
+
+func (x *MachineServiceWatchResponse) GetResourceVersion() int64 {
+	if x != nil {
+		return x.ResourceVersion
+	}
+	return 0
+}
+
+func (x *MachineServiceWatchResponse) GetMachine() *Machine {
+	if x != nil {
+		return x.Machine
+	}
+	return nil
+}
+
+type Machine struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name         string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Description  string                 `protobuf:"bytes,3,opt,name=description,proto3" json:"description,omitempty"`
+	PartitionId  string                 `protobuf:"bytes,4,opt,name=partition_id,json=partitionId,proto3" json:"partition_id,omitempty"`
+	SizeId       string                 `protobuf:"bytes,5,opt,name=size_id,json=sizeId,proto3" json:"size_id,omitempty"`
+	RackId       string                 `protobuf:"bytes,6,opt,name=rack_id,json=rackId,proto3" json:"rack_id,omitempty"`
+	Tags         []string               `protobuf:"bytes,7,rep,name=tags,proto3" json:"tags,omitempty"`
+	State        *MachineState          `protobuf:"bytes,8,opt,name=state,proto3" json:"state,omitempty"`
+	Liveliness   string                 `protobuf:"bytes,9,opt,name=liveliness,proto3" json:"liveliness,omitempty"`
+	Waiting      bool                   `protobuf:"varint,10,opt,name=waiting,proto3" json:"waiting,omitempty"`
+	PreAllocated bool                   `protobuf:"varint,11,opt,name=pre_allocated,json=preAllocated,proto3" json:"pre_allocated,omitempty"`
+	Allocation   *MachineAllocation     `protobuf:"bytes,12,opt,name=allocation,proto3" json:"allocation,omitempty"`
+	Hardware     *MachineHardware       `protobuf:"bytes,13,opt,name=hardware,proto3" json:"hardware,omitempty"`
+	Bios         *MachineBIOS           `protobuf:"bytes,14,opt,name=bios,proto3" json:"bios,omitempty"`
+	Created      *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=created,proto3" json:"created,omitempty"`
+	Changed      *timestamppb.Timestamp `protobuf:"bytes,16,opt,name=changed,proto3" json:"changed,omitempty"`
+}
+
+func (x *Machine) Reset() {
+	*x = Machine{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Machine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Machine) ProtoMessage() {}
+
+func (x *Machine) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Machine.ProtoReflect.Descriptor instead.
+func (*Machine) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Machine) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Machine) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Machine) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Machine) GetPartitionId() string {
+	if x != nil {
+		return x.PartitionId
+	}
+	return ""
+}
+
+func (x *Machine) GetSizeId() string {
+	if x != nil {
+		return x.SizeId
+	}
+	return ""
+}
+
+func (x *Machine) GetRackId() string {
+	if x != nil {
+		return x.RackId
+	}
+	return ""
+}
+
+func (x *Machine) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Machine) GetState() *MachineState {
+	if x != nil {
+		return x.State
+	}
+	return nil
+}
+
+func (x *Machine) GetLiveliness() string {
+	if x != nil {
+		return x.Liveliness
+	}
+	return ""
+}
+
+func (x *Machine) GetWaiting() bool {
+	if x != nil {
+		return x.Waiting
+	}
+	return false
+}
+
+func (x *Machine) GetPreAllocated() bool {
+	if x != nil {
+		return x.PreAllocated
+	}
+	return false
+}
+
+func (x *Machine) GetAllocation() *MachineAllocation {
+	if x != nil {
+		return x.Allocation
+	}
+	return nil
+}
+
+func (x *Machine) GetHardware() *MachineHardware {
+	if x != nil {
+		return x.Hardware
+	}
+	return nil
+}
+
+func (x *Machine) GetBios() *MachineBIOS {
+	if x != nil {
+		return x.Bios
+	}
+	return nil
+}
+
+func (x *Machine) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+func (x *Machine) GetChanged() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Changed
+	}
+	return nil
+}
+
+type MachineState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Value       string `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Description string `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+}
+
+func (x *MachineState) Reset() {
+	*x = MachineState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineState) ProtoMessage() {}
+
+func (x *MachineState) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineState.ProtoReflect.Descriptor instead.
+func (*MachineState) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MachineState) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *MachineState) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+type MachineAllocation struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name        string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Project     string                 `protobuf:"bytes,3,opt,name=project,proto3" json:"project,omitempty"`
+	Hostname    string                 `protobuf:"bytes,4,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	ImageId     string                 `protobuf:"bytes,5,opt,name=image_id,json=imageId,proto3" json:"image_id,omitempty"`
+	Role        string                 `protobuf:"bytes,6,opt,name=role,proto3" json:"role,omitempty"`
+	Succeeded   bool                   `protobuf:"varint,7,opt,name=succeeded,proto3" json:"succeeded,omitempty"`
+	Created     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created,proto3" json:"created,omitempty"`
+}
+
+func (x *MachineAllocation) Reset() {
+	*x = MachineAllocation{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineAllocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineAllocation) ProtoMessage() {}
+
+func (x *MachineAllocation) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineAllocation.ProtoReflect.Descriptor instead.
+func (*MachineAllocation) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *MachineAllocation) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetProject() string {
+	if x != nil {
+		return x.Project
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetImageId() string {
+	if x != nil {
+		return x.ImageId
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetRole() string {
+	if x != nil {
+		return x.Role
+	}
+	return ""
+}
+
+func (x *MachineAllocation) GetSucceeded() bool {
+	if x != nil {
+		return x.Succeeded
+	}
+	return false
+}
+
+func (x *MachineAllocation) GetCreated() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Created
+	}
+	return nil
+}
+
+// MachineQuery contains the same criteria as the machine find request of the REST api
+type MachineQuery struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id                         *string  `protobuf:"bytes,1,opt,name=id,proto3,oneof" json:"id,omitempty"`
+	Name                       *string  `protobuf:"bytes,2,opt,name=name,proto3,oneof" json:"name,omitempty"`
+	PartitionId                *string  `protobuf:"bytes,3,opt,name=partition_id,json=partitionId,proto3,oneof" json:"partition_id,omitempty"`
+	SizeId                     *string  `protobuf:"bytes,4,opt,name=size_id,json=sizeId,proto3,oneof" json:"size_id,omitempty"`
+	RackId                     *string  `protobuf:"bytes,5,opt,name=rack_id,json=rackId,proto3,oneof" json:"rack_id,omitempty"`
+	Tags                       []string `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	AllocationName             *string  `protobuf:"bytes,7,opt,name=allocation_name,json=allocationName,proto3,oneof" json:"allocation_name,omitempty"`
+	AllocationProject          *string  `protobuf:"bytes,8,opt,name=allocation_project,json=allocationProject,proto3,oneof" json:"allocation_project,omitempty"`
+	AllocationImageId          *string  `protobuf:"bytes,9,opt,name=allocation_image_id,json=allocationImageId,proto3,oneof" json:"allocation_image_id,omitempty"`
+	AllocationHostname         *string  `protobuf:"bytes,10,opt,name=allocation_hostname,json=allocationHostname,proto3,oneof" json:"allocation_hostname,omitempty"`
+	AllocationRole             *string  `protobuf:"bytes,11,opt,name=allocation_role,json=allocationRole,proto3,oneof" json:"allocation_role,omitempty"`
+	AllocationSucceeded        *bool    `protobuf:"varint,12,opt,name=allocation_succeeded,json=allocationSucceeded,proto3,oneof" json:"allocation_succeeded,omitempty"`
+	NetworkIds                 []string `protobuf:"bytes,13,rep,name=network_ids,json=networkIds,proto3" json:"network_ids,omitempty"`
+	NetworkPrefixes            []string `protobuf:"bytes,14,rep,name=network_prefixes,json=networkPrefixes,proto3" json:"network_prefixes,omitempty"`
+	NetworkIps                 []string `protobuf:"bytes,15,rep,name=network_ips,json=networkIps,proto3" json:"network_ips,omitempty"`
+	NetworkDestinationPrefixes []string `protobuf:"bytes,16,rep,name=network_destination_prefixes,json=networkDestinationPrefixes,proto3" json:"network_destination_prefixes,omitempty"`
+	NetworkVrfs                []int64  `protobuf:"varint,17,rep,packed,name=network_vrfs,json=networkVrfs,proto3" json:"network_vrfs,omitempty"`
+	NetworkAsns                []int64  `protobuf:"varint,18,rep,packed,name=network_asns,json=networkAsns,proto3" json:"network_asns,omitempty"`
+	HardwareMemory             *int64   `protobuf:"varint,19,opt,name=hardware_memory,json=hardwareMemory,proto3,oneof" json:"hardware_memory,omitempty"`
+	HardwareCpuCores           *int64   `protobuf:"varint,20,opt,name=hardware_cpu_cores,json=hardwareCpuCores,proto3,oneof" json:"hardware_cpu_cores,omitempty"`
+	NicsMacAddresses           []string `protobuf:"bytes,21,rep,name=nics_mac_addresses,json=nicsMacAddresses,proto3" json:"nics_mac_addresses,omitempty"`
+	NicsNames                  []string `protobuf:"bytes,22,rep,name=nics_names,json=nicsNames,proto3" json:"nics_names,omitempty"`
+	NicsVrfs                   []string `protobuf:"bytes,23,rep,name=nics_vrfs,json=nicsVrfs,proto3" json:"nics_vrfs,omitempty"`
+	NicsNeighborMacAddresses   []string `protobuf:"bytes,24,rep,name=nics_neighbor_mac_addresses,json=nicsNeighborMacAddresses,proto3" json:"nics_neighbor_mac_addresses,omitempty"`
+	NicsNeighborNames          []string `protobuf:"bytes,25,rep,name=nics_neighbor_names,json=nicsNeighborNames,proto3" json:"nics_neighbor_names,omitempty"`
+	NicsNeighborVrfs           []string `protobuf:"bytes,26,rep,name=nics_neighbor_vrfs,json=nicsNeighborVrfs,proto3" json:"nics_neighbor_vrfs,omitempty"`
+	DiskNames                  []string `protobuf:"bytes,27,rep,name=disk_names,json=diskNames,proto3" json:"disk_names,omitempty"`
+	DiskSizes                  []int64  `protobuf:"varint,28,rep,packed,name=disk_sizes,json=diskSizes,proto3" json:"disk_sizes,omitempty"`
+	StateValue                 *string  `protobuf:"bytes,29,opt,name=state_value,json=stateValue,proto3,oneof" json:"state_value,omitempty"`
+	IpmiAddress                *string  `protobuf:"bytes,30,opt,name=ipmi_address,json=ipmiAddress,proto3,oneof" json:"ipmi_address,omitempty"`
+	IpmiMacAddress             *string  `protobuf:"bytes,31,opt,name=ipmi_mac_address,json=ipmiMacAddress,proto3,oneof" json:"ipmi_mac_address,omitempty"`
+	IpmiUser                   *string  `protobuf:"bytes,32,opt,name=ipmi_user,json=ipmiUser,proto3,oneof" json:"ipmi_user,omitempty"`
+	IpmiInterface              *string  `protobuf:"bytes,33,opt,name=ipmi_interface,json=ipmiInterface,proto3,oneof" json:"ipmi_interface,omitempty"`
+	FruChassisPartNumber       *string  `protobuf:"bytes,34,opt,name=fru_chassis_part_number,json=fruChassisPartNumber,proto3,oneof" json:"fru_chassis_part_number,omitempty"`
+	FruChassisPartSerial       *string  `protobuf:"bytes,35,opt,name=fru_chassis_part_serial,json=fruChassisPartSerial,proto3,oneof" json:"fru_chassis_part_serial,omitempty"`
+	FruBoardMfg                *string  `protobuf:"bytes,36,opt,name=fru_board_mfg,json=fruBoardMfg,proto3,oneof" json:"fru_board_mfg,omitempty"`
+	FruBoardMfgSerial          *string  `protobuf:"bytes,37,opt,name=fru_board_mfg_serial,json=fruBoardMfgSerial,proto3,oneof" json:"fru_board_mfg_serial,omitempty"`
+	FruBoardPartNumber         *string  `protobuf:"bytes,38,opt,name=fru_board_part_number,json=fruBoardPartNumber,proto3,oneof" json:"fru_board_part_number,omitempty"`
+	FruProductManufacturer     *string  `protobuf:"bytes,39,opt,name=fru_product_manufacturer,json=fruProductManufacturer,proto3,oneof" json:"fru_product_manufacturer,omitempty"`
+	FruProductPartNumber       *string  `protobuf:"bytes,40,opt,name=fru_product_part_number,json=fruProductPartNumber,proto3,oneof" json:"fru_product_part_number,omitempty"`
+	FruProductSerial           *string  `protobuf:"bytes,41,opt,name=fru_product_serial,json=fruProductSerial,proto3,oneof" json:"fru_product_serial,omitempty"`
+}
+
+func (x *MachineQuery) Reset() {
+	*x = MachineQuery{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_api_v1_machine_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MachineQuery) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MachineQuery) ProtoMessage() {}
+
+func (x *MachineQuery) ProtoReflect() protoreflect.Message {
+	mi := &file_api_v1_machine_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MachineQuery.ProtoReflect.Descriptor instead.
+func (*MachineQuery) Descriptor() ([]byte, []int) {
+	return file_api_v1_machine_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MachineQuery) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetPartitionId() string {
+	if x != nil && x.PartitionId != nil {
+		return *x.PartitionId
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetSizeId() string {
+	if x != nil && x.SizeId != nil {
+		return *x.SizeId
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetRackId() string {
+	if x != nil && x.RackId != nil {
+		return *x.RackId
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetAllocationName() string {
+	if x != nil && x.AllocationName != nil {
+		return *x.AllocationName
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetAllocationProject() string {
+	if x != nil && x.AllocationProject != nil {
+		return *x.AllocationProject
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetAllocationImageId() string {
+	if x != nil && x.AllocationImageId != nil {
+		return *x.AllocationImageId
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetAllocationHostname() string {
+	if x != nil && x.AllocationHostname != nil {
+		return *x.AllocationHostname
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetAllocationRole() string {
+	if x != nil && x.AllocationRole != nil {
+		return *x.AllocationRole
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetAllocationSucceeded() bool {
+	if x != nil && x.AllocationSucceeded != nil {
+		return *x.AllocationSucceeded
+	}
+	return false
+}
+
+func (x *MachineQuery) GetNetworkIds() []string {
+	if x != nil {
+		return x.NetworkIds
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNetworkPrefixes() []string {
+	if x != nil {
+		return x.NetworkPrefixes
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNetworkIps() []string {
+	if x != nil {
+		return x.NetworkIps
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNetworkDestinationPrefixes() []string {
+	if x != nil {
+		return x.NetworkDestinationPrefixes
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNetworkVrfs() []int64 {
+	if x != nil {
+		return x.NetworkVrfs
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNetworkAsns() []int64 {
+	if x != nil {
+		return x.NetworkAsns
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetHardwareMemory() int64 {
+	if x != nil && x.HardwareMemory != nil {
+		return *x.HardwareMemory
+	}
+	return 0
+}
+
+func (x *MachineQuery) GetHardwareCpuCores() int64 {
+	if x != nil && x.HardwareCpuCores != nil {
+		return *x.HardwareCpuCores
+	}
+	return 0
+}
+
+func (x *MachineQuery) GetNicsMacAddresses() []string {
+	if x != nil {
+		return x.NicsMacAddresses
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNicsNames() []string {
+	if x != nil {
+		return x.NicsNames
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNicsVrfs() []string {
+	if x != nil {
+		return x.NicsVrfs
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNicsNeighborMacAddresses() []string {
+	if x != nil {
+		return x.NicsNeighborMacAddresses
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNicsNeighborNames() []string {
+	if x != nil {
+		return x.NicsNeighborNames
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetNicsNeighborVrfs() []string {
+	if x != nil {
+		return x.NicsNeighborVrfs
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetDiskNames() []string {
+	if x != nil {
+		return x.DiskNames
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetDiskSizes() []int64 {
+	if x != nil {
+		return x.DiskSizes
+	}
+	return nil
+}
+
+func (x *MachineQuery) GetStateValue() string {
+	if x != nil && x.StateValue != nil {
+		return *x.StateValue
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetIpmiAddress() string {
+	if x != nil && x.IpmiAddress != nil {
+		return *x.IpmiAddress
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetIpmiMacAddress() string {
+	if x != nil && x.IpmiMacAddress != nil {
+		return *x.IpmiMacAddress
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetIpmiUser() string {
+	if x != nil && x.IpmiUser != nil {
+		return *x.IpmiUser
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetIpmiInterface() string {
+	if x != nil && x.IpmiInterface != nil {
+		return *x.IpmiInterface
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruChassisPartNumber() string {
+	if x != nil && x.FruChassisPartNumber != nil {
+		return *x.FruChassisPartNumber
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruChassisPartSerial() string {
+	if x != nil && x.FruChassisPartSerial != nil {
+		return *x.FruChassisPartSerial
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruBoardMfg() string {
+	if x != nil && x.FruBoardMfg != nil {
+		return *x.FruBoardMfg
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruBoardMfgSerial() string {
+	if x != nil && x.FruBoardMfgSerial != nil {
+		return *x.FruBoardMfgSerial
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruBoardPartNumber() string {
+	if x != nil && x.FruBoardPartNumber != nil {
+		return *x.FruBoardPartNumber
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruProductManufacturer() string {
+	if x != nil && x.FruProductManufacturer != nil {
+		return *x.FruProductManufacturer
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruProductPartNumber() string {
+	if x != nil && x.FruProductPartNumber != nil {
+		return *x.FruProductPartNumber
+	}
+	return ""
+}
+
+func (x *MachineQuery) GetFruProductSerial() string {
+	if x != nil && x.FruProductSerial != nil {
+		return *x.FruProductSerial
+	}
+	return ""
+}
+
+var File_api_v1_machine_proto protoreflect.FileDescriptor
+
+var file_api_v1_machine_proto_rawDesc = []byte{
+	0x0a, 0x14, 0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x06, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x1a, 0x11,
+	0x61, 0x70, 0x69, 0x2f, 0x76, 0x31, 0x2f, 0x62, 0x6f, 0x6f, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62,
+	0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x22, 0x73, 0x0a, 0x1a, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x53, 0x65, 0x72,
+	0x76, 0x69, 0x63, 0x65, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x2a, 0x0a, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x14, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x05, 0x71, 0x75, 0x65, 0x72, 0x79, 0x12, 0x29, 0x0a, 0x10,
+	0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0f, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0xa1, 0x01, 0x0a, 0x1b, 0x4d, 0x61, 0x63, 0x68,
+	0x69, 0x6e, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2c, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x18, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x52,
+	0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52,
+	0x0f, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x29, 0x0a, 0x07, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x0f, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x63, 0x68, 0x69,
+	0x6e, 0x65, 0x52, 0x07, 0x6d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x22, 0xc8, 0x04, 0x0a, 0x07,
+	0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a,
+	0x0c, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64,
+	0x12, 0x17, 0x0a, 0x07, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x06, 0x73, 0x69, 0x7a, 0x65, 0x49, 0x64, 0x12, 0x17, 0x0a, 0x07, 0x72, 0x61, 0x63,
+	0x6b, 0x5f, 0x69, 0x64, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x61, 0x63, 0x6b,
+	0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x2a, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73, 0x74, 0x61,
+	0x74, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x6c, 0x69, 0x76, 0x65, 0x6c, 0x69, 0x6e, 0x65, 0x73, 0x73,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x6c, 0x69, 0x76, 0x65, 0x6c, 0x69, 0x6e, 0x65,
+	0x73, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x77, 0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x18, 0x0a, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x07, 0x77, 0x61, 0x69, 0x74, 0x69, 0x6e, 0x67, 0x12, 0x23, 0x0a, 0x0d,
+	0x70, 0x72, 0x65, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0b, 0x20,
+	0x01, 0x28, 0x08, 0x52, 0x0c, 0x70, 0x72, 0x65, 0x41, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x65,
+	0x64, 0x12, 0x39, 0x0a, 0x0a, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18,
+	0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x41, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x52, 0x0a, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x33, 0x0a, 0x08,
+	0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17,
+	0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x48,
+	0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x52, 0x08, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72,
+	0x65, 0x12, 0x27, 0x0a, 0x04, 0x62, 0x69, 0x6f, 0x73, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x13, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65,
+	0x42, 0x49, 0x4f, 0x53, 0x52, 0x04, 0x62, 0x69, 0x6f, 0x73, 0x12, 0x34, 0x0a, 0x07, 0x63, 0x72,
+	0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69,
+	0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64,
+	0x12, 0x34, 0x0a, 0x07, 0x63, 0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x18, 0x10, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x63,
+	0x68, 0x61, 0x6e, 0x67, 0x65, 0x64, 0x22, 0x46, 0x0a, 0x0c, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e,
+	0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x20, 0x0a, 0x0b,
+	0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x82,
+	0x02, 0x0a, 0x11, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x41, 0x6c, 0x6c, 0x6f, 0x63, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64,
+	0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x18, 0x0a, 0x07, 0x70, 0x72,
+	0x6f, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65,
+	0x12, 0x19, 0x0a, 0x08, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x72,
+	0x6f, 0x6c, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x72, 0x6f, 0x6c, 0x65, 0x12,
+	0x1c, 0x0a, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x08, 0x52, 0x09, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x12, 0x34, 0x0a,
+	0x07, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x07, 0x63, 0x72, 0x65, 0x61,
+	0x74, 0x65, 0x64, 0x22, 0x9a, 0x12, 0x0a, 0x0c, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x51,
+	0x75, 0x65, 0x72, 0x79, 0x12, 0x13, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x00, 0x52, 0x02, 0x69, 0x64, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x88,
+	0x01, 0x01, 0x12, 0x26, 0x0a, 0x0c, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x5f,
+	0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x48, 0x02, 0x52, 0x0b, 0x70, 0x61, 0x72, 0x74,
+	0x69, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x88, 0x01, 0x01, 0x12, 0x1c, 0x0a, 0x07, 0x73, 0x69,
+	0x7a, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x48, 0x03, 0x52, 0x06, 0x73,
+	0x69, 0x7a, 0x65, 0x49, 0x64, 0x88, 0x01, 0x01, 0x12, 0x1c, 0x0a, 0x07, 0x72, 0x61, 0x63, 0x6b,
+	0x5f, 0x69, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x04, 0x52, 0x06, 0x72, 0x61, 0x63,
+	0x6b, 0x49, 0x64, 0x88, 0x01, 0x01, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x61, 0x67, 0x73, 0x18, 0x06,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x04, 0x74, 0x61, 0x67, 0x73, 0x12, 0x2c, 0x0a, 0x0f, 0x61, 0x6c,
+	0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x07, 0x20,
+	0x01, 0x28, 0x09, 0x48, 0x05, 0x52, 0x0e, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x4e, 0x61, 0x6d, 0x65, 0x88, 0x01, 0x01, 0x12, 0x32, 0x0a, 0x12, 0x61, 0x6c, 0x6c, 0x6f,
+	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x70, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x18, 0x08,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x06, 0x52, 0x11, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x50, 0x72, 0x6f, 0x6a, 0x65, 0x63, 0x74, 0x88, 0x01, 0x01, 0x12, 0x33, 0x0a, 0x13,
+	0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x48, 0x07, 0x52, 0x11, 0x61, 0x6c, 0x6c,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x49, 0x64, 0x88, 0x01,
+	0x01, 0x12, 0x34, 0x0a, 0x13, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f,
+	0x68, 0x6f, 0x73, 0x74, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x48, 0x08,
+	0x52, 0x12, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x48, 0x6f, 0x73, 0x74,
+	0x6e, 0x61, 0x6d, 0x65, 0x88, 0x01, 0x01, 0x12, 0x2c, 0x0a, 0x0f, 0x61, 0x6c, 0x6c, 0x6f, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x6f, 0x6c, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x09, 0x52, 0x0e, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x6f,
+	0x6c, 0x65, 0x88, 0x01, 0x01, 0x12, 0x36, 0x0a, 0x14, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x18, 0x0c, 0x20,
+	0x01, 0x28, 0x08, 0x48, 0x0a, 0x52, 0x13, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x53, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65, 0x64, 0x88, 0x01, 0x01, 0x12, 0x1f, 0x0a,
+	0x0b, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x69, 0x64, 0x73, 0x18, 0x0d, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x0a, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x49, 0x64, 0x73, 0x12, 0x29,
+	0x0a, 0x10, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78,
+	0x65, 0x73, 0x18, 0x0e, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72,
+	0x6b, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x6e, 0x65, 0x74,
+	0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x69, 0x70, 0x73, 0x18, 0x0f, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a,
+	0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x49, 0x70, 0x73, 0x12, 0x40, 0x0a, 0x1c, 0x6e, 0x65,
+	0x74, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x64, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x5f, 0x70, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x18, 0x10, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x1a, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x44, 0x65, 0x73, 0x74, 0x69, 0x6e, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x50, 0x72, 0x65, 0x66, 0x69, 0x78, 0x65, 0x73, 0x12, 0x21, 0x0a, 0x0c,
+	0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x76, 0x72, 0x66, 0x73, 0x18, 0x11, 0x20, 0x03,
+	0x28, 0x03, 0x52, 0x0b, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x56, 0x72, 0x66, 0x73, 0x12,
+	0x21, 0x0a, 0x0c, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x5f, 0x61, 0x73, 0x6e, 0x73, 0x18,
+	0x12, 0x20, 0x03, 0x28, 0x03, 0x52, 0x0b, 0x6e, 0x65, 0x74, 0x77, 0x6f, 0x72, 0x6b, 0x41, 0x73,
+	0x6e, 0x73, 0x12, 0x2c, 0x0a, 0x0f, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x6d,
+	0x65, 0x6d, 0x6f, 0x72, 0x79, 0x18, 0x13, 0x20, 0x01, 0x28, 0x03, 0x48, 0x0b, 0x52, 0x0e, 0x68,
+	0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x88, 0x01, 0x01,
+	0x12, 0x31, 0x0a, 0x12, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x63, 0x70, 0x75,
+	0x5f, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x18, 0x14, 0x20, 0x01, 0x28, 0x03, 0x48, 0x0c, 0x52, 0x10,
+	0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x43, 0x70, 0x75, 0x43, 0x6f, 0x72, 0x65, 0x73,
+	0x88, 0x01, 0x01, 0x12, 0x2c, 0x0a, 0x12, 0x6e, 0x69, 0x63, 0x73, 0x5f, 0x6d, 0x61, 0x63, 0x5f,
+	0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x15, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x10, 0x6e, 0x69, 0x63, 0x73, 0x4d, 0x61, 0x63, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65,
+	0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x6e, 0x69, 0x63, 0x73, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18,
+	0x16, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x6e, 0x69, 0x63, 0x73, 0x4e, 0x61, 0x6d, 0x65, 0x73,
+	0x12, 0x1b, 0x0a, 0x09, 0x6e, 0x69, 0x63, 0x73, 0x5f, 0x76, 0x72, 0x66, 0x73, 0x18, 0x17, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x08, 0x6e, 0x69, 0x63, 0x73, 0x56, 0x72, 0x66, 0x73, 0x12, 0x3d, 0x0a,
+	0x1b, 0x6e, 0x69, 0x63, 0x73, 0x5f, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x5f, 0x6d,
+	0x61, 0x63, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x18, 0x18, 0x20, 0x03,
+	0x28, 0x09, 0x52, 0x18, 0x6e, 0x69, 0x63, 0x73, 0x4e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72,
+	0x4d, 0x61, 0x63, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x65, 0x73, 0x12, 0x2e, 0x0a, 0x13,
+	0x6e, 0x69, 0x63, 0x73, 0x5f, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x5f, 0x6e, 0x61,
+	0x6d, 0x65, 0x73, 0x18, 0x19, 0x20, 0x03, 0x28, 0x09, 0x52, 0x11, 0x6e, 0x69, 0x63, 0x73, 0x4e,
+	0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x12,
+	0x6e, 0x69, 0x63, 0x73, 0x5f, 0x6e, 0x65, 0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x5f, 0x76, 0x72,
+	0x66, 0x73, 0x18, 0x1a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x10, 0x6e, 0x69, 0x63, 0x73, 0x4e, 0x65,
+	0x69, 0x67, 0x68, 0x62, 0x6f, 0x72, 0x56, 0x72, 0x66, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x69,
+	0x73, 0x6b, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x73, 0x18, 0x1b, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09,
+	0x64, 0x69, 0x73, 0x6b, 0x4e, 0x61, 0x6d, 0x65, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x69, 0x73,
+	0x6b, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x73, 0x18, 0x1c, 0x20, 0x03, 0x28, 0x03, 0x52, 0x09, 0x64,
+	0x69, 0x73, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0b, 0x73, 0x74, 0x61, 0x74,
+	0x65, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x09, 0x48, 0x0d, 0x52,
+	0x0a, 0x73, 0x74, 0x61, 0x74, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x88, 0x01, 0x01, 0x12, 0x26,
+	0x0a, 0x0c, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x1e,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x0e, 0x52, 0x0b, 0x69, 0x70, 0x6d, 0x69, 0x41, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x88, 0x01, 0x01, 0x12, 0x2d, 0x0a, 0x10, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x6d,
+	0x61, 0x63, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x1f, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x0f, 0x52, 0x0e, 0x69, 0x70, 0x6d, 0x69, 0x4d, 0x61, 0x63, 0x41, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x88, 0x01, 0x01, 0x12, 0x20, 0x0a, 0x09, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x75, 0x73,
+	0x65, 0x72, 0x18, 0x20, 0x20, 0x01, 0x28, 0x09, 0x48, 0x10, 0x52, 0x08, 0x69, 0x70, 0x6d, 0x69,
+	0x55, 0x73, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12, 0x2a, 0x0a, 0x0e, 0x69, 0x70, 0x6d, 0x69, 0x5f,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x18, 0x21, 0x20, 0x01, 0x28, 0x09, 0x48,
+	0x11, 0x52, 0x0d, 0x69, 0x70, 0x6d, 0x69, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65,
+	0x88, 0x01, 0x01, 0x12, 0x3a, 0x0a, 0x17, 0x66, 0x72, 0x75, 0x5f, 0x63, 0x68, 0x61, 0x73, 0x73,
+	0x69, 0x73, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x22,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x12, 0x52, 0x14, 0x66, 0x72, 0x75, 0x43, 0x68, 0x61, 0x73, 0x73,
+	0x69, 0x73, 0x50, 0x61, 0x72, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12,
+	0x3a, 0x0a, 0x17, 0x66, 0x72, 0x75, 0x5f, 0x63, 0x68, 0x61, 0x73, 0x73, 0x69, 0x73, 0x5f, 0x70,
+	0x61, 0x72, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x18, 0x23, 0x20, 0x01, 0x28, 0x09,
+	0x48, 0x13, 0x52, 0x14, 0x66, 0x72, 0x75, 0x43, 0x68, 0x61, 0x73, 0x73, 0x69, 0x73, 0x50, 0x61,
+	0x72, 0x74, 0x53, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x27, 0x0a, 0x0d, 0x66,
+	0x72, 0x75, 0x5f, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x6d, 0x66, 0x67, 0x18, 0x24, 0x20, 0x01,
+	0x28, 0x09, 0x48, 0x14, 0x52, 0x0b, 0x66, 0x72, 0x75, 0x42, 0x6f, 0x61, 0x72, 0x64, 0x4d, 0x66,
+	0x67, 0x88, 0x01, 0x01, 0x12, 0x34, 0x0a, 0x14, 0x66, 0x72, 0x75, 0x5f, 0x62, 0x6f, 0x61, 0x72,
+	0x64, 0x5f, 0x6d, 0x66, 0x67, 0x5f, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x18, 0x25, 0x20, 0x01,
+	0x28, 0x09, 0x48, 0x15, 0x52, 0x11, 0x66, 0x72, 0x75, 0x42, 0x6f, 0x61, 0x72, 0x64, 0x4d, 0x66,
+	0x67, 0x53, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x88, 0x01, 0x01, 0x12, 0x36, 0x0a, 0x15, 0x66, 0x72,
+	0x75, 0x5f, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d,
+	0x62, 0x65, 0x72, 0x18, 0x26, 0x20, 0x01, 0x28, 0x09, 0x48, 0x16, 0x52, 0x12, 0x66, 0x72, 0x75,
+	0x42, 0x6f, 0x61, 0x72, 0x64, 0x50, 0x61, 0x72, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x88,
+	0x01, 0x01, 0x12, 0x3d, 0x0a, 0x18, 0x66, 0x72, 0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63,
+	0x74, 0x5f, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x18, 0x27,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x17, 0x52, 0x16, 0x66, 0x72, 0x75, 0x50, 0x72, 0x6f, 0x64, 0x75,
+	0x63, 0x74, 0x4d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x88, 0x01,
+	0x01, 0x12, 0x3a, 0x0a, 0x17, 0x66, 0x72, 0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
+	0x5f, 0x70, 0x61, 0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x28, 0x20, 0x01,
+	0x28, 0x09, 0x48, 0x18, 0x52, 0x14, 0x66, 0x72, 0x75, 0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
+	0x50, 0x61, 0x72, 0x74, 0x4e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12, 0x31, 0x0a,
+	0x12, 0x66, 0x72, 0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72,
+	0x69, 0x61, 0x6c, 0x18, 0x29, 0x20, 0x01, 0x28, 0x09, 0x48, 0x19, 0x52, 0x10, 0x66, 0x72, 0x75,
+	0x50, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x53, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x88, 0x01, 0x01,
+	0x42, 0x05, 0x0a, 0x03, 0x5f, 0x69, 0x64, 0x42, 0x07, 0x0a, 0x05, 0x5f, 0x6e, 0x61, 0x6d, 0x65,
+	0x42, 0x0f, 0x0a, 0x0d, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x69,
+	0x64, 0x42, 0x0a, 0x0a, 0x08, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f, 0x69, 0x64, 0x42, 0x0a, 0x0a,
+	0x08, 0x5f, 0x72, 0x61, 0x63, 0x6b, 0x5f, 0x69, 0x64, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x61, 0x6c,
+	0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x42, 0x15, 0x0a,
+	0x13, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x70, 0x72, 0x6f,
+	0x6a, 0x65, 0x63, 0x74, 0x42, 0x16, 0x0a, 0x14, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x69, 0x64, 0x42, 0x16, 0x0a, 0x14,
+	0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x68, 0x6f, 0x73, 0x74,
+	0x6e, 0x61, 0x6d, 0x65, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x61, 0x6c, 0x6c, 0x6f, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x5f, 0x72, 0x6f, 0x6c, 0x65, 0x42, 0x17, 0x0a, 0x15, 0x5f, 0x61, 0x6c, 0x6c,
+	0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x73, 0x75, 0x63, 0x63, 0x65, 0x65, 0x64, 0x65,
+	0x64, 0x42, 0x12, 0x0a, 0x10, 0x5f, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61, 0x72, 0x65, 0x5f, 0x6d,
+	0x65, 0x6d, 0x6f, 0x72, 0x79, 0x42, 0x15, 0x0a, 0x13, 0x5f, 0x68, 0x61, 0x72, 0x64, 0x77, 0x61,
+	0x72, 0x65, 0x5f, 0x63, 0x70, 0x75, 0x5f, 0x63, 0x6f, 0x72, 0x65, 0x73, 0x42, 0x0e, 0x0a, 0x0c,
+	0x5f, 0x73, 0x74, 0x61, 0x74, 0x65, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x42, 0x0f, 0x0a, 0x0d,
+	0x5f, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x42, 0x13, 0x0a,
+	0x11, 0x5f, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x6d, 0x61, 0x63, 0x5f, 0x61, 0x64, 0x64, 0x72, 0x65,
+	0x73, 0x73, 0x42, 0x0c, 0x0a, 0x0a, 0x5f, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x75, 0x73, 0x65, 0x72,
+	0x42, 0x11, 0x0a, 0x0f, 0x5f, 0x69, 0x70, 0x6d, 0x69, 0x5f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66,
+	0x61, 0x63, 0x65, 0x42, 0x1a, 0x0a, 0x18, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x63, 0x68, 0x61, 0x73,
+	0x73, 0x69, 0x73, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x42,
+	0x1a, 0x0a, 0x18, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x63, 0x68, 0x61, 0x73, 0x73, 0x69, 0x73, 0x5f,
+	0x70, 0x61, 0x72, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x42, 0x10, 0x0a, 0x0e, 0x5f,
+	0x66, 0x72, 0x75, 0x5f, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x6d, 0x66, 0x67, 0x42, 0x17, 0x0a,
+	0x15, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x62, 0x6f, 0x61, 0x72, 0x64, 0x5f, 0x6d, 0x66, 0x67, 0x5f,
+	0x73, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x42, 0x18, 0x0a, 0x16, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x62,
+	0x6f, 0x61, 0x72, 0x64, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72,
+	0x42, 0x1b, 0x0a, 0x19, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74,
+	0x5f, 0x6d, 0x61, 0x6e, 0x75, 0x66, 0x61, 0x63, 0x74, 0x75, 0x72, 0x65, 0x72, 0x42, 0x1a, 0x0a,
+	0x18, 0x5f, 0x66, 0x72, 0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x70, 0x61,
+	0x72, 0x74, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x42, 0x15, 0x0a, 0x13, 0x5f, 0x66, 0x72,
+	0x75, 0x5f, 0x70, 0x72, 0x6f, 0x64, 0x75, 0x63, 0x74, 0x5f, 0x73, 0x65, 0x72, 0x69, 0x61, 0x6c,
+	0x2a, 0x93, 0x01, 0x0a, 0x10, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x45, 0x76, 0x65, 0x6e,
+	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x22, 0x0a, 0x1e, 0x4d, 0x41, 0x43, 0x48, 0x49, 0x4e, 0x45,
+	0x5f, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50,
+	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x1d, 0x0a, 0x19, 0x4d, 0x41, 0x43,
+	0x48, 0x49, 0x4e, 0x45, 0x5f, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f,
+	0x43, 0x52, 0x45, 0x41, 0x54, 0x45, 0x10, 0x01, 0x12, 0x1d, 0x0a, 0x19, 0x4d, 0x41, 0x43, 0x48,
+	0x49, 0x4e, 0x45, 0x5f, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x55,
+	0x50, 0x44, 0x41, 0x54, 0x45, 0x10, 0x02, 0x12, 0x1d, 0x0a, 0x19, 0x4d, 0x41, 0x43, 0x48, 0x49,
+	0x4e, 0x45, 0x5f, 0x45, 0x56, 0x45, 0x4e, 0x54, 0x5f, 0x54, 0x59, 0x50, 0x45, 0x5f, 0x44, 0x45,
+	0x4c, 0x45, 0x54, 0x45, 0x10, 0x03, 0x32, 0x64, 0x0a, 0x0e, 0x4d, 0x61, 0x63, 0x68, 0x69, 0x6e,
+	0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x52, 0x0a, 0x05, 0x57, 0x61, 0x74, 0x63,
+	0x68, 0x12, 0x22, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d, 0x61, 0x63, 0x68, 0x69,
+	0x6e, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x61, 0x70, 0x69, 0x2e, 0x76, 0x31, 0x2e, 0x4d,
+	0x61, 0x63, 0x68, 0x69, 0x6e, 0x65, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x57, 0x61, 0x74,
+	0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x30, 0x01, 0x42, 0x06, 0x5a, 0x04,
+	0x2e, 0x2f, 0x76, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_api_v1_machine_proto_rawDescOnce sync.Once
+	file_api_v1_machine_proto_rawDescData = file_api_v1_machine_proto_rawDesc
+)
+
+func file_api_v1_machine_proto_rawDescGZIP() []byte {
+	file_api_v1_machine_proto_rawDescOnce.Do(func() {
+		file_api_v1_machine_proto_rawDescData = protoimpl.X.CompressGZIP(file_api_v1_machine_proto_rawDescData)
+	})
+	return file_api_v1_machine_proto_rawDescData
+}
+
+var file_api_v1_machine_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_api_v1_machine_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_api_v1_machine_proto_goTypes = []interface{}{
+	(MachineEventType)(0),               // 0: api.v1.MachineEventType
+	(*MachineServiceWatchRequest)(nil),  // 1: api.v1.MachineServiceWatchRequest
+	(*MachineServiceWatchResponse)(nil), // 2: api.v1.MachineServiceWatchResponse
+	(*Machine)(nil),                     // 3: api.v1.Machine
+	(*MachineState)(nil),                // 4: api.v1.MachineState
+	(*MachineAllocation)(nil),           // 5: api.v1.MachineAllocation
+	(*MachineQuery)(nil),                // 6: api.v1.MachineQuery
+	(*MachineHardware)(nil),             // 7: api.v1.MachineHardware
+	(*MachineBIOS)(nil),                 // 8: api.v1.MachineBIOS
+	(*timestamppb.Timestamp)(nil),       // 9: google.protobuf.Timestamp
+}
+var file_api_v1_machine_proto_depIdxs = []int32{
+	6,  // 0: api.v1.MachineServiceWatchRequest.query:type_name -> api.v1.MachineQuery
+	0,  // 1: api.v1.MachineServiceWatchResponse.type:type_name -> api.v1.MachineEventType
+	3,  // 2: api.v1.MachineServiceWatchResponse.machine:type_name -> api.v1.Machine
+	4,  // 3: api.v1.Machine.state:type_name -> api.v1.MachineState
+	5,  // 4: api.v1.Machine.allocation:type_name -> api.v1.MachineAllocation
+	7,  // 5: api.v1.Machine.hardware:type_name -> api.v1.MachineHardware
+	8,  // 6: api.v1.Machine.bios:type_name -> api.v1.MachineBIOS
+	9,  // 7: api.v1.Machine.created:type_name -> google.protobuf.Timestamp
+	9,  // 8: api.v1.Machine.changed:type_name -> google.protobuf.Timestamp
+	9,  // 9: api.v1.MachineAllocation.created:type_name -> google.protobuf.Timestamp
+	1,  // 10: api.v1.MachineService.Watch:input_type -> api.v1.MachineServiceWatchRequest
+	2,  // 11: api.v1.MachineService.Watch:output_type -> api.v1.MachineServiceWatchResponse
+	11, // [11:12] is the sub-list for method output_type
+	10, // [10:11] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_api_v1_machine_proto_init() }
+func file_api_v1_machine_proto_init() {
+	if File_api_v1_machine_proto != nil {
+		return
+	}
+	file_api_v1_boot_proto_init()
+	if !protoimpl.UnsafeEnabled {
+		file_api_v1_machine_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MachineServiceWatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_v1_machine_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MachineServiceWatchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_v1_machine_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Machine); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_v1_machine_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MachineState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_v1_machine_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MachineAllocation); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_api_v1_machine_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MachineQuery); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_api_v1_machine_proto_msgTypes[5].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_api_v1_machine_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_api_v1_machine_proto_goTypes,
+		DependencyIndexes: file_api_v1_machine_proto_depIdxs,
+		EnumInfos:         file_api_v1_machine_proto_enumTypes,
+		MessageInfos:      file_api_v1_machine_proto_msgTypes,
+	}.Build()
+	File_api_v1_machine_proto = out.File
+	file_api_v1_machine_proto_rawDesc = nil
+	file_api_v1_machine_proto_goTypes = nil
+	file_api_v1_machine_proto_depIdxs = nil
+}
@@ -0,0 +1,136 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: api/v1/machine.proto
+
+package v1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	MachineService_Watch_FullMethodName = "/api.v1.MachineService/Watch"
+)
+
+// MachineServiceClient is the client API for MachineService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type MachineServiceClient interface {
+	// Watch is a hanging call that streams the changes of all machines matching the given query
+	Watch(ctx context.Context, in *MachineServiceWatchRequest, opts ...grpc.CallOption) (MachineService_WatchClient, error)
+}
+
+type machineServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMachineServiceClient(cc grpc.ClientConnInterface) MachineServiceClient {
+	return &machineServiceClient{cc}
+}
+
+func (c *machineServiceClient) Watch(ctx context.Context, in *MachineServiceWatchRequest, opts ...grpc.CallOption) (MachineService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MachineService_ServiceDesc.Streams[0], MachineService_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &machineServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MachineService_WatchClient interface {
+	Recv() (*MachineServiceWatchResponse, error)
+	grpc.ClientStream
+}
+
+type machineServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *machineServiceWatchClient) Recv() (*MachineServiceWatchResponse, error) {
+	m := new(MachineServiceWatchResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MachineServiceServer is the server API for MachineService service.
+// All implementations should embed UnimplementedMachineServiceServer
+// for forward compatibility
+type MachineServiceServer interface {
+	// Watch is a hanging call that streams the changes of all machines matching the given query
+	Watch(*MachineServiceWatchRequest, MachineService_WatchServer) error
+}
+
+// UnimplementedMachineServiceServer should be embedded to have forward compatible implementations.
+type UnimplementedMachineServiceServer struct {
+}
+
+func (UnimplementedMachineServiceServer) Watch(*MachineServiceWatchRequest, MachineService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+
+// UnsafeMachineServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MachineServiceServer will
+// result in compilation errors.
+type UnsafeMachineServiceServer interface {
+	mustEmbedUnimplementedMachineServiceServer()
+}
+
+func RegisterMachineServiceServer(s grpc.ServiceRegistrar, srv MachineServiceServer) {
+	s.RegisterService(&MachineService_ServiceDesc, srv)
+}
+
+func _MachineService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(MachineServiceWatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MachineServiceServer).Watch(m, &machineServiceWatchServer{stream})
+}
+
+type MachineService_WatchServer interface {
+	Send(*MachineServiceWatchResponse) error
+	grpc.ServerStream
+}
+
+type machineServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *machineServiceWatchServer) Send(m *MachineServiceWatchResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// MachineService_ServiceDesc is the grpc.ServiceDesc for MachineService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MachineService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "api.v1.MachineService",
+	HandlerType: (*MachineServiceServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _MachineService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api/v1/machine.proto",
+}